@@ -7,7 +7,7 @@ set -euo pipefail
 
 echo "Running unit tests..."
 
-go test -v -coverprofile=./test-out/coverage-unit.out -coverpkg=./... ./test/unit/...
+go test -race -v -coverprofile=./test-out/coverage-unit.out -coverpkg=./... ./test/unit/...
 
 echo "Building coverage report..."
 
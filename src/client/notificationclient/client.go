@@ -1,6 +1,8 @@
 package notificationclient
 
 import (
+	"bookem-reservation-service/client/transport"
+	"bookem-reservation-service/config"
 	"bookem-reservation-service/util"
 	"bytes"
 	"context"
@@ -18,56 +20,60 @@ type NotificationClient interface {
 }
 
 type notificationClient struct {
-	baseURL string
+	baseURL    string
+	httpClient *transport.Client
 }
 
-func NewNotificationClient() NotificationClient {
+func NewNotificationClient(cfg config.ServiceConfig, httpClient *transport.Client) NotificationClient {
 	return &notificationClient{
-		baseURL: "http://notification-service:8080/api",
+		baseURL:    cfg.BaseURL,
+		httpClient: httpClient,
 	}
 }
 
 func (c *notificationClient) CreateNotification(ctx context.Context, jwt string, dto CreateNotificationDTO) (*NotificationDTO, error) {
-	util.TEL.Info("creating notification", "receiver_id", dto.ReceiverID, "type", dto.Type)
+	util.TEL.Info(ctx, "creating notification", "receiver_id", dto.ReceiverID, "type", dto.Type)
 
 	body, err := json.Marshal(dto)
 	if err != nil {
-		util.TEL.Error("failed to marshal notification DTO", err)
+		util.TEL.Error(ctx, "failed to marshal notification DTO", err)
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/notification", c.baseURL), bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/notification", c.baseURL), bytes.NewBuffer(body))
 	if err != nil {
-		util.TEL.Error("could not create HTTP request", err)
+		util.TEL.Error(ctx, "could not create HTTP request", err)
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+jwt)
+	if jwt != "" {
+		req.Header.Set("Authorization", "Bearer "+jwt)
+	}
 	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		util.TEL.Error("failed sending request to notification-service", err)
+		util.TEL.Error(ctx, "failed sending request to notification-service", err)
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		util.TEL.Error("unexpected response from notification-service", nil, "status", resp.StatusCode, "body", string(bodyBytes))
-		return nil, fmt.Errorf("failed to create notification, status: %d", resp.StatusCode)
+		util.TEL.Error(ctx, "unexpected response from notification-service", nil, "status", resp.StatusCode, "body", string(bodyBytes))
+		return nil, transport.StatusError("notification-service", resp)
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		util.TEL.Error("failed reading response body", err)
+		util.TEL.Error(ctx, "failed reading response body", err)
 		return nil, err
 	}
 
 	var notification NotificationDTO
 	if err := json.Unmarshal(bodyBytes, &notification); err != nil {
-		util.TEL.Error("failed unmarshalling response", err)
+		util.TEL.Error(ctx, "failed unmarshalling response", err)
 		return nil, err
 	}
 
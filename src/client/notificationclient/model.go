@@ -24,8 +24,23 @@ type NotificationDTO struct {
 type NotificationType string
 
 const (
-	ReservationRequested NotificationType = "reservation_requested"
-	ReservationCancelled NotificationType = "reservation_cancelled"
-	ReservationAccepted  NotificationType = "reservation_accepted"
-	ReservationDeclined  NotificationType = "reservation_declined"
+	ReservationRequested       NotificationType = "reservation_requested"
+	ReservationRequestChanged  NotificationType = "reservation_request_changed"
+	ReservationCancelled       NotificationType = "reservation_cancelled"
+	ReservationAccepted        NotificationType = "reservation_accepted"
+	ReservationDeclined        NotificationType = "reservation_declined"
+	ReservationExpired         NotificationType = "reservation_expired"
+	ReservationCancelledByHost NotificationType = "reservation_cancelled_by_host"
+	ReservationNoShow          NotificationType = "reservation_no_show"
+	ModificationRequested      NotificationType = "reservation_modification_requested"
+	ModificationAccepted       NotificationType = "reservation_modification_accepted"
+	ModificationDeclined       NotificationType = "reservation_modification_declined"
+	WaitlistOffered            NotificationType = "waitlist_offered"
+	GroupRequested             NotificationType = "reservation_group_requested"
+	GroupAccepted              NotificationType = "reservation_group_accepted"
+	GroupDeclined              NotificationType = "reservation_group_declined"
+	// RequestsDecided tells a guest that the host (Subject) decided some of
+	// their requests in one batch. It's sent once per guest, however many of
+	// their requests, and rooms, the batch decided; Object isn't set.
+	RequestsDecided NotificationType = "reservation_requests_decided"
 )
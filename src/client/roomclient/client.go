@@ -1,6 +1,8 @@
 package roomclient
 
 import (
+	"bookem-reservation-service/client/transport"
+	"bookem-reservation-service/config"
 	"bookem-reservation-service/util"
 	"bytes"
 	"context"
@@ -23,201 +25,203 @@ type RoomClient interface {
 }
 
 type roomClient struct {
-	baseURL string
+	baseURL    string
+	httpClient *transport.Client
 }
 
-func NewRoomClient() RoomClient {
+func NewRoomClient(cfg config.ServiceConfig, httpClient *transport.Client) RoomClient {
 	return &roomClient{
-		baseURL: "http://room-service:8080/api", // TODO: This should not be hardcoded
+		baseURL:    cfg.BaseURL,
+		httpClient: httpClient,
 	}
 }
 
-func (c *roomClient) FindById(context context.Context, id uint) (*RoomDTO, error) {
-	util.TEL.Info("find room", "room_id", id)
+func (c *roomClient) FindById(ctx context.Context, id uint) (*RoomDTO, error) {
+	util.TEL.Info(ctx, "find room", "room_id", id)
 
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/%d", c.baseURL, id), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/%d", c.baseURL, id), nil)
 	if err != nil {
-		util.TEL.Error("could not create request", err)
+		util.TEL.Error(ctx, "could not create request", err)
 		return nil, err
 	}
-	otel.GetTextMapPropagator().Inject(context, propagation.HeaderCarrier(req.Header))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		util.TEL.Error("could not send request", err)
+		util.TEL.Error(ctx, "could not send request", err)
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		util.TEL.Error("room not found", nil, "room_id", id, "http", resp.StatusCode)
-		return nil, fmt.Errorf("room %d not found", id)
+		util.TEL.Error(ctx, "room not found", nil, "room_id", id, "http", resp.StatusCode)
+		return nil, transport.StatusError("room-service", resp)
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		util.TEL.Error("could not parse bytes from response", err)
+		util.TEL.Error(ctx, "could not parse bytes from response", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	var obj RoomDTO
 	if err := json.Unmarshal(bodyBytes, &obj); err != nil {
-		util.TEL.Error("could not unmarshall JSON", err)
+		util.TEL.Error(ctx, "could not unmarshall JSON", err)
 		return nil, err
 	}
 
 	return &obj, nil
 }
 
-func (c *roomClient) FindCurrentAvailabilityListOfRoom(context context.Context, roomId uint) (*RoomAvailabilityListDTO, error) {
-	util.TEL.Info("find current availability list of room", "room_id", roomId)
+func (c *roomClient) FindCurrentAvailabilityListOfRoom(ctx context.Context, roomId uint) (*RoomAvailabilityListDTO, error) {
+	util.TEL.Info(ctx, "find current availability list of room", "room_id", roomId)
 
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/available/room/%d", c.baseURL, roomId), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/available/room/%d", c.baseURL, roomId), nil)
 	if err != nil {
-		util.TEL.Error("could not create request", err)
+		util.TEL.Error(ctx, "could not create request", err)
 		return nil, err
 	}
-	otel.GetTextMapPropagator().Inject(context, propagation.HeaderCarrier(req.Header))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		util.TEL.Error("could not send request", err)
+		util.TEL.Error(ctx, "could not send request", err)
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		util.TEL.Error("current availability list of room not found", nil, "room_id", roomId, "http", resp.StatusCode)
-		return nil, fmt.Errorf("current availability list of room %d not found", roomId)
+		util.TEL.Error(ctx, "current availability list of room not found", nil, "room_id", roomId, "http", resp.StatusCode)
+		return nil, transport.StatusError("room-service", resp)
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		util.TEL.Error("could not parse bytes from response", err)
+		util.TEL.Error(ctx, "could not parse bytes from response", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	var obj RoomAvailabilityListDTO
 	if err := json.Unmarshal(bodyBytes, &obj); err != nil {
-		util.TEL.Error("could not unmarshall JSON", err)
+		util.TEL.Error(ctx, "could not unmarshall JSON", err)
 		return nil, err
 	}
 
 	return &obj, nil
 }
 
-func (c *roomClient) FindCurrentPricelistOfRoom(context context.Context, roomId uint) (*RoomPriceListDTO, error) {
-	util.TEL.Info("find current price list of room", "room_id", roomId)
+func (c *roomClient) FindCurrentPricelistOfRoom(ctx context.Context, roomId uint) (*RoomPriceListDTO, error) {
+	util.TEL.Info(ctx, "find current price list of room", "room_id", roomId)
 
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/price/room/%d", c.baseURL, roomId), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/price/room/%d", c.baseURL, roomId), nil)
 	if err != nil {
-		util.TEL.Error("could not create request", err)
+		util.TEL.Error(ctx, "could not create request", err)
 		return nil, err
 	}
-	otel.GetTextMapPropagator().Inject(context, propagation.HeaderCarrier(req.Header))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		util.TEL.Error("could not send request", err)
+		util.TEL.Error(ctx, "could not send request", err)
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		util.TEL.Error("current price list of room not found", nil, "room_id", roomId, "http", resp.StatusCode)
-		return nil, fmt.Errorf("current price list of room %d not found", roomId)
+		util.TEL.Error(ctx, "current price list of room not found", nil, "room_id", roomId, "http", resp.StatusCode)
+		return nil, transport.StatusError("room-service", resp)
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		util.TEL.Error("could not parse bytes from response", err)
+		util.TEL.Error(ctx, "could not parse bytes from response", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	var obj RoomPriceListDTO
 	if err := json.Unmarshal(bodyBytes, &obj); err != nil {
-		util.TEL.Error("could not unmarshall JSON", err)
+		util.TEL.Error(ctx, "could not unmarshall JSON", err)
 		return nil, err
 	}
 
 	return &obj, nil
 }
 
-func (c *roomClient) QueryForReservation(context context.Context, jwt string, dto RoomReservationQueryDTO) (*RoomReservationQueryResponseDTO, error) {
-	util.TEL.Info("query room for potential reservation", "room_id", dto.RoomID)
+func (c *roomClient) QueryForReservation(ctx context.Context, jwt string, dto RoomReservationQueryDTO) (*RoomReservationQueryResponseDTO, error) {
+	util.TEL.Info(ctx, "query room for potential reservation", "room_id", dto.RoomID)
 
 	jsonBytes, err := json.Marshal(dto)
 	if err != nil {
-		util.TEL.Error("could not unmarshall input JSON", err)
+		util.TEL.Error(ctx, "could not unmarshall input JSON", err)
 		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/reservation/query", c.baseURL), bytes.NewBuffer(jsonBytes))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/reservation/query", c.baseURL), bytes.NewBuffer(jsonBytes))
 	if err != nil {
-		util.TEL.Error("could not create request", err)
+		util.TEL.Error(ctx, "could not create request", err)
 		return nil, err
 	}
-	otel.GetTextMapPropagator().Inject(context, propagation.HeaderCarrier(req.Header))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 	req.Header.Add("Authorization", "Bearer "+jwt)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		util.TEL.Error("could not send request", err)
+		util.TEL.Error(ctx, "could not send request", err)
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		util.TEL.Error("could not query room for potential reservation", nil, "room_id", dto.RoomID, "http", resp.StatusCode)
-		return nil, fmt.Errorf("failed querying room %d", dto.RoomID)
+		util.TEL.Error(ctx, "could not query room for potential reservation", nil, "room_id", dto.RoomID, "http", resp.StatusCode)
+		return nil, transport.StatusError("room-service", resp)
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		util.TEL.Error("could not parse bytes from response", err)
+		util.TEL.Error(ctx, "could not parse bytes from response", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	var obj RoomReservationQueryResponseDTO
 	if err := json.Unmarshal(bodyBytes, &obj); err != nil {
-		util.TEL.Error("could not unmarshall JSON", err)
+		util.TEL.Error(ctx, "could not unmarshall JSON", err)
 		return nil, err
 	}
 
 	return &obj, nil
 }
 
-func (c *roomClient) FindByHostId(context context.Context, id uint) ([]RoomDTO, error) {
-	util.TEL.Info("find host rooms", "host_id", id)
+func (c *roomClient) FindByHostId(ctx context.Context, id uint) ([]RoomDTO, error) {
+	util.TEL.Info(ctx, "find host rooms", "host_id", id)
 
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/host/%d", c.baseURL, id), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/host/%d", c.baseURL, id), nil)
 	if err != nil {
-		util.TEL.Error("could not create request", err)
+		util.TEL.Error(ctx, "could not create request", err)
 		return nil, err
 	}
-	otel.GetTextMapPropagator().Inject(context, propagation.HeaderCarrier(req.Header))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		util.TEL.Error("could not send request", err)
+		util.TEL.Error(ctx, "could not send request", err)
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		util.TEL.Error("rooms not found", nil, "host_id", id, "http", resp.StatusCode)
-		return nil, fmt.Errorf("rooms of host %d not found", id)
+		util.TEL.Error(ctx, "rooms not found", nil, "host_id", id, "http", resp.StatusCode)
+		return nil, transport.StatusError("room-service", resp)
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		util.TEL.Error("could not parse bytes from response", err)
+		util.TEL.Error(ctx, "could not parse bytes from response", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	var obj []RoomDTO
 	if err := json.Unmarshal(bodyBytes, &obj); err != nil {
-		util.TEL.Error("could not unmarshall JSON", err)
+		util.TEL.Error(ctx, "could not unmarshall JSON", err)
 		return nil, err
 	}
 
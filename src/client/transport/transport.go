@@ -0,0 +1,280 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnavailable means the upstream couldn't be reached or kept failing:
+	// connection errors, timeouts, 5xx/429 responses or an open circuit.
+	ErrUnavailable = errors.New("upstream unavailable")
+
+	// ErrNotFound means the upstream answered 404.
+	ErrNotFound = errors.New("not found")
+)
+
+// Error is returned for every failed call. Use errors.Is with ErrUnavailable
+// or ErrNotFound to tell the two apart.
+type Error struct {
+	Upstream   string
+	StatusCode int   // 0 when no response was received
+	Kind       error // ErrUnavailable, ErrNotFound or nil
+	Cause      error
+}
+
+func (e *Error) Error() string {
+	msg := e.Upstream
+	if e.Kind != nil {
+		msg += ": " + e.Kind.Error()
+	}
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (HTTP %d)", e.StatusCode)
+	}
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() []error {
+	var errs []error
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Cause != nil {
+		errs = append(errs, e.Cause)
+	}
+	return errs
+}
+
+// StatusError builds the error for an unexpected response status. It doesn't
+// read or close the body.
+func StatusError(upstream string, resp *http.Response) error {
+	var kind error
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		kind = ErrNotFound
+	case isUnavailableStatus(resp.StatusCode):
+		kind = ErrUnavailable
+	}
+	return &Error{Upstream: upstream, StatusCode: resp.StatusCode, Kind: kind}
+}
+
+func isUnavailableStatus(code int) bool {
+	return code >= 500 || code == http.StatusTooManyRequests
+}
+
+// Policy controls retries and the circuit breaker of a Client.
+type Policy struct {
+	// MaxRetries is how many times an idempotent request (GET, HEAD) is
+	// retried after the first attempt. Other methods are never retried.
+	MaxRetries int
+
+	// Retries wait a random duration between 0 and
+	// min(MaxBackoff, BaseBackoff * 2^attempt) ("full jitter").
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	// After BreakerThreshold consecutive failed calls the circuit opens and
+	// calls fail fast with ErrUnavailable for BreakerCooldown. Then a single
+	// trial call is let through to decide whether to close it again.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxRetries:       2,
+		BaseBackoff:      100 * time.Millisecond,
+		MaxBackoff:       2 * time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// Client wraps an http.Client with retries and a circuit breaker for a
+// single upstream. Deadlines come from the request's context.
+type Client struct {
+	upstream string
+	http     *http.Client
+	policy   Policy
+	breaker  *breaker
+
+	randMu sync.Mutex
+	rand   *rand.Rand
+}
+
+func New(upstream string, httpClient *http.Client, policy Policy) *Client {
+	return &Client{
+		upstream: upstream,
+		http:     httpClient,
+		policy:   policy,
+		breaker:  &breaker{threshold: policy.BreakerThreshold, cooldown: policy.BreakerCooldown, now: time.Now},
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Do sends the request. A response is returned for every status below 500
+// (except 429), and the caller must close its body. Anything else is an
+// *Error wrapping ErrUnavailable.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if !c.breaker.allow() {
+		return nil, &Error{Upstream: c.upstream, Kind: ErrUnavailable, Cause: errors.New("circuit open")}
+	}
+
+	attempts := 1
+	if isIdempotent(req.Method) {
+		attempts += c.policy.MaxRetries
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := c.sleep(req.Context(), attempt); err != nil {
+				break
+			}
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			lastErr = &Error{Upstream: c.upstream, Kind: ErrUnavailable, Cause: err}
+			if req.Context().Err() != nil {
+				break
+			}
+			continue
+		}
+
+		if isUnavailableStatus(resp.StatusCode) {
+			lastErr = StatusError(c.upstream, resp)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			continue
+		}
+
+		c.breaker.success()
+		return resp, nil
+	}
+
+	// The caller giving up says nothing about the upstream's health.
+	if req.Context().Err() == nil {
+		c.breaker.failure()
+	} else {
+		c.breaker.release()
+	}
+	if lastErr == nil {
+		lastErr = &Error{Upstream: c.upstream, Kind: ErrUnavailable, Cause: req.Context().Err()}
+	}
+	return nil, lastErr
+}
+
+func (c *Client) sleep(ctx context.Context, attempt int) error {
+	backoff := c.policy.BaseBackoff << (attempt - 1)
+	if backoff > c.policy.MaxBackoff || backoff <= 0 {
+		backoff = c.policy.MaxBackoff
+	}
+
+	c.randMu.Lock()
+	wait := time.Duration(c.rand.Int63n(int64(backoff) + 1))
+	c.randMu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func isIdempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+type breakerState int
+
+const (
+	closed breakerState = iota
+	open
+	halfOpen
+)
+
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func (b *breaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case open:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = halfOpen
+		b.probing = true
+		return true
+	case halfOpen:
+		// Only one trial call at a time.
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = closed
+	b.failures = 0
+	b.probing = false
+}
+
+// release gives up a trial call without judging the upstream.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *breaker) failure() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == halfOpen || b.failures >= b.threshold {
+		b.state = open
+		b.openedAt = b.now()
+	}
+}
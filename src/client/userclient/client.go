@@ -1,6 +1,8 @@
 package userclient
 
 import (
+	"bookem-reservation-service/client/transport"
+	"bookem-reservation-service/config"
 	"bookem-reservation-service/util"
 	"context"
 	"encoding/json"
@@ -17,46 +19,48 @@ type UserClient interface {
 }
 
 type userClient struct {
-	baseURL string
+	baseURL    string
+	httpClient *transport.Client
 }
 
-func NewUserClient() UserClient {
+func NewUserClient(cfg config.ServiceConfig, httpClient *transport.Client) UserClient {
 	return &userClient{
-		baseURL: "http://user-service:8080/api", // TODO: This should not be hardcoded
+		baseURL:    cfg.BaseURL,
+		httpClient: httpClient,
 	}
 }
 
-func (c *userClient) FindById(context context.Context, id uint) (*UserDTO, error) {
-	util.TEL.Info("find user", "user_id", id)
+func (c *userClient) FindById(ctx context.Context, id uint) (*UserDTO, error) {
+	util.TEL.Info(ctx, "find user", "user_id", id)
 
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/%d", c.baseURL, id), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/%d", c.baseURL, id), nil)
 	if err != nil {
-		util.TEL.Error("could not create request", err)
+		util.TEL.Error(ctx, "could not create request", err)
 		return nil, err
 	}
-	otel.GetTextMapPropagator().Inject(context, propagation.HeaderCarrier(req.Header))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		util.TEL.Error("could not send request", err)
+		util.TEL.Error(ctx, "could not send request", err)
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		util.TEL.Error("user not found", nil, "user_id", id, "http", resp.StatusCode)
-		return nil, fmt.Errorf("user %d not found", id)
+		util.TEL.Error(ctx, "user not found", nil, "user_id", id, "http", resp.StatusCode)
+		return nil, transport.StatusError("user-service", resp)
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		util.TEL.Error("could not parse bytes from response", err)
+		util.TEL.Error(ctx, "could not parse bytes from response", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	var obj UserDTO
 	if err := json.Unmarshal(bodyBytes, &obj); err != nil {
-		util.TEL.Error("could not unmarshall JSON", err)
+		util.TEL.Error(ctx, "could not unmarshall JSON", err)
 		return nil, err
 	}
 
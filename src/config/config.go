@@ -0,0 +1,444 @@
+package config
+
+import (
+	"bookem-reservation-service/client/transport"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config holds the settings of the service's outgoing HTTP calls.
+//
+// Values are resolved in this order, later ones winning:
+//  1. defaults (the compose network names, see Default)
+//  2. the JSON file named by CONFIG_FILE, if set
+//  3. environment variables
+type Config struct {
+	Services    ServicesConfig    `json:"services"`
+	HTTP        HTTPClientConfig  `json:"http"`
+	Outbox      OutboxConfig      `json:"outbox"`
+	Expiry      ExpiryConfig      `json:"expiry"`
+	Stay        StayConfig        `json:"stay"`
+	Idempotency IdempotencyConfig `json:"idempotency"`
+}
+
+type ServicesConfig struct {
+	Room         ServiceConfig `json:"room"`
+	User         ServiceConfig `json:"user"`
+	Notification ServiceConfig `json:"notification"`
+}
+
+// ServiceConfig describes how to reach one downstream service.
+type ServiceConfig struct {
+	BaseURL string   `json:"baseUrl"`
+	Timeout Duration `json:"timeout"` // Per call, including reading the body
+
+	// Token is sent as the bearer token on calls that aren't made on behalf
+	// of a user, like the outbox delivering notifications. Optional.
+	Token string `json:"token"`
+}
+
+type HTTPClientConfig struct {
+	TLS                 TLSConfig        `json:"tls"`
+	MaxIdleConns        int              `json:"maxIdleConns"`
+	MaxIdleConnsPerHost int              `json:"maxIdleConnsPerHost"`
+	MaxConnsPerHost     int              `json:"maxConnsPerHost"` // 0 means no limit
+	IdleConnTimeout     Duration         `json:"idleConnTimeout"`
+	Resilience          ResilienceConfig `json:"resilience"`
+}
+
+// ResilienceConfig is the retry and circuit breaker policy applied to every
+// downstream service, see transport.Policy.
+type ResilienceConfig struct {
+	MaxRetries       int      `json:"maxRetries"`
+	BaseBackoff      Duration `json:"baseBackoff"`
+	MaxBackoff       Duration `json:"maxBackoff"`
+	BreakerThreshold int      `json:"breakerThreshold"` // 0 disables the breaker
+	BreakerCooldown  Duration `json:"breakerCooldown"`
+}
+
+func (c ResilienceConfig) Policy() transport.Policy {
+	return transport.Policy{
+		MaxRetries:       c.MaxRetries,
+		BaseBackoff:      c.BaseBackoff.Std(),
+		MaxBackoff:       c.MaxBackoff.Std(),
+		BreakerThreshold: c.BreakerThreshold,
+		BreakerCooldown:  c.BreakerCooldown.Std(),
+	}
+}
+
+// OutboxConfig controls delivery of the notification outbox.
+type OutboxConfig struct {
+	PollInterval Duration `json:"pollInterval"`
+	BatchSize    int      `json:"batchSize"`
+	// After MaxAttempts failed deliveries a notification is dead-lettered.
+	MaxAttempts int `json:"maxAttempts"`
+	// Failed deliveries are retried after BaseBackoff * 2^(attempts-1),
+	// capped at MaxBackoff.
+	BaseBackoff Duration `json:"baseBackoff"`
+	MaxBackoff  Duration `json:"maxBackoff"`
+	// Lease is how long a claimed notification is hidden from other
+	// dispatchers. Must be longer than a delivery attempt can take.
+	Lease Duration `json:"lease"`
+}
+
+// ExpiryConfig controls the expiry of reservation requests the host never
+// answered. A request expires ResponseDeadline after it was made, or when its
+// stay starts, whichever comes first. Waitlist offers nobody claimed are
+// expired on the same schedule.
+type ExpiryConfig struct {
+	ResponseDeadline Duration `json:"responseDeadline"` // 0 only expires at the start date
+	Interval         Duration `json:"interval"`
+	BatchSize        int      `json:"batchSize"`
+}
+
+// StayConfig controls what happens to stays the host didn't record. Guests
+// are checked out CheckOutGrace after the last day of the stay ended, checked
+// in or not, as the hosts of unrecorded stays rarely mean no-shows. With
+// AutoNoShow a reservation nobody checked in by NoShowGrace after its first
+// day began is a no-show instead.
+type StayConfig struct {
+	AutoNoShow    bool     `json:"autoNoShow"`
+	NoShowGrace   Duration `json:"noShowGrace"`
+	CheckOutGrace Duration `json:"checkOutGrace"`
+	Interval      Duration `json:"interval"`
+	BatchSize     int      `json:"batchSize"`
+}
+
+// IdempotencyConfig controls how long responses to requests made with an
+// Idempotency-Key header are kept for replay.
+type IdempotencyConfig struct {
+	TTL Duration `json:"ttl"`
+	// ProcessingTimeout is how long a key stays locked by a request that
+	// never finished (e.g. the instance died) before a retry can take it.
+	ProcessingTimeout Duration `json:"processingTimeout"`
+	PurgeInterval     Duration `json:"purgeInterval"`
+}
+
+type TLSConfig struct {
+	CAFile             string `json:"caFile"`   // Extra CA to trust, PEM
+	CertFile           string `json:"certFile"` // Client certificate for mTLS, PEM
+	KeyFile            string `json:"keyFile"`  // Key of CertFile, PEM
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+}
+
+// Duration is a time.Duration written as a string ("5s", "1m30s") in JSON.
+type Duration time.Duration
+
+func (d Duration) Std() time.Duration { return time.Duration(d) }
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func Default() Config {
+	policy := transport.DefaultPolicy()
+	return Config{
+		Services: ServicesConfig{
+			Room:         ServiceConfig{BaseURL: "http://room-service:8080/api", Timeout: Duration(5 * time.Second)},
+			User:         ServiceConfig{BaseURL: "http://user-service:8080/api", Timeout: Duration(5 * time.Second)},
+			Notification: ServiceConfig{BaseURL: "http://notification-service:8080/api", Timeout: Duration(5 * time.Second)},
+		},
+		HTTP: HTTPClientConfig{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			MaxConnsPerHost:     0,
+			IdleConnTimeout:     Duration(90 * time.Second),
+			Resilience: ResilienceConfig{
+				MaxRetries:       policy.MaxRetries,
+				BaseBackoff:      Duration(policy.BaseBackoff),
+				MaxBackoff:       Duration(policy.MaxBackoff),
+				BreakerThreshold: policy.BreakerThreshold,
+				BreakerCooldown:  Duration(policy.BreakerCooldown),
+			},
+		},
+		Outbox: OutboxConfig{
+			PollInterval: Duration(time.Second),
+			BatchSize:    50,
+			MaxAttempts:  10,
+			BaseBackoff:  Duration(time.Second),
+			MaxBackoff:   Duration(10 * time.Minute),
+			Lease:        Duration(time.Minute),
+		},
+		Expiry: ExpiryConfig{
+			ResponseDeadline: Duration(72 * time.Hour),
+			Interval:         Duration(5 * time.Minute),
+			BatchSize:        100,
+		},
+		Stay: StayConfig{
+			NoShowGrace:   Duration(36 * time.Hour),
+			CheckOutGrace: Duration(12 * time.Hour),
+			Interval:      Duration(15 * time.Minute),
+			BatchSize:     100,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:               Duration(24 * time.Hour),
+			ProcessingTimeout: Duration(time.Minute),
+			PurgeInterval:     Duration(time.Hour),
+		},
+	}
+}
+
+// Load reads the configuration from CONFIG_FILE and the environment, and
+// validates it.
+func Load() (*Config, error) {
+	return LoadFrom(os.Getenv)
+}
+
+// LoadFrom is Load with a custom environment lookup.
+func LoadFrom(getenv func(string) string) (*Config, error) {
+	cfg := Default()
+
+	if path := getenv("CONFIG_FILE"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read config file %s: %w", path, err)
+		}
+		if err := json.Unmarshal(content, &cfg); err != nil {
+			return nil, fmt.Errorf("could not parse config file %s: %w", path, err)
+		}
+	}
+
+	e := envReader{getenv: getenv}
+	e.str("ROOM_SERVICE_URL", &cfg.Services.Room.BaseURL)
+	e.duration("ROOM_SERVICE_TIMEOUT", &cfg.Services.Room.Timeout)
+	e.str("USER_SERVICE_URL", &cfg.Services.User.BaseURL)
+	e.duration("USER_SERVICE_TIMEOUT", &cfg.Services.User.Timeout)
+	e.str("NOTIFICATION_SERVICE_URL", &cfg.Services.Notification.BaseURL)
+	e.duration("NOTIFICATION_SERVICE_TIMEOUT", &cfg.Services.Notification.Timeout)
+	e.str("NOTIFICATION_SERVICE_TOKEN", &cfg.Services.Notification.Token)
+
+	e.int("HTTP_MAX_IDLE_CONNS", &cfg.HTTP.MaxIdleConns)
+	e.int("HTTP_MAX_IDLE_CONNS_PER_HOST", &cfg.HTTP.MaxIdleConnsPerHost)
+	e.int("HTTP_MAX_CONNS_PER_HOST", &cfg.HTTP.MaxConnsPerHost)
+	e.duration("HTTP_IDLE_CONN_TIMEOUT", &cfg.HTTP.IdleConnTimeout)
+	e.str("HTTP_TLS_CA_FILE", &cfg.HTTP.TLS.CAFile)
+	e.str("HTTP_TLS_CERT_FILE", &cfg.HTTP.TLS.CertFile)
+	e.str("HTTP_TLS_KEY_FILE", &cfg.HTTP.TLS.KeyFile)
+	e.bool("HTTP_TLS_INSECURE_SKIP_VERIFY", &cfg.HTTP.TLS.InsecureSkipVerify)
+	e.int("HTTP_MAX_RETRIES", &cfg.HTTP.Resilience.MaxRetries)
+	e.duration("HTTP_RETRY_BASE_BACKOFF", &cfg.HTTP.Resilience.BaseBackoff)
+	e.duration("HTTP_RETRY_MAX_BACKOFF", &cfg.HTTP.Resilience.MaxBackoff)
+	e.int("HTTP_BREAKER_THRESHOLD", &cfg.HTTP.Resilience.BreakerThreshold)
+	e.duration("HTTP_BREAKER_COOLDOWN", &cfg.HTTP.Resilience.BreakerCooldown)
+
+	e.duration("OUTBOX_POLL_INTERVAL", &cfg.Outbox.PollInterval)
+	e.int("OUTBOX_BATCH_SIZE", &cfg.Outbox.BatchSize)
+	e.int("OUTBOX_MAX_ATTEMPTS", &cfg.Outbox.MaxAttempts)
+	e.duration("OUTBOX_RETRY_BASE_BACKOFF", &cfg.Outbox.BaseBackoff)
+	e.duration("OUTBOX_RETRY_MAX_BACKOFF", &cfg.Outbox.MaxBackoff)
+	e.duration("OUTBOX_LEASE", &cfg.Outbox.Lease)
+
+	e.duration("REQUEST_RESPONSE_DEADLINE", &cfg.Expiry.ResponseDeadline)
+	e.duration("REQUEST_EXPIRY_INTERVAL", &cfg.Expiry.Interval)
+	e.int("REQUEST_EXPIRY_BATCH_SIZE", &cfg.Expiry.BatchSize)
+
+	e.bool("STAY_AUTO_NO_SHOW", &cfg.Stay.AutoNoShow)
+	e.duration("STAY_NO_SHOW_GRACE", &cfg.Stay.NoShowGrace)
+	e.duration("STAY_CHECK_OUT_GRACE", &cfg.Stay.CheckOutGrace)
+	e.duration("STAY_CLOSE_INTERVAL", &cfg.Stay.Interval)
+	e.int("STAY_CLOSE_BATCH_SIZE", &cfg.Stay.BatchSize)
+
+	e.duration("IDEMPOTENCY_KEY_TTL", &cfg.Idempotency.TTL)
+	e.duration("IDEMPOTENCY_PROCESSING_TIMEOUT", &cfg.Idempotency.ProcessingTimeout)
+	e.duration("IDEMPOTENCY_PURGE_INTERVAL", &cfg.Idempotency.PurgeInterval)
+
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate returns every problem with the configuration at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	services := []struct {
+		name string
+		ServiceConfig
+	}{
+		{"room", c.Services.Room},
+		{"user", c.Services.User},
+		{"notification", c.Services.Notification},
+	}
+	for _, svc := range services {
+		u, err := url.Parse(svc.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%s service: base URL %q must be an absolute http(s) URL", svc.name, svc.BaseURL))
+		}
+		if svc.Timeout <= 0 {
+			errs = append(errs, fmt.Errorf("%s service: timeout must be positive", svc.name))
+		}
+	}
+
+	if c.HTTP.MaxIdleConns < 0 || c.HTTP.MaxIdleConnsPerHost < 0 || c.HTTP.MaxConnsPerHost < 0 {
+		errs = append(errs, errors.New("http: connection pool sizes can't be negative"))
+	}
+	if c.HTTP.IdleConnTimeout < 0 {
+		errs = append(errs, errors.New("http: idle connection timeout can't be negative"))
+	}
+	r := c.HTTP.Resilience
+	if r.MaxRetries < 0 || r.BreakerThreshold < 0 {
+		errs = append(errs, errors.New("http: retries and breaker threshold can't be negative"))
+	}
+	if r.MaxRetries > 0 && (r.BaseBackoff <= 0 || r.MaxBackoff < r.BaseBackoff) {
+		errs = append(errs, errors.New("http: retry backoff must be positive and max backoff at least the base backoff"))
+	}
+	if r.BreakerThreshold > 0 && r.BreakerCooldown <= 0 {
+		errs = append(errs, errors.New("http: breaker cooldown must be positive"))
+	}
+	if (c.HTTP.TLS.CertFile == "") != (c.HTTP.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("http: TLS cert file and key file must be set together"))
+	}
+
+	o := c.Outbox
+	if o.PollInterval <= 0 || o.Lease <= 0 {
+		errs = append(errs, errors.New("outbox: poll interval and lease must be positive"))
+	}
+	if o.BatchSize < 1 || o.MaxAttempts < 1 {
+		errs = append(errs, errors.New("outbox: batch size and max attempts must be at least 1"))
+	}
+	if o.BaseBackoff <= 0 || o.MaxBackoff < o.BaseBackoff {
+		errs = append(errs, errors.New("outbox: retry backoff must be positive and max backoff at least the base backoff"))
+	}
+	if o.Lease <= c.Services.Notification.Timeout {
+		errs = append(errs, errors.New("outbox: lease must be longer than the notification service timeout"))
+	}
+
+	if c.Expiry.ResponseDeadline < 0 {
+		errs = append(errs, errors.New("expiry: response deadline can't be negative"))
+	}
+	if c.Expiry.Interval <= 0 || c.Expiry.BatchSize < 1 {
+		errs = append(errs, errors.New("expiry: interval must be positive and batch size at least 1"))
+	}
+
+	if c.Stay.NoShowGrace < 0 || c.Stay.CheckOutGrace < 0 {
+		errs = append(errs, errors.New("stay: grace periods can't be negative"))
+	}
+	if c.Stay.Interval <= 0 || c.Stay.BatchSize < 1 {
+		errs = append(errs, errors.New("stay: interval must be positive and batch size at least 1"))
+	}
+
+	i := c.Idempotency
+	if i.TTL <= 0 || i.ProcessingTimeout <= 0 || i.PurgeInterval <= 0 {
+		errs = append(errs, errors.New("idempotency: ttl, processing timeout and purge interval must be positive"))
+	}
+	if i.ProcessingTimeout >= i.TTL {
+		errs = append(errs, errors.New("idempotency: processing timeout must be shorter than the ttl"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewTransport builds the connection pool shared by all downstream clients.
+func (c HTTPClientConfig) NewTransport() (*http.Transport, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.TLS.InsecureSkipVerify}
+
+	if c.TLS.CAFile != "" {
+		pem, err := os.ReadFile(c.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", c.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxIdleConns = c.MaxIdleConns
+	transport.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = c.MaxConnsPerHost
+	transport.IdleConnTimeout = c.IdleConnTimeout.Std()
+	return transport, nil
+}
+
+// NewHTTPClient returns a client for one downstream service that shares the
+// given transport.
+func (c ServiceConfig) NewHTTPClient(transport http.RoundTripper) *http.Client {
+	return &http.Client{
+		Transport: transport,
+		Timeout:   c.Timeout.Std(),
+	}
+}
+
+type envReader struct {
+	getenv func(string) string
+	err    error
+}
+
+func (e *envReader) str(key string, dst *string) {
+	if v := e.getenv(key); v != "" {
+		*dst = v
+	}
+}
+
+func (e *envReader) int(key string, dst *int) {
+	if v := e.getenv(key); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			e.err = errors.Join(e.err, fmt.Errorf("%s: %q is not a number", key, v))
+			return
+		}
+		*dst = parsed
+	}
+}
+
+func (e *envReader) bool(key string, dst *bool) {
+	if v := e.getenv(key); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			e.err = errors.Join(e.err, fmt.Errorf("%s: %q is not a boolean", key, v))
+			return
+		}
+		*dst = parsed
+	}
+}
+
+func (e *envReader) duration(key string, dst *Duration) {
+	if v := e.getenv(key); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			e.err = errors.Join(e.err, fmt.Errorf("%s: %q is not a duration", key, v))
+			return
+		}
+		*dst = Duration(parsed)
+	}
+}
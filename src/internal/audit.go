@@ -0,0 +1,31 @@
+package internal
+
+import (
+	"bookem-reservation-service/util"
+	"context"
+	"time"
+)
+
+// Actor is who changed the status of something. The system has ID 0.
+type Actor struct {
+	ID   uint
+	Role util.UserRole
+}
+
+var SystemActor = Actor{Role: ActorSystem}
+
+// newTransition records a status change. Status changes go through a
+// stateMachine, which stores it in the same transaction as the change.
+func newTransition(ctx context.Context, subject TransitionSubject, id uint, from, to string, actor Actor, reason string) *StatusTransition {
+	return &StatusTransition{
+		SubjectType: subject,
+		SubjectID:   id,
+		FromStatus:  from,
+		ToStatus:    to,
+		ActorID:     actor.ID,
+		ActorRole:   actor.Role,
+		Reason:      reason,
+		TraceID:     util.TEL.TraceID(ctx),
+		CreatedAt:   time.Now().UTC(),
+	}
+}
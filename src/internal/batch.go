@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"bookem-reservation-service/client/roomclient"
+	"bookem-reservation-service/util"
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// MaxBatchDecisions is how many requests a host can decide in one call.
+const MaxBatchDecisions = 50
+
+type DecisionAction string
+
+const (
+	ApproveDecision DecisionAction = "approve"
+	RejectDecision  DecisionAction = "reject"
+)
+
+// status is the status a request goes to when the action is taken.
+func (a DecisionAction) status() ReservationRequestStatus {
+	if a == ApproveDecision {
+		return Accepted
+	}
+	return Rejected
+}
+
+// RequestDecision is what the host wants done with one pending request.
+type RequestDecision struct {
+	RequestID uint           `json:"requestId"`
+	Action    DecisionAction `json:"action"`
+}
+
+// DecisionResult is the outcome of one decision of a batch. Status is the
+// request's new status, and Err why it didn't get one. A request that lost
+// its dates to an earlier request of the batch has both.
+type DecisionResult struct {
+	RequestID   uint
+	Action      DecisionAction
+	Status      ReservationRequestStatus
+	Reservation *Reservation
+	Err         error
+}
+
+func validateDecisions(decisions []RequestDecision) error {
+	if len(decisions) == 0 {
+		return ErrBadRequestCustom("no decisions given")
+	}
+	if len(decisions) > MaxBatchDecisions {
+		return ErrBadRequestCustom(fmt.Sprintf("at most %d requests can be decided at once", MaxBatchDecisions))
+	}
+	seen := map[uint]bool{}
+	for _, d := range decisions {
+		if d.Action != ApproveDecision && d.Action != RejectDecision {
+			return ErrBadRequestCustom(fmt.Sprintf("action of request %d must be approve or reject", d.RequestID))
+		}
+		if seen[d.RequestID] {
+			return ErrBadRequestCustom(fmt.Sprintf("request %d is decided more than once", d.RequestID))
+		}
+		seen[d.RequestID] = true
+	}
+	return nil
+}
+
+// errLostTo is returned for approving a request whose dates were taken by an
+// earlier request of the same batch.
+func errLostTo(req, winner ReservationRequest) error {
+	return &APIError{
+		Code:    http.StatusConflict,
+		Message: fmt.Sprintf("request %d overlaps request %d, which was made earlier", req.ID, winner.ID),
+	}
+}
+
+func overlaps(a, b ReservationRequest) bool {
+	return a.RoomID == b.RoomID && !a.DateFrom.After(b.DateTo) && !b.DateFrom.After(a.DateTo)
+}
+
+// decisionLookups fetches each room, guest and room's current lists once per
+// batch, however many of its requests need them. Failures are kept too.
+type decisionLookups struct {
+	s      *service
+	rooms  map[uint]roomLookup
+	guests map[uint]error
+	lists  map[uint]listsLookup
+}
+
+type roomLookup struct {
+	room *roomclient.RoomDTO
+	err  error
+}
+
+type listsLookup struct {
+	availability, price uint
+	err                 error
+}
+
+func newDecisionLookups(s *service) *decisionLookups {
+	return &decisionLookups{
+		s:      s,
+		rooms:  map[uint]roomLookup{},
+		guests: map[uint]error{},
+		lists:  map[uint]listsLookup{},
+	}
+}
+
+func (l *decisionLookups) room(ctx context.Context, roomID uint) (*roomclient.RoomDTO, error) {
+	if r, ok := l.rooms[roomID]; ok {
+		return r.room, r.err
+	}
+	room, err := l.s.roomClient.FindById(ctx, roomID)
+	if err != nil {
+		util.TEL.Error(ctx, "room not found", err, "id", roomID)
+		err = downstreamError(err, ErrNotFound("room", roomID))
+	}
+	l.rooms[roomID] = roomLookup{room, err}
+	return room, err
+}
+
+// guest checks the guest still has an account.
+func (l *decisionLookups) guest(ctx context.Context, guestID uint) error {
+	if err, ok := l.guests[guestID]; ok {
+		return err
+	}
+	user, err := l.s.userClient.FindById(ctx, guestID)
+	switch {
+	case err != nil:
+		util.TEL.Error(ctx, "user of reservation request does not exist", err, "id", guestID)
+		err = downstreamError(err, ErrNotFound("user", guestID))
+	case user.Deleted:
+		util.TEL.Error(ctx, "user of reservation request deleted account", nil, "id", guestID)
+		err = ErrNotFound("user", guestID)
+	}
+	l.guests[guestID] = err
+	return err
+}
+
+// currentLists finds the room's current availability and price lists, which
+// reservations approved in the batch are made under.
+func (l *decisionLookups) currentLists(ctx context.Context, roomID uint) (listsLookup, error) {
+	if ls, ok := l.lists[roomID]; ok {
+		return ls, ls.err
+	}
+	var ls listsLookup
+	if availList, err := l.s.roomClient.FindCurrentAvailabilityListOfRoom(ctx, roomID); err != nil {
+		util.TEL.Error(ctx, "room availability list not found", err, "room_id", roomID)
+		ls.err = downstreamError(err, ErrNotFound("room availability list", roomID))
+	} else if pricelist, err := l.s.roomClient.FindCurrentPricelistOfRoom(ctx, roomID); err != nil {
+		util.TEL.Error(ctx, "room price list not found", err, "room_id", roomID)
+		ls.err = downstreamError(err, ErrNotFound("room price list", roomID))
+	} else {
+		ls.availability, ls.price = availList.ID, pricelist.ID
+	}
+	l.lists[roomID] = ls
+	return ls, ls.err
+}
@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"bookem-reservation-service/client/roomclient"
+	"time"
+)
+
+// MaxCalendarDays is how many days one calendar can span.
+const MaxCalendarDays = 366
+
+// CalendarDay is one day of a room's calendar.
+type CalendarDay struct {
+	Date time.Time
+	// ReservationID is the reservation booking the day, or 0 if it's free.
+	ReservationID uint
+	// RequestIDs are the pending requests that cover the day.
+	RequestIDs []uint
+	// Availability is the item of the current availability list that decides
+	// the day, or nil if none covers it.
+	Availability *roomclient.RoomAvailabilityItemDTO
+	// Price is the nightly price from the current price list.
+	Price uint
+}
+
+func (d CalendarDay) Booked() bool {
+	return d.ReservationID != 0
+}
+
+func (d CalendarDay) Available() bool {
+	return d.Availability != nil && d.Availability.Available
+}
+
+// RoomCalendar is a room's occupancy from From to To, both included, one day
+// at a time.
+type RoomCalendar struct {
+	RoomID   uint
+	From     time.Time
+	To       time.Time
+	PerGuest bool // Prices are per guest
+	Days     []CalendarDay
+}
+
+// newRoomCalendar lays out the days between from and to and fills them in.
+// Reservations and requests are expected to be the room's ones overlapping
+// the window; reservations must not be cancelled and requests pending.
+func newRoomCalendar(
+	roomID uint,
+	from, to time.Time,
+	availability *roomclient.RoomAvailabilityListDTO,
+	prices *roomclient.RoomPriceListDTO,
+	reservations []Reservation,
+	requests []ReservationRequest,
+) *RoomCalendar {
+	from, to = dateOf(from), dateOf(to)
+	cal := &RoomCalendar{RoomID: roomID, From: from, To: to, PerGuest: prices.PerGuest}
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		cal.Days = append(cal.Days, CalendarDay{
+			Date:         day,
+			Availability: availabilityOn(availability.Items, day),
+			Price:        priceOn(prices, day),
+		})
+	}
+
+	for _, res := range reservations {
+		for _, i := range cal.daysOf(res.DateFrom, res.DateTo) {
+			cal.Days[i].ReservationID = res.ID
+		}
+	}
+	for _, req := range requests {
+		for _, i := range cal.daysOf(req.DateFrom, req.DateTo) {
+			cal.Days[i].RequestIDs = append(cal.Days[i].RequestIDs, req.ID)
+		}
+	}
+	return cal
+}
+
+// daysOf returns the indexes of the calendar's days between from and to.
+func (c *RoomCalendar) daysOf(from, to time.Time) []int {
+	from, to = dateOf(from), dateOf(to)
+	if from.Before(c.From) {
+		from = c.From
+	}
+	if to.After(c.To) {
+		to = c.To
+	}
+
+	var days []int
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		days = append(days, int(day.Sub(c.From)/(24*time.Hour)))
+	}
+	return days
+}
+
+// availabilityOn picks the availability item covering day. Where items
+// overlap, one that makes the room unavailable wins.
+func availabilityOn(items []roomclient.RoomAvailabilityItemDTO, day time.Time) *roomclient.RoomAvailabilityItemDTO {
+	var found *roomclient.RoomAvailabilityItemDTO
+	for i := range items {
+		item := &items[i]
+		if !covers(item.DateFrom, item.DateTo, day) {
+			continue
+		}
+		if found == nil || !item.Available {
+			found = item
+		}
+		if !item.Available {
+			break
+		}
+	}
+	return found
+}
+
+// priceOn is the price of the first price item covering day, or the base
+// price if none does.
+func priceOn(prices *roomclient.RoomPriceListDTO, day time.Time) uint {
+	for _, item := range prices.Items {
+		if covers(item.DateFrom, item.DateTo, day) {
+			return item.Price
+		}
+	}
+	return prices.BasePrice
+}
+
+func covers(from, to, day time.Time) bool {
+	return !day.Before(dateOf(from)) && !day.After(dateOf(to))
+}
+
+// dateOf is midnight UTC of t's date.
+func dateOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -1,6 +1,9 @@
 package internal
 
-import "time"
+import (
+	"bookem-reservation-service/util"
+	"time"
+)
 
 type CreateReservationRequestDTO struct {
 	RoomID     uint      `json:"roomId"`
@@ -9,6 +12,61 @@ type CreateReservationRequestDTO struct {
 	GuestCount uint      `json:"guestCount"`
 }
 
+// CreateGroupRequestDTO requests several rooms of one host for the same
+// dates at once.
+type CreateGroupRequestDTO struct {
+	Requests []CreateReservationRequestDTO `json:"requests"`
+}
+
+// DecideRequestsDTO is a host's decisions on several pending requests.
+type DecideRequestsDTO struct {
+	Decisions []RequestDecision `json:"decisions"`
+}
+
+// DecisionResultDTO is the outcome of one decision of a batch. Error and Code
+// are set when the decision couldn't be made, as they would be in the
+// response to deciding the request on its own.
+type DecisionResultDTO struct {
+	RequestID   uint            `json:"requestId"`
+	Action      DecisionAction  `json:"action"`
+	Status      string          `json:"status,omitempty"`
+	Reservation *ReservationDTO `json:"reservation,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Code        int             `json:"code,omitempty"`
+}
+
+type DecisionResultsDTO struct {
+	Results []DecisionResultDTO `json:"results"`
+}
+
+func NewDecisionResultsDTO(results []DecisionResult) DecisionResultsDTO {
+	dto := DecisionResultsDTO{Results: make([]DecisionResultDTO, 0, len(results))}
+	for _, r := range results {
+		item := DecisionResultDTO{
+			RequestID: r.RequestID,
+			Action:    r.Action,
+			Status:    string(r.Status),
+		}
+		if r.Reservation != nil {
+			res := NewReservationDTO(*r.Reservation)
+			item.Reservation = &res
+		}
+		if r.Err != nil {
+			item.Code, item.Error = MapErrorToHTTP(r.Err)
+		}
+		dto.Results = append(dto.Results, item)
+	}
+	return dto
+}
+
+// UpdateReservationRequestDTO changes a pending request; left out fields stay
+// as they are.
+type UpdateReservationRequestDTO struct {
+	DateFrom   *time.Time `json:"dateFrom"`
+	DateTo     *time.Time `json:"dateTo"`
+	GuestCount *uint      `json:"guestCount"`
+}
+
 type ReservationRequestDTO struct {
 	ID               uint      `json:"id"`
 	RoomID           uint      `json:"roomId"`
@@ -19,21 +77,192 @@ type ReservationRequestDTO struct {
 	Status           string    `json:"status"`
 	Cost             uint      `json:"cost"`
 	GuestCancelCount uint      `json:"guestCancelCount"`
+	GroupID          uint      `json:"groupId,omitempty"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+	// WithdrawnAt is only there once the guest withdrew the request.
+	WithdrawnAt *time.Time `json:"withdrawnAt,omitempty"`
+}
+
+// MaxCancellationReasonLength is how long the reason a host gives for
+// cancelling a reservation can be.
+const MaxCancellationReasonLength = 500
+
+type HostCancelReservationDTO struct {
+	Reason string `json:"reason"`
+}
+
+type CancellationCountDTO struct {
+	HostID uint `json:"hostId"`
+	Count  uint `json:"count"`
 }
 
 type ReservationDTO struct {
-	ID         uint      `json:"id"`
-	RoomID     uint      `json:"roomId"`
+	ID         uint              `json:"id"`
+	RoomID     uint              `json:"roomId"`
+	DateFrom   time.Time         `json:"dateFrom"`
+	DateTo     time.Time         `json:"dateTo"`
+	GuestCount uint              `json:"guestCount"`
+	GuestID    uint              `json:"guestId"`
+	Status     ReservationStatus `json:"status"`
+	Cancelled  bool              `json:"cancelled"`
+	Cost       uint              `json:"cost"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	UpdatedAt  time.Time         `json:"updatedAt"`
+
+	CancelledBy        CancelledBy `json:"cancelledBy,omitempty"`
+	CancellationReason string      `json:"cancellationReason,omitempty"`
+	CancelledAt        *time.Time  `json:"cancelledAt,omitempty"`
+
+	CheckedInAt  *time.Time `json:"checkedInAt,omitempty"`
+	CheckedOutAt *time.Time `json:"checkedOutAt,omitempty"`
+
+	CancellationPolicy CancellationTerms `json:"cancellationPolicy"`
+	// Refund and Penalty are only there once the reservation is cancelled.
+	Refund  *uint `json:"refund,omitempty"`
+	Penalty *uint `json:"penalty,omitempty"`
+}
+
+// ModifyReservationDTO proposes a new stay; left out fields stay as they are.
+type ModifyReservationDTO struct {
+	DateFrom   *time.Time `json:"dateFrom"`
+	DateTo     *time.Time `json:"dateTo"`
+	GuestCount *uint      `json:"guestCount"`
+}
+
+type StayDTO struct {
 	DateFrom   time.Time `json:"dateFrom"`
 	DateTo     time.Time `json:"dateTo"`
 	GuestCount uint      `json:"guestCount"`
-	GuestID    uint      `json:"guestId"`
-	Cancelled  bool      `json:"cancelled"`
 	Cost       uint      `json:"cost"`
 }
 
+type ModificationDTO struct {
+	ID            uint               `json:"id"`
+	ReservationID uint               `json:"reservationId"`
+	RoomID        uint               `json:"roomId"`
+	GuestID       uint               `json:"guestId"`
+	Previous      StayDTO            `json:"previous"`
+	Proposed      StayDTO            `json:"proposed"`
+	Status        ModificationStatus `json:"status"`
+	CreatedAt     time.Time          `json:"createdAt"`
+	DecidedAt     *time.Time         `json:"decidedAt,omitempty"`
+}
+
+type StatusTransitionDTO struct {
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	ActorID   uint          `json:"actorId"`
+	ActorRole util.UserRole `json:"actorRole"`
+	Reason    string        `json:"reason,omitempty"`
+	TraceID   string        `json:"traceId,omitempty"`
+	At        time.Time     `json:"at"`
+}
+
+type RoomCalendarDTO struct {
+	RoomID   uint             `json:"roomId"`
+	From     time.Time        `json:"from"`
+	To       time.Time        `json:"to"`
+	PerGuest bool             `json:"perGuest"`
+	Days     []CalendarDayDTO `json:"days"`
+}
+
+type CalendarDayDTO struct {
+	Date          time.Time `json:"date"`
+	Booked        bool      `json:"booked"`
+	ReservationID *uint     `json:"reservationId,omitempty"`
+	RequestIDs    []uint    `json:"pendingRequestIds"`
+	Available     bool      `json:"available"`
+	// AvailableFrom and AvailableTo are the availability window the day is
+	// in, when there is one.
+	AvailableFrom *time.Time `json:"availableFrom,omitempty"`
+	AvailableTo   *time.Time `json:"availableTo,omitempty"`
+	Price         uint       `json:"price"`
+}
+
+type CreateFeedTokenDTO struct {
+	Kind   FeedKind `json:"kind"`
+	RoomID uint     `json:"roomId"` // Only for room feeds
+}
+
+type FeedTokenDTO struct {
+	ID        uint       `json:"id"`
+	Kind      FeedKind   `json:"kind"`
+	RoomID    uint       `json:"roomId,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	// Token and URL are only there when the token is created.
+	Token string `json:"token,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+type CreateHoldDTO struct {
+	RoomID   uint      `json:"roomId"`
+	DateFrom time.Time `json:"dateFrom"`
+	DateTo   time.Time `json:"dateTo"`
+	Minutes  uint      `json:"minutes"` // How long to hold the dates; 0 for the default
+}
+
+type HoldDTO struct {
+	ID        uint       `json:"id"`
+	RoomID    uint       `json:"roomId"`
+	DateFrom  time.Time  `json:"dateFrom"`
+	DateTo    time.Time  `json:"dateTo"`
+	Status    HoldStatus `json:"status"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+}
+
+type CreateWaitlistEntryDTO struct {
+	RoomID     uint      `json:"roomId"`
+	DateFrom   time.Time `json:"dateFrom"`
+	DateTo     time.Time `json:"dateTo"`
+	GuestCount uint      `json:"guestCount"`
+}
+
+type WaitlistEntryDTO struct {
+	ID         uint           `json:"id"`
+	RoomID     uint           `json:"roomId"`
+	DateFrom   time.Time      `json:"dateFrom"`
+	DateTo     time.Time      `json:"dateTo"`
+	GuestCount uint           `json:"guestCount"`
+	Status     WaitlistStatus `json:"status"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	// The offer is only there once the dates were offered to the guest, and
+	// the request once the guest claimed them.
+	OfferedAt      *time.Time `json:"offeredAt,omitempty"`
+	OfferExpiresAt *time.Time `json:"offerExpiresAt,omitempty"`
+	RequestID      uint       `json:"requestId,omitempty"`
+}
+
+type CancellationPolicyDTO struct {
+	RoomID uint                   `json:"roomId"`
+	Kind   CancellationPolicyKind `json:"kind"`
+	Tiers  []RefundTier           `json:"tiers"`
+}
+
+// SetCancellationPolicyDTO picks a preset policy, or a custom one with its
+// tiers.
+type SetCancellationPolicyDTO struct {
+	Kind  CancellationPolicyKind `json:"kind"`
+	Tiers []RefundTier           `json:"tiers"`
+}
+
+// CancellationDTO is the answer to cancelling a reservation: how its cost was
+// split between what's refunded and what's kept as a penalty.
+type CancellationDTO struct {
+	ReservationID uint                   `json:"reservationId"`
+	CancelledBy   CancelledBy            `json:"cancelledBy"`
+	Policy        CancellationPolicyKind `json:"policy"`
+	Cost          uint                   `json:"cost"`
+	DaysBefore    uint                   `json:"daysBefore"`
+	RefundPercent uint                   `json:"refundPercent"`
+	Refund        uint                   `json:"refund"`
+	Penalty       uint                   `json:"penalty"`
+}
+
 func NewReservationRequestDTO(r ReservationRequest) ReservationRequestDTO {
-	return ReservationRequestDTO{
+	dto := ReservationRequestDTO{
 		ID:         r.ID,
 		RoomID:     r.RoomID,
 		DateFrom:   r.DateFrom,
@@ -42,7 +271,62 @@ func NewReservationRequestDTO(r ReservationRequest) ReservationRequestDTO {
 		GuestID:    r.GuestID,
 		Status:     string(r.Status),
 		Cost:       r.Cost,
+		GroupID:    r.GroupID,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+	}
+	if r.WithdrawnAt.Valid {
+		dto.WithdrawnAt = &r.WithdrawnAt.Time
+	}
+	return dto
+}
+
+// CreatedReservationRequestDTO is the response to creating a reservation
+// request. Reservation is set when the room approved the request right away.
+type CreatedReservationRequestDTO struct {
+	ReservationRequestDTO
+	Reservation *ReservationDTO `json:"reservation,omitempty"`
+}
+
+func NewCreatedReservationRequestDTO(req ReservationRequest, res *Reservation) CreatedReservationRequestDTO {
+	dto := CreatedReservationRequestDTO{ReservationRequestDTO: NewReservationRequestDTO(req)}
+	if res != nil {
+		resDTO := NewReservationDTO(*res)
+		dto.Reservation = &resDTO
 	}
+	return dto
+}
+
+// RequestGroupDTO is a group request with the total cost of all its rooms.
+// Reservations is set when the group was approved by the call returning it.
+type RequestGroupDTO struct {
+	ID           uint                    `json:"id"`
+	GuestID      uint                    `json:"guestId"`
+	HostID       uint                    `json:"hostId"`
+	Status       string                  `json:"status"`
+	TotalCost    uint                    `json:"totalCost"`
+	CreatedAt    time.Time               `json:"createdAt"`
+	Requests     []ReservationRequestDTO `json:"requests"`
+	Reservations []ReservationDTO        `json:"reservations,omitempty"`
+}
+
+func NewRequestGroupDTO(g RequestGroup, reservations []Reservation) RequestGroupDTO {
+	dto := RequestGroupDTO{
+		ID:        g.ID,
+		GuestID:   g.GuestID,
+		HostID:    g.HostID,
+		Status:    string(g.Status()),
+		TotalCost: g.TotalCost(),
+		CreatedAt: g.CreatedAt,
+		Requests:  make([]ReservationRequestDTO, 0, len(g.Requests)),
+	}
+	for _, req := range g.Requests {
+		dto.Requests = append(dto.Requests, NewReservationRequestDTO(req))
+	}
+	for _, res := range reservations {
+		dto.Reservations = append(dto.Reservations, NewReservationDTO(res))
+	}
+	return dto
 }
 
 func NewReservationRequestDTOWithCancellations(r ReservationRequest, cancelCount uint) ReservationRequestDTO {
@@ -52,16 +336,154 @@ func NewReservationRequestDTOWithCancellations(r ReservationRequest, cancelCount
 }
 
 func NewReservationDTO(r Reservation) ReservationDTO {
-	return ReservationDTO{
+	dto := ReservationDTO{
 		ID:         r.ID,
 		RoomID:     r.RoomID,
 		DateFrom:   r.DateFrom,
 		DateTo:     r.DateTo,
 		GuestCount: r.GuestCount,
 		GuestID:    r.GuestID,
+		Status:     r.Status,
 		Cancelled:  r.Cancelled,
 		Cost:       r.Cost,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+
+		CancelledBy:        r.CancelledBy,
+		CancellationReason: r.CancellationReason,
+		CancelledAt:        r.CancelledAt,
+
+		CheckedInAt:  r.CheckedInAt,
+		CheckedOutAt: r.CheckedOutAt,
+
+		CancellationPolicy: r.CancellationTerms,
+	}
+	if r.Cancelled {
+		dto.Refund = &r.RefundAmount
+		dto.Penalty = &r.CancellationPenalty
+	}
+	return dto
+}
+
+func NewStayDTO(s Stay) StayDTO {
+	return StayDTO{DateFrom: s.DateFrom, DateTo: s.DateTo, GuestCount: s.GuestCount, Cost: s.Cost}
+}
+
+func NewModificationDTO(m ReservationModification) ModificationDTO {
+	return ModificationDTO{
+		ID:            m.ID,
+		ReservationID: m.ReservationID,
+		RoomID:        m.RoomID,
+		GuestID:       m.GuestID,
+		Previous:      NewStayDTO(m.Previous),
+		Proposed:      NewStayDTO(m.Proposed),
+		Status:        m.Status,
+		CreatedAt:     m.CreatedAt,
+		DecidedAt:     m.DecidedAt,
+	}
+}
+
+func NewStatusTransitionDTO(t StatusTransition) StatusTransitionDTO {
+	return StatusTransitionDTO{
+		From:      t.FromStatus,
+		To:        t.ToStatus,
+		ActorID:   t.ActorID,
+		ActorRole: t.ActorRole,
+		Reason:    t.Reason,
+		TraceID:   t.TraceID,
+		At:        t.CreatedAt,
+	}
+}
+
+func NewRoomCalendarDTO(c RoomCalendar) RoomCalendarDTO {
+	days := make([]CalendarDayDTO, 0, len(c.Days))
+	for _, d := range c.Days {
+		day := CalendarDayDTO{
+			Date:       d.Date,
+			Booked:     d.Booked(),
+			RequestIDs: d.RequestIDs,
+			Available:  d.Available(),
+			Price:      d.Price,
+		}
+		if day.RequestIDs == nil {
+			day.RequestIDs = []uint{}
+		}
+		if d.Booked() {
+			day.ReservationID = &d.ReservationID
+		}
+		if d.Availability != nil {
+			day.AvailableFrom = &d.Availability.DateFrom
+			day.AvailableTo = &d.Availability.DateTo
+		}
+		days = append(days, day)
+	}
+	return RoomCalendarDTO{RoomID: c.RoomID, From: c.From, To: c.To, PerGuest: c.PerGuest, Days: days}
+}
+
+func NewFeedTokenDTO(t FeedToken) FeedTokenDTO {
+	return FeedTokenDTO{ID: t.ID, Kind: t.Kind, RoomID: t.RoomID, CreatedAt: t.CreatedAt, RevokedAt: t.RevokedAt}
+}
+
+func NewHoldDTO(h Hold) HoldDTO {
+	return HoldDTO{
+		ID:        h.ID,
+		RoomID:    h.RoomID,
+		DateFrom:  h.DateFrom,
+		DateTo:    h.DateTo,
+		Status:    h.Status,
+		CreatedAt: h.CreatedAt,
+		ExpiresAt: h.ExpiresAt,
+	}
+}
+
+func NewWaitlistEntryDTO(e WaitlistEntry) WaitlistEntryDTO {
+	return WaitlistEntryDTO{
+		ID:             e.ID,
+		RoomID:         e.RoomID,
+		DateFrom:       e.DateFrom,
+		DateTo:         e.DateTo,
+		GuestCount:     e.GuestCount,
+		Status:         e.Status,
+		CreatedAt:      e.CreatedAt,
+		OfferedAt:      e.OfferedAt,
+		OfferExpiresAt: e.OfferExpiresAt,
+		RequestID:      e.RequestID,
+	}
+}
+
+func NewCancellationPolicyDTO(p CancellationPolicy) CancellationPolicyDTO {
+	return CancellationPolicyDTO{RoomID: p.RoomID, Kind: p.Terms.Kind, Tiers: p.Terms.Tiers}
+}
+
+func NewCancellationDTO(c CancellationResult) CancellationDTO {
+	return CancellationDTO{
+		ReservationID: c.Reservation.ID,
+		CancelledBy:   c.Reservation.CancelledBy,
+		Policy:        c.Reservation.CancellationTerms.Kind,
+		Cost:          c.Reservation.Cost,
+		DaysBefore:    c.Refund.DaysBefore,
+		RefundPercent: c.Refund.RefundPercent,
+		Refund:        c.Refund.Refund,
+		Penalty:       c.Refund.Penalty,
+	}
+}
+
+// PageDTO is the envelope every listing endpoint answers with. NextCursor is
+// left out on the last page. Total is counted before items of deleted rooms
+// or users are dropped from the pages, so it can be more than the items a
+// client gets.
+type PageDTO[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	Total      int64  `json:"total"`
+}
+
+func NewPageDTO[T, D any](page *Page[T], toDTO func(T) D) PageDTO[D] {
+	items := make([]D, 0, len(page.Items))
+	for _, item := range page.Items {
+		items = append(items, toDTO(item))
 	}
+	return PageDTO[D]{Items: items, NextCursor: page.NextCursor, Total: page.Total}
 }
 
 type RoomIDsDTO struct {
@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/client/roomclient"
+	"bookem-reservation-service/config"
+	"bookem-reservation-service/util"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	requestsExpired = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "reservation_requests_expired_total",
+			Help: "Pending reservation requests expired because the host didn't answer in time",
+		},
+		[]string{"reason"}, // deadline or start_date
+	)
+
+	registerExpiryMetrics sync.Once
+)
+
+// RequestExpirer expires pending reservation requests the host hasn't
+// answered: ResponseDeadline after they were made, or once their stay has
+// started. Both the guest and the host are notified.
+type RequestExpirer struct {
+	repo       Repository
+	roomClient roomclient.RoomClient
+	cfg        config.ExpiryConfig
+
+	now func() time.Time
+}
+
+func NewRequestExpirer(repo Repository, roomClient roomclient.RoomClient, cfg config.ExpiryConfig) *RequestExpirer {
+	registerExpiryMetrics.Do(func() {
+		prometheus.MustRegister(requestsExpired)
+	})
+
+	return &RequestExpirer{
+		repo:       repo,
+		roomClient: roomClient,
+		cfg:        cfg,
+		now:        func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Run expires requests every interval until ctx is done.
+func (e *RequestExpirer) Run(ctx context.Context) {
+	util.RunPeriodically(ctx, e.cfg.Interval.Std(), func(ctx context.Context) {
+		for {
+			n, err := e.ExpireOnce(ctx)
+			if err != nil {
+				util.TEL.Error(ctx, "request expiry failed", err)
+			}
+			if err != nil || n < e.cfg.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+	})
+}
+
+// ExpireOnce expires one batch of requests and returns how many it expired.
+func (e *RequestExpirer) ExpireOnce(ctx context.Context) (int, error) {
+	ctx, span := util.TEL.Start(ctx, "expire-reservation-requests")
+	defer span.End()
+
+	now := e.now()
+	var createdBefore time.Time // Zero matches nothing
+	if e.cfg.ResponseDeadline > 0 {
+		createdBefore = now.Add(-e.cfg.ResponseDeadline.Std())
+	}
+
+	candidates, err := e.repo.FindExpirableRequests(createdBefore, now, e.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+
+	// Hosts to notify, by room. A room that has been deleted has no host to
+	// notify, but its requests still expire.
+	hosts := map[uint]uint{}
+	var ids []uint
+	for _, req := range candidates {
+		if _, ok := hosts[req.RoomID]; !ok {
+			room, err := e.roomClient.FindById(ctx, req.RoomID)
+			switch {
+			case isUnavailable(err):
+				util.TEL.Warn(ctx, "room service unavailable, expiring the room's requests later", "room_id", req.RoomID)
+				continue
+			case err != nil:
+				hosts[req.RoomID] = 0
+			default:
+				hosts[req.RoomID] = room.HostID
+			}
+		}
+		if _, ok := hosts[req.RoomID]; ok {
+			ids = append(ids, req.ID)
+		}
+	}
+
+	var expired []ReservationRequest
+	err = e.repo.Transaction(func(tx Repository) error {
+		var err error
+		expired, err = tx.ExpireRequests(ids)
+		if err != nil {
+			return err
+		}
+
+		for _, req := range expired {
+			if err := requestStates.apply(ctx, tx, req.ID, Pending, Expired, SystemActor, expiryReason(req, now)); err != nil {
+				return err
+			}
+
+			hostID := hosts[req.RoomID]
+			err := tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+				ReceiverID: req.GuestID,
+				Type:       notificationclient.ReservationExpired,
+				Subject:    hostID,
+				Object:     req.RoomID,
+			}))
+			if err != nil {
+				return err
+			}
+
+			if hostID == 0 {
+				continue
+			}
+			err = tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+				ReceiverID: hostID,
+				Type:       notificationclient.ReservationExpired,
+				Subject:    req.GuestID,
+				Object:     req.RoomID,
+			}))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, req := range expired {
+		reason := expiryReason(req, now)
+		requestsExpired.WithLabelValues(reason).Inc()
+		util.TEL.Info(ctx, "reservation request expired", "request_id", req.ID, "reason", reason)
+	}
+
+	return len(expired), nil
+}
+
+// expiryReason tells whether req expired because the host didn't answer in
+// time or because its stay started.
+func expiryReason(req ReservationRequest, now time.Time) string {
+	if !req.DateFrom.After(now) {
+		return "start_date"
+	}
+	return "deadline"
+}
@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"bookem-reservation-service/client/roomclient"
+	"bookem-reservation-service/util"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FeedKind is what a calendar feed shows.
+type FeedKind string
+
+const (
+	FeedRoom  FeedKind = "room"  // Reservations of one of the host's rooms
+	FeedHost  FeedKind = "host"  // Reservations of all of the host's rooms
+	FeedGuest FeedKind = "guest" // The guest's upcoming stays
+)
+
+// feedRoles says who can have which kind of feed.
+var feedRoles = map[FeedKind]util.UserRole{
+	FeedRoom:  util.Host,
+	FeedHost:  util.Host,
+	FeedGuest: util.Guest,
+}
+
+// FeedHistory is how long past reservations stay in room and host feeds.
+const FeedHistory = 30 * 24 * time.Hour
+
+// FeedToken lets a calendar app read a feed without a JWT. Only a hash of the
+// token is stored; the token itself is shown once, when it's created.
+type FeedToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null"` // Whose feed it is
+	Kind      FeedKind  `gorm:"not null"`
+	RoomID    uint      `gorm:"not null"` // Only for room feeds
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	CreatedAt time.Time `gorm:"not null"`
+	RevokedAt *time.Time
+}
+
+// newFeedToken makes an unguessable token and the hash to store for it.
+func newFeedToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashFeedToken(token), nil
+}
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Feed is a calendar of reservations, ready to be written as iCalendar.
+type Feed struct {
+	Name         string
+	Reservations []Reservation
+	// Rooms has the rooms of the reservations, where they could be found.
+	Rooms map[uint]roomclient.RoomDTO
+	// ForGuest words the events for the guest instead of the host.
+	ForGuest bool
+}
+
+// ICS writes the feed as an iCalendar (RFC 5545) file, with one event per
+// reservation. An event's UID only depends on the reservation's ID, so
+// calendar apps update the event when the reservation changes.
+func (f *Feed) ICS(now time.Time) []byte {
+	var b bytes.Buffer
+	w := icsWriter{&b}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//Bookem//Reservation Service//EN")
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	w.line("X-WR-CALNAME:" + icsText(f.Name))
+
+	for _, res := range f.Reservations {
+		room, known := f.Rooms[res.RoomID]
+		name := fmt.Sprintf("room %d", res.RoomID)
+		if known {
+			name = room.Name
+		}
+
+		w.line("BEGIN:VEVENT")
+		w.line(fmt.Sprintf("UID:reservation-%d@bookem", res.ID))
+		w.line("DTSTAMP:" + icsTime(now))
+		w.line("LAST-MODIFIED:" + icsTime(res.UpdatedAt))
+		// Reservations include their last day, DTEND doesn't.
+		w.line("DTSTART;VALUE=DATE:" + icsDate(res.DateFrom))
+		w.line("DTEND;VALUE=DATE:" + icsDate(res.DateTo.AddDate(0, 0, 1)))
+		if f.ForGuest {
+			w.line("SUMMARY:" + icsText("Stay at "+name))
+			if known && room.Address != "" {
+				w.line("LOCATION:" + icsText(room.Address))
+			}
+		} else {
+			w.line("SUMMARY:" + icsText("Booked: "+name))
+		}
+		w.line("DESCRIPTION:" + icsText(fmt.Sprintf("Reservation %d, guests: %d", res.ID, res.GuestCount)))
+		w.line("STATUS:CONFIRMED")
+		w.line("TRANSP:OPAQUE")
+		w.line("END:VEVENT")
+	}
+
+	w.line("END:VCALENDAR")
+	return b.Bytes()
+}
+
+// icsWriter ends lines with CRLF and folds them at 75 octets, without
+// splitting UTF-8 sequences.
+type icsWriter struct{ b *bytes.Buffer }
+
+func (w icsWriter) line(s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.b.WriteString(s[:cut])
+		w.b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74 // The leading space counts too
+	}
+	w.b.WriteString(s)
+	w.b.WriteString("\r\n")
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func icsText(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+func icsDate(t time.Time) string {
+	return dateOf(t).Format("20060102")
+}
+
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MaxGroupSize is how many rooms a group request can have.
+const MaxGroupSize = 10
+
+// RequestGroup bundles the requests a guest made for several rooms of one
+// host, for the same dates, at once. Its requests are decided together: the
+// host approves or rejects all of them, and if one of them can't be approved
+// none of them are.
+type RequestGroup struct {
+	ID        uint      `gorm:"primaryKey"`
+	GuestID   uint      `gorm:"not null"`
+	HostID    uint      `gorm:"not null"`
+	CreatedAt time.Time `gorm:"not null"`
+
+	// Requests are the group's requests, by ID, including withdrawn ones.
+	Requests []ReservationRequest `gorm:"-"`
+}
+
+// Status is the status the group's requests share. Requests that expire in
+// different runs of the RequestExpirer can briefly disagree; the group is
+// pending as long as any of them is.
+func (g RequestGroup) Status() ReservationRequestStatus {
+	for _, req := range g.Requests {
+		if req.Status == Pending {
+			return Pending
+		}
+	}
+	if len(g.Requests) == 0 {
+		return ""
+	}
+	return g.Requests[0].Status
+}
+
+// TotalCost is what the whole group costs the guest.
+func (g RequestGroup) TotalCost() uint {
+	var total uint
+	for _, req := range g.Requests {
+		total += req.Cost
+	}
+	return total
+}
+
+// errGrouped is returned for changing a request of a group on its own.
+func errGrouped(req ReservationRequest) error {
+	return ErrBadRequestCustom(fmt.Sprintf("request %d is part of group %d and can only change with it", req.ID, req.GroupID))
+}
+
+// rejectRestOfGroup rejects the group's pending requests once one of them
+// was rejected because its dates were taken, as the group can't be approved
+// any more.
+func rejectRestOfGroup(ctx context.Context, tx Repository, groupID, rejectedID uint) error {
+	rejected, err := tx.RejectPendingRequestsInGroup(groupID)
+	if err != nil {
+		return err
+	}
+	reason := fmt.Sprintf("request %d of the group was rejected", rejectedID)
+	for _, req := range rejected {
+		if err := requestStates.apply(ctx, tx, req.ID, Pending, Rejected, SystemActor, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
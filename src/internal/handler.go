@@ -2,430 +2,1462 @@ package internal
 
 import (
 	"bookem-reservation-service/util"
+	"context"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-type Route struct{ handler Handler }
+type Route struct {
+	handler Handler
+	// idempotent guards the routes clients may retry, see Idempotency.
+	idempotent gin.HandlerFunc
+}
+
+func NewRoute(handler Handler, idempotent gin.HandlerFunc) *Route { return &Route{handler, idempotent} }
+
+func (r *Route) Route(rg *gin.RouterGroup) {
+	rg.POST("/req", r.idempotent, r.handler.createReservationRequest)
+	rg.GET("/req/user", r.handler.findPendingRequestsByGuest)
+	rg.GET("/req/room/:id", r.handler.findPendingRequestsByRoom)
+	rg.PUT("/req/:id", r.handler.updateRequestByGuest)
+	rg.DELETE("/req/:id", r.handler.deleteRequestByGuest)
+	rg.GET("/req/:id/history", r.handler.getRequestHistory)
+
+	rg.POST("/req/group", r.idempotent, r.handler.createGroupRequest)
+	rg.GET("/req/group/:id", r.handler.getGroupRequest)
+	rg.PUT("/req/group/:id/approve", r.handler.approveGroupRequest)
+	rg.PUT("/req/group/:id/reject", r.handler.rejectGroupRequest)
+	rg.DELETE("/req/group/:id", r.handler.withdrawGroupRequest)
+
+	rg.GET("/room/:id/availability", r.handler.checkAvailability)
+	rg.GET("/room/:id/cancellation-policy", r.handler.getCancellationPolicy)
+	rg.PUT("/room/:id/cancellation-policy", r.handler.setCancellationPolicy)
+	rg.GET("/room/:id/calendar", r.handler.getRoomCalendar)
+
+	rg.POST("/feeds", r.handler.createFeedToken)
+	rg.GET("/feeds", r.handler.getFeedTokens)
+	rg.DELETE("/feeds/:id", r.handler.revokeFeedToken)
+	// Calendar apps can't send a JWT; the token query parameter is the
+	// credential. It's not in the path, which ends up in the request logs.
+	rg.GET("/feeds/calendar.ics", r.handler.getFeed)
+
+	rg.POST("/holds", r.handler.createHold)
+	rg.DELETE("/holds/:id", r.handler.releaseHold)
+
+	rg.POST("/waitlist", r.handler.joinWaitlist)
+	rg.GET("/waitlist", r.handler.getWaitlistEntries)
+	rg.DELETE("/waitlist/:id", r.handler.leaveWaitlist)
+	rg.POST("/waitlist/:id/claim", r.idempotent, r.handler.claimWaitlistOffer)
+
+	rg.GET("/reservations/guest/active", r.handler.getActiveGuestReservations)
+	rg.GET("/reservations/host/active", r.handler.getActiveHostReservations)
+
+	rg.PUT("/req/:id/reject", r.handler.rejectReservationRequest)
+	rg.PUT("/req/:id/approve", r.handler.approveReservationRequest)
+	rg.POST("/req/decisions", r.idempotent, r.handler.decideRequests)
+	rg.DELETE("/reservations/:id/cancel", r.handler.cancelReservation)
+	rg.PUT("/reservations/:id/host-cancel", r.handler.hostCancelReservation)
+	rg.PUT("/reservations/:id/check-in", r.handler.checkIn)
+	rg.PUT("/reservations/:id/check-out", r.handler.checkOut)
+	rg.PUT("/reservations/:id/no-show", r.handler.markNoShow)
+	rg.GET("/reservations/host/:id/cancellations", r.handler.getHostCancellationCount)
+
+	rg.GET("/reservations/:id/history", r.handler.getReservationHistory)
+	rg.POST("/reservations/:id/modifications", r.handler.requestModification)
+	rg.GET("/reservations/:id/modifications", r.handler.getModifications)
+	rg.PUT("/reservations/modifications/:id/approve", r.handler.approveModification)
+	rg.PUT("/reservations/modifications/:id/reject", r.handler.rejectModification)
+	rg.GET("/reservations/modifications/:id/history", r.handler.getModificationHistory)
+
+	rg.GET("/reservations/guest-stayed-with-host", r.handler.canUserRateHost)
+	rg.GET("/reservations/guest-stayed-in-room", r.handler.canUserRateRoom)
+
+	rg.GET("/reservations/history", r.handler.GetPastReservationsByGuest) 
+
+}
+
+type Handler struct{ service Service }
+
+func NewHandler(s Service) Handler { return Handler{s} }
+
+func (h *Handler) createReservationRequest(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "create-reservation-request-api")
+	defer span.End()
+
+	jwtString, err := util.GetJwtString(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if jwt.Role != util.Guest {
+		util.TEL.Error(rctx, "user is not guest", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
+
+	var dto CreateReservationRequestDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		util.TEL.Error(rctx, "failed binding JSON", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	req, res, err := h.service.CreateRequest(rctx, AuthContext{CallerID: jwt.ID, JWT: jwtString}, dto)
+	if err != nil {
+		util.TEL.Error(rctx, "failed creating reservation request", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, NewCreatedReservationRequestDTO(*req, res))
+}
+
+func (h *Handler) createGroupRequest(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "create-group-request-api")
+	defer span.End()
+
+	jwtString, err := util.GetJwtString(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if jwt.Role != util.Guest {
+		util.TEL.Error(rctx, "user is not guest", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
+
+	var dto CreateGroupRequestDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		util.TEL.Error(rctx, "failed binding JSON", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	group, reservations, err := h.service.CreateGroupRequest(rctx, AuthContext{CallerID: jwt.ID, JWT: jwtString}, dto)
+	if err != nil {
+		util.TEL.Error(rctx, "failed creating group request", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, NewRequestGroupDTO(*group, reservations))
+}
+
+func (h *Handler) getGroupRequest(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "get-group-request-api")
+	defer span.End()
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	group, err := h.service.GetGroupRequest(rctx, Actor{jwt.ID, jwt.Role}, uint(id))
+	if err != nil {
+		util.TEL.Error(rctx, "failed getting group request", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, NewRequestGroupDTO(*group, nil))
+}
+
+func (h *Handler) approveGroupRequest(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "approve-group-request-api")
+	defer span.End()
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if jwt.Role != util.Host {
+		util.TEL.Error(rctx, "user is not host", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into a number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	group, reservations, err := h.service.ApproveGroupRequest(rctx, jwt.ID, uint(id))
+	if err != nil {
+		util.TEL.Error(rctx, "could not accept group request", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, NewRequestGroupDTO(*group, reservations))
+}
+
+func (h *Handler) rejectGroupRequest(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "reject-group-request-api")
+	defer span.End()
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if jwt.Role != util.Host {
+		util.TEL.Error(rctx, "user is not host", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into a number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	if err := h.service.RejectGroupRequest(rctx, jwt.ID, uint(id)); err != nil {
+		util.TEL.Error(rctx, "could not reject group request", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "group request rejected successfully"})
+}
+
+func (h *Handler) withdrawGroupRequest(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "withdraw-group-request-api")
+	defer span.End()
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if jwt.Role != util.Guest {
+		util.TEL.Error(rctx, "user is not guest", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into a number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	if err := h.service.WithdrawGroupRequest(rctx, jwt.ID, uint(id)); err != nil {
+		util.TEL.Error(rctx, "could not withdraw group request", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
+
+func (h *Handler) findPendingRequestsByGuest(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "find-pending-requests-by-guest-api")
+	defer span.End()
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if jwt.Role != util.Guest {
+		util.TEL.Error(rctx, "user is not guest", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
+
+	query, err := parseListQuery(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed parsing listing query", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	requests, err := h.service.FindPendingRequestsByGuest(rctx, jwt.ID, query)
+	if err != nil {
+		util.TEL.Error(rctx, "failed finding pending requests by guest", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	util.TEL.Debug(rctx, "building response")
+
+	ctx.JSON(http.StatusOK, NewPageDTO(requests, NewReservationRequestDTO))
+}
+
+func (h *Handler) findPendingRequestsByRoom(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "find-pending-requests-by-room-api")
+	defer span.End()
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into a number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if jwt.Role != util.Host {
+		util.TEL.Error(rctx, "user is not host", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
+
+	query, err := parseListQuery(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed parsing listing query", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	requests, err := h.service.FindPendingRequestsByRoom(rctx, jwt.ID, uint(id), query)
+	if err != nil {
+		util.TEL.Error(rctx, "failed finding pending requests by room", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	util.TEL.Debug(rctx, "building response with guest cancellation counts", "requests", len(requests.Items))
+
+	result := NewPageDTO(requests, func(req ReservationRequest) ReservationRequestDTO {
+		cancelCount, cntErr := h.service.GetGuestCancellationCount(rctx, req.GuestID)
+		if cntErr != nil {
+			util.TEL.Warn(rctx, "could not fetch guest cancellation count; using 0", "guest_id", req.GuestID)
+			cancelCount = 0
+		}
+
+		return NewReservationRequestDTOWithCancellations(req, cancelCount)
+	})
+
+	util.TEL.Info(rctx, "returning pending requests with guest cancellation counts", "count", len(result.Items))
+	ctx.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) updateRequestByGuest(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "update-request-by-guest-api")
+	defer span.End()
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	jwtString, err := util.GetJwtString(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if jwt.Role != util.Guest {
+		util.TEL.Error(rctx, "user is not guest", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
+
+	var dto UpdateReservationRequestDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		util.TEL.Error(rctx, "failed binding JSON", err)
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	req, err := h.service.UpdateRequest(rctx, AuthContext{CallerID: jwt.ID, JWT: jwtString}, uint(id), dto)
+	if err != nil {
+		util.TEL.Error(rctx, "failed updating request by guest", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, NewReservationRequestDTO(*req))
+}
+
+func (h *Handler) deleteRequestByGuest(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "delete-request-by-guest-api")
+	defer span.End()
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if jwt.Role != util.Guest {
+		util.TEL.Error(rctx, "user is not host", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
+
+	err = h.service.DeleteRequest(rctx, jwt.ID, uint(id))
+	if err != nil {
+		util.TEL.Error(rctx, "failed deleting request by guest", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
+
+func (h *Handler) getRequestHistory(ctx *gin.Context) {
+	h.getHistory(ctx, "get-request-history-api", h.service.GetRequestHistory)
+}
+
+func (h *Handler) getReservationHistory(ctx *gin.Context) {
+	h.getHistory(ctx, "get-reservation-history-api", h.service.GetReservationHistory)
+}
+
+func (h *Handler) getModificationHistory(ctx *gin.Context) {
+	h.getHistory(ctx, "get-modification-history-api", h.service.GetModificationHistory)
+}
+
+func (h *Handler) getHistory(ctx *gin.Context, name string, find func(context.Context, Actor, uint) ([]StatusTransition, error)) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), name)
+	defer span.End()
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	transitions, err := find(rctx, Actor{jwt.ID, jwt.Role}, uint(id))
+	if err != nil {
+		util.TEL.Error(rctx, "failed getting status history", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	dtos := make([]StatusTransitionDTO, 0, len(transitions))
+	for _, t := range transitions {
+		dtos = append(dtos, NewStatusTransitionDTO(t))
+	}
+	ctx.JSON(http.StatusOK, dtos)
+}
+
+func (h *Handler) checkAvailability(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "check-availability-api")
+	defer span.End()
+
+	roomID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	fromStr := ctx.Query("from")
+	toStr := ctx.Query("to")
+
+	from, err := time.Parse("2006-01-02", fromStr)
+	if err != nil {
+		util.TEL.Error(rctx, "invalid 'from' date format (should be YYYY-MM-DD)", err, "date", from)
+		AbortError(ctx, ErrBadRequestCustom("invalid 'from' date format"))
+		return
+	}
+
+	to, err := time.Parse("2006-01-02", toStr)
+	if err != nil {
+		util.TEL.Error(rctx, "invalid 'to' date format (should be YYYY-MM-DD)", err, "date", from)
+		AbortError(ctx, ErrBadRequestCustom("invalid 'to' date format"))
+		return
+	}
+
+	// Anyone can check, but the caller's own holds don't count against them.
+	var callerID uint
+	if jwt, err := util.GetJwt(ctx); err == nil {
+		callerID = jwt.ID
+	}
+
+	available, err := h.service.IsRoomAvailable(rctx, callerID, uint(roomID), from, to)
+	if err != nil {
+		util.TEL.Error(rctx, "failed check if room is available in a date range", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"available": available})
+}
+
+func (h *Handler) getRoomCalendar(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "get-room-calendar-api")
+	defer span.End()
+
+	roomID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "could not get JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if jwt.Role != util.Host {
+		util.TEL.Error(rctx, "user is not host", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
+
+	from, err := time.Parse("2006-01-02", ctx.Query("from"))
+	if err != nil {
+		util.TEL.Error(rctx, "invalid 'from' date format (should be YYYY-MM-DD)", err, "date", ctx.Query("from"))
+		AbortError(ctx, ErrBadRequestCustom("invalid 'from' date format"))
+		return
+	}
+
+	to, err := time.Parse("2006-01-02", ctx.Query("to"))
+	if err != nil {
+		util.TEL.Error(rctx, "invalid 'to' date format (should be YYYY-MM-DD)", err, "date", ctx.Query("to"))
+		AbortError(ctx, ErrBadRequestCustom("invalid 'to' date format"))
+		return
+	}
+
+	calendar, err := h.service.GetRoomCalendar(rctx, jwt.ID, uint(roomID), from, to)
+	if err != nil {
+		util.TEL.Error(rctx, "failed getting room calendar", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, NewRoomCalendarDTO(*calendar))
+}
+
+func (h *Handler) createFeedToken(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "create-feed-token-api")
+	defer span.End()
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	var dto CreateFeedTokenDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		util.TEL.Error(rctx, "failed binding JSON", err)
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	t, token, err := h.service.CreateFeedToken(rctx, Actor{jwt.ID, jwt.Role}, dto.Kind, dto.RoomID)
+	if err != nil {
+		util.TEL.Error(rctx, "failed creating feed token", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	res := NewFeedTokenDTO(*t)
+	res.Token = token
+	res.URL = strings.TrimSuffix(ctx.Request.URL.Path, "/") + "/calendar.ics?token=" + token
+	ctx.JSON(http.StatusCreated, res)
+}
+
+func (h *Handler) getFeedTokens(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "get-feed-tokens-api")
+	defer span.End()
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	tokens, err := h.service.GetFeedTokens(rctx, jwt.ID)
+	if err != nil {
+		util.TEL.Error(rctx, "failed getting feed tokens", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	dtos := make([]FeedTokenDTO, 0, len(tokens))
+	for _, t := range tokens {
+		dtos = append(dtos, NewFeedTokenDTO(t))
+	}
+	ctx.JSON(http.StatusOK, dtos)
+}
+
+func (h *Handler) revokeFeedToken(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "revoke-feed-token-api")
+	defer span.End()
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if err := h.service.RevokeFeedToken(rctx, jwt.ID, uint(id)); err != nil {
+		util.TEL.Error(rctx, "failed revoking feed token", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) getFeed(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "get-feed-api")
+	defer span.End()
+
+	feed, err := h.service.GetFeed(rctx, ctx.Query("token"))
+	if err != nil {
+		util.TEL.Error(rctx, "failed getting feed", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "private, no-cache")
+	ctx.Data(http.StatusOK, "text/calendar; charset=utf-8", feed.ICS(time.Now()))
+}
+
+func (h *Handler) createHold(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "create-hold-api")
+	defer span.End()
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if jwt.Role != util.Guest {
+		util.TEL.Error(rctx, "user is not guest", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
+
+	var dto CreateHoldDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		util.TEL.Error(rctx, "failed binding JSON", err)
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	hold, err := h.service.CreateHold(rctx, jwt.ID, dto)
+	if err != nil {
+		util.TEL.Error(rctx, "failed holding dates", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, NewHoldDTO(*hold))
+}
+
+func (h *Handler) releaseHold(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "release-hold-api")
+	defer span.End()
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if err := h.service.ReleaseHold(rctx, jwt.ID, uint(id)); err != nil {
+		util.TEL.Error(rctx, "failed releasing hold", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) joinWaitlist(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "join-waitlist-api")
+	defer span.End()
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if jwt.Role != util.Guest {
+		util.TEL.Error(rctx, "user is not guest", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
+
+	var dto CreateWaitlistEntryDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		util.TEL.Error(rctx, "failed binding JSON", err)
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	entry, err := h.service.JoinWaitlist(rctx, jwt.ID, dto)
+	if err != nil {
+		util.TEL.Error(rctx, "failed joining waitlist", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, NewWaitlistEntryDTO(*entry))
+}
+
+func (h *Handler) getWaitlistEntries(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "get-waitlist-entries-api")
+	defer span.End()
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	entries, err := h.service.GetWaitlistEntries(rctx, jwt.ID)
+	if err != nil {
+		util.TEL.Error(rctx, "failed getting waitlist entries", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	dtos := make([]WaitlistEntryDTO, 0, len(entries))
+	for _, e := range entries {
+		dtos = append(dtos, NewWaitlistEntryDTO(e))
+	}
+	ctx.JSON(http.StatusOK, dtos)
+}
+
+func (h *Handler) leaveWaitlist(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "leave-waitlist-api")
+	defer span.End()
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if err := h.service.LeaveWaitlist(rctx, jwt.ID, uint(id)); err != nil {
+		util.TEL.Error(rctx, "failed leaving waitlist", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *Handler) claimWaitlistOffer(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "claim-waitlist-offer-api")
+	defer span.End()
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	jwtString, err := util.GetJwtString(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
 
-func NewRoute(handler Handler) *Route { return &Route{handler} }
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
 
-func (r *Route) Route(rg *gin.RouterGroup) {
-	rg.POST("/req", r.handler.createReservationRequest)
-	rg.GET("/req/user", r.handler.findPendingRequestsByGuest)
-	rg.GET("/req/room/:id", r.handler.findPendingRequestsByRoom)
-	rg.DELETE("/req/:id", r.handler.deleteRequestByGuest)
+	if jwt.Role != util.Guest {
+		util.TEL.Error(rctx, "user is not guest", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
 
-	rg.GET("/room/:id/availability", r.handler.checkAvailability)
+	req, res, err := h.service.ClaimWaitlistOffer(rctx, AuthContext{CallerID: jwt.ID, JWT: jwtString}, uint(id))
+	if err != nil {
+		util.TEL.Error(rctx, "failed claiming waitlist offer", err)
+		AbortError(ctx, err)
+		return
+	}
 
-	rg.GET("/reservations/guest/active", r.handler.getActiveGuestReservations)
-	rg.GET("/reservations/host/active", r.handler.getActiveHostReservations)
+	ctx.JSON(http.StatusCreated, NewCreatedReservationRequestDTO(*req, res))
+}
 
-	rg.PUT("/req/:id/reject", r.handler.rejectReservationRequest)
-	rg.PUT("/req/:id/approve", r.handler.approveReservationRequest)
-	rg.DELETE("/reservations/:id/cancel", r.handler.cancelReservation)
+func (h *Handler) getCancellationPolicy(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "get-cancellation-policy-api")
+	defer span.End()
 
-	rg.GET("/reservations/guest-stayed-with-host", r.handler.canUserRateHost)
-	rg.GET("/reservations/guest-stayed-in-room", r.handler.canUserRateRoom)
+	roomID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
 
-	rg.GET("/reservations/history", r.handler.GetPastReservationsByGuest) 
+	policy, err := h.service.GetCancellationPolicy(rctx, uint(roomID))
+	if err != nil {
+		util.TEL.Error(rctx, "failed getting cancellation policy", err)
+		AbortError(ctx, err)
+		return
+	}
 
+	ctx.JSON(http.StatusOK, NewCancellationPolicyDTO(*policy))
 }
 
-type Handler struct{ service Service }
-
-func NewHandler(s Service) Handler { return Handler{s} }
-
-func (h *Handler) createReservationRequest(ctx *gin.Context) {
-	util.TEL.Push(ctx.Request.Context(), "create-reservation-request-api")
-	defer util.TEL.Pop()
+func (h *Handler) setCancellationPolicy(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "set-cancellation-policy-api")
+	defer span.End()
 
-	jwtString, err := util.GetJwtString(ctx)
+	roomID, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		util.TEL.Error("failed fetching JWT", err)
-		AbortError(ctx, ErrUnauthenticated)
+		util.TEL.Error(rctx, "could not parse request param id into number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
 		return
 	}
 
 	jwt, err := util.GetJwt(ctx)
 	if err != nil {
-		util.TEL.Error("failed fetching JWT", err)
+		util.TEL.Error(rctx, "could not get JWT", err)
 		AbortError(ctx, ErrUnauthenticated)
 		return
 	}
 
-	if jwt.Role != util.Guest {
-		util.TEL.Error("user is not guest", nil, "role", jwt.Role)
+	if jwt.Role != util.Host {
+		util.TEL.Error(rctx, "user is not host", nil, "role", jwt.Role)
 		AbortError(ctx, ErrUnauthorized)
 		return
 	}
 
-	var dto CreateReservationRequestDTO
+	var dto SetCancellationPolicyDTO
 	if err := ctx.ShouldBindJSON(&dto); err != nil {
-		util.TEL.Error("failed binding JSON", err)
+		util.TEL.Error(rctx, "failed binding JSON", err)
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	terms, err := NewCancellationTerms(dto.Kind, dto.Tiers)
+	if err != nil {
+		util.TEL.Error(rctx, "invalid cancellation policy", err)
 		AbortError(ctx, err)
 		return
 	}
 
-	reservation, err := h.service.CreateRequest(util.TEL.Ctx(), AuthContext{CallerID: jwt.ID, JWT: jwtString}, dto)
+	policy, err := h.service.SetCancellationPolicy(rctx, jwt.ID, uint(roomID), terms)
 	if err != nil {
-		util.TEL.Error("failed creating reservation request", err)
+		util.TEL.Error(rctx, "failed setting cancellation policy", err)
 		AbortError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, reservation)
+	ctx.JSON(http.StatusOK, NewCancellationPolicyDTO(*policy))
 }
 
-func (h *Handler) findPendingRequestsByGuest(ctx *gin.Context) {
-	util.TEL.Push(ctx.Request.Context(), "find-pending-requests-by-guest-api")
-	defer util.TEL.Pop()
+func (h *Handler) getActiveGuestReservations(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "get-active-reservations-for-guest")
+	defer span.End()
 
 	jwt, err := util.GetJwt(ctx)
 	if err != nil {
-		util.TEL.Error("failed fetching JWT", err)
+		util.TEL.Error(rctx, "failed fetching JWT", err)
 		AbortError(ctx, ErrUnauthenticated)
 		return
 	}
 
 	if jwt.Role != util.Guest {
-		util.TEL.Error("user is not guest", nil, "role", jwt.Role)
+		util.TEL.Error(rctx, "user is not host", nil, "role", jwt.Role)
 		AbortError(ctx, ErrUnauthorized)
 		return
 	}
 
-	requests, err := h.service.FindPendingRequestsByGuest(util.TEL.Ctx(), jwt.ID)
+	query, err := parseListQuery(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed parsing listing query", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	reservations, err := h.service.GetActiveGuestReservations(rctx, jwt.ID, query)
 	if err != nil {
-		util.TEL.Error("failed finding pending requests by guest", err)
+		util.TEL.Error(rctx, "could not get active guest reservations", err)
 		AbortError(ctx, err)
 		return
 	}
 
-	util.TEL.Debug("building response")
+	ctx.JSON(http.StatusOK, NewPageDTO(reservations, NewReservationDTO))
+}
+
+func (h *Handler) getActiveHostReservations(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "get-active-host-reservations-api")
+	defer span.End()
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if jwt.Role != util.Host {
+		util.TEL.Error(rctx, "user is not host", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
 
-	result := make([]ReservationRequestDTO, 0)
-	for _, req := range requests {
-		result = append(result, NewReservationRequestDTO(req))
+	query, err := parseListQuery(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed parsing listing query", err)
+		AbortError(ctx, err)
+		return
 	}
 
-	ctx.JSON(http.StatusOK, result)
+	reservations, err := h.service.GetActiveHostReservations(rctx, jwt.ID, query)
+	if err != nil {
+		util.TEL.Error(rctx, "could not get active host reservations", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, NewPageDTO(reservations, NewReservationDTO))
 }
 
-func (h *Handler) findPendingRequestsByRoom(ctx *gin.Context) {
-	util.TEL.Push(ctx.Request.Context(), "find-pending-requests-by-room-api")
-	defer util.TEL.Pop()
+func (h *Handler) rejectReservationRequest(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "reject-reservation-request")
+	defer span.End()
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
+
+	if jwt.Role != util.Host {
+		util.TEL.Error(rctx, "user is not host", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
 
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		util.TEL.Error("could not parse request param id into a number", err, "id", ctx.Param("id"))
+		util.TEL.Error(rctx, "could not parse request param id into a number", err, "id", ctx.Param("id"))
 		AbortError(ctx, ErrBadRequest)
 		return
 	}
 
+	jwt_string, _ := util.GetJwtString(ctx)
+	err = h.service.RejectReservationRequest(rctx, jwt.ID, uint(id), jwt_string)
+	if err != nil {
+		util.TEL.Error(rctx, "could not reject reservation request", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "reservation request rejected successfully"})
+}
+
+func (h *Handler) approveReservationRequest(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "approve-reservation-request")
+	defer span.End()
+
 	jwt, err := util.GetJwt(ctx)
 	if err != nil {
-		util.TEL.Error("failed fetching JWT", err)
+		util.TEL.Error(rctx, "failed fetching JWT", err)
 		AbortError(ctx, ErrUnauthenticated)
 		return
 	}
 
 	if jwt.Role != util.Host {
-		util.TEL.Error("user is not host", nil, "role", jwt.Role)
+		util.TEL.Error(rctx, "user is not host", nil, "role", jwt.Role)
 		AbortError(ctx, ErrUnauthorized)
 		return
 	}
 
-	requests, err := h.service.FindPendingRequestsByRoom(util.TEL.Ctx(), jwt.ID, uint(id))
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse request param id into a number", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	jwt_string, _ := util.GetJwtString(ctx)
+	err = h.service.ApproveReservationRequest(rctx, jwt.ID, uint(id), jwt_string)
 	if err != nil {
-		util.TEL.Error("failed finding pending requests by room", err)
+		util.TEL.Error(rctx, "could not accept reservation request", err)
 		AbortError(ctx, err)
 		return
 	}
 
-	util.TEL.Debug("building response with guest cancellation counts", "requests", len(requests))
+	ctx.JSON(http.StatusOK, gin.H{"message": "reservation request approved successfully"})
+}
 
-	result := make([]ReservationRequestDTO, 0, len(requests))
-	for _, req := range requests {
-		cancelCount, cntErr := h.service.GetGuestCancellationCount(util.TEL.Ctx(), req.GuestID)
-		if cntErr != nil {
-			util.TEL.Warn("could not fetch guest cancellation count; using 0", "guest_id", req.GuestID)
-			cancelCount = 0
-		}
+func (h *Handler) decideRequests(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "decide-requests-api")
+	defer span.End()
 
-		result = append(result, NewReservationRequestDTOWithCancellations(req, cancelCount))
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
 	}
 
-	util.TEL.Info("returning pending requests with guest cancellation counts", "count", len(result))
-	ctx.JSON(http.StatusOK, result)
+	if jwt.Role != util.Host {
+		util.TEL.Error(rctx, "user is not host", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
+		return
+	}
+
+	var dto DecideRequestsDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		util.TEL.Error(rctx, "failed binding JSON", err)
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	results, err := h.service.DecideRequests(rctx, jwt.ID, dto.Decisions)
+	if err != nil {
+		util.TEL.Error(rctx, "could not decide requests", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, NewDecisionResultsDTO(results))
 }
 
-func (h *Handler) deleteRequestByGuest(ctx *gin.Context) {
-	util.TEL.Push(ctx.Request.Context(), "delete-request-by-guest-api")
-	defer util.TEL.Pop()
+func (h *Handler) cancelReservation(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "cancel-reservation-api")
+	defer span.End()
 
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		util.TEL.Error("could not parse request param id into number", err, "id", ctx.Param("id"))
+		util.TEL.Error(rctx, "could not parse reservation id", err, "id", ctx.Param("id"))
 		AbortError(ctx, ErrBadRequest)
 		return
 	}
 
 	jwt, err := util.GetJwt(ctx)
 	if err != nil {
-		util.TEL.Error("failed fetching JWT", err)
+		util.TEL.Error(rctx, "could not get JWT", err)
 		AbortError(ctx, ErrUnauthenticated)
 		return
 	}
 
 	if jwt.Role != util.Guest {
-		util.TEL.Error("user is not host", nil, "role", jwt.Role)
+		util.TEL.Error(rctx, "user is not guest", nil, "role", jwt.Role)
 		AbortError(ctx, ErrUnauthorized)
 		return
 	}
 
-	err = h.service.DeleteRequest(util.TEL.Ctx(), jwt.ID, uint(id))
+	jwt_string, _ := util.GetJwtString(ctx)
+	result, err := h.service.CancelReservation(rctx, jwt.ID, uint(id), jwt_string)
 	if err != nil {
-		util.TEL.Error("failed deleting request by guest", err)
+		util.TEL.Error(rctx, "failed to cancel reservation", err)
 		AbortError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusNoContent, nil)
+	ctx.JSON(http.StatusOK, NewCancellationDTO(*result))
 }
 
-func (h *Handler) checkAvailability(ctx *gin.Context) {
-	util.TEL.Push(ctx.Request.Context(), "check-availability-api")
-	defer util.TEL.Pop()
+func (h *Handler) hostCancelReservation(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "host-cancel-reservation-api")
+	defer span.End()
 
-	roomID, err := strconv.Atoi(ctx.Param("id"))
+	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		util.TEL.Error("could not parse request param id into number", err, "id", ctx.Param("id"))
+		util.TEL.Error(rctx, "could not parse reservation id", err, "id", ctx.Param("id"))
 		AbortError(ctx, ErrBadRequest)
 		return
 	}
 
-	fromStr := ctx.Query("from")
-	toStr := ctx.Query("to")
-
-	from, err := time.Parse("2006-01-02", fromStr)
+	jwt, err := util.GetJwt(ctx)
 	if err != nil {
-		util.TEL.Error("invalid 'from' date format (should be YYYY-MM-DD)", err, "date", from)
-		AbortError(ctx, ErrBadRequestCustom("invalid 'from' date format"))
+		util.TEL.Error(rctx, "could not get JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
 		return
 	}
 
-	to, err := time.Parse("2006-01-02", toStr)
-	if err != nil {
-		util.TEL.Error("invalid 'to' date format (should be YYYY-MM-DD)", err, "date", from)
-		AbortError(ctx, ErrBadRequestCustom("invalid 'to' date format"))
+	if jwt.Role != util.Host {
+		util.TEL.Error(rctx, "user is not host", nil, "role", jwt.Role)
+		AbortError(ctx, ErrUnauthorized)
 		return
 	}
 
-	available, err := h.service.AreThereReservationsOnDays(util.TEL.Ctx(), uint(roomID), from, to)
+	var dto HostCancelReservationDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		util.TEL.Error(rctx, "failed binding JSON", err)
+		AbortError(ctx, ErrBadRequestCustom("a reason is required"))
+		return
+	}
+
+	result, err := h.service.HostCancelReservation(rctx, jwt.ID, uint(id), dto.Reason)
 	if err != nil {
-		util.TEL.Error("failed check if room has reservation in a date range", err)
+		util.TEL.Error(rctx, "failed to cancel reservation", err)
 		AbortError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"available": !available})
+	ctx.JSON(http.StatusOK, NewCancellationDTO(*result))
 }
 
-func (h *Handler) getActiveGuestReservations(ctx *gin.Context) {
-	util.TEL.Push(ctx, "get-active-reservations-for-guest")
-	defer util.TEL.Pop()
+func (h *Handler) checkIn(ctx *gin.Context) {
+	h.recordStay(ctx, "check-in-api", h.service.CheckIn)
+}
+
+func (h *Handler) checkOut(ctx *gin.Context) {
+	h.recordStay(ctx, "check-out-api", h.service.CheckOut)
+}
+
+func (h *Handler) markNoShow(ctx *gin.Context) {
+	h.recordStay(ctx, "mark-no-show-api", h.service.MarkNoShow)
+}
+
+// recordStay handles the host's check-in, check-out and no-show calls, which
+// only differ in what they record.
+func (h *Handler) recordStay(ctx *gin.Context, spanName string, record func(context.Context, uint, uint) (*Reservation, error)) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), spanName)
+	defer span.End()
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse reservation id", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
 
 	jwt, err := util.GetJwt(ctx)
 	if err != nil {
-		util.TEL.Error("failed fetching JWT", err)
+		util.TEL.Error(rctx, "could not get JWT", err)
 		AbortError(ctx, ErrUnauthenticated)
 		return
 	}
 
-	if jwt.Role != util.Guest {
-		util.TEL.Error("user is not host", nil, "role", jwt.Role)
+	if jwt.Role != util.Host {
+		util.TEL.Error(rctx, "user is not host", nil, "role", jwt.Role)
 		AbortError(ctx, ErrUnauthorized)
 		return
 	}
 
-	reservations, err := h.service.GetActiveGuestReservations(ctx, jwt.ID)
+	reservation, err := record(rctx, jwt.ID, uint(id))
 	if err != nil {
-		util.TEL.Error("could not get active guest reservations", err)
+		util.TEL.Error(rctx, "failed to record stay", err)
 		AbortError(ctx, err)
 		return
 	}
 
-	result := make([]ReservationDTO, 0)
-	for _, res := range reservations {
-		result = append(result, NewReservationDTO(res))
-	}
-
-	ctx.JSON(http.StatusOK, result)
+	ctx.JSON(http.StatusOK, NewReservationDTO(*reservation))
 }
 
-func (h *Handler) getActiveHostReservations(ctx *gin.Context) {
-	util.TEL.Push(ctx.Request.Context(), "get-active-host-reservations-api")
-	defer util.TEL.Pop()
+func (h *Handler) requestModification(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "request-modification-api")
+	defer span.End()
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse reservation id", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	jwtString, err := util.GetJwtString(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
+	}
 
 	jwt, err := util.GetJwt(ctx)
 	if err != nil {
-		util.TEL.Error("failed fetching JWT", err)
+		util.TEL.Error(rctx, "failed fetching JWT", err)
 		AbortError(ctx, ErrUnauthenticated)
 		return
 	}
 
-	if jwt.Role != util.Host {
-		util.TEL.Error("user is not host", nil, "role", jwt.Role)
+	if jwt.Role != util.Guest {
+		util.TEL.Error(rctx, "user is not guest", nil, "role", jwt.Role)
 		AbortError(ctx, ErrUnauthorized)
 		return
 	}
 
-	reservations, err := h.service.GetActiveHostReservations(ctx, jwt.ID)
+	var dto ModifyReservationDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		util.TEL.Error(rctx, "failed binding JSON", err)
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	m, err := h.service.RequestModification(rctx, AuthContext{CallerID: jwt.ID, JWT: jwtString}, uint(id), dto)
 	if err != nil {
-		util.TEL.Error("could not get active host reservations", err)
+		util.TEL.Error(rctx, "failed requesting modification", err)
 		AbortError(ctx, err)
 		return
 	}
 
-	result := make([]ReservationDTO, 0)
-	for _, res := range reservations {
-		result = append(result, NewReservationDTO(res))
+	ctx.JSON(http.StatusCreated, NewModificationDTO(*m))
+}
+
+func (h *Handler) getModifications(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "get-modifications-api")
+	defer span.End()
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		util.TEL.Error(rctx, "could not parse reservation id", err, "id", ctx.Param("id"))
+		AbortError(ctx, ErrBadRequest)
+		return
+	}
+
+	jwt, err := util.GetJwt(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed fetching JWT", err)
+		AbortError(ctx, ErrUnauthenticated)
+		return
 	}
 
-	ctx.JSON(http.StatusOK, result)
+	modifications, err := h.service.GetModifications(rctx, jwt.ID, uint(id))
+	if err != nil {
+		util.TEL.Error(rctx, "failed getting modifications", err)
+		AbortError(ctx, err)
+		return
+	}
+
+	dtos := make([]ModificationDTO, 0, len(modifications))
+	for _, m := range modifications {
+		dtos = append(dtos, NewModificationDTO(m))
+	}
+	ctx.JSON(http.StatusOK, dtos)
 }
 
-func (h *Handler) rejectReservationRequest(ctx *gin.Context) {
-	util.TEL.Push(ctx, "reject-reservation-request")
-	defer util.TEL.Pop()
+func (h *Handler) approveModification(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "approve-modification-api")
+	defer span.End()
 
 	jwt, err := util.GetJwt(ctx)
 	if err != nil {
-		util.TEL.Error("failed fetching JWT", err)
+		util.TEL.Error(rctx, "failed fetching JWT", err)
 		AbortError(ctx, ErrUnauthenticated)
 		return
 	}
 
 	if jwt.Role != util.Host {
-		util.TEL.Error("user is not host", nil, "role", jwt.Role)
+		util.TEL.Error(rctx, "user is not host", nil, "role", jwt.Role)
 		AbortError(ctx, ErrUnauthorized)
 		return
 	}
 
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		util.TEL.Error("could not parse request param id into a number", err, "id", ctx.Param("id"))
+		util.TEL.Error(rctx, "could not parse modification id", err, "id", ctx.Param("id"))
 		AbortError(ctx, ErrBadRequest)
 		return
 	}
 
-	jwt_string, _ := util.GetJwtString(ctx)
-	err = h.service.RejectReservationRequest(util.TEL.Ctx(), jwt.ID, uint(id), jwt_string)
+	m, err := h.service.ApproveModification(rctx, jwt.ID, uint(id))
 	if err != nil {
-		util.TEL.Error("could not reject reservation request", err)
+		util.TEL.Error(rctx, "could not approve modification", err)
 		AbortError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "reservation request rejected successfully"})
+	ctx.JSON(http.StatusOK, NewModificationDTO(*m))
 }
 
-func (h *Handler) approveReservationRequest(ctx *gin.Context) {
-	util.TEL.Push(ctx, "approve-reservation-request")
-	defer util.TEL.Pop()
+func (h *Handler) rejectModification(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "reject-modification-api")
+	defer span.End()
 
 	jwt, err := util.GetJwt(ctx)
 	if err != nil {
-		util.TEL.Error("failed fetching JWT", err)
+		util.TEL.Error(rctx, "failed fetching JWT", err)
 		AbortError(ctx, ErrUnauthenticated)
 		return
 	}
 
 	if jwt.Role != util.Host {
-		util.TEL.Error("user is not host", nil, "role", jwt.Role)
+		util.TEL.Error(rctx, "user is not host", nil, "role", jwt.Role)
 		AbortError(ctx, ErrUnauthorized)
 		return
 	}
 
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		util.TEL.Error("could not parse request param id into a number", err, "id", ctx.Param("id"))
+		util.TEL.Error(rctx, "could not parse modification id", err, "id", ctx.Param("id"))
 		AbortError(ctx, ErrBadRequest)
 		return
 	}
 
-	jwt_string, _ := util.GetJwtString(ctx)
-	err = h.service.ApproveReservationRequest(util.TEL.Ctx(), jwt.ID, uint(id), jwt_string)
-	if err != nil {
-		util.TEL.Error("could not accept reservation request", err)
+	if err := h.service.RejectModification(rctx, jwt.ID, uint(id)); err != nil {
+		util.TEL.Error(rctx, "could not reject modification", err)
 		AbortError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "reservation request approved successfully"})
+	ctx.JSON(http.StatusOK, gin.H{"message": "modification rejected"})
 }
 
-func (h *Handler) cancelReservation(ctx *gin.Context) {
-	util.TEL.Push(ctx.Request.Context(), "cancel-reservation-api")
-	defer util.TEL.Pop()
+// getHostCancellationCount is open to the host themselves and to admins.
+func (h *Handler) getHostCancellationCount(ctx *gin.Context) {
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "get-host-cancellation-count-api")
+	defer span.End()
 
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		util.TEL.Error("could not parse reservation id", err, "id", ctx.Param("id"))
+		util.TEL.Error(rctx, "could not parse host id", err, "id", ctx.Param("id"))
 		AbortError(ctx, ErrBadRequest)
 		return
 	}
 
 	jwt, err := util.GetJwt(ctx)
 	if err != nil {
-		util.TEL.Error("could not get JWT", err)
+		util.TEL.Error(rctx, "could not get JWT", err)
 		AbortError(ctx, ErrUnauthenticated)
 		return
 	}
 
-	if jwt.Role != util.Guest {
-		util.TEL.Error("user is not guest", nil, "role", jwt.Role)
+	if jwt.Role != util.Admin && !(jwt.Role == util.Host && jwt.ID == uint(id)) {
+		util.TEL.Error(rctx, "user can't see this host's cancellations", nil, "role", jwt.Role, "user_id", jwt.ID, "host_id", id)
 		AbortError(ctx, ErrUnauthorized)
 		return
 	}
 
-	jwt_string, _ := util.GetJwtString(ctx)
-	err = h.service.CancelReservation(util.TEL.Ctx(), jwt.ID, uint(id), jwt_string)
+	count, err := h.service.GetHostCancellationCount(rctx, uint(id))
 	if err != nil {
-		util.TEL.Error("failed to cancel reservation", err)
+		util.TEL.Error(rctx, "could not count host cancellations", err)
 		AbortError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusNoContent, nil)
+	ctx.JSON(http.StatusOK, CancellationCountDTO{HostID: uint(id), Count: count})
 }
 
 func (h *Handler) canUserRateHost(ctx *gin.Context) {
-    util.TEL.Push(ctx.Request.Context(), "can-user-rate-host-api")
-    defer util.TEL.Pop()
+    rctx, span := util.TEL.Start(ctx.Request.Context(), "can-user-rate-host-api")
+    defer span.End()
 
     guestIDStr := ctx.Query("guestId")
     hostIDStr := ctx.Query("hostId")
 
     guestID64, err := strconv.ParseUint(guestIDStr, 10, 64)
     if err != nil || guestID64 == 0 {
-        util.TEL.Error("invalid guestId", err, "guestId", guestIDStr)
+        util.TEL.Error(rctx, "invalid guestId", err, "guestId", guestIDStr)
         AbortError(ctx, ErrBadRequestCustom("invalid guestId"))
         return
     }
     hostID64, err := strconv.ParseUint(hostIDStr, 10, 64)
     if err != nil || hostID64 == 0 {
-        util.TEL.Error("invalid hostId", err, "hostId", hostIDStr)
+        util.TEL.Error(rctx, "invalid hostId", err, "hostId", hostIDStr)
         AbortError(ctx, ErrBadRequestCustom("invalid hostId"))
         return
     }
 
-    ok, err := h.service.CanUserRateHost(util.TEL.Ctx(), uint(guestID64), uint(hostID64))
+    ok, err := h.service.CanUserRateHost(rctx, uint(guestID64), uint(hostID64))
     if err != nil {
-        util.TEL.Error("failed to check if user can rate host", err)
+        util.TEL.Error(rctx, "failed to check if user can rate host", err)
         AbortError(ctx, err)
         return
     }
@@ -434,28 +1466,28 @@ func (h *Handler) canUserRateHost(ctx *gin.Context) {
 }
 
 func (h *Handler) canUserRateRoom(ctx *gin.Context) {
-	util.TEL.Push(ctx.Request.Context(), "can-user-rate-room-api")
-	defer util.TEL.Pop()
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "can-user-rate-room-api")
+	defer span.End()
 
 	guestIDStr := ctx.Query("guestId")
 	roomIDStr := ctx.Query("roomId")
 
 	guestID64, err := strconv.ParseUint(guestIDStr, 10, 64)
 	if err != nil || guestID64 == 0 {
-		util.TEL.Error("invalid guestId", err, "guestId", guestIDStr)
+		util.TEL.Error(rctx, "invalid guestId", err, "guestId", guestIDStr)
 		AbortError(ctx, ErrBadRequestCustom("invalid guestId"))
 		return
 	}
 	roomID64, err := strconv.ParseUint(roomIDStr, 10, 64)
 	if err != nil || roomID64 == 0 {
-		util.TEL.Error("invalid roomId", err, "roomId", roomIDStr)
+		util.TEL.Error(rctx, "invalid roomId", err, "roomId", roomIDStr)
 		AbortError(ctx, ErrBadRequestCustom("invalid roomId"))
 		return
 	}
 
-	ok, err := h.service.CanUserRateRoom(util.TEL.Ctx(), uint(guestID64), uint(roomID64))
+	ok, err := h.service.CanUserRateRoom(rctx, uint(guestID64), uint(roomID64))
 	if err != nil {
-		util.TEL.Error("service error", err)
+		util.TEL.Error(rctx, "service error", err)
 		AbortError(ctx, err)
 		return
 	}
@@ -464,32 +1496,98 @@ func (h *Handler) canUserRateRoom(ctx *gin.Context) {
 }
 
 func (h *Handler) GetPastReservationsByGuest(ctx *gin.Context) {
-	util.TEL.Push(ctx.Request.Context(), "get-past-reservations-api")
-	defer util.TEL.Pop()
+	rctx, span := util.TEL.Start(ctx.Request.Context(), "get-past-reservations-api")
+	defer span.End()
 
 	jwt, err := util.GetJwt(ctx)
 	if err != nil {
-		util.TEL.Error("could not get JWT", err)
+		util.TEL.Error(rctx, "could not get JWT", err)
 		AbortError(ctx, ErrUnauthenticated)
 		return
 	}
 
 	if jwt.Role != util.Guest {
-		util.TEL.Error("user is not guest", nil, "role", jwt.Role)
+		util.TEL.Error(rctx, "user is not guest", nil, "role", jwt.Role)
 		AbortError(ctx, ErrUnauthorized)
 		return
 	}
 
 	before := time.Now().UTC()
-	util.TEL.Info("fetching past reservations for guest", "guest_id", jwt.ID, "before", before)
+	util.TEL.Info(rctx, "fetching past reservations for guest", "guest_id", jwt.ID, "before", before)
+
+	query, err := parseListQuery(ctx)
+	if err != nil {
+		util.TEL.Error(rctx, "failed parsing listing query", err)
+		AbortError(ctx, err)
+		return
+	}
 
-	reservations, err := h.service.GetPastReservationsByGuest(util.TEL.Ctx(), uint(jwt.ID), before)
+	reservations, err := h.service.GetPastReservationsByGuest(rctx, uint(jwt.ID), before, query)
 	if err != nil {
-		util.TEL.Error("failed to get past reservations", err, "guest_id", jwt.ID)
+		util.TEL.Error(rctx, "failed to get past reservations", err, "guest_id", jwt.ID)
+		if _, ok := err.(*APIError); ok {
+			AbortError(ctx, err)
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch past reservations"})
 		return
 	}
 
-	util.TEL.Debug("returning past reservations to client", "count", len(reservations))
-	ctx.JSON(http.StatusOK, reservations)
+	util.TEL.Debug(rctx, "returning past reservations to client", "count", len(reservations.Items))
+	ctx.JSON(http.StatusOK, NewPageDTO(reservations, NewReservationDTO))
+}
+
+// parseListQuery reads the paging and filtering parameters shared by the
+// listing endpoints:
+//
+//	limit, cursor, sort, status (comma separated), roomId, from, to (YYYY-MM-DD)
+func parseListQuery(ctx *gin.Context) (ListQuery, error) {
+	var query ListQuery
+	query.Cursor = ctx.Query("cursor")
+	query.Sort = ctx.Query("sort")
+
+	if s := ctx.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil {
+			return query, ErrBadRequestCustom("invalid 'limit'")
+		}
+		query.Limit = limit
+	}
+
+	if s := ctx.Query("status"); s != "" {
+		for _, status := range strings.Split(s, ",") {
+			switch status := ReservationRequestStatus(strings.TrimSpace(status)); status {
+			case Pending, Accepted, Rejected, Expired, Withdrawn:
+				query.Statuses = append(query.Statuses, status)
+			default:
+				return query, ErrBadRequestCustom("invalid 'status' " + string(status))
+			}
+		}
+	}
+
+	if s := ctx.Query("roomId"); s != "" {
+		roomID, err := strconv.ParseUint(s, 10, 0)
+		if err != nil {
+			return query, ErrBadRequestCustom("invalid 'roomId'")
+		}
+		query.RoomID = uint(roomID)
+	}
+
+	if s := ctx.Query("from"); s != "" {
+		from, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return query, ErrBadRequestCustom("invalid 'from' date format")
+		}
+		query.From = from
+	}
+
+	if s := ctx.Query("to"); s != "" {
+		to, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return query, ErrBadRequestCustom("invalid 'to' date format")
+		}
+		query.To = to
+	}
+
+	return query, nil
 }
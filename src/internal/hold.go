@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"bookem-reservation-service/config"
+	"bookem-reservation-service/util"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	holdsCreated = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "reservation_holds_created_total",
+			Help: "Holds guests put on a room's dates while making a request",
+		},
+	)
+
+	holdsEnded = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "reservation_holds_ended_total",
+			Help: "Holds that ended, by whether they were converted into a request, released or expired",
+		},
+		[]string{"outcome"}, // converted, released or expired
+	)
+
+	registerHoldMetrics sync.Once
+)
+
+const (
+	// DefaultHoldTime is how long a hold lasts if the guest doesn't ask for
+	// less or more.
+	DefaultHoldTime = 10 * time.Minute
+	// MaxHoldTime is the longest a hold can last.
+	MaxHoldTime = 30 * time.Minute
+	// MaxActiveHolds is how many holds a guest can have at once.
+	MaxActiveHolds = 3
+)
+
+type HoldStatus string
+
+const (
+	HoldActive    HoldStatus = "active"
+	HoldConverted HoldStatus = "converted" // The guest made a request for the dates
+	HoldReleased  HoldStatus = "released"  // The guest gave the dates up
+	HoldExpired   HoldStatus = "expired"
+)
+
+// Hold keeps a room's dates for a guest for a few minutes, while the guest
+// makes a request for them. Until ExpiresAt nobody else can request the
+// dates, and they aren't available to anyone else.
+type Hold struct {
+	ID        uint       `gorm:"primaryKey"`
+	RoomID    uint       `gorm:"not null"`
+	GuestID   uint       `gorm:"not null"`
+	DateFrom  time.Time  `gorm:"not null"`
+	DateTo    time.Time  `gorm:"not null"`
+	Status    HoldStatus `gorm:"not null"`
+	CreatedAt time.Time  `gorm:"not null"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	EndedAt   *time.Time
+	RequestID uint `gorm:"not null"` // The request the hold was converted into
+}
+
+// checkNotHeld fails with ErrConflict if anyone but guestID holds some of the
+// room's dates. The caller has to hold the room's lock, or a hold could be
+// made right after the check.
+func checkNotHeld(ctx context.Context, tx Repository, roomID uint, from, to time.Time, guestID uint, now time.Time) error {
+	held, err := tx.HasHold(roomID, from, to, guestID, now)
+	if err != nil {
+		util.TEL.Error(ctx, "could not check for holds for room", err, "room_id", roomID)
+		return err
+	}
+	if held {
+		util.TEL.Error(ctx, "someone else holds the dates", nil, "room_id", roomID, "from", from, "to", to)
+		return ErrConflict
+	}
+	return nil
+}
+
+// HoldExpirer marks holds whose time ran out as expired. Holds stop counting
+// once ExpiresAt passes either way; this only keeps their status and the
+// metrics up to date.
+type HoldExpirer struct {
+	repo Repository
+	cfg  config.ExpiryConfig
+
+	now func() time.Time
+}
+
+// NewHoldExpirer also registers the hold metrics, which the service counts
+// too.
+func NewHoldExpirer(repo Repository, cfg config.ExpiryConfig) *HoldExpirer {
+	registerHoldMetrics.Do(func() {
+		prometheus.MustRegister(holdsCreated, holdsEnded)
+	})
+
+	return &HoldExpirer{
+		repo: repo,
+		cfg:  cfg,
+		now:  func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Run expires holds every interval until ctx is done.
+func (e *HoldExpirer) Run(ctx context.Context) {
+	util.RunPeriodically(ctx, e.cfg.Interval.Std(), func(ctx context.Context) {
+		if _, err := e.ExpireOnce(ctx); err != nil {
+			util.TEL.Error(ctx, "hold expiry failed", err)
+		}
+	})
+}
+
+// ExpireOnce expires every hold whose time ran out and returns how many it
+// expired.
+func (e *HoldExpirer) ExpireOnce(ctx context.Context) (int64, error) {
+	ctx, span := util.TEL.Start(ctx, "expire-holds")
+	defer span.End()
+
+	n, err := e.repo.ExpireHolds(e.now())
+	if err != nil {
+		return 0, err
+	}
+
+	holdsEnded.WithLabelValues("expired").Add(float64(n))
+	util.TEL.Debug(ctx, "expired holds", "count", n)
+	return n, nil
+}
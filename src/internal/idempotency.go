@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"bookem-reservation-service/config"
+	"bookem-reservation-service/util"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// Idempotency makes a route safe to retry. The response to the first request
+// with a given Idempotency-Key header is stored, and later requests of the
+// same user with that key get it replayed instead of being handled again.
+//
+// Responses with a 5xx status aren't stored, so a request that failed that
+// way is handled again when it's retried. Requests without the header, or
+// without a valid JWT, are handled as usual.
+func Idempotency(repo Repository, cfg config.IdempotencyConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+
+		if len(key) > maxIdempotencyKeyLength {
+			AbortError(c, ErrBadRequestCustom("Idempotency-Key can be at most 255 characters long"))
+			return
+		}
+
+		jwt, err := util.GetJwt(c)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			AbortError(c, ErrBadRequest)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := time.Now().UTC()
+		claim := &IdempotencyKey{
+			UserID:      jwt.ID,
+			Key:         key,
+			RequestHash: requestHash(c.Request, body),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(cfg.TTL.Std()),
+		}
+		existing, err := repo.ClaimIdempotencyKey(claim, now.Add(-cfg.ProcessingTimeout.Std()))
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// Released by the request that held it in the meantime.
+			AbortError(c, ErrIdempotencyKeyInUse)
+			return
+		case err != nil:
+			util.TEL.Error(ctx, "could not claim idempotency key", err, "user_id", jwt.ID)
+			AbortError(c, err)
+			return
+		case existing != nil:
+			replay(c, existing, claim.RequestHash)
+			return
+		}
+
+		recorder := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError {
+			release(ctx, repo, jwt.ID, key)
+			return
+		}
+		if err := repo.CompleteIdempotencyKey(jwt.ID, key, status, recorder.body.Bytes()); err != nil {
+			util.TEL.Error(ctx, "could not store response for idempotency key", err, "user_id", jwt.ID)
+			release(ctx, repo, jwt.ID, key)
+		}
+	}
+}
+
+func replay(c *gin.Context, existing *IdempotencyKey, hash string) {
+	switch {
+	case existing.RequestHash != hash:
+		AbortError(c, ErrIdempotencyKeyMismatch)
+	case existing.StatusCode == 0:
+		AbortError(c, ErrIdempotencyKeyInUse)
+	default:
+		util.TEL.Info(c.Request.Context(), "replaying response for idempotency key", "user_id", existing.UserID, "status", existing.StatusCode)
+		c.Header(IdempotentReplayedHeader, "true")
+		c.Data(existing.StatusCode, "application/json; charset=utf-8", existing.Body)
+		c.Abort()
+	}
+}
+
+func release(ctx context.Context, repo Repository, userID uint, key string) {
+	if err := repo.ReleaseIdempotencyKey(userID, key); err != nil {
+		// The key stays locked until the processing timeout runs out.
+		util.TEL.Error(ctx, "could not release idempotency key", err, "user_id", userID)
+	}
+}
+
+// requestHash tells apart different requests sent with the same key.
+func requestHash(req *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, req.Method+" "+req.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the response body.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// PurgeIdempotencyKeys deletes expired idempotency keys every interval until
+// ctx is done.
+func PurgeIdempotencyKeys(ctx context.Context, repo Repository, cfg config.IdempotencyConfig) {
+	util.RunPeriodically(ctx, cfg.PurgeInterval.Std(), func(ctx context.Context) {
+		n, err := repo.DeleteExpiredIdempotencyKeys(time.Now().UTC())
+		if err != nil {
+			util.TEL.Error(ctx, "could not purge expired idempotency keys", err)
+			return
+		}
+		util.TEL.Debug(ctx, "purged expired idempotency keys", "count", n)
+	})
+}
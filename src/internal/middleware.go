@@ -38,12 +38,24 @@ var (
 	ErrBadRequest      = &APIError{Code: http.StatusBadRequest, Message: "Bad request"}
 	ErrUnauthenticated = &APIError{Code: http.StatusForbidden, Message: "Unauthenticated"}
 	ErrConflict        = &APIError{Code: http.StatusConflict, Message: "Conflict"}
+
+	ErrServiceUnavailable = &APIError{Code: http.StatusServiceUnavailable, Message: "A dependent service is unavailable, try again later"}
+
+	ErrFeedNotFound = &APIError{Code: http.StatusNotFound, Message: "Feed not found"}
+
+	ErrNoWaitlistOffer     = &APIError{Code: http.StatusConflict, Message: "The dates haven't been offered to you yet"}
+	ErrWaitlistOfferLapsed = &APIError{Code: http.StatusConflict, Message: "The offer has lapsed"}
+
+	ErrIdempotencyKeyInUse    = &APIError{Code: http.StatusConflict, Message: "A request with this Idempotency-Key is still being processed"}
+	ErrIdempotencyKeyMismatch = &APIError{Code: http.StatusUnprocessableEntity, Message: "This Idempotency-Key was used for a different request"}
 )
 
 func MapErrorToHTTP(err error) (int, string) {
 	switch e := err.(type) {
 	case *APIError:
 		return e.Code, e.Message
+	case *TransitionError:
+		return http.StatusConflict, e.Error()
 	default:
 		return http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err)
 	}
@@ -92,7 +104,7 @@ func PrometheusMiddleware() gin.HandlerFunc {
 		if size >= 0 {
 			httpResponseSizeBytes.WithLabelValues(endpoint, status).Add(float64(size))
 		} else {
-			util.TEL.Warn("Response size < 0, cannot push to Prometheus", "size", size)
+			util.TEL.Warn(c.Request.Context(), "Response size < 0, cannot push to Prometheus", "size", size)
 		}
 	}
 }
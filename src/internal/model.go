@@ -1,13 +1,33 @@
 package internal
 
-import "time"
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/util"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type ReservationRequestStatus string
 
 const (
-	Pending  ReservationRequestStatus = "pending"
-	Accepted ReservationRequestStatus = "accepted"
-	Rejected ReservationRequestStatus = "rejected"
+	Pending   ReservationRequestStatus = "pending"
+	Accepted  ReservationRequestStatus = "accepted"
+	Rejected  ReservationRequestStatus = "rejected"
+	Expired   ReservationRequestStatus = "expired"   // The host didn't answer in time
+	Withdrawn ReservationRequestStatus = "withdrawn" // The guest took it back
+)
+
+// ReservationStatus is where a reservation is in its life. See
+// reservationStates for how it can change.
+type ReservationStatus string
+
+const (
+	ReservationConfirmed  ReservationStatus = "confirmed"
+	ReservationCancelled  ReservationStatus = "cancelled"
+	ReservationCheckedIn  ReservationStatus = "checked_in"
+	ReservationCheckedOut ReservationStatus = "checked_out" // The stay is over; the guest can rate it
+	ReservationNoShow     ReservationStatus = "no_show"     // The guest never arrived
 )
 
 type ReservationRequest struct {
@@ -21,17 +41,181 @@ type ReservationRequest struct {
 	GuestID            uint                     `gorm:"not null"` // User who made the request
 	Status             ReservationRequestStatus `gorm:"not null"`
 	Cost               uint                     `gorm:"not null"` // Computed field
+	GroupID            uint                     `gorm:"not null"` // 0 unless the request is part of a RequestGroup
+	CreatedAt          time.Time                `gorm:"not null"`
+	UpdatedAt          time.Time                `gorm:"not null"`
+	// WithdrawnAt soft deletes the request: queries leave withdrawn requests
+	// out unless they're Unscoped.
+	WithdrawnAt gorm.DeletedAt
 }
 
 type Reservation struct {
-	ID                 uint      `gorm:"primaryKey"`
-	RoomID             uint      `gorm:"not null"`
-	RoomAvailabilityID uint      `gorm:"not null"`
-	RoomPriceID        uint      `gorm:"not null"`
-	GuestID            uint      `gorm:"not null"` // User who made the request
-	DateFrom           time.Time `gorm:"not null"` // Including year
-	DateTo             time.Time `gorm:"not null"` // Including year
-	GuestCount         uint      `gorm:"not null"`
-	Cancelled          bool      `gorm:"not null"`
-	Cost               uint      `gorm:"not null"` // Computed field
+	ID                 uint              `gorm:"primaryKey"`
+	RoomID             uint              `gorm:"not null"`
+	RoomAvailabilityID uint              `gorm:"not null"`
+	RoomPriceID        uint              `gorm:"not null"`
+	GuestID            uint              `gorm:"not null"` // User who made the request
+	DateFrom           time.Time         `gorm:"not null"` // Including year
+	DateTo             time.Time         `gorm:"not null"` // Including year
+	GuestCount         uint              `gorm:"not null"`
+	Status             ReservationStatus `gorm:"not null"`
+	Cancelled          bool              `gorm:"not null"` // Same as Status == ReservationCancelled
+	Cost               uint              `gorm:"not null"` // Computed field
+	CreatedAt          time.Time         `gorm:"not null"`
+	UpdatedAt          time.Time         `gorm:"not null"`
+
+	CancelledBy        CancelledBy `gorm:"not null"` // Empty unless cancelled
+	CancelledByID      uint        `gorm:"not null"` // User who cancelled
+	CancellationReason string      `gorm:"not null"` // Required when the host cancels
+	CancelledAt        *time.Time
+
+	// CancellationTerms is the room's cancellation policy when the reservation
+	// was approved. Refund and penalty are set when it's cancelled.
+	CancellationTerms   CancellationTerms `gorm:"type:jsonb;not null"`
+	RefundAmount        uint              `gorm:"not null"`
+	CancellationPenalty uint              `gorm:"not null"`
+
+	CheckedInAt  *time.Time
+	CheckedOutAt *time.Time
+}
+
+// CancelledBy says which side of a reservation cancelled it. Only guest
+// cancellations count against the guest.
+type CancelledBy string
+
+const (
+	CancelledByGuest CancelledBy = "guest"
+	CancelledByHost  CancelledBy = "host"
+)
+
+// cancelled returns a copy of r as it is once c is stored.
+func (r Reservation) cancelled(c Cancellation) *Reservation {
+	r.Status = ReservationCancelled
+	r.Cancelled = true
+	r.CancelledBy = c.By
+	r.CancelledByID = c.ByID
+	r.CancellationReason = c.Reason
+	r.CancelledAt = &c.At
+	r.RefundAmount = c.Refund
+	r.CancellationPenalty = c.Penalty
+	return &r
+}
+
+// Cancellation records who cancelled a reservation, when and why, and how
+// much of its cost is refunded.
+type Cancellation struct {
+	By      CancelledBy
+	ByID    uint
+	Reason  string
+	At      time.Time
+	Refund  uint
+	Penalty uint
+}
+
+type ModificationStatus string
+
+const (
+	ModificationPending   ModificationStatus = "pending"
+	ModificationApproved  ModificationStatus = "approved"
+	ModificationRejected  ModificationStatus = "rejected"
+	ModificationCancelled ModificationStatus = "cancelled" // The reservation was cancelled first
+)
+
+// Stay is what a reservation modification changes.
+type Stay struct {
+	DateFrom   time.Time `gorm:"not null"`
+	DateTo     time.Time `gorm:"not null"`
+	GuestCount uint      `gorm:"not null"`
+	Cost       uint      `gorm:"not null"`
+}
+
+// ReservationModification is a guest's proposal to change the dates or guest
+// count of a reservation. Modifications are never deleted, so they are the
+// history of every change a reservation went through.
+type ReservationModification struct {
+	ID            uint `gorm:"primaryKey"`
+	ReservationID uint `gorm:"not null"`
+	RoomID        uint `gorm:"not null"`
+	GuestID       uint `gorm:"not null"`
+
+	Previous Stay `gorm:"embedded;embeddedPrefix:previous_"`
+	Proposed Stay `gorm:"embedded;embeddedPrefix:proposed_"`
+	// The room's lists the proposed cost was worked out with.
+	RoomAvailabilityID uint `gorm:"not null"`
+	RoomPriceID        uint `gorm:"not null"`
+
+	Status    ModificationStatus `gorm:"not null"`
+	CreatedAt time.Time          `gorm:"not null"`
+	DecidedAt *time.Time
+}
+
+// TransitionSubject is what kind of thing a StatusTransition is about.
+type TransitionSubject string
+
+const (
+	SubjectRequest      TransitionSubject = "request"
+	SubjectReservation  TransitionSubject = "reservation"
+	SubjectModification TransitionSubject = "modification"
+)
+
+// ActorSystem is the role of changes nobody asked for directly, like expiry
+// and automatic approval.
+const ActorSystem util.UserRole = "system"
+
+// StatusTransition is one status change of a reservation request, a
+// reservation or a modification. Transitions are only ever added, never
+// changed or deleted.
+type StatusTransition struct {
+	ID          uint              `gorm:"primaryKey"`
+	SubjectType TransitionSubject `gorm:"not null"`
+	SubjectID   uint              `gorm:"not null"`
+	FromStatus  string            `gorm:"not null"` // Empty when the subject was created
+	ToStatus    string            `gorm:"not null"`
+	ActorID     uint              `gorm:"not null"` // 0 for the system
+	ActorRole   util.UserRole     `gorm:"not null"`
+	Reason      string            `gorm:"not null"`
+	TraceID     string            `gorm:"not null"` // Of the request that made the change
+	CreatedAt   time.Time         `gorm:"not null"`
+}
+
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	OutboxSent    OutboxStatus = "sent"
+	OutboxDead    OutboxStatus = "dead" // Gave up after too many failed attempts
+)
+
+// NotificationOutbox is a notification waiting to be delivered. It's written
+// in the same transaction as the change it announces and delivered later by
+// the OutboxDispatcher, so a notification-service outage doesn't lose it.
+type NotificationOutbox struct {
+	ID            uint                                `gorm:"primaryKey"`
+	ReceiverID    uint                                `gorm:"not null"`
+	Type          notificationclient.NotificationType `gorm:"not null"`
+	Subject       uint                                `gorm:"not null"`
+	Object        uint                                `gorm:"not null"`
+	Status        OutboxStatus                        `gorm:"not null"`
+	Attempts      uint                                `gorm:"not null"`
+	NextAttemptAt time.Time                           `gorm:"not null"`
+	LastError     string                              `gorm:"not null"`
+	CreatedAt     time.Time                           `gorm:"not null"`
+	SentAt        *time.Time
+}
+
+func (NotificationOutbox) TableName() string {
+	return "notification_outbox"
+}
+
+// IdempotencyKey remembers the response to a request made with an
+// Idempotency-Key header, so a retry gets that response again instead of
+// repeating the request. Keys are scoped to the user who sent them.
+type IdempotencyKey struct {
+	UserID      uint      `gorm:"primaryKey"`
+	Key         string    `gorm:"primaryKey"`
+	RequestHash string    `gorm:"not null"` // Of the method, path and body
+	StatusCode  int       `gorm:"not null"` // 0 while the request is being processed
+	Body        []byte    // Response body
+	CreatedAt   time.Time `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null"`
 }
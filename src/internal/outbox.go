@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/config"
+	"bookem-reservation-service/util"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	outboxDelivered = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "notification_outbox_delivered_total",
+			Help: "Notifications delivered to the notification service",
+		},
+		[]string{"type"},
+	)
+
+	outboxFailedAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "notification_outbox_failed_attempts_total",
+			Help: "Failed attempts to deliver a notification",
+		},
+		[]string{"type"},
+	)
+
+	outboxDeadLettered = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "notification_outbox_dead_lettered_total",
+			Help: "Notifications given up on after too many failed attempts",
+		},
+		[]string{"type"},
+	)
+
+	outboxBacklog = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "notification_outbox_notifications",
+			Help: "Notifications in the outbox that are waiting or dead-lettered",
+		},
+		[]string{"status"},
+	)
+
+	registerOutboxMetrics sync.Once
+)
+
+// newOutboxNotification builds the outbox row for a notification. Enqueue it
+// with the Repository of the transaction that makes the change it announces.
+func newOutboxNotification(dto notificationclient.CreateNotificationDTO) *NotificationOutbox {
+	now := time.Now().UTC()
+	return &NotificationOutbox{
+		ReceiverID:    dto.ReceiverID,
+		Type:          dto.Type,
+		Subject:       dto.Subject,
+		Object:        dto.Object,
+		Status:        OutboxPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
+
+// OutboxDispatcher delivers the notification outbox to the notification
+// service. Several instances of the service can run it at the same time.
+type OutboxDispatcher struct {
+	repo   Repository
+	client notificationclient.NotificationClient
+	cfg    config.OutboxConfig
+	token  string
+
+	now func() time.Time
+}
+
+func NewOutboxDispatcher(repo Repository, client notificationclient.NotificationClient, cfg config.OutboxConfig, token string) *OutboxDispatcher {
+	registerOutboxMetrics.Do(func() {
+		prometheus.MustRegister(outboxDelivered, outboxFailedAttempts, outboxDeadLettered, outboxBacklog)
+	})
+
+	return &OutboxDispatcher{
+		repo:   repo,
+		client: client,
+		cfg:    cfg,
+		token:  token,
+		now:    func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Run delivers due notifications every poll interval until ctx is done.
+func (d *OutboxDispatcher) Run(ctx context.Context) {
+	util.RunPeriodically(ctx, d.cfg.PollInterval.Std(), func(ctx context.Context) {
+		// Keep going while there's a full batch, so a backlog drains quickly.
+		for {
+			n, err := d.DispatchOnce(ctx)
+			if err != nil {
+				util.TEL.Error(ctx, "outbox dispatch failed", err)
+			}
+			if err != nil || n < d.cfg.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+		d.updateBacklog(ctx)
+	})
+}
+
+// DispatchOnce claims one batch of due notifications and tries to deliver
+// each of them. It returns how many notifications it claimed.
+func (d *OutboxDispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	notifications, err := d.repo.ClaimDueNotifications(d.now(), d.cfg.Lease.Std(), d.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range notifications {
+		d.deliver(ctx, &notifications[i])
+	}
+	return len(notifications), nil
+}
+
+func (d *OutboxDispatcher) deliver(ctx context.Context, n *NotificationOutbox) {
+	dto := notificationclient.CreateNotificationDTO{
+		ReceiverID: n.ReceiverID,
+		Type:       n.Type,
+		Subject:    n.Subject,
+		Object:     n.Object,
+	}
+
+	n.Attempts++
+	_, err := d.client.CreateNotification(ctx, d.token, dto)
+	now := d.now()
+
+	switch {
+	case err == nil:
+		n.Status = OutboxSent
+		n.SentAt = &now
+		n.LastError = ""
+		outboxDelivered.WithLabelValues(string(n.Type)).Inc()
+		util.TEL.Debug(ctx, "notification delivered", "outbox_id", n.ID, "receiver_id", n.ReceiverID, "type", n.Type)
+
+	case int(n.Attempts) >= d.cfg.MaxAttempts:
+		n.Status = OutboxDead
+		n.LastError = err.Error()
+		outboxFailedAttempts.WithLabelValues(string(n.Type)).Inc()
+		outboxDeadLettered.WithLabelValues(string(n.Type)).Inc()
+		util.TEL.Error(ctx, "giving up on notification", err, "outbox_id", n.ID, "attempts", n.Attempts)
+
+	default:
+		n.NextAttemptAt = now.Add(d.backoff(n.Attempts))
+		n.LastError = err.Error()
+		outboxFailedAttempts.WithLabelValues(string(n.Type)).Inc()
+		util.TEL.Warn(ctx, "notification delivery failed, will retry", "error", err, "outbox_id", n.ID, "attempts", n.Attempts, "next_attempt_at", n.NextAttemptAt)
+	}
+
+	// If this fails the lease runs out and the notification is sent again,
+	// which is the price of at-least-once delivery.
+	if err := d.repo.UpdateNotificationDelivery(n); err != nil {
+		util.TEL.Error(ctx, "could not record notification delivery", err, "outbox_id", n.ID)
+	}
+}
+
+func (d *OutboxDispatcher) backoff(attempts uint) time.Duration {
+	backoff := d.cfg.BaseBackoff.Std()
+	for i := uint(1); i < attempts && backoff < d.cfg.MaxBackoff.Std(); i++ {
+		backoff *= 2
+	}
+	return min(backoff, d.cfg.MaxBackoff.Std())
+}
+
+func (d *OutboxDispatcher) updateBacklog(ctx context.Context) {
+	for _, status := range []OutboxStatus{OutboxPending, OutboxDead} {
+		count, err := d.repo.CountNotificationsByStatus(status)
+		if err != nil {
+			util.TEL.Error(ctx, "could not count outbox notifications", err, "status", status)
+			continue
+		}
+		outboxBacklog.WithLabelValues(string(status)).Set(float64(count))
+	}
+}
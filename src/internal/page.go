@@ -0,0 +1,267 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// SortKey is a field listings can be sorted by. Ties are broken by ID, so
+// the order is always total and a cursor always points at one place.
+type SortKey string
+
+const (
+	SortDateFrom  SortKey = "dateFrom"
+	SortDateTo    SortKey = "dateTo"
+	SortCreatedAt SortKey = "createdAt" // Reservation requests only
+)
+
+var sortColumns = map[SortKey]string{
+	SortDateFrom:  "date_from",
+	SortDateTo:    "date_to",
+	SortCreatedAt: "created_at",
+}
+
+// Sort is a sort key and direction. It's written as the key, prefixed with
+// "-" for descending order, e.g. "-dateFrom".
+type Sort struct {
+	Key  SortKey
+	Desc bool
+}
+
+func (s Sort) String() string {
+	if s.Desc {
+		return "-" + string(s.Key)
+	}
+	return string(s.Key)
+}
+
+// ListQuery is what a client asks a listing endpoint for. Zero values mean
+// "no filter" or "the endpoint's default".
+type ListQuery struct {
+	Limit  int
+	Cursor string
+	Sort   string
+
+	// Statuses only applies to reservation requests.
+	Statuses []ReservationRequestStatus
+	RoomID   uint
+	// From and To select what overlaps the date window [From, To].
+	From time.Time
+	To   time.Time
+}
+
+// PageRequest is a validated ListQuery page: how many rows, in which order,
+// and after which row.
+type PageRequest struct {
+	Limit int
+	Sort  Sort
+	After *Cursor
+}
+
+// pageRequest validates the paging part of the query. Only the keys in
+// allowed can be sorted by; def is used when the client doesn't pick one.
+func (q ListQuery) pageRequest(def Sort, allowed ...SortKey) (PageRequest, error) {
+	page := PageRequest{Limit: q.Limit, Sort: def}
+
+	switch {
+	case page.Limit == 0:
+		page.Limit = DefaultPageLimit
+	case page.Limit < 0 || page.Limit > MaxPageLimit:
+		return page, ErrBadRequestCustom("limit must be between 1 and 100")
+	}
+
+	if q.Sort != "" {
+		page.Sort = Sort{Key: SortKey(strings.TrimPrefix(q.Sort, "-")), Desc: strings.HasPrefix(q.Sort, "-")}
+		if !slices.Contains(allowed, page.Sort.Key) {
+			return page, ErrBadRequestCustom("cannot sort by " + string(page.Sort.Key))
+		}
+	}
+
+	if !q.From.IsZero() && !q.To.IsZero() && q.From.After(q.To) {
+		return page, ErrBadRequestCustom("dates are reversed")
+	}
+
+	if q.Cursor != "" {
+		cursor, err := DecodeCursor(q.Cursor)
+		if err != nil {
+			return page, ErrBadRequestCustom("invalid cursor")
+		}
+		if cursor.Sort != page.Sort.String() {
+			return page, ErrBadRequestCustom("cursor was made for a different sort order")
+		}
+		page.After = cursor
+	}
+
+	return page, nil
+}
+
+// scope orders the query and picks the rows after the cursor. It asks for one
+// row more than the limit, so newPage can tell whether there's a next page.
+func (p PageRequest) scope(db *gorm.DB) *gorm.DB {
+	column := sortColumns[p.Sort.Key]
+	cmp, dir := ">", "ASC"
+	if p.Sort.Desc {
+		cmp, dir = "<", "DESC"
+	}
+
+	if p.After != nil {
+		db = db.Where("("+column+", id) "+cmp+" (?, ?)", p.After.Value, p.After.ID)
+	}
+	return db.Order(column + " " + dir + ", id " + dir).Limit(p.Limit + 1)
+}
+
+// Cursor marks the last row of a page. It's handed to clients as an opaque
+// string, see Encode.
+type Cursor struct {
+	Sort  string    `json:"s"`
+	Value time.Time `json:"v"`
+	ID    uint      `json:"id"`
+}
+
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Page is one page of a listing. Total counts everything that matches the
+// filters, on every page, including items some listings drop from their pages
+// afterwards, e.g. those of deleted rooms.
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+	Total      int64
+}
+
+type pageable interface {
+	cursor(sort Sort) Cursor
+}
+
+// newPage builds the page from the rows PageRequest.scope fetched.
+func newPage[T pageable](rows []T, total int64, page PageRequest) *Page[T] {
+	p := &Page[T]{Items: rows, Total: total}
+	if len(rows) > page.Limit {
+		p.Items = rows[:page.Limit]
+		p.NextCursor = p.Items[page.Limit-1].cursor(page.Sort).Encode()
+	}
+	return p
+}
+
+func (r ReservationRequest) cursor(sort Sort) Cursor {
+	c := Cursor{Sort: sort.String(), ID: r.ID}
+	switch sort.Key {
+	case SortDateFrom:
+		c.Value = r.DateFrom
+	case SortDateTo:
+		c.Value = r.DateTo
+	case SortCreatedAt:
+		c.Value = r.CreatedAt
+	}
+	return c
+}
+
+func (r Reservation) cursor(sort Sort) Cursor {
+	c := Cursor{Sort: sort.String(), ID: r.ID}
+	switch sort.Key {
+	case SortDateFrom:
+		c.Value = r.DateFrom
+	case SortDateTo:
+		c.Value = r.DateTo
+	}
+	return c
+}
+
+// RequestFilter selects reservation requests. Zero values don't filter; a
+// non-nil IDs or RoomIDs restricts to those, even when it's empty. Withdrawn
+// requests are only included when Statuses asks for them.
+type RequestFilter struct {
+	IDs      []uint
+	GuestID  uint
+	RoomIDs  []uint
+	Statuses []ReservationRequestStatus
+	From     time.Time
+	To       time.Time
+}
+
+func (f RequestFilter) scope(db *gorm.DB) *gorm.DB {
+	if f.IDs != nil {
+		db = db.Where("id IN ?", f.IDs)
+	}
+	if f.GuestID != 0 {
+		db = db.Where("guest_id = ?", f.GuestID)
+	}
+	if f.RoomIDs != nil {
+		db = db.Where("room_id IN ?", f.RoomIDs)
+	}
+	if len(f.Statuses) > 0 {
+		db = db.Where("status IN ?", f.Statuses)
+	}
+	if slices.Contains(f.Statuses, Withdrawn) {
+		db = db.Unscoped()
+	}
+	return dateWindow(db, f.From, f.To)
+}
+
+// ReservationFilter selects reservations. Zero values don't filter; a non-nil
+// RoomIDs restricts to those rooms, even when it's empty.
+type ReservationFilter struct {
+	GuestID          uint
+	RoomIDs          []uint
+	ExcludeCancelled bool
+	// EndsAfter and EndsBy bound DateTo: EndsAfter < DateTo <= EndsBy.
+	EndsAfter time.Time
+	EndsBy    time.Time
+	From      time.Time
+	To        time.Time
+}
+
+func (f ReservationFilter) scope(db *gorm.DB) *gorm.DB {
+	if f.GuestID != 0 {
+		db = db.Where("guest_id = ?", f.GuestID)
+	}
+	if f.RoomIDs != nil {
+		db = db.Where("room_id IN ?", f.RoomIDs)
+	}
+	if f.ExcludeCancelled {
+		db = db.Where("cancelled = ?", false)
+	}
+	if !f.EndsAfter.IsZero() {
+		db = db.Where("date_to > ?", f.EndsAfter)
+	}
+	if !f.EndsBy.IsZero() {
+		db = db.Where("date_to <= ?", f.EndsBy)
+	}
+	return dateWindow(db, f.From, f.To)
+}
+
+// dateWindow keeps the rows whose dates overlap [from, to].
+func dateWindow(db *gorm.DB, from, to time.Time) *gorm.DB {
+	if !from.IsZero() {
+		db = db.Where("date_to >= ?", from)
+	}
+	if !to.IsZero() {
+		db = db.Where("date_from <= ?", to)
+	}
+	return db
+}
@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// CancellationPolicyKind names one of the preset cancellation policies, or
+// custom for one the host put together.
+type CancellationPolicyKind string
+
+const (
+	PolicyFlexible CancellationPolicyKind = "flexible"
+	PolicyModerate CancellationPolicyKind = "moderate"
+	PolicyStrict   CancellationPolicyKind = "strict"
+	PolicyCustom   CancellationPolicyKind = "custom"
+)
+
+const maxRefundTiers = 10
+
+// RefundTier gives back RefundPercent of the cost when the reservation is
+// cancelled at least DaysBefore days before it starts.
+type RefundTier struct {
+	DaysBefore    uint `json:"daysBefore"`
+	RefundPercent uint `json:"refundPercent"`
+}
+
+var presetTiers = map[CancellationPolicyKind][]RefundTier{
+	PolicyFlexible: {{DaysBefore: 1, RefundPercent: 100}},
+	PolicyModerate: {{DaysBefore: 5, RefundPercent: 100}, {DaysBefore: 1, RefundPercent: 50}},
+	PolicyStrict:   {{DaysBefore: 7, RefundPercent: 50}},
+}
+
+// DefaultCancellationTerms apply to rooms whose host never picked a policy.
+var DefaultCancellationTerms = CancellationTerms{Kind: PolicyFlexible, Tiers: presetTiers[PolicyFlexible]}
+
+// CancellationTerms is a cancellation policy spelled out as refund tiers,
+// sorted by DaysBefore, largest first. Preset policies carry their tiers too,
+// so a snapshot on a reservation doesn't change when the presets do.
+type CancellationTerms struct {
+	Kind  CancellationPolicyKind `json:"kind"`
+	Tiers []RefundTier           `json:"tiers"`
+}
+
+// NewCancellationTerms validates a policy. Tiers are only given for custom
+// policies; the presets come with their own.
+func NewCancellationTerms(kind CancellationPolicyKind, tiers []RefundTier) (CancellationTerms, error) {
+	if kind != PolicyCustom {
+		preset, ok := presetTiers[kind]
+		if !ok {
+			return CancellationTerms{}, ErrBadRequestCustom(fmt.Sprintf("unknown cancellation policy %q", kind))
+		}
+		if len(tiers) > 0 {
+			return CancellationTerms{}, ErrBadRequestCustom("tiers can only be given for a custom policy")
+		}
+		return CancellationTerms{Kind: kind, Tiers: slices.Clone(preset)}, nil
+	}
+
+	if len(tiers) == 0 || len(tiers) > maxRefundTiers {
+		return CancellationTerms{}, ErrBadRequestCustom(fmt.Sprintf("a custom policy needs between 1 and %d tiers", maxRefundTiers))
+	}
+
+	sorted := slices.Clone(tiers)
+	slices.SortFunc(sorted, func(a, b RefundTier) int { return int(b.DaysBefore) - int(a.DaysBefore) })
+	for i, tier := range sorted {
+		if tier.RefundPercent > 100 {
+			return CancellationTerms{}, ErrBadRequestCustom("refundPercent must be between 0 and 100")
+		}
+		if i == 0 {
+			continue
+		}
+		prev := sorted[i-1]
+		if prev.DaysBefore == tier.DaysBefore {
+			return CancellationTerms{}, ErrBadRequestCustom(fmt.Sprintf("more than one tier for %d days before", tier.DaysBefore))
+		}
+		if prev.RefundPercent < tier.RefundPercent {
+			return CancellationTerms{}, ErrBadRequestCustom("the refund cannot grow closer to the stay")
+		}
+	}
+	return CancellationTerms{Kind: PolicyCustom, Tiers: sorted}, nil
+}
+
+// RefundBreakdown is what a guest gets back for cancelling, and what they
+// lose.
+type RefundBreakdown struct {
+	DaysBefore    uint
+	RefundPercent uint
+	Refund        uint
+	Penalty       uint
+}
+
+// Refund works out the refund for cancelling a reservation that costs cost
+// and starts at start, at time at. Only full days before the start count;
+// the first tier the cancellation is early enough for applies, and if there
+// is none, nothing is refunded.
+func (t CancellationTerms) Refund(cost uint, start, at time.Time) RefundBreakdown {
+	var b RefundBreakdown
+	if at.Before(start) {
+		b.DaysBefore = uint(start.Sub(at) / (24 * time.Hour))
+	}
+	for _, tier := range t.Tiers {
+		if b.DaysBefore >= tier.DaysBefore {
+			b.RefundPercent = tier.RefundPercent
+			break
+		}
+	}
+	b.Refund = uint(uint64(cost) * uint64(b.RefundPercent) / 100)
+	b.Penalty = cost - b.Refund
+	return b
+}
+
+// CancellationResult is a cancelled reservation and how its cost was split.
+type CancellationResult struct {
+	Reservation Reservation
+	Refund      RefundBreakdown
+}
+
+// FullRefund is used when the guest isn't the one cancelling.
+func FullRefund(cost uint) RefundBreakdown {
+	return RefundBreakdown{RefundPercent: 100, Refund: cost}
+}
+
+// Value stores the terms as JSON.
+func (t CancellationTerms) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+func (t *CancellationTerms) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	case nil:
+		*t = CancellationTerms{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan cancellation terms from %T", src)
+	}
+}
+
+// CancellationPolicy is the policy a host set for one of their rooms. New
+// reservations of the room get a snapshot of its terms.
+type CancellationPolicy struct {
+	RoomID    uint              `gorm:"primaryKey"`
+	Terms     CancellationTerms `gorm:"type:jsonb;not null"`
+	UpdatedAt time.Time         `gorm:"not null"`
+}
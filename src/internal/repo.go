@@ -1,33 +1,213 @@
 package internal
 
 import (
+	"errors"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// roomLockNamespace is the first key of the two-key advisory lock used by
+// LockRoom, so room locks can't collide with other advisory locks.
+const roomLockNamespace = 1
+
+// ReservationsNoOverlapConstraint is the name of the exclusion constraint that
+// stops two non-cancelled reservations of the same room from overlapping.
+const ReservationsNoOverlapConstraint = "reservations_no_overlap"
+
+// pgExclusionViolation is the SQLSTATE Postgres reports when an exclusion
+// constraint is violated.
+const pgExclusionViolation = "23P01"
+
+// mapConstraintError turns a violation of the no-double-booking constraint
+// into ErrConflict and leaves every other error as is.
+func mapConstraintError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgExclusionViolation && pgErr.ConstraintName == ReservationsNoOverlapConstraint {
+		return ErrConflict
+	}
+	return err
+}
+
 type Repository interface {
+	// Transaction runs fn inside a single database transaction. The Repository
+	// handed to fn is bound to that transaction; returning an error from fn
+	// rolls back everything fn did.
+	Transaction(fn func(tx Repository) error) error
+
+	// LockRoom takes a transaction-scoped lock on the room, serializing every
+	// transaction that wants to change the room's reservations. Outside of a
+	// transaction the lock is released immediately, so only call it through
+	// the Repository given to Transaction.
+	LockRoom(roomID uint) error
+
 	// ReservationRequest methods
 	CreateRequest(req *ReservationRequest) error
-	DeleteRequest(id uint) error
+	// WithdrawRequest marks a pending request as withdrawn, which hides it
+	// from every query that isn't Unscoped.
+	WithdrawRequest(id uint, at time.Time) error
 	FindRequestsByRoomIDUpcoming(roomID uint, now time.Time) ([]ReservationRequest, error)
+	// UpdateRequest stores the request's stay, cost and room lists.
+	UpdateRequest(req *ReservationRequest) error
 	SetRequestStatus(id uint, status ReservationRequestStatus) error
-	RejectPendingRequestsInRange(roomID uint, from, to time.Time) error
-	FindPendingRequestsByRoomID(roomID uint) ([]ReservationRequest, error)
+	// RejectPendingRequestsInRange rejects the room's pending requests that
+	// overlap [from, to] and returns them.
+	RejectPendingRequestsInRange(roomID uint, from, to time.Time) ([]ReservationRequest, error)
 	FindPendingRequestsByGuestID(guestID uint) ([]ReservationRequest, error)
+	// FindRequestsPage returns one page of the requests matching filter.
+	FindRequestsPage(filter RequestFilter, page PageRequest) (*Page[ReservationRequest], error)
+	// FindRequests returns every request matching filter, by DateFrom.
+	FindRequests(filter RequestFilter) ([]ReservationRequest, error)
 	FindRequestByID(id uint) (*ReservationRequest, error)
+	// FindRequestByIDUnscoped also finds withdrawn requests.
+	FindRequestByIDUnscoped(id uint) (*ReservationRequest, error)
+	FindRequestByIDForUpdate(id uint) (*ReservationRequest, error)
+	// FindExpirableRequests returns up to limit pending requests created
+	// before createdBefore or starting on or before startsBy, oldest first.
+	FindExpirableRequests(createdBefore, startsBy time.Time, limit int) ([]ReservationRequest, error)
+	// ExpireRequests marks the given requests as expired, skipping any that
+	// are no longer pending, and returns the ones it expired.
+	ExpireRequests(ids []uint) ([]ReservationRequest, error)
 
 	// Reservation methods
 	CreateReservation(res *Reservation) error
-	CancelReservation(id uint) error
+	CancelReservation(id uint, c Cancellation) error
 	FindCancelledReservationsByGuestID(guestID uint) ([]Reservation, error)
-	FindReservationsByRoomIDForDay(roomID uint, day time.Time) ([]Reservation, error)
-	FindReservationsByGuestID(guestID uint) ([]Reservation, error)
+	// HasReservationsInRange reports whether any non-cancelled reservation of
+	// the room overlaps the date range [from, to] (both ends inclusive).
+	HasReservationsInRange(roomID uint, from, to time.Time) (bool, error)
+	// HasOtherReservationsInRange is HasReservationsInRange, leaving out the
+	// reservation exceptID.
+	HasOtherReservationsInRange(roomID uint, from, to time.Time, exceptID uint) (bool, error)
+	// CountGuestCancellations counts the reservations the guest cancelled.
+	// Reservations the host cancelled don't count.
 	CountGuestCancellations(guestID uint) (int64, error)
-	FindReservationsByRoomID(roomID uint) ([]Reservation, error)
+	CountHostCancellations(hostID uint) (int64, error)
 	FindReservationById(id uint) (*Reservation, error)
-	HasGuestPastReservationInRooms(guestID uint, roomIDs []uint, now time.Time) (bool, error)
-	 GetAllPastReservationsByGuest(guestID uint, before time.Time) ([]Reservation, error)}
+	FindReservationByIDForUpdate(id uint) (*Reservation, error)
+	// HasGuestCompletedStayInRooms reports whether the guest checked out of a
+	// reservation of any of the rooms.
+	HasGuestCompletedStayInRooms(guestID uint, roomIDs []uint) (bool, error)
+	// SetStayStatus moves a reservation to checked in, checked out or no-show.
+	// at is stored as the check-in or check-out time.
+	SetStayStatus(id uint, status ReservationStatus, at time.Time) error
+	// FindOverdueStays returns up to limit reservations the host should have
+	// recorded by now: confirmed ones whose first day began before
+	// startedBefore, and confirmed or checked in ones whose last day ended
+	// before endedBefore. Oldest stays first.
+	FindOverdueStays(startedBefore, endedBefore time.Time, limit int) ([]Reservation, error)
+	// FindReservationsPage returns one page of the reservations matching filter.
+	FindReservationsPage(filter ReservationFilter, page PageRequest) (*Page[Reservation], error)
+	// FindReservations returns every reservation matching filter, by DateFrom.
+	FindReservations(filter ReservationFilter) ([]Reservation, error)
+
+	// ReservationModification methods
+	CreateModification(m *ReservationModification) error
+	FindModificationByID(id uint) (*ReservationModification, error)
+	FindModificationByIDForUpdate(id uint) (*ReservationModification, error)
+	// FindModificationsByReservationID returns the reservation's
+	// modifications, oldest first.
+	FindModificationsByReservationID(reservationID uint) ([]ReservationModification, error)
+	HasPendingModification(reservationID uint) (bool, error)
+	SetModificationStatus(id uint, status ModificationStatus, decidedAt time.Time) error
+	// CancelPendingModifications cancels the reservation's pending
+	// modifications and returns them.
+	CancelPendingModifications(reservationID uint, at time.Time) ([]ReservationModification, error)
+	// ApplyModification changes the reservation to the modification's
+	// proposed stay and room lists.
+	ApplyModification(m *ReservationModification) error
+
+	// StatusTransition methods
+	RecordTransition(t *StatusTransition) error
+	// FindTransitions returns the status history of the subject, oldest first.
+	FindTransitions(subject TransitionSubject, id uint) ([]StatusTransition, error)
+
+	// CancellationPolicy methods
+	// FindCancellationPolicy returns gorm.ErrRecordNotFound if the host of the
+	// room never set a policy.
+	FindCancellationPolicy(roomID uint) (*CancellationPolicy, error)
+	SaveCancellationPolicy(p *CancellationPolicy) error
+
+	// FeedToken methods
+	CreateFeedToken(t *FeedToken) error
+	// FindFeedTokenByHash only finds tokens that haven't been revoked.
+	FindFeedTokenByHash(hash string) (*FeedToken, error)
+	// FindFeedTokensByUserID returns the user's tokens, newest first.
+	FindFeedTokensByUserID(userID uint) ([]FeedToken, error)
+	// RevokeFeedToken revokes one of the user's tokens. It returns
+	// gorm.ErrRecordNotFound if the user has no such token that's still valid.
+	RevokeFeedToken(id, userID uint, at time.Time) error
+
+	// WaitlistEntry methods
+	CreateWaitlistEntry(e *WaitlistEntry) error
+	FindWaitlistEntryByID(id uint) (*WaitlistEntry, error)
+	FindWaitlistEntryByIDForUpdate(id uint) (*WaitlistEntry, error)
+	// FindWaitlistEntriesByGuestID returns the guest's entries, newest first.
+	FindWaitlistEntriesByGuestID(guestID uint) ([]WaitlistEntry, error)
+	// FindWaitingEntries returns the room's waiting entries that overlap
+	// [from, to], oldest first.
+	FindWaitingEntries(roomID uint, from, to time.Time) ([]WaitlistEntry, error)
+	// HasWaitlistOffer reports whether a guest other than exceptGuestID holds
+	// an offer on any of the room's days in [from, to] that's still open at
+	// now.
+	HasWaitlistOffer(roomID uint, from, to time.Time, exceptGuestID uint, now time.Time) (bool, error)
+	// FindLapsedWaitlistEntries returns up to limit offered entries whose
+	// offer is over and waiting or offered entries whose stay started by now.
+	FindLapsedWaitlistEntries(now time.Time, limit int) ([]WaitlistEntry, error)
+	// UpdateWaitlistEntry stores the entry's status, offer and request.
+	UpdateWaitlistEntry(e *WaitlistEntry) error
+
+	// Hold methods
+	CreateHold(h *Hold) error
+	// HasHold reports whether a guest other than exceptGuestID holds any of
+	// the room's days in [from, to] at now.
+	HasHold(roomID uint, from, to time.Time, exceptGuestID uint, now time.Time) (bool, error)
+	CountActiveHolds(guestID uint, now time.Time) (int64, error)
+	// ReleaseHold releases one of the guest's holds. It returns
+	// gorm.ErrRecordNotFound if the guest has no such hold that's active.
+	ReleaseHold(id, guestID uint, at time.Time) error
+	// ConvertHolds marks the guest's active holds on the room's days in
+	// [from, to] as converted into the request, and returns how many it
+	// converted.
+	ConvertHolds(guestID, roomID uint, from, to time.Time, requestID uint, now time.Time) (int64, error)
+	// ExpireHolds marks the active holds that ran out by now as expired, and
+	// returns how many it expired.
+	ExpireHolds(now time.Time) (int64, error)
+
+	// RequestGroup methods
+	CreateRequestGroup(g *RequestGroup) error
+	// FindRequestGroupByID also finds the group's requests, withdrawn ones
+	// included.
+	FindRequestGroupByID(id uint) (*RequestGroup, error)
+	// RejectPendingRequestsInGroup rejects the group's pending requests and
+	// returns them.
+	RejectPendingRequestsInGroup(groupID uint) ([]ReservationRequest, error)
+
+	// NotificationOutbox methods
+	EnqueueNotification(n *NotificationOutbox) error
+	// ClaimDueNotifications returns up to limit pending notifications whose
+	// next attempt is due and pushes their next attempt lease into the future,
+	// so concurrent dispatchers don't pick the same rows. A dispatcher that
+	// dies mid-delivery leaves the rows to be retried once the lease is over.
+	ClaimDueNotifications(now time.Time, lease time.Duration, limit int) ([]NotificationOutbox, error)
+	// UpdateNotificationDelivery stores the outcome of a delivery attempt.
+	UpdateNotificationDelivery(n *NotificationOutbox) error
+	CountNotificationsByStatus(status OutboxStatus) (int64, error)
+
+	// IdempotencyKey methods
+	// ClaimIdempotencyKey stores k as being processed and returns nil. If the
+	// key is already taken, it returns the stored key instead. Expired keys,
+	// and keys still being processed that were claimed before staleBefore,
+	// are taken over.
+	ClaimIdempotencyKey(k *IdempotencyKey, staleBefore time.Time) (*IdempotencyKey, error)
+	// CompleteIdempotencyKey stores the response to the key's request.
+	CompleteIdempotencyKey(userID uint, key string, statusCode int, body []byte) error
+	// ReleaseIdempotencyKey forgets the key, so the request can be retried.
+	ReleaseIdempotencyKey(userID uint, key string) error
+	DeleteExpiredIdempotencyKeys(now time.Time) (int64, error)
+}
 
 type repository struct {
 	db *gorm.DB
@@ -37,12 +217,25 @@ func NewRepository(db *gorm.DB) Repository {
 	return &repository{db}
 }
 
+func (r *repository) Transaction(fn func(tx Repository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&repository{tx})
+	})
+}
+
+func (r *repository) LockRoom(roomID uint) error {
+	return r.db.Exec("SELECT pg_advisory_xact_lock(?, ?)", roomLockNamespace, int32(roomID)).Error
+}
+
 func (r *repository) CreateRequest(req *ReservationRequest) error {
 	return r.db.Create(req).Error
 }
 
-func (r *repository) DeleteRequest(id uint) error {
-	return r.db.Delete(&ReservationRequest{}, id).Error
+func (r *repository) WithdrawRequest(id uint, at time.Time) error {
+	return r.db.Model(&ReservationRequest{}).Where("id = ? AND status = ?", id, Pending).Updates(map[string]any{
+		"status":       Withdrawn,
+		"withdrawn_at": at,
+	}).Error
 }
 
 func (r *repository) FindRequestsByRoomIDUpcoming(roomID uint, now time.Time) ([]ReservationRequest, error) {
@@ -51,22 +244,45 @@ func (r *repository) FindRequestsByRoomIDUpcoming(roomID uint, now time.Time) ([
 	return requests, err
 }
 
+func (r *repository) UpdateRequest(req *ReservationRequest) error {
+	return r.db.Model(&ReservationRequest{}).Where("id = ?", req.ID).Updates(map[string]any{
+		"date_from":            req.DateFrom,
+		"date_to":              req.DateTo,
+		"guest_count":          req.GuestCount,
+		"cost":                 req.Cost,
+		"room_availability_id": req.RoomAvailabilityID,
+		"room_price_id":        req.RoomPriceID,
+	}).Error
+}
+
 func (r *repository) SetRequestStatus(id uint, status ReservationRequestStatus) error {
 	return r.db.Model(&ReservationRequest{}).Where("id = ?", id).Update("status", status).Error
 }
 
-func (r *repository) RejectPendingRequestsInRange(roomID uint, from, to time.Time) error {
-	return r.db.Model(&ReservationRequest{}).
+func (r *repository) RejectPendingRequestsInRange(roomID uint, from, to time.Time) ([]ReservationRequest, error) {
+	var requests []ReservationRequest
+	err := r.db.Model(&requests).
+		Clauses(clause.Returning{}).
 		Where("room_id = ? AND status = ? AND date_to >= ? AND date_from <= ?", roomID, Pending, from, to).
 		Update("status", Rejected).Error
+	return requests, err
 }
 
 func (r *repository) CreateReservation(res *Reservation) error {
-	return r.db.Create(res).Error
+	return mapConstraintError(r.db.Create(res).Error)
 }
 
-func (r *repository) CancelReservation(id uint) error {
-	return r.db.Model(&Reservation{}).Where("id = ?", id).Update("cancelled", true).Error
+func (r *repository) CancelReservation(id uint, c Cancellation) error {
+	return r.db.Model(&Reservation{}).Where("id = ?", id).Updates(map[string]any{
+		"status":               ReservationCancelled,
+		"cancelled":            true,
+		"cancelled_by":         c.By,
+		"cancelled_by_id":      c.ByID,
+		"cancellation_reason":  c.Reason,
+		"cancelled_at":         c.At,
+		"refund_amount":        c.Refund,
+		"cancellation_penalty": c.Penalty,
+	}).Error
 }
 
 func (r *repository) FindCancelledReservationsByGuestID(guestID uint) ([]Reservation, error) {
@@ -75,21 +291,37 @@ func (r *repository) FindCancelledReservationsByGuestID(guestID uint) ([]Reserva
 	return reservations, err
 }
 
-func (r *repository) FindReservationsByRoomIDForDay(roomID uint, day time.Time) ([]Reservation, error) {
-	var reservations []Reservation
-	err := r.db.Where("room_id = ? AND date_to >= ? AND date_from <= ?", roomID, day, day).Find(&reservations).Error
-	return reservations, err
+func (r *repository) HasReservationsInRange(roomID uint, from, to time.Time) (bool, error) {
+	var exists bool
+	err := r.db.Raw(
+		"SELECT EXISTS (SELECT 1 FROM reservations WHERE room_id = ? AND NOT cancelled AND date_from <= ? AND date_to >= ?)",
+		roomID, to, from,
+	).Scan(&exists).Error
+	return exists, err
 }
 
-func (r *repository) FindPendingRequestsByRoomID(roomID uint) ([]ReservationRequest, error) {
-	var requests []ReservationRequest
-	err := r.db.Where("room_id = ? AND status = ?", roomID, Pending).Find(&requests).Error
-	return requests, err
+func (r *repository) HasOtherReservationsInRange(roomID uint, from, to time.Time, exceptID uint) (bool, error) {
+	var exists bool
+	err := r.db.Raw(
+		"SELECT EXISTS (SELECT 1 FROM reservations WHERE room_id = ? AND id <> ? AND NOT cancelled AND date_from <= ? AND date_to >= ?)",
+		roomID, exceptID, to, from,
+	).Scan(&exists).Error
+	return exists, err
 }
 
 func (r *repository) CountGuestCancellations(guestID uint) (int64, error) {
 	var count int64
-	err := r.db.Model(&Reservation{}).Where("guest_id = ? AND cancelled = ?", guestID, true).Count(&count).Error
+	err := r.db.Model(&Reservation{}).
+		Where("guest_id = ? AND cancelled = ? AND cancelled_by = ?", guestID, true, CancelledByGuest).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *repository) CountHostCancellations(hostID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&Reservation{}).
+		Where("cancelled = ? AND cancelled_by = ? AND cancelled_by_id = ?", true, CancelledByHost, hostID).
+		Count(&count).Error
 	return count, err
 }
 
@@ -99,18 +331,127 @@ func (r *repository) FindPendingRequestsByGuestID(guestID uint) ([]ReservationRe
 	return requests, err
 }
 
-func (r *repository) FindReservationsByGuestID(guestID uint) ([]Reservation, error) {
+func (r *repository) FindRequestsPage(filter RequestFilter, page PageRequest) (*Page[ReservationRequest], error) {
+	var total int64
+	if err := r.db.Model(&ReservationRequest{}).Scopes(filter.scope).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var requests []ReservationRequest
+	if err := r.db.Scopes(filter.scope, page.scope).Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	return newPage(requests, total, page), nil
+}
+
+func (r *repository) FindRequests(filter RequestFilter) ([]ReservationRequest, error) {
+	var requests []ReservationRequest
+	err := r.db.Scopes(filter.scope).Order("date_from, id").Find(&requests).Error
+	return requests, err
+}
+
+func (r *repository) FindReservationsPage(filter ReservationFilter, page PageRequest) (*Page[Reservation], error) {
+	var total int64
+	if err := r.db.Model(&Reservation{}).Scopes(filter.scope).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
 	var reservations []Reservation
-	err := r.db.Where("guest_id = ?", guestID).Find(&reservations).Error
-	return reservations, err
+	if err := r.db.Scopes(filter.scope, page.scope).Find(&reservations).Error; err != nil {
+		return nil, err
+	}
+	return newPage(reservations, total, page), nil
 }
 
-func (r *repository) FindReservationsByRoomID(roomID uint) ([]Reservation, error) {
+func (r *repository) FindReservations(filter ReservationFilter) ([]Reservation, error) {
 	var reservations []Reservation
-	err := r.db.Where("room_id = ?", roomID).Find(&reservations).Error
+	err := r.db.Scopes(filter.scope).Order("date_from, id").Find(&reservations).Error
 	return reservations, err
 }
 
+func (r *repository) CreateModification(m *ReservationModification) error {
+	return r.db.Create(m).Error
+}
+
+func (r *repository) FindModificationByID(id uint) (*ReservationModification, error) {
+	var m ReservationModification
+	if err := r.db.First(&m, id).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *repository) FindModificationByIDForUpdate(id uint) (*ReservationModification, error) {
+	var m ReservationModification
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&m, id).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *repository) FindModificationsByReservationID(reservationID uint) ([]ReservationModification, error) {
+	var modifications []ReservationModification
+	err := r.db.Where("reservation_id = ?", reservationID).Order("created_at, id").Find(&modifications).Error
+	return modifications, err
+}
+
+func (r *repository) HasPendingModification(reservationID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&ReservationModification{}).
+		Where("reservation_id = ? AND status = ?", reservationID, ModificationPending).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *repository) SetModificationStatus(id uint, status ModificationStatus, decidedAt time.Time) error {
+	return r.db.Model(&ReservationModification{}).Where("id = ?", id).Updates(map[string]any{
+		"status":     status,
+		"decided_at": decidedAt,
+	}).Error
+}
+
+func (r *repository) CancelPendingModifications(reservationID uint, at time.Time) ([]ReservationModification, error) {
+	var modifications []ReservationModification
+	err := r.db.Model(&modifications).
+		Clauses(clause.Returning{}).
+		Where("reservation_id = ? AND status = ?", reservationID, ModificationPending).
+		Updates(map[string]any{"status": ModificationCancelled, "decided_at": at}).Error
+	return modifications, err
+}
+
+func (r *repository) ApplyModification(m *ReservationModification) error {
+	return r.db.Model(&Reservation{}).Where("id = ?", m.ReservationID).Updates(map[string]any{
+		"date_from":            m.Proposed.DateFrom,
+		"date_to":              m.Proposed.DateTo,
+		"guest_count":          m.Proposed.GuestCount,
+		"cost":                 m.Proposed.Cost,
+		"room_availability_id": m.RoomAvailabilityID,
+		"room_price_id":        m.RoomPriceID,
+	}).Error
+}
+
+func (r *repository) RecordTransition(t *StatusTransition) error {
+	return r.db.Create(t).Error
+}
+
+func (r *repository) FindTransitions(subject TransitionSubject, id uint) ([]StatusTransition, error) {
+	var transitions []StatusTransition
+	err := r.db.Where("subject_type = ? AND subject_id = ?", subject, id).Order("created_at, id").Find(&transitions).Error
+	return transitions, err
+}
+
+func (r *repository) FindCancellationPolicy(roomID uint) (*CancellationPolicy, error) {
+	var p CancellationPolicy
+	if err := r.db.First(&p, "room_id = ?", roomID).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *repository) SaveCancellationPolicy(p *CancellationPolicy) error {
+	return r.db.Save(p).Error
+}
+
 func (r *repository) FindRequestByID(id uint) (*ReservationRequest, error) {
 	var req ReservationRequest
 	err := r.db.First(&req, id).Error
@@ -120,6 +461,46 @@ func (r *repository) FindRequestByID(id uint) (*ReservationRequest, error) {
 	return &req, nil
 }
 
+func (r *repository) FindRequestByIDUnscoped(id uint) (*ReservationRequest, error) {
+	var req ReservationRequest
+	err := r.db.Unscoped().First(&req, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (r *repository) FindRequestByIDForUpdate(id uint) (*ReservationRequest, error) {
+	var req ReservationRequest
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&req, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (r *repository) FindExpirableRequests(createdBefore, startsBy time.Time, limit int) ([]ReservationRequest, error) {
+	var requests []ReservationRequest
+	err := r.db.
+		Where("status = ? AND (created_at < ? OR date_from <= ?)", Pending, createdBefore, startsBy).
+		Order("created_at, id").
+		Limit(limit).
+		Find(&requests).Error
+	return requests, err
+}
+
+func (r *repository) ExpireRequests(ids []uint) ([]ReservationRequest, error) {
+	var requests []ReservationRequest
+	if len(ids) == 0 {
+		return requests, nil
+	}
+	err := r.db.Model(&requests).
+		Clauses(clause.Returning{}).
+		Where("id IN ? AND status = ?", ids, Pending).
+		Update("status", Expired).Error
+	return requests, err
+}
+
 func (r *repository) FindReservationById(id uint) (*Reservation, error) {
 	var reservation Reservation
 	err := r.db.Where("id = ?", id).First(&reservation).Error
@@ -129,21 +510,302 @@ func (r *repository) FindReservationById(id uint) (*Reservation, error) {
 	return &reservation, nil
 }
 
-func (r *repository) HasGuestPastReservationInRooms(guestID uint, roomIDs []uint, now time.Time) (bool, error) {
+func (r *repository) FindReservationByIDForUpdate(id uint) (*Reservation, error) {
+	var reservation Reservation
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reservation, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+func (r *repository) HasGuestCompletedStayInRooms(guestID uint, roomIDs []uint) (bool, error) {
 	if len(roomIDs) == 0 {
 		return false, nil
 	}
 	var count int64
 	err := r.db.Model(&Reservation{}).
-		Where("guest_id = ? AND cancelled = ? AND date_to < ? AND room_id IN ?", guestID, false, now, roomIDs).
+		Where("guest_id = ? AND status = ? AND room_id IN ?", guestID, ReservationCheckedOut, roomIDs).
 		Count(&count).Error
 	return count > 0, err
 }
 
-func (r *repository) GetAllPastReservationsByGuest(guestID uint, before time.Time) ([]Reservation, error) {
+func (r *repository) SetStayStatus(id uint, status ReservationStatus, at time.Time) error {
+	updates := map[string]any{"status": status}
+	switch status {
+	case ReservationCheckedIn:
+		updates["checked_in_at"] = at
+	case ReservationCheckedOut:
+		updates["checked_out_at"] = at
+	}
+	return r.db.Model(&Reservation{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *repository) FindOverdueStays(startedBefore, endedBefore time.Time, limit int) ([]Reservation, error) {
 	var reservations []Reservation
-	err := r.db.Where("guest_id = ? AND cancelled = false AND date_to <= ?", guestID, before).
-        Order("date_to DESC").
-        Find(&reservations).Error
+	err := r.db.
+		Where("(status = ? AND date_from < ?) OR (status IN ? AND date_to < ?)",
+			ReservationConfirmed, startedBefore,
+			[]ReservationStatus{ReservationConfirmed, ReservationCheckedIn}, endedBefore).
+		Order("date_from, id").
+		Limit(limit).
+		Find(&reservations).Error
 	return reservations, err
-}
\ No newline at end of file
+}
+
+func (r *repository) CreateFeedToken(t *FeedToken) error {
+	return r.db.Create(t).Error
+}
+
+func (r *repository) FindFeedTokenByHash(hash string) (*FeedToken, error) {
+	var t FeedToken
+	if err := r.db.Where("token_hash = ? AND revoked_at IS NULL", hash).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *repository) FindFeedTokensByUserID(userID uint) ([]FeedToken, error) {
+	var tokens []FeedToken
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *repository) RevokeFeedToken(id, userID uint, at time.Time) error {
+	result := r.db.Model(&FeedToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *repository) CreateWaitlistEntry(e *WaitlistEntry) error {
+	return r.db.Create(e).Error
+}
+
+func (r *repository) FindWaitlistEntryByID(id uint) (*WaitlistEntry, error) {
+	var e WaitlistEntry
+	if err := r.db.First(&e, id).Error; err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *repository) FindWaitlistEntryByIDForUpdate(id uint) (*WaitlistEntry, error) {
+	var e WaitlistEntry
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&e, id).Error; err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *repository) FindWaitlistEntriesByGuestID(guestID uint) ([]WaitlistEntry, error) {
+	var entries []WaitlistEntry
+	err := r.db.Where("guest_id = ?", guestID).Order("created_at DESC, id DESC").Find(&entries).Error
+	return entries, err
+}
+
+func (r *repository) FindWaitingEntries(roomID uint, from, to time.Time) ([]WaitlistEntry, error) {
+	var entries []WaitlistEntry
+	err := r.db.
+		Where("room_id = ? AND status = ? AND date_from <= ? AND date_to >= ?", roomID, WaitlistWaiting, to, from).
+		Order("created_at, id").
+		Find(&entries).Error
+	return entries, err
+}
+
+func (r *repository) HasWaitlistOffer(roomID uint, from, to time.Time, exceptGuestID uint, now time.Time) (bool, error) {
+	var exists bool
+	err := r.db.Raw(
+		`SELECT EXISTS (SELECT 1 FROM waitlist_entries WHERE room_id = ? AND guest_id <> ? AND status = ?
+			AND offer_expires_at > ? AND date_from <= ? AND date_to >= ?)`,
+		roomID, exceptGuestID, WaitlistOffered, now, to, from,
+	).Scan(&exists).Error
+	return exists, err
+}
+
+func (r *repository) FindLapsedWaitlistEntries(now time.Time, limit int) ([]WaitlistEntry, error) {
+	var entries []WaitlistEntry
+	err := r.db.
+		Where("(status = ? AND offer_expires_at <= ?) OR (status IN ? AND date_from <= ?)",
+			WaitlistOffered, now, []WaitlistStatus{WaitlistWaiting, WaitlistOffered}, now).
+		Order("id").
+		Limit(limit).
+		Find(&entries).Error
+	return entries, err
+}
+
+func (r *repository) UpdateWaitlistEntry(e *WaitlistEntry) error {
+	return r.db.Model(&WaitlistEntry{}).
+		Where("id = ?", e.ID).
+		Updates(map[string]any{
+			"status":           e.Status,
+			"offered_at":       e.OfferedAt,
+			"offer_expires_at": e.OfferExpiresAt,
+			"request_id":       e.RequestID,
+		}).Error
+}
+
+func (r *repository) CreateHold(h *Hold) error {
+	return r.db.Create(h).Error
+}
+
+func (r *repository) HasHold(roomID uint, from, to time.Time, exceptGuestID uint, now time.Time) (bool, error) {
+	var exists bool
+	err := r.db.Raw(
+		`SELECT EXISTS (SELECT 1 FROM holds WHERE room_id = ? AND guest_id <> ? AND status = ?
+			AND expires_at > ? AND date_from <= ? AND date_to >= ?)`,
+		roomID, exceptGuestID, HoldActive, now, to, from,
+	).Scan(&exists).Error
+	return exists, err
+}
+
+func (r *repository) CountActiveHolds(guestID uint, now time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&Hold{}).
+		Where("guest_id = ? AND status = ? AND expires_at > ?", guestID, HoldActive, now).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *repository) ReleaseHold(id, guestID uint, at time.Time) error {
+	result := r.db.Model(&Hold{}).
+		Where("id = ? AND guest_id = ? AND status = ? AND expires_at > ?", id, guestID, HoldActive, at).
+		Updates(map[string]any{"status": HoldReleased, "ended_at": at})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *repository) ConvertHolds(guestID, roomID uint, from, to time.Time, requestID uint, now time.Time) (int64, error) {
+	result := r.db.Model(&Hold{}).
+		Where("guest_id = ? AND room_id = ? AND status = ? AND expires_at > ? AND date_from <= ? AND date_to >= ?",
+			guestID, roomID, HoldActive, now, to, from).
+		Updates(map[string]any{"status": HoldConverted, "ended_at": now, "request_id": requestID})
+	return result.RowsAffected, result.Error
+}
+
+func (r *repository) ExpireHolds(now time.Time) (int64, error) {
+	result := r.db.Model(&Hold{}).
+		Where("status = ? AND expires_at <= ?", HoldActive, now).
+		Updates(map[string]any{"status": HoldExpired, "ended_at": gorm.Expr("expires_at")})
+	return result.RowsAffected, result.Error
+}
+
+func (r *repository) CreateRequestGroup(g *RequestGroup) error {
+	return r.db.Create(g).Error
+}
+
+func (r *repository) FindRequestGroupByID(id uint) (*RequestGroup, error) {
+	var g RequestGroup
+	if err := r.db.First(&g, id).Error; err != nil {
+		return nil, err
+	}
+	err := r.db.Unscoped().Where("group_id = ?", id).Order("id").Find(&g.Requests).Error
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (r *repository) RejectPendingRequestsInGroup(groupID uint) ([]ReservationRequest, error) {
+	var requests []ReservationRequest
+	err := r.db.Model(&requests).
+		Clauses(clause.Returning{}).
+		Where("group_id = ? AND status = ?", groupID, Pending).
+		Update("status", Rejected).Error
+	return requests, err
+}
+
+func (r *repository) EnqueueNotification(n *NotificationOutbox) error {
+	return r.db.Create(n).Error
+}
+
+func (r *repository) ClaimDueNotifications(now time.Time, lease time.Duration, limit int) ([]NotificationOutbox, error) {
+	var notifications []NotificationOutbox
+	err := r.db.Raw(`
+		UPDATE notification_outbox SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM notification_outbox
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		now.Add(lease), OutboxPending, now, limit,
+	).Scan(&notifications).Error
+	return notifications, err
+}
+
+func (r *repository) UpdateNotificationDelivery(n *NotificationOutbox) error {
+	return r.db.Model(&NotificationOutbox{}).
+		Where("id = ?", n.ID).
+		Updates(map[string]any{
+			"status":          n.Status,
+			"attempts":        n.Attempts,
+			"next_attempt_at": n.NextAttemptAt,
+			"last_error":      n.LastError,
+			"sent_at":         n.SentAt,
+		}).Error
+}
+
+func (r *repository) CountNotificationsByStatus(status OutboxStatus) (int64, error) {
+	var count int64
+	err := r.db.Model(&NotificationOutbox{}).
+		Where("status = ?", status).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *repository) ClaimIdempotencyKey(k *IdempotencyKey, staleBefore time.Time) (*IdempotencyKey, error) {
+	var claimed []IdempotencyKey
+	err := r.db.Raw(`
+		INSERT INTO idempotency_keys (user_id, key, request_hash, status_code, body, created_at, expires_at)
+		VALUES (?, ?, ?, 0, NULL, ?, ?)
+		ON CONFLICT (user_id, key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			status_code = 0,
+			body = NULL,
+			created_at = EXCLUDED.created_at,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+			OR (idempotency_keys.status_code = 0 AND idempotency_keys.created_at < ?)
+		RETURNING *`,
+		k.UserID, k.Key, k.RequestHash, k.CreatedAt, k.ExpiresAt, staleBefore,
+	).Scan(&claimed).Error
+	if err != nil || len(claimed) > 0 {
+		return nil, err
+	}
+
+	var existing IdempotencyKey
+	err = r.db.Where("user_id = ? AND key = ?", k.UserID, k.Key).First(&existing).Error
+	if err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+func (r *repository) CompleteIdempotencyKey(userID uint, key string, statusCode int, body []byte) error {
+	return r.db.Model(&IdempotencyKey{}).
+		Where("user_id = ? AND key = ?", userID, key).
+		Updates(map[string]any{"status_code": statusCode, "body": body}).Error
+}
+
+func (r *repository) ReleaseIdempotencyKey(userID uint, key string) error {
+	return r.db.Where("user_id = ? AND key = ?", userID, key).Delete(&IdempotencyKey{}).Error
+}
+
+func (r *repository) DeleteExpiredIdempotencyKeys(now time.Time) (int64, error) {
+	result := r.db.Where("expires_at <= ?", now).Delete(&IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
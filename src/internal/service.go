@@ -3,11 +3,19 @@ package internal
 import (
 	"bookem-reservation-service/client/notificationclient"
 	"bookem-reservation-service/client/roomclient"
+	"bookem-reservation-service/client/transport"
 	"bookem-reservation-service/client/userclient"
 	"bookem-reservation-service/util"
+	"cmp"
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
 )
 
 // AuthContext is used in cases where callerID is not enoug
@@ -17,40 +25,57 @@ type AuthContext struct {
 }
 
 type Service interface {
-	CreateRequest(context context.Context, authctx AuthContext, dto CreateReservationRequestDTO) (*ReservationRequest, error)
+	// CreateRequest creates a reservation request. If the room approves
+	// requests automatically, the reservation made for it is returned too.
+	CreateRequest(context context.Context, authctx AuthContext, dto CreateReservationRequestDTO) (*ReservationRequest, *Reservation, error)
 
 	// FindPendingRequestsByGuest answers the question:
 	//
 	// "which reservation requests have I created, that are not accepted or rejected?"
-	FindPendingRequestsByGuest(context context.Context, callerID uint) ([]ReservationRequest, error)
+	//
+	// Other statuses can be asked for through the query.
+	FindPendingRequestsByGuest(context context.Context, callerID uint, query ListQuery) (*Page[ReservationRequest], error)
 
 	// FindPendingRequestsByRoom answers the question:
 	//
 	// "which reservation requests are there for this room, that are not accepted or rejected?"
-	FindPendingRequestsByRoom(context context.Context, callerID uint, roomID uint) ([]ReservationRequest, error)
+	//
+	// Other statuses can be asked for through the query.
+	FindPendingRequestsByRoom(context context.Context, callerID uint, roomID uint, query ListQuery) (*Page[ReservationRequest], error)
 
-	// DeleteRequest removes a reservation request by the guest. This happens
+	// DeleteRequest withdraws a reservation request by the guest. This happens
 	// when the guest changes his mind before the request has been processed
-	// (accepted/rejected).
+	// (accepted/rejected). The request is kept, with the Withdrawn status.
 	DeleteRequest(context context.Context, callerID uint, requestID uint) error
 
+	// UpdateRequest changes the dates or guest count of the guest's pending
+	// request. It's checked and priced again like a new request, and the host
+	// is told it changed.
+	UpdateRequest(ctx context.Context, authctx AuthContext, requestID uint, dto UpdateReservationRequestDTO) (*ReservationRequest, error)
+
 	// AreThereReservationsOnDays checks if a room has reservations in the
 	// specified date range.
 	//
 	// Note that this is referring to RESERVATIONS and not RESERVATION REQUESTS.
 	AreThereReservationsOnDays(context context.Context, roomID uint, from, to time.Time) (bool, error)
 
-	// GetActiveGuestReservations checks whether a guest has any active reservations
-	// now or in the future.
-	GetActiveGuestReservations(context context.Context, guestID uint) ([]Reservation, error)
+	// IsRoomAvailable tells whether the caller could request the room between
+	// from and to: no reservation overlaps the dates, and nobody else holds
+	// them or has been offered them from the waitlist. callerID can be 0.
+	IsRoomAvailable(ctx context.Context, callerID uint, roomID uint, from, to time.Time) (bool, error)
 
-	// GetActiveHostReservations checks whether a host has any active reservations
-	// now or in the future.
-	GetActiveHostReservations(context context.Context, hostID uint) ([]Reservation, error)
+	// CreateHold keeps the room's dates for the guest for a few minutes, so
+	// nobody else can request them while the guest does.
+	CreateHold(ctx context.Context, guestID uint, dto CreateHoldDTO) (*Hold, error)
+	ReleaseHold(ctx context.Context, guestID uint, holdID uint) error
 
-	// ExtractActiveReservations filters the provided list of reservations
-	// and returns only the active ones.
-	ExtractActiveReservations(reservations []Reservation) []Reservation
+	// GetActiveGuestReservations lists the guest's reservations that are
+	// active now or in the future.
+	GetActiveGuestReservations(context context.Context, guestID uint, query ListQuery) (*Page[Reservation], error)
+
+	// GetActiveHostReservations lists the reservations of the host's rooms that
+	// are active now or in the future.
+	GetActiveHostReservations(context context.Context, hostID uint, query ListQuery) (*Page[Reservation], error)
 
 	// RejectReservationRequest changes the status of a pending reservation request
 	// to rejected.
@@ -58,152 +83,332 @@ type Service interface {
 
 	// ApproveReservationRequest approves a reservation request for a room
 	// and creates a corresponding reservation record.
+	//
+	// The approval runs in a single transaction holding the room's lock. If the
+	// request stopped being pending or the room got an overlapping reservation
+	// in the meantime (e.g. a concurrent approval won), ErrConflict is returned.
 	ApproveReservationRequest(context context.Context, hostID, requestID uint, jwt string) error
 
-	CancelReservation(ctx context.Context, callerID uint, reservationID uint, jwt string) error
+	// DecideRequests approves and rejects several pending requests of the
+	// host's rooms at once, and returns the outcome of each decision in the
+	// order given. Rooms and guests are fetched once for the whole batch.
+	// Rejections are made first, then approvals from the earliest made
+	// request on, so of overlapping requests the earliest one wins. Each
+	// guest whose requests were decided gets one notification about it.
+	DecideRequests(ctx context.Context, hostID uint, decisions []RequestDecision) ([]DecisionResult, error)
+
+	// CreateGroupRequest requests several rooms of one host for the same
+	// dates at once. The requests are approved or rejected together, and
+	// only as a group. If every room approves requests automatically, the
+	// group is approved right away and its reservations are returned.
+	CreateGroupRequest(ctx context.Context, authctx AuthContext, dto CreateGroupRequestDTO) (*RequestGroup, []Reservation, error)
+	// GetGroupRequest returns the group to its guest, its host and admins.
+	GetGroupRequest(ctx context.Context, caller Actor, groupID uint) (*RequestGroup, error)
+	// ApproveGroupRequest approves all of the group's requests in a single
+	// transaction. If any of the rooms got an overlapping reservation in the
+	// meantime, nothing is approved and ErrConflict is returned.
+	ApproveGroupRequest(ctx context.Context, hostID, groupID uint) (*RequestGroup, []Reservation, error)
+	RejectGroupRequest(ctx context.Context, hostID, groupID uint) error
+	WithdrawGroupRequest(ctx context.Context, guestID, groupID uint) error
+
+	// CancelReservation cancels the guest's reservation. What's refunded
+	// follows the cancellation policy the reservation was approved under.
+	CancelReservation(ctx context.Context, callerID uint, reservationID uint, jwt string) (*CancellationResult, error)
+
+	// HostCancelReservation cancels a reservation of one of the host's rooms,
+	// e.g. because of maintenance or overbooking. The reason is required and
+	// shown to the guest. It doesn't count against the guest, and the guest
+	// gets a full refund.
+	HostCancelReservation(ctx context.Context, hostID uint, reservationID uint, reason string) (*CancellationResult, error)
+
+	// CheckIn, CheckOut and MarkNoShow let the host record what happened to a
+	// reservation of one of their rooms once its stay began. Guests can only
+	// rate stays they checked out of. Stays the host doesn't record are moved
+	// on by the StayCloser.
+	CheckIn(ctx context.Context, hostID uint, reservationID uint) (*Reservation, error)
+	CheckOut(ctx context.Context, hostID uint, reservationID uint) (*Reservation, error)
+	MarkNoShow(ctx context.Context, hostID uint, reservationID uint) (*Reservation, error)
+
+	// GetCancellationPolicy returns the room's cancellation policy, which is
+	// the flexible one if the host never picked one.
+	GetCancellationPolicy(ctx context.Context, roomID uint) (*CancellationPolicy, error)
+	// SetCancellationPolicy sets the policy of one of the host's rooms. It
+	// only applies to reservations approved from now on.
+	SetCancellationPolicy(ctx context.Context, hostID uint, roomID uint, terms CancellationTerms) (*CancellationPolicy, error)
+
+	// GetRoomCalendar lays out one of the host's rooms day by day between from
+	// and to: what's booked, which pending requests want it, and the room's
+	// current availability and prices.
+	GetRoomCalendar(ctx context.Context, hostID uint, roomID uint, from, to time.Time) (*RoomCalendar, error)
+
+	// CreateFeedToken makes a calendar feed token for the caller, and returns
+	// it along with the token itself, which isn't stored anywhere. Guests get
+	// feeds of their stays, hosts of one of their rooms or of all of them.
+	CreateFeedToken(ctx context.Context, caller Actor, kind FeedKind, roomID uint) (*FeedToken, string, error)
+	GetFeedTokens(ctx context.Context, userID uint) ([]FeedToken, error)
+	RevokeFeedToken(ctx context.Context, userID uint, tokenID uint) error
+	// GetFeed builds the feed the token is for. Unknown and revoked tokens get
+	// ErrFeedNotFound, and so do room feeds of rooms the host no longer has.
+	GetFeed(ctx context.Context, token string) (*Feed, error)
+
+	// JoinWaitlist puts the guest on the waitlist of a room's booked dates.
+	// When a reservation overlapping them is cancelled, the dates are offered
+	// to the waiting guests in the order they joined.
+	JoinWaitlist(ctx context.Context, guestID uint, dto CreateWaitlistEntryDTO) (*WaitlistEntry, error)
+	GetWaitlistEntries(ctx context.Context, guestID uint) ([]WaitlistEntry, error)
+	// LeaveWaitlist takes the guest off the waitlist. An offer the guest
+	// held goes to the next guest waiting.
+	LeaveWaitlist(ctx context.Context, guestID uint, entryID uint) error
+	// ClaimWaitlistOffer turns an offer the guest holds into a reservation
+	// request, made like any other through CreateRequest.
+	ClaimWaitlistOffer(ctx context.Context, authctx AuthContext, entryID uint) (*ReservationRequest, *Reservation, error)
+
+	// RequestModification proposes new dates or a new guest count for the
+	// guest's reservation. The room is asked for the new price, and the change
+	// is applied right away if the room approves requests automatically;
+	// otherwise it waits for the host.
+	RequestModification(ctx context.Context, authctx AuthContext, reservationID uint, dto ModifyReservationDTO) (*ReservationModification, error)
+	// ApproveModification applies a pending modification to its reservation.
+	// ErrConflict is returned if the new dates were taken in the meantime.
+	ApproveModification(ctx context.Context, hostID uint, modificationID uint) (*ReservationModification, error)
+	RejectModification(ctx context.Context, hostID uint, modificationID uint) error
+	// GetModifications lists every modification of the reservation, oldest
+	// first. It's open to the guest and the host of the reservation.
+	GetModifications(ctx context.Context, callerID uint, reservationID uint) ([]ReservationModification, error)
+
+	// GetRequestHistory, GetReservationHistory and GetModificationHistory
+	// return every status change of a request, reservation or modification,
+	// oldest first. They're open to its guest, the host of its room and
+	// admins.
+	GetRequestHistory(ctx context.Context, caller Actor, requestID uint) ([]StatusTransition, error)
+	GetReservationHistory(ctx context.Context, caller Actor, reservationID uint) ([]StatusTransition, error)
+	GetModificationHistory(ctx context.Context, caller Actor, modificationID uint) ([]StatusTransition, error)
 
 	GetGuestCancellationCount(context context.Context, guestID uint) (uint, error)
+	GetHostCancellationCount(ctx context.Context, hostID uint) (uint, error)
 
 	CanUserRateHost(ctx context.Context, guestID, hostID uint) (bool, error)
 	CanUserRateRoom(ctx context.Context, guestID, roomID uint) (bool, error)
 
-	GetPastReservationsByGuest(ctx context.Context, guestID uint, before time.Time) ([]ReservationDTO, error)
+	GetPastReservationsByGuest(ctx context.Context, guestID uint, before time.Time, query ListQuery) (*Page[Reservation], error)
 }
 
+// Notifications are never sent from here: they are enqueued in the
+// notification outbox in the same transaction as the change they announce,
+// and delivered by the OutboxDispatcher.
 type service struct {
-	repo               Repository
-	userClient         userclient.UserClient
-	roomClient         roomclient.RoomClient
-	notificationClient notificationclient.NotificationClient
+	repo       Repository
+	userClient userclient.UserClient
+	roomClient roomclient.RoomClient
 }
 
 func NewService(
 	roomRepo Repository,
 	userClient userclient.UserClient,
 	roomClient roomclient.RoomClient,
-	notificationClient notificationclient.NotificationClient,
 ) Service {
-	return &service{roomRepo, userClient, roomClient, notificationClient}
+	return &service{roomRepo, userClient, roomClient}
+}
+
+func (s *service) CreateRequest(ctx context.Context, authctx AuthContext, dto CreateReservationRequestDTO) (*ReservationRequest, *Reservation, error) {
+	return s.createRequest(ctx, authctx, dto, nil)
 }
 
-func (s *service) CreateRequest(context context.Context, authctx AuthContext, dto CreateReservationRequestDTO) (*ReservationRequest, error) {
+// createRequest makes the request. If inTx is set, it runs in the transaction
+// storing the request, right after it's stored, and the request isn't made if
+// inTx fails.
+func (s *service) createRequest(ctx context.Context, authctx AuthContext, dto CreateReservationRequestDTO, inTx func(tx Repository, req *ReservationRequest) error) (*ReservationRequest, *Reservation, error) {
 	callerID := authctx.CallerID
-	jwt := authctx.JWT
 
-	util.TEL.Info("user wants to create a reservation request", nil, "caller_id", authctx.CallerID)
+	util.TEL.Info(ctx, "user wants to create a reservation request", nil, "caller_id", authctx.CallerID)
+
+	ctx, span := util.TEL.Start(ctx, "validate-room-and-user")
+	defer span.End()
+
+	if err := s.checkGuest(ctx, callerID); err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now().UTC()
+	req, room, err := s.prepareRequest(ctx, authctx, dto)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, span = util.TEL.Start(ctx, "create-reservation-request-in-db")
+	defer span.End()
+
+	var converted int64
+	err = s.repo.Transaction(func(tx Repository) error {
+		util.TEL.Debug(ctx, "lock room", "room_id", req.RoomID)
+		if err := tx.LockRoom(req.RoomID); err != nil {
+			util.TEL.Error(ctx, "could not lock room", err, "room_id", req.RoomID)
+			return err
+		}
+
+		if err := checkNotHeld(ctx, tx, req.RoomID, req.DateFrom, req.DateTo, callerID, now); err != nil {
+			return err
+		}
+		if err := checkNotOffered(ctx, tx, req.RoomID, req.DateFrom, req.DateTo, callerID, now); err != nil {
+			return err
+		}
+
+		var err error
+		converted, err = storeRequest(ctx, tx, req, now)
+		if err != nil {
+			return err
+		}
+		if inTx != nil {
+			if err := inTx(tx, req); err != nil {
+				return err
+			}
+		}
+
+		util.TEL.Debug(ctx, "enqueue notification for host", "host_id", room.HostID)
+		return tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+			ReceiverID: room.HostID, // Host of the room receives the notification
+			Type:       notificationclient.ReservationRequested,
+			Subject:    callerID, // Guest ID (who made the request)
+			Object:     room.ID,
+		}))
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	holdsEnded.WithLabelValues("converted").Add(float64(converted))
+
+	var res *Reservation
+	if room.AutoApprove {
+		util.TEL.Info(ctx, "auto-approval is enabled, accepting reservation request automatically", "room_id", room.ID)
+		res, err = s.acceptReservationRequest(ctx, req, room, SystemActor, "auto-approved")
+		if err != nil {
+			util.TEL.Error(ctx, "auto-approval process failed", err)
+			return nil, nil, err
+		}
+	}
+
+	util.TEL.Info(ctx, "reservation request created successfully", "request_id", req.ID)
 
-	util.TEL.Push(context, "validate-room-and-user")
-	defer util.TEL.Pop()
+	return req, res, nil
+}
 
-	util.TEL.Debug("check if user exists", nil, "id", callerID)
-	user, err := s.userClient.FindById(util.TEL.Ctx(), callerID)
+// checkGuest makes sure the caller is a guest whose account still exists.
+func (s *service) checkGuest(ctx context.Context, callerID uint) error {
+	util.TEL.Debug(ctx, "check if user exists", nil, "id", callerID)
+	user, err := s.userClient.FindById(ctx, callerID)
 	if err != nil {
-		util.TEL.Error("user does not exist", err, "id", callerID)
-		return nil, ErrUnauthenticated
+		util.TEL.Error(ctx, "user does not exist", err, "id", callerID)
+		return downstreamError(err, ErrUnauthenticated)
 	}
 
-	util.TEL.Debug("check if user is a guest", nil, "id", callerID)
+	util.TEL.Debug(ctx, "check if user is a guest", nil, "id", callerID)
 	if user.Role != string(util.Guest) {
-		util.TEL.Error("user has a bad role", nil, "role", user.Role)
-		return nil, ErrUnauthorized
+		util.TEL.Error(ctx, "user has a bad role", nil, "role", user.Role)
+		return ErrUnauthorized
 	}
 
-	util.TEL.Debug("check-account-deletion", nil, "id", callerID)
+	util.TEL.Debug(ctx, "check-account-deletion", nil, "id", callerID)
 	if user.Deleted {
-		util.TEL.Error("account is deleted", nil, "id", callerID)
-		return nil, ErrUnauthorized
+		util.TEL.Error(ctx, "account is deleted", nil, "id", callerID)
+		return ErrUnauthorized
 	}
+	return nil
+}
+
+// prepareRequest checks that the caller can request the room's dates, and
+// prices the stay. It returns the request, ready to be stored, and its room.
+// Holds and waitlist offers are checked when it's stored, under the room's
+// lock.
+func (s *service) prepareRequest(ctx context.Context, authctx AuthContext, dto CreateReservationRequestDTO) (*ReservationRequest, *roomclient.RoomDTO, error) {
+	callerID := authctx.CallerID
 
-	util.TEL.Debug("find room", "id", dto.RoomID)
-	room, err := s.roomClient.FindById(util.TEL.Ctx(), dto.RoomID)
+	util.TEL.Debug(ctx, "find room", "id", dto.RoomID)
+	room, err := s.roomClient.FindById(ctx, dto.RoomID)
 	if err != nil {
-		util.TEL.Error("room not found", err, "id", dto.RoomID)
-		return nil, ErrNotFound("room", dto.RoomID)
+		util.TEL.Error(ctx, "room not found", err, "id", dto.RoomID)
+		return nil, nil, downstreamError(err, ErrNotFound("room", dto.RoomID))
 	}
 
-	util.TEL.Debug("find room availability list", "room_id", room.ID)
-	availList, err := s.roomClient.FindCurrentAvailabilityListOfRoom(util.TEL.Ctx(), room.ID)
+	util.TEL.Debug(ctx, "find room availability list", "room_id", room.ID)
+	availList, err := s.roomClient.FindCurrentAvailabilityListOfRoom(ctx, room.ID)
 	if err != nil {
-		util.TEL.Error("room availability list of room not found", err, "room_id", dto.RoomID)
-		return nil, ErrNotFound("room availability list", dto.RoomID)
+		util.TEL.Error(ctx, "room availability list of room not found", err, "room_id", dto.RoomID)
+		return nil, nil, downstreamError(err, ErrNotFound("room availability list", dto.RoomID))
 	}
 
-	util.TEL.Debug("find room price list", "room_id", room.ID)
-	pricelist, err := s.roomClient.FindCurrentPricelistOfRoom(util.TEL.Ctx(), room.ID)
+	util.TEL.Debug(ctx, "find room price list", "room_id", room.ID)
+	pricelist, err := s.roomClient.FindCurrentPricelistOfRoom(ctx, room.ID)
 	if err != nil {
-		util.TEL.Error("room price list of room not found", err, "room_id", dto.RoomID)
-		return nil, ErrNotFound("room price list", dto.RoomID)
+		util.TEL.Error(ctx, "room price list of room not found", err, "room_id", dto.RoomID)
+		return nil, nil, downstreamError(err, ErrNotFound("room price list", dto.RoomID))
 	}
 
-	util.TEL.Push(context, "query-for-reservation")
-	defer util.TEL.Pop()
+	ctx, span := util.TEL.Start(ctx, "query-for-reservation")
+	defer span.End()
 
-	util.TEL.Debug("query room for reservation data")
+	util.TEL.Debug(ctx, "query room for reservation data")
 	queryDTO := roomclient.RoomReservationQueryDTO{
 		RoomID:     room.ID,
 		DateFrom:   dto.DateFrom,
 		DateTo:     dto.DateTo,
 		GuestCount: dto.GuestCount,
 	}
-	queryResponse, err := s.roomClient.QueryForReservation(util.TEL.Ctx(), jwt, queryDTO)
+	queryResponse, err := s.roomClient.QueryForReservation(ctx, authctx.JWT, queryDTO)
 	if err != nil {
-		util.TEL.Error("could not query room for reservation", err, "room_id", dto.RoomID)
-		return nil, ErrBadRequest
+		util.TEL.Error(ctx, "could not query room for reservation", err, "room_id", dto.RoomID)
+		return nil, nil, downstreamError(err, ErrBadRequest)
 	}
 
 	if !queryResponse.Available {
-		util.TEL.Error("room is not available at this time", err)
-		return nil, ErrBadRequest
+		util.TEL.Error(ctx, "room is not available at this time", err)
+		return nil, nil, ErrBadRequest
 	}
 
-	util.TEL.Debug("calculate price")
+	util.TEL.Debug(ctx, "calculate price")
 	cost := queryResponse.TotalCost
 
-	util.TEL.Push(context, "validate-reservation-request")
-	defer util.TEL.Pop()
+	ctx, span = util.TEL.Start(ctx, "validate-reservation-request")
+	defer span.End()
 
-	util.TEL.Debug("validate fields")
+	util.TEL.Debug(ctx, "validate fields")
 	if dto.GuestCount < 1 {
-		util.TEL.Error("guest count must be at least 1", err, "guest_count", dto.GuestCount)
-		return nil, ErrBadRequestCustom("guest count must be at least 1")
+		util.TEL.Error(ctx, "guest count must be at least 1", err, "guest_count", dto.GuestCount)
+		return nil, nil, ErrBadRequestCustom("guest count must be at least 1")
 	}
 
 	if dto.DateFrom.After(dto.DateTo) {
-		util.TEL.Error("dates are reversed", err, "from", dto.DateFrom, "to", dto.DateTo)
-		return nil, ErrBadRequestCustom("dates are reversed")
+		util.TEL.Error(ctx, "dates are reversed", err, "from", dto.DateFrom, "to", dto.DateTo)
+		return nil, nil, ErrBadRequestCustom("dates are reversed")
 	}
 
-	util.TEL.Debug("prevent overlapping requests for the same room and same guest")
+	util.TEL.Debug(ctx, "prevent overlapping requests for the same room and same guest")
 	existing, err := s.repo.FindPendingRequestsByGuestID(callerID)
 	if err != nil {
-		util.TEL.Error("could not find pending reservation requests of guest", err, "guest_id", callerID)
-		return nil, err
+		util.TEL.Error(ctx, "could not find pending reservation requests of guest", err, "guest_id", callerID)
+		return nil, nil, err
 	}
 	for _, req := range existing {
 		if req.RoomID == dto.RoomID {
 			if util.AreDatesIntersecting(req.DateFrom, req.DateTo, dto.DateFrom, dto.DateTo) {
-				util.TEL.Error("conflicting request of user for room", nil, "user_id", callerID, "room_id", dto.RoomID, "request_from", req.DateFrom, "request_to", req.DateTo, "existing_from", req.DateFrom, "existing_to", req.DateTo)
-				return nil, ErrConflict
+				util.TEL.Error(ctx, "conflicting request of user for room", nil, "user_id", callerID, "room_id", dto.RoomID, "request_from", req.DateFrom, "request_to", req.DateTo, "existing_from", req.DateFrom, "existing_to", req.DateTo)
+				return nil, nil, ErrConflict
 			}
 		}
 	}
 
-	util.TEL.Debug("check if this room has a reservation for this date range", nil)
+	util.TEL.Debug(ctx, "check if this room has a reservation for this date range", nil)
 
-	has, err := s.AreThereReservationsOnDays(util.TEL.Ctx(), dto.RoomID, dto.DateFrom, dto.DateTo)
+	has, err := s.AreThereReservationsOnDays(ctx, dto.RoomID, dto.DateFrom, dto.DateTo)
 	if err != nil {
-		util.TEL.Error("could not check for reservations for room", err, "room_id", dto.RoomID, "from", dto.DateFrom, "to", dto.DateTo)
-		return nil, err
+		util.TEL.Error(ctx, "could not check for reservations for room", err, "room_id", dto.RoomID, "from", dto.DateFrom, "to", dto.DateTo)
+		return nil, nil, err
 	}
 	if has {
-		util.TEL.Error("room has a reservation for this date range, cannot create a request", nil, "room_id", dto.RoomID, "from", dto.DateFrom, "to", dto.DateTo)
-		return nil, ErrConflict
+		util.TEL.Error(ctx, "room has a reservation for this date range, cannot create a request", nil, "room_id", dto.RoomID, "from", dto.DateFrom, "to", dto.DateTo)
+		return nil, nil, ErrConflict
 	}
 
-	util.TEL.Push(context, "create-reservation-request-in-db")
-	defer util.TEL.Pop()
-
-	req := &ReservationRequest{
+	return &ReservationRequest{
 		RoomID:             dto.RoomID,
 		DateFrom:           dto.DateFrom,
 		DateTo:             dto.DateTo,
@@ -213,210 +418,305 @@ func (s *service) CreateRequest(context context.Context, authctx AuthContext, dt
 		RoomAvailabilityID: availList.ID,
 		RoomPriceID:        pricelist.ID,
 		Cost:               cost,
+	}, room, nil
+}
+
+// storeRequest creates a prepared request in tx and converts the guest's
+// holds on its dates. It returns how many holds it converted.
+func storeRequest(ctx context.Context, tx Repository, req *ReservationRequest, now time.Time) (int64, error) {
+	if err := tx.CreateRequest(req); err != nil {
+		util.TEL.Error(ctx, "failed creating a reservation request", err)
+		return 0, err
 	}
 
-	if err := s.repo.CreateRequest(req); err != nil {
-		util.TEL.Error("failed creating a reservation request", err)
-		return nil, err
+	if err := requestStates.apply(ctx, tx, req.ID, "", Pending, Actor{req.GuestID, util.Guest}, ""); err != nil {
+		util.TEL.Error(ctx, "failed recording status transition", err)
+		return 0, err
 	}
 
-	if room.AutoApprove {
-		util.TEL.Info("auto-approval is enabled, accepting reservation request automatically", "room_id", room.ID)
-		if err := s.acceptReservationRequest(util.TEL.Ctx(), req, room, jwt); err != nil {
-			util.TEL.Error("auto-approval process failed", err)
-			return nil, err
-		}
+	converted, err := tx.ConvertHolds(req.GuestID, req.RoomID, req.DateFrom, req.DateTo, req.ID, now)
+	if err != nil {
+		util.TEL.Error(ctx, "failed converting the guest's holds", err)
+		return 0, err
 	}
+	return converted, nil
+}
 
-	util.TEL.Info("reservation request created successfully", "request_id", req.ID)
+// acceptReservationRequest approves the request on behalf of actor; reason
+// ends up in the status history.
+func (s *service) acceptReservationRequest(ctx context.Context, req *ReservationRequest, room *roomclient.RoomDTO, actor Actor, reason string) (*Reservation, error) {
+	util.TEL.Info(ctx, "accept reservation request", "room_id", req.RoomID, "guest_id", req.GuestID)
 
-	util.TEL.Push(context, "create-notification")
-	defer util.TEL.Pop()
+	util.TEL.Debug(ctx, "find current availability and price lists")
+	availList, err := s.roomClient.FindCurrentAvailabilityListOfRoom(ctx, room.ID)
+	if err != nil {
+		util.TEL.Error(ctx, "room availability list not found", err, "room_id", room.ID)
+		return nil, downstreamError(err, ErrNotFound("room availability list", room.ID))
+	}
 
-	createNotifDTO := notificationclient.CreateNotificationDTO{
-		ReceiverID: room.HostID, // Host of the room receives the notification
-		Type:       notificationclient.ReservationRequested,
-		Subject:    callerID, // Guest ID (who made the request)
-		Object:     room.ID,
+	pricelist, err := s.roomClient.FindCurrentPricelistOfRoom(ctx, room.ID)
+	if err != nil {
+		util.TEL.Error(ctx, "room price list not found", err, "room_id", room.ID)
+		return nil, downstreamError(err, ErrNotFound("room price list", room.ID))
 	}
 
-	if _, err := s.notificationClient.CreateNotification(util.TEL.Ctx(), jwt, createNotifDTO); err != nil {
-		util.TEL.Error("failed to send notification to host", err, "host_id", room.HostID)
-		// not returning error – notification failures shouldn’t break reservation creation
+	ctx, span := util.TEL.Start(ctx, "accept-reservation-request-in-db")
+	defer span.End()
+
+	var res *Reservation
+	err = s.repo.Transaction(func(tx Repository) error {
+		util.TEL.Debug(ctx, "lock room", "room_id", req.RoomID)
+		if err := tx.LockRoom(req.RoomID); err != nil {
+			util.TEL.Error(ctx, "could not lock room", err, "room_id", req.RoomID)
+			return err
+		}
+
+		var err error
+		res, err = acceptInTx(ctx, tx, req, availList.ID, pricelist.ID, actor, reason)
+		if err != nil {
+			return err
+		}
+
+		util.TEL.Debug(ctx, "enqueue notification for guest", "guest_id", req.GuestID)
+		return tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+			ReceiverID: req.GuestID, // Guest receives the notification
+			Type:       notificationclient.ReservationAccepted,
+			Subject:    room.HostID,
+			Object:     room.ID,
+		}))
+	})
+	if err != nil {
+		return nil, err
 	}
+	req.Status = Accepted
+
+	util.TEL.Info(ctx, "reservation request accepted successfully", "request_id", req.ID)
 
-	return req, nil
+	return res, nil
 }
 
-func (s *service) acceptReservationRequest(ctx context.Context, req *ReservationRequest, room *roomclient.RoomDTO, jwt string) error {
-	util.TEL.Info("accept reservation request", "room_id", req.RoomID, "guest_id", req.GuestID)
+// acceptInTx approves the request in tx: it makes the reservation and rejects
+// the room's other pending requests for the dates. The caller has to hold
+// the room's lock.
+func acceptInTx(ctx context.Context, tx Repository, req *ReservationRequest, availListID, pricelistID uint, actor Actor, reason string) (*Reservation, error) {
+	util.TEL.Debug(ctx, "recheck request status", "request_id", req.ID)
+	current, err := tx.FindRequestByIDForUpdate(req.ID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find reservation request", err, "request_id", req.ID)
+		return nil, err
+	}
+	if err := requestStates.check(req.ID, current.Status, Accepted, actor); err != nil {
+		util.TEL.Error(ctx, "request can no longer be accepted", err, "request_id", req.ID, "status", current.Status)
+		return nil, err
+	}
 
-	util.TEL.Debug("find current availability and price lists")
-	availList, err := s.roomClient.FindCurrentAvailabilityListOfRoom(util.TEL.Ctx(), room.ID)
+	util.TEL.Debug(ctx, "recheck for overlapping reservations", "room_id", req.RoomID)
+	has, err := tx.HasReservationsInRange(req.RoomID, req.DateFrom, req.DateTo)
 	if err != nil {
-		util.TEL.Error("room availability list not found", err, "room_id", room.ID)
-		return ErrNotFound("room availability list", room.ID)
+		util.TEL.Error(ctx, "could not check for reservations for room", err, "room_id", req.RoomID)
+		return nil, err
+	}
+	if has {
+		util.TEL.Error(ctx, "room got a reservation for this date range in the meantime", nil, "room_id", req.RoomID, "from", req.DateFrom, "to", req.DateTo)
+		return nil, ErrConflict
 	}
 
-	pricelist, err := s.roomClient.FindCurrentPricelistOfRoom(util.TEL.Ctx(), room.ID)
+	terms, err := cancellationTerms(tx, req.RoomID)
 	if err != nil {
-		util.TEL.Error("room price list not found", err, "room_id", room.ID)
-		return ErrNotFound("room price list", room.ID)
+		util.TEL.Error(ctx, "could not find cancellation policy", err, "room_id", req.RoomID)
+		return nil, err
 	}
 
-	util.TEL.Debug("create reservation")
+	util.TEL.Debug(ctx, "create reservation")
 	res := &Reservation{
 		RoomID:             req.RoomID,
-		RoomAvailabilityID: availList.ID,
-		RoomPriceID:        pricelist.ID,
+		RoomAvailabilityID: availListID,
+		RoomPriceID:        pricelistID,
 		GuestID:            req.GuestID,
 		DateFrom:           req.DateFrom,
 		DateTo:             req.DateTo,
 		GuestCount:         req.GuestCount,
+		Status:             ReservationConfirmed,
 		Cancelled:          false,
 		Cost:               req.Cost,
+		CancellationTerms:  terms,
 	}
-	if err := s.repo.CreateReservation(res); err != nil {
-		util.TEL.Error("could not create reservation", err)
-		return err
+	if err := tx.CreateReservation(res); err != nil {
+		util.TEL.Error(ctx, "could not create reservation", err)
+		return nil, err
 	}
 
-	util.TEL.Debug("reject overlapping pending requests")
-	if err := s.repo.RejectPendingRequestsInRange(req.RoomID, req.DateFrom, req.DateTo); err != nil {
-		util.TEL.Warn("could not reject overlapping requests", "room_id", req.RoomID)
+	if err := reservationStates.apply(ctx, tx, res.ID, "", ReservationConfirmed, actor, reason); err != nil {
+		util.TEL.Error(ctx, "could not record status transition", err)
+		return nil, err
 	}
 
-	util.TEL.Debug("update current request to accepted")
-	if err := s.repo.SetRequestStatus(req.ID, Accepted); err != nil {
-		util.TEL.Error("failed updating request status to accepted", err)
-		return err
+	util.TEL.Debug(ctx, "update current request to accepted")
+	if err := tx.SetRequestStatus(req.ID, Accepted); err != nil {
+		util.TEL.Error(ctx, "failed updating request status to accepted", err)
+		return nil, err
 	}
-	req.Status = Accepted
-
-	util.TEL.Info("reservation request accepted successfully", "request_id", req.ID)
-
-	util.TEL.Push(ctx, "create-notification")
-	defer util.TEL.Pop()
 
-	createNotifDTO := notificationclient.CreateNotificationDTO{
-		ReceiverID: req.GuestID, // Guest receives the notification
-		Type:       notificationclient.ReservationAccepted,
-		Subject:    room.HostID,
-		Object:     room.ID,
+	if err := requestStates.apply(ctx, tx, req.ID, current.Status, Accepted, actor, reason); err != nil {
+		util.TEL.Error(ctx, "could not record status transition", err)
+		return nil, err
 	}
 
-	if _, err := s.notificationClient.CreateNotification(util.TEL.Ctx(), jwt, createNotifDTO); err != nil {
-		util.TEL.Error("failed to send notification to guest", err, "guest_id", req.GuestID)
-		// not returning error – notification failures shouldn’t break reservation creation
+	util.TEL.Debug(ctx, "reject overlapping pending requests")
+	if err := rejectOverlappingRequests(ctx, tx, req.RoomID, req.DateFrom, req.DateTo, res.ID); err != nil {
+		util.TEL.Error(ctx, "could not reject overlapping requests", err, "room_id", req.RoomID)
+		return nil, err
 	}
 
-	return nil
+	return res, nil
 }
 
-func (s *service) FindPendingRequestsByGuest(context context.Context, callerID uint) ([]ReservationRequest, error) {
-	util.TEL.Info("user wants to see his pending reservation requests", "caller_id", callerID)
+func (s *service) FindPendingRequestsByGuest(ctx context.Context, callerID uint, query ListQuery) (*Page[ReservationRequest], error) {
+	util.TEL.Info(ctx, "user wants to see his pending reservation requests", "caller_id", callerID)
+
+	page, err := query.pageRequest(Sort{Key: SortDateFrom}, SortDateFrom, SortDateTo, SortCreatedAt)
+	if err != nil {
+		util.TEL.Error(ctx, "bad listing query", err)
+		return nil, err
+	}
 
-	util.TEL.Debug("check if user exists", "id", callerID)
-	user, err := s.userClient.FindById(util.TEL.Ctx(), callerID)
+	util.TEL.Debug(ctx, "check if user exists", "id", callerID)
+	user, err := s.userClient.FindById(ctx, callerID)
 	if err != nil {
-		util.TEL.Debug("user does not exist", err, "id", callerID)
-		return nil, ErrNotFound("user", callerID)
+		util.TEL.Debug(ctx, "user does not exist", err, "id", callerID)
+		return nil, downstreamError(err, ErrNotFound("user", callerID))
 	}
 
-	util.TEL.Debug("check if user is a guest", "id", callerID)
+	util.TEL.Debug(ctx, "check if user is a guest", "id", callerID)
 	if user.Role != string(util.Guest) {
-		util.TEL.Debug("user has a bad role", "role", user.Role)
+		util.TEL.Debug(ctx, "user has a bad role", "role", user.Role)
 		return nil, ErrUnauthorized
 	}
 
-	util.TEL.Push(context, "find-pending-reservation-requests-by-guest-in-db")
-	defer util.TEL.Pop()
-	requests, err := s.repo.FindPendingRequestsByGuestID(callerID)
+	ctx, span := util.TEL.Start(ctx, "find-pending-reservation-requests-by-guest-in-db")
+	defer span.End()
+	filter := RequestFilter{
+		GuestID:  callerID,
+		Statuses: query.Statuses,
+		From:     query.From,
+		To:       query.To,
+	}
+	if len(filter.Statuses) == 0 {
+		filter.Statuses = []ReservationRequestStatus{Pending}
+	}
+	if query.RoomID != 0 {
+		filter.RoomIDs = []uint{query.RoomID}
+	}
+	requests, err := s.repo.FindRequestsPage(filter, page)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find reservation requests of guest", err, "guest_id", callerID)
+		return nil, err
+	}
 
-	util.TEL.Push(context, "filter out requests from deleted rooms")
-	var validRequests []ReservationRequest
-	for _, request := range requests {
-		util.TEL.Debug("find room", "id", request.RoomID)
-		room, err := s.roomClient.FindById(util.TEL.Ctx(), request.RoomID)
-		if err == nil && room.Deleted == false {
-			validRequests = append(validRequests, request)
-		}
+	ctx, span = util.TEL.Start(ctx, "filter out requests from deleted rooms")
+	defer span.End()
+	if err := dropDeletedRooms(ctx, s.roomClient, requests, func(r ReservationRequest) uint { return r.RoomID }); err != nil {
+		return nil, err
 	}
 
-	return validRequests, nil
+	return requests, nil
 }
 
-func (s *service) FindPendingRequestsByRoom(context context.Context, callerID uint, roomID uint) ([]ReservationRequest, error) {
-	util.TEL.Info("user wants to see pending reservation requests for room", "caller_id", callerID, "room_id", roomID)
+func (s *service) FindPendingRequestsByRoom(ctx context.Context, callerID uint, roomID uint, query ListQuery) (*Page[ReservationRequest], error) {
+	util.TEL.Info(ctx, "user wants to see pending reservation requests for room", "caller_id", callerID, "room_id", roomID)
+
+	page, err := query.pageRequest(Sort{Key: SortDateFrom}, SortDateFrom, SortDateTo, SortCreatedAt)
+	if err != nil {
+		util.TEL.Error(ctx, "bad listing query", err)
+		return nil, err
+	}
 
-	util.TEL.Debug("check if user exists", "id", callerID)
-	user, err := s.userClient.FindById(util.TEL.Ctx(), callerID)
+	util.TEL.Debug(ctx, "check if user exists", "id", callerID)
+	user, err := s.userClient.FindById(ctx, callerID)
 	if err != nil {
-		util.TEL.Error("user does not exist", err, "id", callerID)
-		return nil, ErrNotFound("user", callerID)
+		util.TEL.Error(ctx, "user does not exist", err, "id", callerID)
+		return nil, downstreamError(err, ErrNotFound("user", callerID))
 	}
 
-	util.TEL.Debug("check if user is a host", "id", callerID)
+	util.TEL.Debug(ctx, "check if user is a host", "id", callerID)
 	if user.Role != string(util.Host) {
-		util.TEL.Error("user has a bad role", nil, "role", user.Role)
+		util.TEL.Error(ctx, "user has a bad role", nil, "role", user.Role)
 		return nil, ErrUnauthorized
 	}
 
-	util.TEL.Debug("find room", "id", roomID)
-	room, err := s.roomClient.FindById(util.TEL.Ctx(), roomID)
+	util.TEL.Debug(ctx, "find room", "id", roomID)
+	room, err := s.roomClient.FindById(ctx, roomID)
 	if err != nil {
-		util.TEL.Error("room does not exist", err, "id", roomID)
-		return nil, ErrNotFound("room", roomID)
+		util.TEL.Error(ctx, "room does not exist", err, "id", roomID)
+		return nil, downstreamError(err, ErrNotFound("room", roomID))
 	}
 
-	util.TEL.Debug("check if user is owner of the room", "user_id", callerID, "room_id", room.ID)
+	util.TEL.Debug(ctx, "check if user is owner of the room", "user_id", callerID, "room_id", room.ID)
 	if room.HostID != callerID {
-		util.TEL.Error("user is not owner of the room", nil, "user_id", callerID, "host_id", room.HostID)
+		util.TEL.Error(ctx, "user is not owner of the room", nil, "user_id", callerID, "host_id", room.HostID)
 
 		return nil, ErrUnauthorized
 	}
 
-	util.TEL.Push(context, "find-pending-reservation-requests-by-room-in-db")
-	defer util.TEL.Pop()
-	requests, err := s.repo.FindPendingRequestsByRoomID(roomID)
+	ctx, span := util.TEL.Start(ctx, "find-pending-reservation-requests-by-room-in-db")
+	defer span.End()
+	filter := RequestFilter{
+		RoomIDs:  []uint{roomID},
+		Statuses: query.Statuses,
+		From:     query.From,
+		To:       query.To,
+	}
+	if len(filter.Statuses) == 0 {
+		filter.Statuses = []ReservationRequestStatus{Pending}
+	}
+	requests, err := s.repo.FindRequestsPage(filter, page)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find reservation requests of room", err, "room_id", roomID)
+		return nil, err
+	}
 
-	util.TEL.Push(context, "filter out requests from deleted users")
-	var validRequests []ReservationRequest
-	for _, request := range requests {
-		util.TEL.Debug("find user", "id", request.GuestID)
-		user, err := s.userClient.FindById(util.TEL.Ctx(), request.GuestID)
+	ctx, span = util.TEL.Start(ctx, "filter out requests from deleted users")
+	defer span.End()
+	validRequests := make([]ReservationRequest, 0, len(requests.Items))
+	for _, request := range requests.Items {
+		util.TEL.Debug(ctx, "find user", "id", request.GuestID)
+		user, err := s.userClient.FindById(ctx, request.GuestID)
+		if isUnavailable(err) {
+			return nil, ErrServiceUnavailable
+		}
 		if err == nil && user.Deleted == false {
 			validRequests = append(validRequests, request)
 		}
 	}
+	requests.Items = validRequests
 
-	return validRequests, nil
+	return requests, nil
 }
 
-func (s *service) DeleteRequest(context context.Context, callerID uint, requestID uint) error {
-	util.TEL.Info("user wants delete reservation request", "caller_id", callerID, "request_id", requestID)
+func (s *service) DeleteRequest(ctx context.Context, callerID uint, requestID uint) error {
+	util.TEL.Info(ctx, "user wants delete reservation request", "caller_id", callerID, "request_id", requestID)
 
-	util.TEL.Debug("check if user exists", "id", callerID)
-	user, err := s.userClient.FindById(util.TEL.Ctx(), callerID)
+	util.TEL.Debug(ctx, "check if user exists", "id", callerID)
+	user, err := s.userClient.FindById(ctx, callerID)
 	if err != nil {
-		util.TEL.Error("user does not exist", err, "id", callerID)
-		return ErrNotFound("user", callerID)
+		util.TEL.Error(ctx, "user does not exist", err, "id", callerID)
+		return downstreamError(err, ErrNotFound("user", callerID))
 	}
 
-	util.TEL.Debug("check if user is a guest", "id", callerID)
+	util.TEL.Debug(ctx, "check if user is a guest", "id", callerID)
 	if user.Role != string(util.Guest) {
-		util.TEL.Error("user has a bad role", nil, "role", user.Role)
+		util.TEL.Error(ctx, "user has a bad role", nil, "role", user.Role)
 		return ErrUnauthorized
 	}
 
-	util.TEL.Debug("find all reservation requests by user in db", "user_id", callerID)
+	util.TEL.Debug(ctx, "find all reservation requests by user in db", "user_id", callerID)
 	requests, err := s.repo.FindPendingRequestsByGuestID(callerID)
 	if err != nil {
-		util.TEL.Error("could not find reservation requests of user", err, "user_id", callerID)
+		util.TEL.Error(ctx, "could not find reservation requests of user", err, "user_id", callerID)
 		return err
 	}
 	found := false
-	util.TEL.Debug("find reservation request by id", nil, "request_id", requestID)
+	util.TEL.Debug(ctx, "find reservation request by id", nil, "request_id", requestID)
 	var request *ReservationRequest
 	for _, req := range requests {
 		if req.ID == requestID {
@@ -427,301 +727,2341 @@ func (s *service) DeleteRequest(context context.Context, callerID uint, requestI
 	}
 
 	if !found {
-		util.TEL.Error("could not find reservation request of user", err, "request_id", requestID, "user_id", callerID)
+		util.TEL.Error(ctx, "could not find reservation request of user", err, "request_id", requestID, "user_id", callerID)
 		return ErrNotFound("reservation request", requestID)
 	}
-
-	if request.Status != Pending {
-		util.TEL.Error("request isn't pending", nil, "request_status", request.Status)
-		return ErrBadRequestCustom("cannot cancel a handled request")
+	if request.GroupID != 0 {
+		util.TEL.Error(ctx, "request is part of a group", nil, "request_id", requestID, "group_id", request.GroupID)
+		return errGrouped(*request)
 	}
 
-	util.TEL.Push(context, "delete-request-in-db")
-	defer util.TEL.Pop()
-
-	return s.repo.DeleteRequest(requestID)
-}
-
-func (s *service) AreThereReservationsOnDays(context context.Context, roomID uint, from, to time.Time) (bool, error) {
-	util.TEL.Info("checking if room has reservations on days", "room_id", roomID, "from", from, "to", to)
+	actor := Actor{callerID, util.Guest}
+	if err := requestStates.check(requestID, request.Status, Withdrawn, actor); err != nil {
+		util.TEL.Error(ctx, "request cannot be withdrawn", err, "request_status", request.Status)
+		return err
+	}
 
-	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
-		util.TEL.Debug("checking if room has a reservation on a single day ", "room_id", roomID, "day", d)
+	ctx, span := util.TEL.Start(ctx, "withdraw-request-in-db")
+	defer span.End()
 
-		reservations, err := s.repo.FindReservationsByRoomIDForDay(roomID, d)
+	return s.repo.Transaction(func(tx Repository) error {
+		current, err := tx.FindRequestByIDForUpdate(requestID)
 		if err != nil {
-			util.TEL.Error("could not find reservations for room on a single day", err, "room_id", roomID, "day", d)
-			return false, err
+			util.TEL.Error(ctx, "could not find reservation request", err, "request_id", requestID)
+			return err
 		}
-		for _, res := range reservations {
-			if !res.Cancelled {
-				util.TEL.Debug("active reservation found", "room_id", roomID, "day", d)
-				return true, nil
-			}
+		if err := requestStates.apply(ctx, tx, requestID, current.Status, Withdrawn, actor, ""); err != nil {
+			return err
 		}
-	}
-
-	util.TEL.Debug("ok, room has no reservations on days", "room_id", roomID, "from", from, "to", to)
-	return false, nil
+		return tx.WithdrawRequest(requestID, time.Now().UTC())
+	})
 }
 
-func (s *service) GetActiveGuestReservations(context context.Context, guestID uint) ([]Reservation, error) {
-	log.Print("GetActiveGuestReservations [1] User must be guest")
+func (s *service) UpdateRequest(ctx context.Context, authctx AuthContext, requestID uint, dto UpdateReservationRequestDTO) (*ReservationRequest, error) {
+	ctx, span := util.TEL.Start(ctx, "update-request")
+	defer span.End()
 
-	_, err := s.userClient.FindById(context, guestID)
-	if err != nil {
-		return nil, ErrNotFound("user", guestID)
-	}
+	callerID := authctx.CallerID
+	util.TEL.Info(ctx, "user wants to update reservation request", "caller_id", callerID, "request_id", requestID)
 
-	log.Print("GetActiveGuestReservations [2]  Find all reservations")
+	if err := s.checkGuest(ctx, callerID); err != nil {
+		return nil, err
+	}
 
-	allReservations, err := s.repo.FindReservationsByGuestID(guestID)
+	util.TEL.Debug(ctx, "find pending reservation requests of user", "user_id", callerID)
+	pending, err := s.repo.FindPendingRequestsByGuestID(callerID)
 	if err != nil {
+		util.TEL.Error(ctx, "could not find reservation requests of user", err, "user_id", callerID)
 		return nil, err
 	}
+	i := slices.IndexFunc(pending, func(r ReservationRequest) bool { return r.ID == requestID })
+	if i < 0 {
+		util.TEL.Error(ctx, "could not find pending reservation request of user", nil, "request_id", requestID, "user_id", callerID)
+		return nil, ErrNotFound("reservation request", requestID)
+	}
+	req := pending[i]
+	if req.GroupID != 0 {
+		util.TEL.Error(ctx, "request is part of a group", nil, "request_id", requestID, "group_id", req.GroupID)
+		return nil, errGrouped(req)
+	}
 
-	log.Print("GetActiveGuestReservations [3] Return")
+	from, to, guestCount := req.DateFrom, req.DateTo, req.GuestCount
+	if dto.DateFrom != nil {
+		from = *dto.DateFrom
+	}
+	if dto.DateTo != nil {
+		to = *dto.DateTo
+	}
+	if dto.GuestCount != nil {
+		guestCount = *dto.GuestCount
+	}
 
-	activeReservations := s.ExtractActiveReservations(allReservations)
+	if from.Equal(req.DateFrom) && to.Equal(req.DateTo) && guestCount == req.GuestCount {
+		return nil, ErrBadRequestCustom("nothing to change")
+	}
+	if guestCount < 1 {
+		util.TEL.Error(ctx, "guest count must be at least 1", nil, "guest_count", guestCount)
+		return nil, ErrBadRequestCustom("guest count must be at least 1")
+	}
+	if from.After(to) {
+		util.TEL.Error(ctx, "dates are reversed", nil, "from", from, "to", to)
+		return nil, ErrBadRequestCustom("dates are reversed")
+	}
 
-	return activeReservations, nil
-}
+	room, err := s.roomClient.FindById(ctx, req.RoomID)
+	if err != nil {
+		util.TEL.Error(ctx, "room not found", err, "id", req.RoomID)
+		return nil, downstreamError(err, ErrNotFound("room", req.RoomID))
+	}
 
-func (s *service) GetActiveHostReservations(context context.Context, hostID uint) ([]Reservation, error) {
+	availList, err := s.roomClient.FindCurrentAvailabilityListOfRoom(ctx, room.ID)
+	if err != nil {
+		util.TEL.Error(ctx, "room availability list of room not found", err, "room_id", room.ID)
+		return nil, downstreamError(err, ErrNotFound("room availability list", room.ID))
+	}
 
-	log.Print("GetActiveHostReservations [1] Fetch host rooms")
+	pricelist, err := s.roomClient.FindCurrentPricelistOfRoom(ctx, room.ID)
+	if err != nil {
+		util.TEL.Error(ctx, "room price list of room not found", err, "room_id", room.ID)
+		return nil, downstreamError(err, ErrNotFound("room price list", room.ID))
+	}
 
-	rooms, err := s.roomClient.FindByHostId(context, hostID)
+	util.TEL.Debug(ctx, "query room for reservation data")
+	queryResponse, err := s.roomClient.QueryForReservation(ctx, authctx.JWT, roomclient.RoomReservationQueryDTO{
+		RoomID:     room.ID,
+		DateFrom:   from,
+		DateTo:     to,
+		GuestCount: guestCount,
+	})
 	if err != nil {
-		log.Printf("%s", err.Error())
-		return nil, ErrNotFound("rooms of host", hostID)
+		util.TEL.Error(ctx, "could not query room for reservation", err, "room_id", room.ID)
+		return nil, downstreamError(err, ErrBadRequest)
+	}
+	if !queryResponse.Available {
+		util.TEL.Error(ctx, "room is not available at this time", nil, "room_id", room.ID)
+		return nil, ErrBadRequest
 	}
 
-	log.Print("GetActiveHostReservations [2] Find all reservations from all rooms")
+	ctx, span = util.TEL.Start(ctx, "update-request-in-db")
+	defer span.End()
 
-	var allReservations []Reservation
-	for _, room := range rooms {
-		roomReservations, err := s.repo.FindReservationsByRoomID(room.ID)
-		if err != nil {
-			return nil, err
-		}
-		allReservations = append(allReservations, roomReservations...)
-	}
+	now := time.Now().UTC()
+	req.DateFrom, req.DateTo, req.GuestCount = from, to, guestCount
+	req.Cost = queryResponse.TotalCost
+	req.RoomAvailabilityID = availList.ID
+	req.RoomPriceID = pricelist.ID
 
-	log.Print("GetActiveHostReservations [3] Extract active reservations")
+	err = s.repo.Transaction(func(tx Repository) error {
+		util.TEL.Debug(ctx, "lock room", "room_id", req.RoomID)
+		if err := tx.LockRoom(req.RoomID); err != nil {
+			util.TEL.Error(ctx, "could not lock room", err, "room_id", req.RoomID)
+			return err
+		}
 
-	activeReservations := s.ExtractActiveReservations(allReservations)
+		current, err := tx.FindRequestByIDForUpdate(req.ID)
+		if err != nil {
+			util.TEL.Error(ctx, "could not find reservation request", err, "request_id", req.ID)
+			return err
+		}
+		if err := requestStates.apply(ctx, tx, req.ID, current.Status, Pending, Actor{callerID, util.Guest}, "changed by guest"); err != nil {
+			util.TEL.Error(ctx, "request can no longer be changed", err, "request_id", req.ID, "status", current.Status)
+			return err
+		}
 
-	log.Print("GetActiveHostReservations [4] Return")
+		util.TEL.Debug(ctx, "prevent overlapping requests for the same room and same guest")
+		pending, err := tx.FindPendingRequestsByGuestID(callerID)
+		if err != nil {
+			util.TEL.Error(ctx, "could not find reservation requests of user", err, "user_id", callerID)
+			return err
+		}
+		for _, other := range pending {
+			if other.ID != req.ID && other.RoomID == req.RoomID && util.AreDatesIntersecting(other.DateFrom, other.DateTo, from, to) {
+				util.TEL.Error(ctx, "conflicting request of user for room", nil, "user_id", callerID, "room_id", req.RoomID, "existing_from", other.DateFrom, "existing_to", other.DateTo)
+				return ErrConflict
+			}
+		}
 
-	return activeReservations, nil
-}
+		has, err := tx.HasReservationsInRange(req.RoomID, from, to)
+		if err != nil {
+			util.TEL.Error(ctx, "could not check for reservations for room", err, "room_id", req.RoomID)
+			return err
+		}
+		if has {
+			util.TEL.Error(ctx, "room has a reservation for this date range", nil, "room_id", req.RoomID, "from", from, "to", to)
+			return ErrConflict
+		}
+		if err := checkNotHeld(ctx, tx, req.RoomID, from, to, callerID, now); err != nil {
+			return err
+		}
+		if err := checkNotOffered(ctx, tx, req.RoomID, from, to, callerID, now); err != nil {
+			return err
+		}
 
-func (s *service) ExtractActiveReservations(reservations []Reservation) []Reservation {
-	var activeReservations []Reservation
-	for _, reservation := range reservations {
-		if reservation.DateTo.After(time.Now()) && reservation.Cancelled == false {
-			activeReservations = append(activeReservations, reservation)
+		if err := tx.UpdateRequest(&req); err != nil {
+			util.TEL.Error(ctx, "could not update reservation request", err, "request_id", req.ID)
+			return err
 		}
+
+		util.TEL.Debug(ctx, "enqueue notification for host", "host_id", room.HostID)
+		return tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+			ReceiverID: room.HostID,
+			Type:       notificationclient.ReservationRequestChanged,
+			Subject:    callerID,
+			Object:     room.ID,
+		}))
+	})
+	if err != nil {
+		return nil, err
 	}
-	return activeReservations
+
+	util.TEL.Info(ctx, "reservation request updated", "request_id", req.ID)
+	return &req, nil
 }
 
-func (s *service) RejectReservationRequest(ctx context.Context, hostID, requestID uint, jwt string) error {
-	util.TEL.Push(ctx, "reject-reservation-request-service")
-	defer util.TEL.Pop()
+func (s *service) AreThereReservationsOnDays(ctx context.Context, roomID uint, from, to time.Time) (bool, error) {
+	ctx, span := util.TEL.Start(ctx, "are-there-reservations-on-days", attribute.Int("room.id", int(roomID)))
+	defer span.End()
 
-	req, err := s.repo.FindRequestByID(requestID)
+	util.TEL.Info(ctx, "checking if room has reservations on days", "room_id", roomID, "from", from, "to", to)
+
+	has, err := s.repo.HasReservationsInRange(roomID, from, to)
 	if err != nil {
-		util.TEL.Error("could not find reservation requst", err, "request_id", requestID)
-		return err
+		util.TEL.Error(ctx, "could not check for reservations for room", err, "room_id", roomID, "from", from, "to", to)
+		return false, err
 	}
 
-	room, err := s.roomClient.FindById(util.TEL.Ctx(), req.RoomID)
+	util.TEL.Debug(ctx, "checked if room has reservations on days", "room_id", roomID, "from", from, "to", to, "has", has)
+	return has, nil
+}
+
+func (s *service) IsRoomAvailable(ctx context.Context, callerID uint, roomID uint, from, to time.Time) (bool, error) {
+	ctx, span := util.TEL.Start(ctx, "is-room-available", attribute.Int("room.id", int(roomID)))
+	defer span.End()
+
+	booked, err := s.repo.HasReservationsInRange(roomID, from, to)
 	if err != nil {
-		util.TEL.Error("room not found", err, "id", req.RoomID)
-		return err
+		util.TEL.Error(ctx, "could not check for reservations for room", err, "room_id", roomID)
+		return false, err
 	}
-
-	if room.HostID != hostID {
-		util.TEL.Error("bad host for room", nil, "host_id", room.HostID, "room_id", room.ID)
-		return ErrUnauthorized
+	if booked {
+		return false, nil
 	}
 
-	user, err := s.userClient.FindById(util.TEL.Ctx(), req.GuestID)
+	now := time.Now().UTC()
+	held, err := s.repo.HasHold(roomID, from, to, callerID, now)
 	if err != nil {
-		util.TEL.Error("user of reservation request does not exist", err, "id", req.GuestID)
-		return ErrNotFound("user", req.GuestID)
+		util.TEL.Error(ctx, "could not check for holds for room", err, "room_id", roomID)
+		return false, err
 	}
-
-	if user.Deleted {
-		util.TEL.Error("user of reservation request deleted account", err, "id", req.GuestID)
-		return ErrNotFound("user", req.GuestID)
+	if held {
+		return false, nil
 	}
 
-	if err := s.repo.SetRequestStatus(requestID, Rejected); err != nil {
-		util.TEL.Error("could not change status to rejected", err, "request_id", requestID)
-		return err
+	offered, err := s.repo.HasWaitlistOffer(roomID, from, to, callerID, now)
+	if err != nil {
+		util.TEL.Error(ctx, "could not check for waitlist offers for room", err, "room_id", roomID)
+		return false, err
 	}
 
-	util.TEL.Info("reservation request rejected", "request_id", requestID)
+	util.TEL.Debug(ctx, "checked room availability", "room_id", roomID, "from", from, "to", to, "offered", offered)
+	return !offered, nil
+}
 
-	util.TEL.Push(ctx, "create-notification")
-	defer util.TEL.Pop()
+func (s *service) CreateHold(ctx context.Context, guestID uint, dto CreateHoldDTO) (*Hold, error) {
+	ctx, span := util.TEL.Start(ctx, "create-hold")
+	defer span.End()
 
-	createNotifDTO := notificationclient.CreateNotificationDTO{
-		ReceiverID: req.GuestID, // Guest receives the notification
-		Type:       notificationclient.ReservationDeclined,
-		Subject:    room.HostID,
-		Object:     room.ID,
-	}
+	util.TEL.Info(ctx, "guest wants to hold dates", "guest_id", guestID, "room_id", dto.RoomID)
 
-	if _, err := s.notificationClient.CreateNotification(util.TEL.Ctx(), jwt, createNotifDTO); err != nil {
-		util.TEL.Error("failed to send notification to guest", err, "guest_id", req.GuestID)
-		// not returning error – notification failures shouldn’t break reservation creation
+	if dto.DateFrom.After(dto.DateTo) {
+		return nil, ErrBadRequestCustom("dates are reversed")
+	}
+	holdTime := DefaultHoldTime
+	if dto.Minutes != 0 {
+		holdTime = time.Duration(dto.Minutes) * time.Minute
+	}
+	if holdTime > MaxHoldTime {
+		return nil, ErrBadRequestCustom(fmt.Sprintf("dates can be held for at most %d minutes", int(MaxHoldTime.Minutes())))
 	}
 
-	return nil
-}
-
-func (s *service) ApproveReservationRequest(ctx context.Context, hostID, requestID uint, jwt string) error {
-	util.TEL.Push(ctx, "approve-reservation-request-service")
-	defer util.TEL.Pop()
-
-	req, err := s.repo.FindRequestByID(requestID)
+	room, err := s.roomClient.FindById(ctx, dto.RoomID)
 	if err != nil {
-		util.TEL.Error("could not find reservation requst", err, "request_id", requestID)
-		return err
+		util.TEL.Error(ctx, "room not found", err, "id", dto.RoomID)
+		return nil, downstreamError(err, ErrNotFound("room", dto.RoomID))
 	}
 
-	room, err := s.roomClient.FindById(util.TEL.Ctx(), req.RoomID)
+	now := time.Now().UTC()
+	active, err := s.repo.CountActiveHolds(guestID, now)
 	if err != nil {
-		util.TEL.Error("room not found", err, "id", req.RoomID)
-		return err
+		util.TEL.Error(ctx, "could not count holds of guest", err, "guest_id", guestID)
+		return nil, err
 	}
+	if active >= MaxActiveHolds {
+		util.TEL.Error(ctx, "guest holds too many dates", nil, "guest_id", guestID, "holds", active)
+		return nil, ErrBadRequestCustom(fmt.Sprintf("at most %d dates can be held at once", MaxActiveHolds))
+	}
+
+	hold := &Hold{
+		RoomID:    room.ID,
+		GuestID:   guestID,
+		DateFrom:  dto.DateFrom,
+		DateTo:    dto.DateTo,
+		Status:    HoldActive,
+		CreatedAt: now,
+		ExpiresAt: now.Add(holdTime),
+	}
+	err = s.repo.Transaction(func(tx Repository) error {
+		util.TEL.Debug(ctx, "lock room", "room_id", room.ID)
+		if err := tx.LockRoom(room.ID); err != nil {
+			util.TEL.Error(ctx, "could not lock room", err, "room_id", room.ID)
+			return err
+		}
 
-	if room.HostID != hostID {
-		util.TEL.Error("bad host for room", nil, "host_id", room.HostID, "room_id", room.ID)
-		return ErrUnauthorized
-	}
+		booked, err := tx.HasReservationsInRange(room.ID, hold.DateFrom, hold.DateTo)
+		if err != nil {
+			return err
+		}
+		held, err := tx.HasHold(room.ID, hold.DateFrom, hold.DateTo, guestID, now)
+		if err != nil {
+			return err
+		}
+		offered, err := tx.HasWaitlistOffer(room.ID, hold.DateFrom, hold.DateTo, guestID, now)
+		if err != nil {
+			return err
+		}
+		if booked || held || offered {
+			util.TEL.Error(ctx, "dates aren't available", nil, "room_id", room.ID, "booked", booked, "held", held, "offered", offered)
+			return ErrConflict
+		}
 
-	user, err := s.userClient.FindById(util.TEL.Ctx(), req.GuestID)
+		return tx.CreateHold(hold)
+	})
 	if err != nil {
-		util.TEL.Error("user of reservation request does not exist", err, "id", req.GuestID)
-		return ErrNotFound("user", req.GuestID)
+		return nil, err
 	}
 
-	if user.Deleted {
-		util.TEL.Error("user of reservation request deleted account", err, "id", req.GuestID)
-		return ErrNotFound("user", req.GuestID)
-	}
+	holdsCreated.Inc()
+	util.TEL.Info(ctx, "dates held", "hold_id", hold.ID, "expires_at", hold.ExpiresAt)
+	return hold, nil
+}
 
-	if err := s.acceptReservationRequest(util.TEL.Ctx(), req, room, jwt); err != nil {
-		util.TEL.Error("could not change status to accepted", err, "request_id", requestID)
+func (s *service) ReleaseHold(ctx context.Context, guestID uint, holdID uint) error {
+	ctx, span := util.TEL.Start(ctx, "release-hold")
+	defer span.End()
+
+	err := s.repo.ReleaseHold(holdID, guestID, time.Now().UTC())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		util.TEL.Error(ctx, "no such active hold", err, "guest_id", guestID, "hold_id", holdID)
+		return ErrNotFound("hold", holdID)
+	}
+	if err != nil {
+		util.TEL.Error(ctx, "could not release hold", err, "hold_id", holdID)
 		return err
 	}
 
-	util.TEL.Info("reservation request approved successfully", "request_id", requestID)
+	holdsEnded.WithLabelValues("released").Inc()
+	util.TEL.Info(ctx, "hold released", "guest_id", guestID, "hold_id", holdID)
 	return nil
 }
 
-func (s *service) CancelReservation(ctx context.Context, callerID uint, reservationID uint, jwt string) error {
-	util.TEL.Info("user wants to cancel reservation", "caller_id", callerID, "reservation_id", reservationID)
+func (s *service) GetActiveGuestReservations(ctx context.Context, guestID uint, query ListQuery) (*Page[Reservation], error) {
+	ctx, span := util.TEL.Start(ctx, "get-active-guest-reservations")
+	defer span.End()
 
-	user, err := s.userClient.FindById(util.TEL.Ctx(), callerID)
+	page, err := query.pageRequest(Sort{Key: SortDateFrom}, SortDateFrom, SortDateTo)
 	if err != nil {
-		util.TEL.Error("user not found", err, "user_id", callerID)
-		return ErrUnauthenticated
-	}
-
-	if user.Role != string(util.Guest) {
-		util.TEL.Error("user is not a guest", nil, "role", user.Role)
-		return ErrUnauthorized
+		util.TEL.Error(ctx, "bad listing query", err)
+		return nil, err
 	}
 
-	reservation, err := s.repo.FindReservationById(reservationID)
+	util.TEL.Debug(ctx, "check if user exists", "id", guestID)
+	_, err = s.userClient.FindById(ctx, guestID)
 	if err != nil {
-		util.TEL.Error("reservation not found", err, "reservation_id", reservationID)
-		return ErrNotFound("reservation", reservationID)
+		util.TEL.Error(ctx, "user does not exist", err, "id", guestID)
+		return nil, downstreamError(err, ErrNotFound("user", guestID))
 	}
 
-	if reservation.GuestID != callerID {
-		util.TEL.Error("reservation does not belong to this guest", nil, "reservation_guest_id", reservation.GuestID, "caller_id", callerID)
-		return ErrUnauthorized
+	filter := ReservationFilter{
+		GuestID:          guestID,
+		ExcludeCancelled: true,
+		EndsAfter:        time.Now(),
+		From:             query.From,
+		To:               query.To,
 	}
-
-	if reservation.Cancelled {
-		util.TEL.Error("reservation already cancelled", nil, "reservation_id", reservationID)
-		return ErrBadRequestCustom("reservation already cancelled")
+	if query.RoomID != 0 {
+		filter.RoomIDs = []uint{query.RoomID}
 	}
 
-	if !time.Now().Before(reservation.DateFrom) {
-		util.TEL.Error("cannot cancel reservation that already started", nil, "date_from", reservation.DateFrom)
-		return ErrBadRequestCustom("cannot cancel reservation that already started")
-	}
+	util.TEL.Debug(ctx, "find active reservations", "guest_id", guestID)
+	return s.repo.FindReservationsPage(filter, page)
+}
 
-	util.TEL.Push(ctx, "cancel-reservation-in-db")
-	defer util.TEL.Pop()
+func (s *service) GetActiveHostReservations(ctx context.Context, hostID uint, query ListQuery) (*Page[Reservation], error) {
+	ctx, span := util.TEL.Start(ctx, "get-active-host-reservations")
+	defer span.End()
 
-	err = s.repo.CancelReservation(reservationID)
+	page, err := query.pageRequest(Sort{Key: SortDateFrom}, SortDateFrom, SortDateTo)
 	if err != nil {
-		util.TEL.Error("could not cancel reservation in database", err, "reservation_id", reservationID)
+		util.TEL.Error(ctx, "bad listing query", err)
+		return nil, err
+	}
+
+	util.TEL.Debug(ctx, "fetch host rooms", "host_id", hostID)
+	rooms, err := s.roomClient.FindByHostId(ctx, hostID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find rooms of host", err, "host_id", hostID)
+		return nil, downstreamError(err, ErrNotFound("rooms of host", hostID))
+	}
+
+	roomIDs := make([]uint, 0, len(rooms))
+	for _, room := range rooms {
+		if query.RoomID == 0 || room.ID == query.RoomID {
+			roomIDs = append(roomIDs, room.ID)
+		}
+	}
+	if query.RoomID != 0 && len(roomIDs) == 0 {
+		util.TEL.Error(ctx, "user is not owner of the room", nil, "user_id", hostID, "room_id", query.RoomID)
+		return nil, ErrUnauthorized
+	}
+	if len(roomIDs) == 0 {
+		return &Page[Reservation]{Items: []Reservation{}}, nil
+	}
+
+	filter := ReservationFilter{
+		RoomIDs:          roomIDs,
+		ExcludeCancelled: true,
+		EndsAfter:        time.Now(),
+		From:             query.From,
+		To:               query.To,
+	}
+
+	util.TEL.Debug(ctx, "find active reservations of host rooms", "rooms", len(roomIDs))
+	return s.repo.FindReservationsPage(filter, page)
+}
+
+func (s *service) RejectReservationRequest(ctx context.Context, hostID, requestID uint, jwt string) error {
+	ctx, span := util.TEL.Start(ctx, "reject-reservation-request-service")
+	defer span.End()
+
+	req, err := s.repo.FindRequestByID(requestID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find reservation requst", err, "request_id", requestID)
+		return err
+	}
+	if req.GroupID != 0 {
+		util.TEL.Error(ctx, "request is part of a group", nil, "request_id", requestID, "group_id", req.GroupID)
+		return errGrouped(*req)
+	}
+
+	room, err := s.roomClient.FindById(ctx, req.RoomID)
+	if err != nil {
+		util.TEL.Error(ctx, "room not found", err, "id", req.RoomID)
+		return downstreamError(err, ErrNotFound("room", req.RoomID))
+	}
+
+	if room.HostID != hostID {
+		util.TEL.Error(ctx, "bad host for room", nil, "host_id", room.HostID, "room_id", room.ID)
+		return ErrUnauthorized
+	}
+
+	actor := Actor{hostID, util.Host}
+	if err := requestStates.check(requestID, req.Status, Rejected, actor); err != nil {
+		util.TEL.Error(ctx, "request cannot be rejected", err, "request_id", requestID, "status", req.Status)
+		return err
+	}
+
+	user, err := s.userClient.FindById(ctx, req.GuestID)
+	if err != nil {
+		util.TEL.Error(ctx, "user of reservation request does not exist", err, "id", req.GuestID)
+		return downstreamError(err, ErrNotFound("user", req.GuestID))
+	}
+
+	if user.Deleted {
+		util.TEL.Error(ctx, "user of reservation request deleted account", err, "id", req.GuestID)
+		return ErrNotFound("user", req.GuestID)
+	}
+
+	err = s.repo.Transaction(func(tx Repository) error {
+		if err := rejectInTx(ctx, tx, requestID, actor); err != nil {
+			return err
+		}
+
+		return tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+			ReceiverID: req.GuestID, // Guest receives the notification
+			Type:       notificationclient.ReservationDeclined,
+			Subject:    room.HostID,
+			Object:     room.ID,
+		}))
+	})
+	if err != nil {
+		return err
+	}
+
+	util.TEL.Info(ctx, "reservation request rejected", "request_id", requestID)
+
+	return nil
+}
+
+// rejectInTx rejects the request in tx, if it's still pending.
+func rejectInTx(ctx context.Context, tx Repository, requestID uint, actor Actor) error {
+	current, err := tx.FindRequestByIDForUpdate(requestID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find reservation request", err, "request_id", requestID)
+		return err
+	}
+	if err := requestStates.apply(ctx, tx, requestID, current.Status, Rejected, actor, ""); err != nil {
+		return err
+	}
+
+	if err := tx.SetRequestStatus(requestID, Rejected); err != nil {
+		util.TEL.Error(ctx, "could not change status to rejected", err, "request_id", requestID)
+		return err
+	}
+	return nil
+}
+
+func (s *service) ApproveReservationRequest(ctx context.Context, hostID, requestID uint, jwt string) error {
+	ctx, span := util.TEL.Start(ctx, "approve-reservation-request-service")
+	defer span.End()
+
+	req, err := s.repo.FindRequestByID(requestID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find reservation requst", err, "request_id", requestID)
+		return err
+	}
+	if req.GroupID != 0 {
+		util.TEL.Error(ctx, "request is part of a group", nil, "request_id", requestID, "group_id", req.GroupID)
+		return errGrouped(*req)
+	}
+
+	room, err := s.roomClient.FindById(ctx, req.RoomID)
+	if err != nil {
+		util.TEL.Error(ctx, "room not found", err, "id", req.RoomID)
+		return downstreamError(err, ErrNotFound("room", req.RoomID))
+	}
+
+	if room.HostID != hostID {
+		util.TEL.Error(ctx, "bad host for room", nil, "host_id", room.HostID, "room_id", room.ID)
+		return ErrUnauthorized
+	}
+
+	actor := Actor{hostID, util.Host}
+	if err := requestStates.check(requestID, req.Status, Accepted, actor); err != nil {
+		util.TEL.Error(ctx, "request cannot be approved", err, "request_id", requestID, "status", req.Status)
+		return err
+	}
+
+	user, err := s.userClient.FindById(ctx, req.GuestID)
+	if err != nil {
+		util.TEL.Error(ctx, "user of reservation request does not exist", err, "id", req.GuestID)
+		return downstreamError(err, ErrNotFound("user", req.GuestID))
+	}
+
+	if user.Deleted {
+		util.TEL.Error(ctx, "user of reservation request deleted account", err, "id", req.GuestID)
+		return ErrNotFound("user", req.GuestID)
+	}
+
+	if _, err := s.acceptReservationRequest(ctx, req, room, actor, ""); err != nil {
+		util.TEL.Error(ctx, "could not change status to accepted", err, "request_id", requestID)
+		return err
+	}
+
+	util.TEL.Info(ctx, "reservation request approved successfully", "request_id", requestID)
+	return nil
+}
+
+func (s *service) DecideRequests(ctx context.Context, hostID uint, decisions []RequestDecision) ([]DecisionResult, error) {
+	ctx, span := util.TEL.Start(ctx, "decide-requests-service")
+	defer span.End()
+
+	if err := validateDecisions(decisions); err != nil {
+		util.TEL.Error(ctx, "invalid batch of decisions", err)
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(decisions))
+	for _, d := range decisions {
+		ids = append(ids, d.RequestID)
+	}
+	found, err := s.repo.FindRequests(RequestFilter{IDs: ids})
+	if err != nil {
+		util.TEL.Error(ctx, "could not find reservation requests", err)
+		return nil, err
+	}
+	requests := map[uint]ReservationRequest{}
+	for _, req := range found {
+		requests[req.ID] = req
+	}
+
+	actor := Actor{hostID, util.Host}
+	lookups := newDecisionLookups(s)
+	results := make([]DecisionResult, len(decisions))
+	var rejections, approvals []int
+	lockRooms := map[uint]bool{}
+	for i, d := range decisions {
+		results[i] = DecisionResult{RequestID: d.RequestID, Action: d.Action}
+		req, ok := requests[d.RequestID]
+		if !ok {
+			results[i].Err = ErrNotFound("reservation request", d.RequestID)
+			continue
+		}
+		if err := s.checkDecision(ctx, lookups, actor, req, d.Action); err != nil {
+			util.TEL.Error(ctx, "request can't be decided", err, "request_id", req.ID, "action", d.Action)
+			results[i].Err = err
+			continue
+		}
+		lockRooms[req.RoomID] = true
+		if d.Action == ApproveDecision {
+			approvals = append(approvals, i)
+		} else {
+			rejections = append(rejections, i)
+		}
+	}
+
+	// Of overlapping requests, the one made first wins, whatever order the
+	// host listed them in.
+	slices.SortFunc(approvals, func(a, b int) int {
+		ra, rb := requests[decisions[a].RequestID], requests[decisions[b].RequestID]
+		if c := ra.CreatedAt.Compare(rb.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(ra.ID, rb.ID)
+	})
+
+	roomIDs := make([]uint, 0, len(lockRooms))
+	for id := range lockRooms {
+		roomIDs = append(roomIDs, id)
+	}
+	slices.Sort(roomIDs)
+
+	ctx, span = util.TEL.Start(ctx, "decide-requests-in-db")
+	defer span.End()
+
+	err = s.repo.Transaction(func(tx Repository) error {
+		for _, id := range roomIDs {
+			if err := tx.LockRoom(id); err != nil {
+				util.TEL.Error(ctx, "could not lock room", err, "room_id", id)
+				return err
+			}
+		}
+
+		// Each decision is made in a nested transaction, so one that fails
+		// doesn't undo the others.
+		for _, i := range rejections {
+			err := tx.Transaction(func(tx Repository) error {
+				return rejectInTx(ctx, tx, results[i].RequestID, actor)
+			})
+			if err != nil {
+				util.TEL.Error(ctx, "could not reject request", err, "request_id", results[i].RequestID)
+				results[i].Err = err
+				continue
+			}
+			results[i].Status = Rejected
+		}
+
+		var accepted []ReservationRequest
+		for _, i := range approvals {
+			req := requests[results[i].RequestID]
+			lists, _ := lookups.currentLists(ctx, req.RoomID)
+			var res *Reservation
+			err := tx.Transaction(func(tx Repository) error {
+				var err error
+				res, err = acceptInTx(ctx, tx, &req, lists.availability, lists.price, actor, "")
+				return err
+			})
+			if err != nil {
+				util.TEL.Error(ctx, "could not accept request", err, "request_id", req.ID)
+				results[i].Err = err
+				// The earlier request rejected it along with the room's other
+				// requests for its dates.
+				if j := slices.IndexFunc(accepted, func(w ReservationRequest) bool { return overlaps(req, w) }); j >= 0 {
+					results[i].Err = errLostTo(req, accepted[j])
+					results[i].Status = Rejected
+				}
+				continue
+			}
+			accepted = append(accepted, req)
+			results[i].Status = Accepted
+			results[i].Reservation = res
+		}
+
+		var guestIDs []uint
+		for _, r := range results {
+			if guestID := requests[r.RequestID].GuestID; r.Status != "" && !slices.Contains(guestIDs, guestID) {
+				guestIDs = append(guestIDs, guestID)
+			}
+		}
+		slices.Sort(guestIDs)
+		for _, guestID := range guestIDs {
+			util.TEL.Debug(ctx, "enqueue summary notification for guest", "guest_id", guestID)
+			err := tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+				ReceiverID: guestID,
+				Type:       notificationclient.RequestsDecided,
+				Subject:    hostID,
+			}))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	util.TEL.Info(ctx, "batch of requests decided", "host_id", hostID, "decisions", len(decisions))
+
+	return results, nil
+}
+
+// checkDecision checks that the host can take the action on the request, as
+// ApproveReservationRequest and RejectReservationRequest do.
+func (s *service) checkDecision(ctx context.Context, lookups *decisionLookups, actor Actor, req ReservationRequest, action DecisionAction) error {
+	if req.GroupID != 0 {
+		return errGrouped(req)
+	}
+
+	room, err := lookups.room(ctx, req.RoomID)
+	if err != nil {
+		return err
+	}
+	if room.HostID != actor.ID {
+		return ErrUnauthorized
+	}
+
+	if err := requestStates.check(req.ID, req.Status, action.status(), actor); err != nil {
+		return err
+	}
+
+	if err := lookups.guest(ctx, req.GuestID); err != nil {
+		return err
+	}
+
+	if action == ApproveDecision {
+		if _, err := lookups.currentLists(ctx, req.RoomID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) CreateGroupRequest(ctx context.Context, authctx AuthContext, dto CreateGroupRequestDTO) (*RequestGroup, []Reservation, error) {
+	ctx, span := util.TEL.Start(ctx, "create-group-request")
+	defer span.End()
+
+	callerID := authctx.CallerID
+	util.TEL.Info(ctx, "guest wants to request a group of rooms", "caller_id", callerID, "rooms", len(dto.Requests))
+
+	if len(dto.Requests) < 2 || len(dto.Requests) > MaxGroupSize {
+		return nil, nil, ErrBadRequestCustom(fmt.Sprintf("a group has between 2 and %d rooms", MaxGroupSize))
+	}
+	first := dto.Requests[0]
+	rooms := map[uint]bool{}
+	for _, item := range dto.Requests {
+		if !item.DateFrom.Equal(first.DateFrom) || !item.DateTo.Equal(first.DateTo) {
+			return nil, nil, ErrBadRequestCustom("every room of a group is for the same dates")
+		}
+		if rooms[item.RoomID] {
+			return nil, nil, ErrBadRequestCustom(fmt.Sprintf("room %d is in the group more than once", item.RoomID))
+		}
+		rooms[item.RoomID] = true
+	}
+
+	if err := s.checkGuest(ctx, callerID); err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now().UTC()
+	group := &RequestGroup{GuestID: callerID, CreatedAt: now}
+	autoApprove := true
+	for _, item := range dto.Requests {
+		req, room, err := s.prepareRequest(ctx, authctx, item)
+		if err != nil {
+			return nil, nil, err
+		}
+		if group.HostID == 0 {
+			group.HostID = room.HostID
+		} else if room.HostID != group.HostID {
+			util.TEL.Error(ctx, "rooms of the group have different hosts", nil, "room_id", room.ID, "host_id", room.HostID)
+			return nil, nil, ErrBadRequestCustom("every room of a group has to be of the same host")
+		}
+		autoApprove = autoApprove && room.AutoApprove
+		group.Requests = append(group.Requests, *req)
+	}
+
+	ctx, span = util.TEL.Start(ctx, "create-group-request-in-db")
+	defer span.End()
+
+	// Rooms are locked in the same order by everyone, as in acceptGroup.
+	roomIDs := make([]uint, 0, len(group.Requests))
+	for _, req := range group.Requests {
+		roomIDs = append(roomIDs, req.RoomID)
+	}
+	slices.Sort(roomIDs)
+
+	var converted int64
+	err := s.repo.Transaction(func(tx Repository) error {
+		for _, id := range roomIDs {
+			if err := tx.LockRoom(id); err != nil {
+				util.TEL.Error(ctx, "could not lock room", err, "room_id", id)
+				return err
+			}
+		}
+		for _, req := range group.Requests {
+			if err := checkNotHeld(ctx, tx, req.RoomID, req.DateFrom, req.DateTo, callerID, now); err != nil {
+				return err
+			}
+			if err := checkNotOffered(ctx, tx, req.RoomID, req.DateFrom, req.DateTo, callerID, now); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.CreateRequestGroup(group); err != nil {
+			util.TEL.Error(ctx, "failed creating a request group", err)
+			return err
+		}
+
+		for i := range group.Requests {
+			req := &group.Requests[i]
+			req.GroupID = group.ID
+			n, err := storeRequest(ctx, tx, req, now)
+			if err != nil {
+				return err
+			}
+			converted += n
+		}
+
+		return tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+			ReceiverID: group.HostID,
+			Type:       notificationclient.GroupRequested,
+			Subject:    callerID,
+			Object:     group.ID,
+		}))
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	holdsEnded.WithLabelValues("converted").Add(float64(converted))
+
+	var reservations []Reservation
+	if autoApprove {
+		util.TEL.Info(ctx, "every room approves automatically, accepting the group", "group_id", group.ID)
+		reservations, err = s.acceptGroup(ctx, group, SystemActor, "auto-approved")
+		if err != nil {
+			util.TEL.Error(ctx, "auto-approval of the group failed", err, "group_id", group.ID)
+			return nil, nil, err
+		}
+	}
+
+	util.TEL.Info(ctx, "group request created successfully", "group_id", group.ID)
+
+	return group, reservations, nil
+}
+
+// acceptGroup approves all of the group's requests in one transaction, which
+// holds the locks of all of its rooms.
+func (s *service) acceptGroup(ctx context.Context, group *RequestGroup, actor Actor, reason string) ([]Reservation, error) {
+	util.TEL.Info(ctx, "accept group request", "group_id", group.ID, "guest_id", group.GuestID)
+
+	type lists struct{ availability, price uint }
+	current := map[uint]lists{}
+	for _, req := range group.Requests {
+		availList, err := s.roomClient.FindCurrentAvailabilityListOfRoom(ctx, req.RoomID)
+		if err != nil {
+			util.TEL.Error(ctx, "room availability list not found", err, "room_id", req.RoomID)
+			return nil, downstreamError(err, ErrNotFound("room availability list", req.RoomID))
+		}
+		pricelist, err := s.roomClient.FindCurrentPricelistOfRoom(ctx, req.RoomID)
+		if err != nil {
+			util.TEL.Error(ctx, "room price list not found", err, "room_id", req.RoomID)
+			return nil, downstreamError(err, ErrNotFound("room price list", req.RoomID))
+		}
+		current[req.RoomID] = lists{availList.ID, pricelist.ID}
+	}
+
+	// Rooms are locked in the same order by everyone, so two groups sharing
+	// rooms can't deadlock.
+	roomIDs := make([]uint, 0, len(current))
+	for id := range current {
+		roomIDs = append(roomIDs, id)
+	}
+	slices.Sort(roomIDs)
+
+	ctx, span := util.TEL.Start(ctx, "accept-group-request-in-db")
+	defer span.End()
+
+	var reservations []Reservation
+	err := s.repo.Transaction(func(tx Repository) error {
+		for _, id := range roomIDs {
+			if err := tx.LockRoom(id); err != nil {
+				util.TEL.Error(ctx, "could not lock room", err, "room_id", id)
+				return err
+			}
+		}
+
+		for i := range group.Requests {
+			req := &group.Requests[i]
+			l := current[req.RoomID]
+			res, err := acceptInTx(ctx, tx, req, l.availability, l.price, actor, reason)
+			if err != nil {
+				return err
+			}
+			reservations = append(reservations, *res)
+		}
+
+		return tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+			ReceiverID: group.GuestID,
+			Type:       notificationclient.GroupAccepted,
+			Subject:    group.HostID,
+			Object:     group.ID,
+		}))
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range group.Requests {
+		group.Requests[i].Status = Accepted
+	}
+
+	util.TEL.Info(ctx, "group request accepted successfully", "group_id", group.ID)
+
+	return reservations, nil
+}
+
+// findGroupOfHost finds a group of the host's rooms whose requests can all go
+// to status.
+func (s *service) findGroupOfHost(ctx context.Context, hostID, groupID uint, status ReservationRequestStatus) (*RequestGroup, error) {
+	group, err := s.repo.FindRequestGroupByID(groupID)
+	if err != nil {
+		util.TEL.Error(ctx, "request group not found", err, "group_id", groupID)
+		return nil, ErrNotFound("request group", groupID)
+	}
+
+	if group.HostID != hostID {
+		util.TEL.Error(ctx, "bad host for request group", nil, "host_id", group.HostID, "group_id", groupID)
+		return nil, ErrUnauthorized
+	}
+
+	actor := Actor{hostID, util.Host}
+	for _, req := range group.Requests {
+		if err := requestStates.check(req.ID, req.Status, status, actor); err != nil {
+			util.TEL.Error(ctx, "request of the group cannot change", err, "request_id", req.ID, "status", req.Status)
+			return nil, err
+		}
+	}
+	return group, nil
+}
+
+func (s *service) GetGroupRequest(ctx context.Context, caller Actor, groupID uint) (*RequestGroup, error) {
+	ctx, span := util.TEL.Start(ctx, "get-group-request")
+	defer span.End()
+
+	group, err := s.repo.FindRequestGroupByID(groupID)
+	if err != nil {
+		util.TEL.Error(ctx, "request group not found", err, "group_id", groupID)
+		return nil, ErrNotFound("request group", groupID)
+	}
+
+	switch {
+	case caller.Role == util.Admin,
+		caller.Role == util.Guest && caller.ID == group.GuestID,
+		caller.Role == util.Host && caller.ID == group.HostID:
+		return group, nil
+	}
+	util.TEL.Error(ctx, "user may not see this request group", nil, "caller_id", caller.ID, "role", caller.Role)
+	return nil, ErrUnauthorized
+}
+
+func (s *service) ApproveGroupRequest(ctx context.Context, hostID, groupID uint) (*RequestGroup, []Reservation, error) {
+	ctx, span := util.TEL.Start(ctx, "approve-group-request")
+	defer span.End()
+
+	group, err := s.findGroupOfHost(ctx, hostID, groupID, Accepted)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.userClient.FindById(ctx, group.GuestID)
+	if err != nil {
+		util.TEL.Error(ctx, "user of request group does not exist", err, "id", group.GuestID)
+		return nil, nil, downstreamError(err, ErrNotFound("user", group.GuestID))
+	}
+
+	if user.Deleted {
+		util.TEL.Error(ctx, "user of request group deleted account", nil, "id", group.GuestID)
+		return nil, nil, ErrNotFound("user", group.GuestID)
+	}
+
+	reservations, err := s.acceptGroup(ctx, group, Actor{hostID, util.Host}, "")
+	if err != nil {
+		util.TEL.Error(ctx, "could not accept request group", err, "group_id", groupID)
+		return nil, nil, err
+	}
+	return group, reservations, nil
+}
+
+func (s *service) RejectGroupRequest(ctx context.Context, hostID, groupID uint) error {
+	ctx, span := util.TEL.Start(ctx, "reject-group-request")
+	defer span.End()
+
+	group, err := s.findGroupOfHost(ctx, hostID, groupID, Rejected)
+	if err != nil {
+		return err
+	}
+
+	actor := Actor{hostID, util.Host}
+	err = s.repo.Transaction(func(tx Repository) error {
+		for _, req := range group.Requests {
+			current, err := tx.FindRequestByIDForUpdate(req.ID)
+			if err != nil {
+				util.TEL.Error(ctx, "could not find reservation request", err, "request_id", req.ID)
+				return err
+			}
+			if err := requestStates.apply(ctx, tx, req.ID, current.Status, Rejected, actor, ""); err != nil {
+				return err
+			}
+			if err := tx.SetRequestStatus(req.ID, Rejected); err != nil {
+				util.TEL.Error(ctx, "could not change status to rejected", err, "request_id", req.ID)
+				return err
+			}
+		}
+
+		return tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+			ReceiverID: group.GuestID,
+			Type:       notificationclient.GroupDeclined,
+			Subject:    hostID,
+			Object:     groupID,
+		}))
+	})
+	if err != nil {
+		return err
+	}
+
+	util.TEL.Info(ctx, "request group rejected", "group_id", groupID)
+	return nil
+}
+
+func (s *service) WithdrawGroupRequest(ctx context.Context, guestID, groupID uint) error {
+	ctx, span := util.TEL.Start(ctx, "withdraw-group-request")
+	defer span.End()
+
+	group, err := s.repo.FindRequestGroupByID(groupID)
+	if err != nil || group.GuestID != guestID {
+		util.TEL.Error(ctx, "could not find request group of user", err, "group_id", groupID, "user_id", guestID)
+		return ErrNotFound("request group", groupID)
+	}
+
+	actor := Actor{guestID, util.Guest}
+	for _, req := range group.Requests {
+		if err := requestStates.check(req.ID, req.Status, Withdrawn, actor); err != nil {
+			util.TEL.Error(ctx, "request of the group cannot be withdrawn", err, "request_id", req.ID, "status", req.Status)
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	return s.repo.Transaction(func(tx Repository) error {
+		for _, req := range group.Requests {
+			current, err := tx.FindRequestByIDForUpdate(req.ID)
+			if err != nil {
+				util.TEL.Error(ctx, "could not find reservation request", err, "request_id", req.ID)
+				return err
+			}
+			if err := requestStates.apply(ctx, tx, req.ID, current.Status, Withdrawn, actor, ""); err != nil {
+				return err
+			}
+			if err := tx.WithdrawRequest(req.ID, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *service) CancelReservation(ctx context.Context, callerID uint, reservationID uint, jwt string) (*CancellationResult, error) {
+	util.TEL.Info(ctx, "user wants to cancel reservation", "caller_id", callerID, "reservation_id", reservationID)
+
+	user, err := s.userClient.FindById(ctx, callerID)
+	if err != nil {
+		util.TEL.Error(ctx, "user not found", err, "user_id", callerID)
+		return nil, downstreamError(err, ErrUnauthenticated)
+	}
+
+	if user.Role != string(util.Guest) {
+		util.TEL.Error(ctx, "user is not a guest", nil, "role", user.Role)
+		return nil, ErrUnauthorized
+	}
+
+	reservation, err := s.repo.FindReservationById(reservationID)
+	if err != nil {
+		util.TEL.Error(ctx, "reservation not found", err, "reservation_id", reservationID)
+		return nil, ErrNotFound("reservation", reservationID)
+	}
+
+	if reservation.GuestID != callerID {
+		util.TEL.Error(ctx, "reservation does not belong to this guest", nil, "reservation_guest_id", reservation.GuestID, "caller_id", callerID)
+		return nil, ErrUnauthorized
+	}
+
+	actor := Actor{callerID, util.Guest}
+	if err := reservationStates.check(reservationID, reservation.Status, ReservationCancelled, actor); err != nil {
+		util.TEL.Error(ctx, "reservation cannot be cancelled", err, "reservation_id", reservationID, "status", reservation.Status)
+		return nil, err
+	}
+
+	if !time.Now().Before(reservation.DateFrom) {
+		util.TEL.Error(ctx, "cannot cancel reservation that already started", nil, "date_from", reservation.DateFrom)
+		return nil, ErrBadRequestCustom("cannot cancel reservation that already started")
+	}
+
+	// The host to notify has to be known before cancelling.
+	room, err := s.roomClient.FindById(ctx, reservation.RoomID)
+	if err != nil {
+		util.TEL.Error(ctx, "room not found", err, "id", reservation.RoomID)
+		return nil, downstreamError(err, ErrNotFound("room", reservation.RoomID))
+	}
+
+	ctx, span := util.TEL.Start(ctx, "cancel-reservation-in-db")
+	defer span.End()
+
+	now := time.Now().UTC()
+	refund := reservation.CancellationTerms.Refund(reservation.Cost, reservation.DateFrom, now)
+	util.TEL.Debug(ctx, "refund worked out", "reservation_id", reservationID, "policy", reservation.CancellationTerms.Kind, "refund", refund.Refund, "penalty", refund.Penalty)
+
+	cancellation := Cancellation{By: CancelledByGuest, ByID: callerID, At: now, Refund: refund.Refund, Penalty: refund.Penalty}
+	err = s.repo.Transaction(func(tx Repository) error {
+		current, err := tx.FindReservationByIDForUpdate(reservationID)
+		if err != nil {
+			util.TEL.Error(ctx, "could not find reservation", err, "reservation_id", reservationID)
+			return err
+		}
+		if err := reservationStates.apply(ctx, tx, reservationID, current.Status, ReservationCancelled, actor, ""); err != nil {
+			return err
+		}
+
+		if err := tx.CancelReservation(reservationID, cancellation); err != nil {
+			util.TEL.Error(ctx, "could not cancel reservation in database", err, "reservation_id", reservationID)
+			return err
+		}
+		if err := cancelPendingModifications(ctx, tx, reservationID, actor, cancellation.At); err != nil {
+			util.TEL.Error(ctx, "could not cancel pending modifications", err, "reservation_id", reservationID)
+			return err
+		}
+
+		if err := offerFreedDates(ctx, tx, current.RoomID, current.DateFrom, current.DateTo, cancellation.At); err != nil {
+			util.TEL.Error(ctx, "could not offer the dates to the waitlist", err, "room_id", current.RoomID)
+			return err
+		}
+
+		return tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+			ReceiverID: room.HostID,
+			Type:       notificationclient.ReservationCancelled,
+			Subject:    reservation.GuestID,
+			Object:     reservation.RoomID,
+		}))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	util.TEL.Info(ctx, "reservation cancelled successfully", "reservation_id", reservationID)
+
+	return &CancellationResult{Reservation: *reservation.cancelled(cancellation), Refund: refund}, nil
+}
+
+func (s *service) HostCancelReservation(ctx context.Context, hostID uint, reservationID uint, reason string) (*CancellationResult, error) {
+	ctx, span := util.TEL.Start(ctx, "host-cancel-reservation")
+	defer span.End()
+
+	util.TEL.Info(ctx, "host wants to cancel reservation", "host_id", hostID, "reservation_id", reservationID)
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrBadRequestCustom("a reason is required")
+	}
+	if len(reason) > MaxCancellationReasonLength {
+		return nil, ErrBadRequestCustom(fmt.Sprintf("reason can be at most %d characters long", MaxCancellationReasonLength))
+	}
+
+	user, err := s.userClient.FindById(ctx, hostID)
+	if err != nil {
+		util.TEL.Error(ctx, "user not found", err, "user_id", hostID)
+		return nil, downstreamError(err, ErrUnauthenticated)
+	}
+
+	if user.Role != string(util.Host) {
+		util.TEL.Error(ctx, "user is not a host", nil, "role", user.Role)
+		return nil, ErrUnauthorized
+	}
+
+	reservation, err := s.repo.FindReservationById(reservationID)
+	if err != nil {
+		util.TEL.Error(ctx, "reservation not found", err, "reservation_id", reservationID)
+		return nil, ErrNotFound("reservation", reservationID)
+	}
+
+	room, err := s.roomClient.FindById(ctx, reservation.RoomID)
+	if err != nil {
+		util.TEL.Error(ctx, "room not found", err, "id", reservation.RoomID)
+		return nil, downstreamError(err, ErrNotFound("room", reservation.RoomID))
+	}
+
+	if room.HostID != hostID {
+		util.TEL.Error(ctx, "user is not owner of the room", nil, "user_id", hostID, "host_id", room.HostID)
+		return nil, ErrUnauthorized
+	}
+
+	actor := Actor{hostID, util.Host}
+	if err := reservationStates.check(reservationID, reservation.Status, ReservationCancelled, actor); err != nil {
+		util.TEL.Error(ctx, "reservation cannot be cancelled", err, "reservation_id", reservationID, "status", reservation.Status)
+		return nil, err
+	}
+
+	if !time.Now().Before(reservation.DateFrom) {
+		util.TEL.Error(ctx, "cannot cancel reservation that already started", nil, "date_from", reservation.DateFrom)
+		return nil, ErrBadRequestCustom("cannot cancel reservation that already started")
+	}
+
+	ctx, span = util.TEL.Start(ctx, "host-cancel-reservation-in-db")
+	defer span.End()
+
+	refund := FullRefund(reservation.Cost)
+	cancellation := Cancellation{By: CancelledByHost, ByID: hostID, Reason: reason, At: time.Now().UTC(), Refund: refund.Refund}
+	err = s.repo.Transaction(func(tx Repository) error {
+		current, err := tx.FindReservationByIDForUpdate(reservationID)
+		if err != nil {
+			util.TEL.Error(ctx, "could not find reservation", err, "reservation_id", reservationID)
+			return err
+		}
+		if err := reservationStates.apply(ctx, tx, reservationID, current.Status, ReservationCancelled, actor, reason); err != nil {
+			return err
+		}
+
+		if err := tx.CancelReservation(reservationID, cancellation); err != nil {
+			util.TEL.Error(ctx, "could not cancel reservation in database", err, "reservation_id", reservationID)
+			return err
+		}
+		if err := cancelPendingModifications(ctx, tx, reservationID, actor, cancellation.At); err != nil {
+			util.TEL.Error(ctx, "could not cancel pending modifications", err, "reservation_id", reservationID)
+			return err
+		}
+
+		if err := offerFreedDates(ctx, tx, current.RoomID, current.DateFrom, current.DateTo, cancellation.At); err != nil {
+			util.TEL.Error(ctx, "could not offer the dates to the waitlist", err, "room_id", current.RoomID)
+			return err
+		}
+
+		return tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+			ReceiverID: reservation.GuestID,
+			Type:       notificationclient.ReservationCancelledByHost,
+			Subject:    hostID,
+			Object:     reservation.RoomID,
+		}))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	util.TEL.Info(ctx, "reservation cancelled by host", "reservation_id", reservationID)
+
+	return &CancellationResult{Reservation: *reservation.cancelled(cancellation), Refund: refund}, nil
+}
+
+func (s *service) CheckIn(ctx context.Context, hostID uint, reservationID uint) (*Reservation, error) {
+	ctx, span := util.TEL.Start(ctx, "check-in")
+	defer span.End()
+
+	return s.recordStay(ctx, hostID, reservationID, ReservationCheckedIn)
+}
+
+func (s *service) CheckOut(ctx context.Context, hostID uint, reservationID uint) (*Reservation, error) {
+	ctx, span := util.TEL.Start(ctx, "check-out")
+	defer span.End()
+
+	return s.recordStay(ctx, hostID, reservationID, ReservationCheckedOut)
+}
+
+func (s *service) MarkNoShow(ctx context.Context, hostID uint, reservationID uint) (*Reservation, error) {
+	ctx, span := util.TEL.Start(ctx, "mark-no-show")
+	defer span.End()
+
+	return s.recordStay(ctx, hostID, reservationID, ReservationNoShow)
+}
+
+// recordStay moves a reservation of one of the host's rooms to status, which
+// is checked in, checked out or no-show. Only guests whose stay began can
+// arrive or fail to, and nobody can arrive after it ended.
+func (s *service) recordStay(ctx context.Context, hostID uint, reservationID uint, status ReservationStatus) (*Reservation, error) {
+	util.TEL.Info(ctx, "host wants to record stay", "host_id", hostID, "reservation_id", reservationID, "status", status)
+
+	reservation, err := s.repo.FindReservationById(reservationID)
+	if err != nil {
+		util.TEL.Error(ctx, "reservation not found", err, "reservation_id", reservationID)
+		return nil, ErrNotFound("reservation", reservationID)
+	}
+
+	room, err := s.roomClient.FindById(ctx, reservation.RoomID)
+	if err != nil {
+		util.TEL.Error(ctx, "room not found", err, "id", reservation.RoomID)
+		return nil, downstreamError(err, ErrNotFound("room", reservation.RoomID))
+	}
+
+	if room.HostID != hostID {
+		util.TEL.Error(ctx, "user is not owner of the room", nil, "user_id", hostID, "host_id", room.HostID)
+		return nil, ErrUnauthorized
+	}
+
+	actor := Actor{hostID, util.Host}
+	if err := reservationStates.check(reservationID, reservation.Status, status, actor); err != nil {
+		util.TEL.Error(ctx, "stay cannot be recorded", err, "reservation_id", reservationID, "status", reservation.Status)
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if now.Before(dateOf(reservation.DateFrom)) {
+		util.TEL.Error(ctx, "stay hasn't begun", nil, "date_from", reservation.DateFrom)
+		return nil, ErrBadRequestCustom("the stay hasn't begun yet")
+	}
+	if status == ReservationCheckedIn && !now.Before(dateOf(reservation.DateTo).AddDate(0, 0, 1)) {
+		util.TEL.Error(ctx, "stay is over", nil, "date_to", reservation.DateTo)
+		return nil, ErrBadRequestCustom("the stay is over")
+	}
+
+	err = s.repo.Transaction(func(tx Repository) error {
+		current, err := tx.FindReservationByIDForUpdate(reservationID)
+		if err != nil {
+			util.TEL.Error(ctx, "could not find reservation", err, "reservation_id", reservationID)
+			return err
+		}
+		return moveStay(ctx, tx, current, hostID, status, actor, "", now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	util.TEL.Info(ctx, "stay recorded", "reservation_id", reservationID, "status", status)
+
+	return reservation.withStayStatus(status, now), nil
+}
+
+func (s *service) RequestModification(ctx context.Context, authctx AuthContext, reservationID uint, dto ModifyReservationDTO) (*ReservationModification, error) {
+	ctx, span := util.TEL.Start(ctx, "request-modification")
+	defer span.End()
+
+	callerID := authctx.CallerID
+	util.TEL.Info(ctx, "guest wants to modify reservation", "caller_id", callerID, "reservation_id", reservationID)
+
+	reservation, err := s.repo.FindReservationById(reservationID)
+	if err != nil {
+		util.TEL.Error(ctx, "reservation not found", err, "reservation_id", reservationID)
+		return nil, ErrNotFound("reservation", reservationID)
+	}
+
+	if reservation.GuestID != callerID {
+		util.TEL.Error(ctx, "reservation does not belong to this guest", nil, "reservation_guest_id", reservation.GuestID, "caller_id", callerID)
+		return nil, ErrUnauthorized
+	}
+
+	if reservation.Cancelled {
+		util.TEL.Error(ctx, "reservation is cancelled", nil, "reservation_id", reservationID)
+		return nil, ErrBadRequestCustom("reservation is cancelled")
+	}
+	if reservation.Status == ReservationCheckedOut || reservation.Status == ReservationNoShow {
+		util.TEL.Error(ctx, "stay is over", nil, "reservation_id", reservationID, "status", reservation.Status)
+		return nil, ErrBadRequestCustom("the stay is over")
+	}
+
+	previous := Stay{DateFrom: reservation.DateFrom, DateTo: reservation.DateTo, GuestCount: reservation.GuestCount, Cost: reservation.Cost}
+	proposed := previous
+	if dto.DateFrom != nil {
+		proposed.DateFrom = *dto.DateFrom
+	}
+	if dto.DateTo != nil {
+		proposed.DateTo = *dto.DateTo
+	}
+	if dto.GuestCount != nil {
+		proposed.GuestCount = *dto.GuestCount
+	}
+	if err := validateModification(previous, proposed, time.Now()); err != nil {
+		util.TEL.Error(ctx, "invalid modification", err, "reservation_id", reservationID)
+		return nil, err
+	}
+
+	pending, err := s.repo.HasPendingModification(reservationID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not check for pending modifications", err, "reservation_id", reservationID)
+		return nil, err
+	}
+	if pending {
+		util.TEL.Error(ctx, "reservation already has a pending modification", nil, "reservation_id", reservationID)
+		return nil, ErrConflict
+	}
+
+	room, err := s.roomClient.FindById(ctx, reservation.RoomID)
+	if err != nil {
+		util.TEL.Error(ctx, "room not found", err, "id", reservation.RoomID)
+		return nil, downstreamError(err, ErrNotFound("room", reservation.RoomID))
+	}
+
+	availList, err := s.roomClient.FindCurrentAvailabilityListOfRoom(ctx, room.ID)
+	if err != nil {
+		util.TEL.Error(ctx, "room availability list of room not found", err, "room_id", room.ID)
+		return nil, downstreamError(err, ErrNotFound("room availability list", room.ID))
+	}
+
+	pricelist, err := s.roomClient.FindCurrentPricelistOfRoom(ctx, room.ID)
+	if err != nil {
+		util.TEL.Error(ctx, "room price list of room not found", err, "room_id", room.ID)
+		return nil, downstreamError(err, ErrNotFound("room price list", room.ID))
+	}
+
+	util.TEL.Debug(ctx, "query room for the new stay")
+	queryResponse, err := s.roomClient.QueryForReservation(ctx, authctx.JWT, roomclient.RoomReservationQueryDTO{
+		RoomID:     room.ID,
+		DateFrom:   proposed.DateFrom,
+		DateTo:     proposed.DateTo,
+		GuestCount: proposed.GuestCount,
+	})
+	if err != nil {
+		util.TEL.Error(ctx, "could not query room for reservation", err, "room_id", room.ID)
+		return nil, downstreamError(err, ErrBadRequest)
+	}
+	if !queryResponse.Available {
+		util.TEL.Error(ctx, "room is not available for the new stay", nil, "room_id", room.ID)
+		return nil, ErrBadRequestCustom("room is not available for the new stay")
+	}
+	proposed.Cost = queryResponse.TotalCost
+
+	has, err := s.repo.HasOtherReservationsInRange(room.ID, proposed.DateFrom, proposed.DateTo, reservationID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not check for reservations for room", err, "room_id", room.ID)
+		return nil, err
+	}
+	if has {
+		util.TEL.Error(ctx, "room has another reservation for the new dates", nil, "room_id", room.ID, "from", proposed.DateFrom, "to", proposed.DateTo)
+		return nil, ErrConflict
+	}
+
+	ctx, span = util.TEL.Start(ctx, "request-modification-in-db")
+	defer span.End()
+
+	now := time.Now().UTC()
+	m := &ReservationModification{
+		ReservationID:      reservationID,
+		RoomID:             room.ID,
+		GuestID:            callerID,
+		Previous:           previous,
+		Proposed:           proposed,
+		RoomAvailabilityID: availList.ID,
+		RoomPriceID:        pricelist.ID,
+		Status:             ModificationPending,
+		CreatedAt:          now,
+	}
+	// A modification the room approves automatically is made and applied in
+	// one transaction, so one that can't be applied leaves nothing behind.
+	err = s.repo.Transaction(func(tx Repository) error {
+		if room.AutoApprove {
+			util.TEL.Debug(ctx, "lock room", "room_id", room.ID)
+			if err := tx.LockRoom(room.ID); err != nil {
+				util.TEL.Error(ctx, "could not lock room", err, "room_id", room.ID)
+				return err
+			}
+		}
+
+		if err := tx.CreateModification(m); err != nil {
+			util.TEL.Error(ctx, "could not create modification", err, "reservation_id", reservationID)
+			return err
+		}
+		if err := modificationStates.apply(ctx, tx, m.ID, "", ModificationPending, Actor{callerID, util.Guest}, ""); err != nil {
+			return err
+		}
+
+		err := tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+			ReceiverID: room.HostID,
+			Type:       notificationclient.ModificationRequested,
+			Subject:    callerID,
+			Object:     room.ID,
+		}))
+		if err != nil || !room.AutoApprove {
+			return err
+		}
+
+		util.TEL.Info(ctx, "auto-approval is enabled, applying modification automatically", "room_id", room.ID)
+		return applyModificationInTx(ctx, tx, m, room, SystemActor, "auto-approved", now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if room.AutoApprove {
+		m.Status = ModificationApproved
+		m.DecidedAt = &now
+	}
+
+	util.TEL.Info(ctx, "modification requested", "modification_id", m.ID, "status", m.Status)
+	return m, nil
+}
+
+// validateModification checks the proposed stay on its own. A stay that
+// already started can still be made longer or shorter, but not moved.
+func validateModification(previous, proposed Stay, now time.Time) error {
+	if proposed.DateFrom.Equal(previous.DateFrom) && proposed.DateTo.Equal(previous.DateTo) && proposed.GuestCount == previous.GuestCount {
+		return ErrBadRequestCustom("nothing to change")
+	}
+	if proposed.GuestCount < 1 {
+		return ErrBadRequestCustom("guest count must be at least 1")
+	}
+	if proposed.DateFrom.After(proposed.DateTo) {
+		return ErrBadRequestCustom("dates are reversed")
+	}
+	if !now.Before(previous.DateTo) {
+		return ErrBadRequestCustom("reservation is over")
+	}
+
+	started := !now.Before(previous.DateFrom)
+	if started && !proposed.DateFrom.Equal(previous.DateFrom) {
+		return ErrBadRequestCustom("cannot move the start of a stay that already started")
+	}
+	if !started && !now.Before(proposed.DateFrom) {
+		return ErrBadRequestCustom("new dates must be in the future")
+	}
+	if !now.Before(proposed.DateTo) {
+		return ErrBadRequestCustom("new dates must be in the future")
+	}
+	return nil
+}
+
+func (s *service) ApproveModification(ctx context.Context, hostID uint, modificationID uint) (*ReservationModification, error) {
+	ctx, span := util.TEL.Start(ctx, "approve-modification")
+	defer span.End()
+
+	actor := Actor{hostID, util.Host}
+	m, room, err := s.findModificationOfHost(ctx, actor, modificationID, ModificationApproved)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.applyModification(ctx, m, room, actor); err != nil {
+		util.TEL.Error(ctx, "could not apply modification", err, "modification_id", modificationID)
+		return nil, err
+	}
+
+	util.TEL.Info(ctx, "modification approved", "modification_id", modificationID)
+	return m, nil
+}
+
+func (s *service) RejectModification(ctx context.Context, hostID uint, modificationID uint) error {
+	ctx, span := util.TEL.Start(ctx, "reject-modification")
+	defer span.End()
+
+	actor := Actor{hostID, util.Host}
+	m, room, err := s.findModificationOfHost(ctx, actor, modificationID, ModificationRejected)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.Transaction(func(tx Repository) error {
+		current, err := tx.FindModificationByIDForUpdate(m.ID)
+		if err != nil {
+			util.TEL.Error(ctx, "could not find modification", err, "modification_id", m.ID)
+			return err
+		}
+		if err := modificationStates.apply(ctx, tx, m.ID, current.Status, ModificationRejected, actor, ""); err != nil {
+			return err
+		}
+
+		if err := tx.SetModificationStatus(m.ID, ModificationRejected, time.Now().UTC()); err != nil {
+			util.TEL.Error(ctx, "could not change modification status to rejected", err, "modification_id", m.ID)
+			return err
+		}
+
+		return tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+			ReceiverID: m.GuestID,
+			Type:       notificationclient.ModificationDeclined,
+			Subject:    room.HostID,
+			Object:     room.ID,
+		}))
+	})
+	if err != nil {
+		return err
+	}
+
+	util.TEL.Info(ctx, "modification rejected", "modification_id", modificationID)
+	return nil
+}
+
+// findModificationOfHost finds a modification of one of the host's rooms that
+// can go to status.
+func (s *service) findModificationOfHost(ctx context.Context, host Actor, modificationID uint, status ModificationStatus) (*ReservationModification, *roomclient.RoomDTO, error) {
+	m, err := s.repo.FindModificationByID(modificationID)
+	if err != nil {
+		util.TEL.Error(ctx, "modification not found", err, "modification_id", modificationID)
+		return nil, nil, ErrNotFound("modification", modificationID)
+	}
+
+	room, err := s.roomClient.FindById(ctx, m.RoomID)
+	if err != nil {
+		util.TEL.Error(ctx, "room not found", err, "id", m.RoomID)
+		return nil, nil, downstreamError(err, ErrNotFound("room", m.RoomID))
+	}
+
+	if room.HostID != host.ID {
+		util.TEL.Error(ctx, "user is not owner of the room", nil, "user_id", host.ID, "host_id", room.HostID)
+		return nil, nil, ErrUnauthorized
+	}
+
+	if err := modificationStates.check(m.ID, m.Status, status, host); err != nil {
+		util.TEL.Error(ctx, "modification cannot change", err, "modification_id", modificationID, "status", m.Status)
+		return nil, nil, err
+	}
+	return m, room, nil
+}
+
+// applyModification changes the reservation on behalf of actor, in one
+// transaction holding the room's lock.
+func (s *service) applyModification(ctx context.Context, m *ReservationModification, room *roomclient.RoomDTO, actor Actor) error {
+	ctx, span := util.TEL.Start(ctx, "apply-modification-in-db")
+	defer span.End()
+
+	now := time.Now().UTC()
+	err := s.repo.Transaction(func(tx Repository) error {
+		util.TEL.Debug(ctx, "lock room", "room_id", m.RoomID)
+		if err := tx.LockRoom(m.RoomID); err != nil {
+			util.TEL.Error(ctx, "could not lock room", err, "room_id", m.RoomID)
+			return err
+		}
+		return applyModificationInTx(ctx, tx, m, room, actor, "", now)
+	})
+	if err != nil {
+		return err
+	}
+
+	m.Status = ModificationApproved
+	m.DecidedAt = &now
+	return nil
+}
+
+// applyModificationInTx changes the reservation in tx, rechecking that the
+// modification is still pending and the new dates are still free. Pending
+// requests overlapping the new dates are rejected, as they are when a request
+// is approved. The caller has to hold the room's lock.
+func applyModificationInTx(ctx context.Context, tx Repository, m *ReservationModification, room *roomclient.RoomDTO, actor Actor, reason string, now time.Time) error {
+	current, err := tx.FindModificationByIDForUpdate(m.ID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find modification", err, "modification_id", m.ID)
+		return err
+	}
+	if err := modificationStates.apply(ctx, tx, m.ID, current.Status, ModificationApproved, actor, reason); err != nil {
+		return err
+	}
+
+	// The reservation can't have been cancelled or closed either, or the
+	// new dates would be taken from nobody.
+	res, err := tx.FindReservationByIDForUpdate(m.ReservationID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find reservation", err, "reservation_id", m.ReservationID)
+		return err
+	}
+	if res.Status != ReservationConfirmed && res.Status != ReservationCheckedIn {
+		util.TEL.Error(ctx, "reservation can no longer be modified", nil, "reservation_id", m.ReservationID, "status", res.Status)
+		return ErrConflict
+	}
+
+	has, err := tx.HasOtherReservationsInRange(m.RoomID, m.Proposed.DateFrom, m.Proposed.DateTo, m.ReservationID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not check for reservations for room", err, "room_id", m.RoomID)
+		return err
+	}
+	if has {
+		util.TEL.Error(ctx, "room got a reservation for the new dates in the meantime", nil, "room_id", m.RoomID)
+		return ErrConflict
+	}
+	if err := checkNotHeld(ctx, tx, m.RoomID, m.Proposed.DateFrom, m.Proposed.DateTo, m.GuestID, now); err != nil {
+		return err
+	}
+	if err := checkNotOffered(ctx, tx, m.RoomID, m.Proposed.DateFrom, m.Proposed.DateTo, m.GuestID, now); err != nil {
+		return err
+	}
+
+	if err := tx.ApplyModification(m); err != nil {
+		util.TEL.Error(ctx, "could not apply modification to reservation", err, "reservation_id", m.ReservationID)
 		return err
 	}
 
-	util.TEL.Info("reservation cancelled successfully", "reservation_id", reservationID)
+	if err := tx.SetModificationStatus(m.ID, ModificationApproved, now); err != nil {
+		util.TEL.Error(ctx, "could not change modification status to approved", err, "modification_id", m.ID)
+		return err
+	}
+
+	if err := rejectOverlappingRequests(ctx, tx, m.RoomID, m.Proposed.DateFrom, m.Proposed.DateTo, m.ReservationID); err != nil {
+		util.TEL.Error(ctx, "could not reject overlapping requests", err, "room_id", m.RoomID)
+		return err
+	}
+
+	return tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+		ReceiverID: m.GuestID,
+		Type:       notificationclient.ModificationAccepted,
+		Subject:    room.HostID,
+		Object:     room.ID,
+	}))
+}
+
+func (s *service) GetRequestHistory(ctx context.Context, caller Actor, requestID uint) ([]StatusTransition, error) {
+	ctx, span := util.TEL.Start(ctx, "get-request-history")
+	defer span.End()
+
+	req, err := s.repo.FindRequestByIDUnscoped(requestID)
+	if err != nil {
+		util.TEL.Error(ctx, "reservation request not found", err, "request_id", requestID)
+		return nil, ErrNotFound("reservation request", requestID)
+	}
+
+	if err := s.checkHistoryAccess(ctx, caller, req.GuestID, req.RoomID); err != nil {
+		return nil, err
+	}
+
+	transitions, err := s.repo.FindTransitions(SubjectRequest, requestID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find status history", err, "request_id", requestID)
+		return nil, err
+	}
+	return transitions, nil
+}
+
+func (s *service) GetReservationHistory(ctx context.Context, caller Actor, reservationID uint) ([]StatusTransition, error) {
+	ctx, span := util.TEL.Start(ctx, "get-reservation-history")
+	defer span.End()
+
+	reservation, err := s.repo.FindReservationById(reservationID)
+	if err != nil {
+		util.TEL.Error(ctx, "reservation not found", err, "reservation_id", reservationID)
+		return nil, ErrNotFound("reservation", reservationID)
+	}
+
+	if err := s.checkHistoryAccess(ctx, caller, reservation.GuestID, reservation.RoomID); err != nil {
+		return nil, err
+	}
+
+	transitions, err := s.repo.FindTransitions(SubjectReservation, reservationID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find status history", err, "reservation_id", reservationID)
+		return nil, err
+	}
+	return transitions, nil
+}
+
+func (s *service) GetModificationHistory(ctx context.Context, caller Actor, modificationID uint) ([]StatusTransition, error) {
+	ctx, span := util.TEL.Start(ctx, "get-modification-history")
+	defer span.End()
+
+	m, err := s.repo.FindModificationByID(modificationID)
+	if err != nil {
+		util.TEL.Error(ctx, "modification not found", err, "modification_id", modificationID)
+		return nil, ErrNotFound("modification", modificationID)
+	}
+
+	if err := s.checkHistoryAccess(ctx, caller, m.GuestID, m.RoomID); err != nil {
+		return nil, err
+	}
+
+	transitions, err := s.repo.FindTransitions(SubjectModification, modificationID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find status history", err, "modification_id", modificationID)
+		return nil, err
+	}
+	return transitions, nil
+}
 
-	util.TEL.Push(ctx, "create-notification")
-	defer util.TEL.Pop()
+// checkHistoryAccess lets admins, the guest and the host of the room see the
+// status history of something of guestID in roomID.
+func (s *service) checkHistoryAccess(ctx context.Context, caller Actor, guestID, roomID uint) error {
+	switch caller.Role {
+	case util.Admin:
+		return nil
+	case util.Guest:
+		if caller.ID == guestID {
+			return nil
+		}
+	case util.Host:
+		room, err := s.roomClient.FindById(ctx, roomID)
+		if err != nil {
+			util.TEL.Error(ctx, "room not found", err, "id", roomID)
+			return downstreamError(err, ErrNotFound("room", roomID))
+		}
+		if room.HostID == caller.ID {
+			return nil
+		}
+	}
+	util.TEL.Error(ctx, "user may not see this status history", nil, "caller_id", caller.ID, "role", caller.Role)
+	return ErrUnauthorized
+}
 
-	room, err := s.roomClient.FindById(util.TEL.Ctx(), reservation.RoomID)
+// cancelPendingModifications cancels the pending modifications of the
+// reservation actor just cancelled.
+func cancelPendingModifications(ctx context.Context, tx Repository, reservationID uint, actor Actor, at time.Time) error {
+	cancelled, err := tx.CancelPendingModifications(reservationID, at)
 	if err != nil {
-		util.TEL.Error("room not found", err, "id", reservation.RoomID)
 		return err
 	}
+	reason := fmt.Sprintf("reservation %d cancelled", reservationID)
+	for _, m := range cancelled {
+		if err := modificationStates.apply(ctx, tx, m.ID, ModificationPending, ModificationCancelled, actor, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	createNotifDTO := notificationclient.CreateNotificationDTO{
-		ReceiverID: room.HostID,
-		Type:       notificationclient.ReservationCancelled,
-		Subject:    reservation.GuestID,
-		Object:     reservation.RoomID,
+// rejectOverlappingRequests rejects the room's pending requests whose dates
+// were just taken by the reservation reservationID.
+func rejectOverlappingRequests(ctx context.Context, tx Repository, roomID uint, from, to time.Time, reservationID uint) error {
+	rejected, err := tx.RejectPendingRequestsInRange(roomID, from, to)
+	if err != nil {
+		return err
+	}
+	reason := fmt.Sprintf("dates taken by reservation %d", reservationID)
+	for _, req := range rejected {
+		if err := requestStates.apply(ctx, tx, req.ID, Pending, Rejected, SystemActor, reason); err != nil {
+			return err
+		}
+		if req.GroupID != 0 {
+			if err := rejectRestOfGroup(ctx, tx, req.GroupID, req.ID); err != nil {
+				return err
+			}
+		}
 	}
+	return nil
+}
 
-	if _, err := s.notificationClient.CreateNotification(util.TEL.Ctx(), jwt, createNotifDTO); err != nil {
-		util.TEL.Error("failed to send notification to host", err, "host_id", room.HostID)
-		// not returning error – notification failures shouldn’t break reservation creation
+func (s *service) GetModifications(ctx context.Context, callerID uint, reservationID uint) ([]ReservationModification, error) {
+	ctx, span := util.TEL.Start(ctx, "get-modifications")
+	defer span.End()
+
+	reservation, err := s.repo.FindReservationById(reservationID)
+	if err != nil {
+		util.TEL.Error(ctx, "reservation not found", err, "reservation_id", reservationID)
+		return nil, ErrNotFound("reservation", reservationID)
 	}
 
-	return nil
+	if reservation.GuestID != callerID {
+		room, err := s.roomClient.FindById(ctx, reservation.RoomID)
+		if err != nil {
+			util.TEL.Error(ctx, "room not found", err, "id", reservation.RoomID)
+			return nil, downstreamError(err, ErrNotFound("room", reservation.RoomID))
+		}
+		if room.HostID != callerID {
+			util.TEL.Error(ctx, "user is neither guest nor host of the reservation", nil, "caller_id", callerID, "reservation_id", reservationID)
+			return nil, ErrUnauthorized
+		}
+	}
+
+	modifications, err := s.repo.FindModificationsByReservationID(reservationID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find modifications", err, "reservation_id", reservationID)
+		return nil, err
+	}
+	return modifications, nil
 }
 
 func (s *service) GetGuestCancellationCount(ctx context.Context, guestID uint) (uint, error) {
-	util.TEL.Push(ctx, "get-guest-cancellation-count")
-	defer util.TEL.Pop()
+	ctx, span := util.TEL.Start(ctx, "get-guest-cancellation-count")
+	defer span.End()
 
-	util.TEL.Info("count guest cancellations for user", "guest_id", guestID)
+	util.TEL.Info(ctx, "count guest cancellations for user", "guest_id", guestID)
 
 	count, err := s.repo.CountGuestCancellations(guestID)
 	if err != nil {
-		util.TEL.Error("could not count guest cancellations", err, "guest_id", guestID)
+		util.TEL.Error(ctx, "could not count guest cancellations", err, "guest_id", guestID)
+		return 0, err
+	}
+
+	util.TEL.Debug(ctx, "guest cancellation count calculated", "guest_id", guestID, "count", count)
+	return uint(count), nil
+}
+
+func (s *service) GetHostCancellationCount(ctx context.Context, hostID uint) (uint, error) {
+	ctx, span := util.TEL.Start(ctx, "get-host-cancellation-count")
+	defer span.End()
+
+	count, err := s.repo.CountHostCancellations(hostID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not count host cancellations", err, "host_id", hostID)
 		return 0, err
 	}
 
-	util.TEL.Debug("guest cancellation count calculated", "guest_id", guestID, "count", count)
+	util.TEL.Debug(ctx, "host cancellation count calculated", "host_id", hostID, "count", count)
 	return uint(count), nil
 }
 
+func (s *service) GetCancellationPolicy(ctx context.Context, roomID uint) (*CancellationPolicy, error) {
+	ctx, span := util.TEL.Start(ctx, "get-cancellation-policy")
+	defer span.End()
+
+	if _, err := s.roomClient.FindById(ctx, roomID); err != nil {
+		util.TEL.Error(ctx, "room not found", err, "id", roomID)
+		return nil, downstreamError(err, ErrNotFound("room", roomID))
+	}
+
+	terms, err := cancellationTerms(s.repo, roomID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find cancellation policy", err, "room_id", roomID)
+		return nil, err
+	}
+	return &CancellationPolicy{RoomID: roomID, Terms: terms}, nil
+}
+
+func (s *service) SetCancellationPolicy(ctx context.Context, hostID uint, roomID uint, terms CancellationTerms) (*CancellationPolicy, error) {
+	ctx, span := util.TEL.Start(ctx, "set-cancellation-policy")
+	defer span.End()
+
+	util.TEL.Info(ctx, "host wants to set cancellation policy", "host_id", hostID, "room_id", roomID, "policy", terms.Kind)
+
+	room, err := s.roomClient.FindById(ctx, roomID)
+	if err != nil {
+		util.TEL.Error(ctx, "room not found", err, "id", roomID)
+		return nil, downstreamError(err, ErrNotFound("room", roomID))
+	}
+
+	if room.HostID != hostID {
+		util.TEL.Error(ctx, "user is not owner of the room", nil, "user_id", hostID, "host_id", room.HostID)
+		return nil, ErrUnauthorized
+	}
+
+	policy := &CancellationPolicy{RoomID: roomID, Terms: terms, UpdatedAt: time.Now().UTC()}
+	if err := s.repo.SaveCancellationPolicy(policy); err != nil {
+		util.TEL.Error(ctx, "could not save cancellation policy", err, "room_id", roomID)
+		return nil, err
+	}
+
+	util.TEL.Info(ctx, "cancellation policy set", "room_id", roomID, "policy", terms.Kind)
+	return policy, nil
+}
+
+func (s *service) GetRoomCalendar(ctx context.Context, hostID uint, roomID uint, from, to time.Time) (*RoomCalendar, error) {
+	ctx, span := util.TEL.Start(ctx, "get-room-calendar")
+	defer span.End()
+
+	util.TEL.Info(ctx, "host wants room calendar", "host_id", hostID, "room_id", roomID, "from", from, "to", to)
+
+	if to.Before(from) {
+		return nil, ErrBadRequestCustom("dates are reversed")
+	}
+	if dateOf(to).Sub(dateOf(from)) >= MaxCalendarDays*24*time.Hour {
+		return nil, ErrBadRequestCustom(fmt.Sprintf("a calendar can span at most %d days", MaxCalendarDays))
+	}
+
+	room, err := s.roomClient.FindById(ctx, roomID)
+	if err != nil {
+		util.TEL.Error(ctx, "room not found", err, "id", roomID)
+		return nil, downstreamError(err, ErrNotFound("room", roomID))
+	}
+
+	if room.HostID != hostID {
+		util.TEL.Error(ctx, "user is not owner of the room", nil, "user_id", hostID, "host_id", room.HostID)
+		return nil, ErrUnauthorized
+	}
+
+	availList, err := s.roomClient.FindCurrentAvailabilityListOfRoom(ctx, roomID)
+	if err != nil {
+		util.TEL.Error(ctx, "room availability list not found", err, "room_id", roomID)
+		return nil, downstreamError(err, ErrNotFound("room availability list", roomID))
+	}
+
+	pricelist, err := s.roomClient.FindCurrentPricelistOfRoom(ctx, roomID)
+	if err != nil {
+		util.TEL.Error(ctx, "room price list not found", err, "room_id", roomID)
+		return nil, downstreamError(err, ErrNotFound("room price list", roomID))
+	}
+
+	reservations, err := s.repo.FindReservations(ReservationFilter{
+		RoomIDs:          []uint{roomID},
+		ExcludeCancelled: true,
+		From:             from,
+		To:               to,
+	})
+	if err != nil {
+		util.TEL.Error(ctx, "could not find reservations of room", err, "room_id", roomID)
+		return nil, err
+	}
+
+	requests, err := s.repo.FindRequests(RequestFilter{
+		RoomIDs:  []uint{roomID},
+		Statuses: []ReservationRequestStatus{Pending},
+		From:     from,
+		To:       to,
+	})
+	if err != nil {
+		util.TEL.Error(ctx, "could not find pending requests of room", err, "room_id", roomID)
+		return nil, err
+	}
+
+	util.TEL.Debug(ctx, "building room calendar", "reservations", len(reservations), "requests", len(requests))
+	return newRoomCalendar(roomID, from, to, availList, pricelist, reservations, requests), nil
+}
+
+func (s *service) CreateFeedToken(ctx context.Context, caller Actor, kind FeedKind, roomID uint) (*FeedToken, string, error) {
+	ctx, span := util.TEL.Start(ctx, "create-feed-token")
+	defer span.End()
+
+	util.TEL.Info(ctx, "user wants a feed token", "user_id", caller.ID, "kind", kind, "room_id", roomID)
+
+	role, ok := feedRoles[kind]
+	if !ok {
+		return nil, "", ErrBadRequestCustom(fmt.Sprintf("unknown feed kind %q", kind))
+	}
+	if caller.Role != role {
+		util.TEL.Error(ctx, "user cannot have this kind of feed", nil, "role", caller.Role, "kind", kind)
+		return nil, "", ErrUnauthorized
+	}
+
+	if kind == FeedRoom {
+		room, err := s.roomClient.FindById(ctx, roomID)
+		if err != nil {
+			util.TEL.Error(ctx, "room not found", err, "id", roomID)
+			return nil, "", downstreamError(err, ErrNotFound("room", roomID))
+		}
+		if room.HostID != caller.ID {
+			util.TEL.Error(ctx, "user is not owner of the room", nil, "user_id", caller.ID, "host_id", room.HostID)
+			return nil, "", ErrUnauthorized
+		}
+	} else {
+		roomID = 0
+	}
+
+	token, hash, err := newFeedToken()
+	if err != nil {
+		util.TEL.Error(ctx, "could not generate feed token", err)
+		return nil, "", err
+	}
+
+	t := &FeedToken{UserID: caller.ID, Kind: kind, RoomID: roomID, TokenHash: hash, CreatedAt: time.Now().UTC()}
+	if err := s.repo.CreateFeedToken(t); err != nil {
+		util.TEL.Error(ctx, "could not store feed token", err)
+		return nil, "", err
+	}
+
+	util.TEL.Info(ctx, "feed token created", "token_id", t.ID)
+	return t, token, nil
+}
+
+func (s *service) GetFeedTokens(ctx context.Context, userID uint) ([]FeedToken, error) {
+	ctx, span := util.TEL.Start(ctx, "get-feed-tokens")
+	defer span.End()
+
+	tokens, err := s.repo.FindFeedTokensByUserID(userID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find feed tokens", err, "user_id", userID)
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s *service) RevokeFeedToken(ctx context.Context, userID uint, tokenID uint) error {
+	ctx, span := util.TEL.Start(ctx, "revoke-feed-token")
+	defer span.End()
+
+	err := s.repo.RevokeFeedToken(tokenID, userID, time.Now().UTC())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		util.TEL.Error(ctx, "no such valid feed token", err, "user_id", userID, "token_id", tokenID)
+		return ErrNotFound("feed token", tokenID)
+	}
+	if err != nil {
+		util.TEL.Error(ctx, "could not revoke feed token", err, "token_id", tokenID)
+		return err
+	}
+
+	util.TEL.Info(ctx, "feed token revoked", "user_id", userID, "token_id", tokenID)
+	return nil
+}
+
+func (s *service) GetFeed(ctx context.Context, token string) (*Feed, error) {
+	ctx, span := util.TEL.Start(ctx, "get-feed")
+	defer span.End()
+
+	t, err := s.repo.FindFeedTokenByHash(hashFeedToken(token))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		util.TEL.Error(ctx, "unknown or revoked feed token", nil)
+		return nil, ErrFeedNotFound
+	}
+	if err != nil {
+		util.TEL.Error(ctx, "could not find feed token", err)
+		return nil, err
+	}
+	util.TEL.Debug(ctx, "building feed", "token_id", t.ID, "user_id", t.UserID, "kind", t.Kind)
+
+	now := time.Now()
+	feed := &Feed{Rooms: map[uint]roomclient.RoomDTO{}}
+	filter := ReservationFilter{ExcludeCancelled: true, EndsAfter: now.Add(-FeedHistory)}
+
+	switch t.Kind {
+	case FeedGuest:
+		feed.Name = "My stays"
+		feed.ForGuest = true
+		filter.GuestID = t.UserID
+		filter.EndsAfter = now
+	case FeedRoom:
+		room, err := s.roomClient.FindById(ctx, t.RoomID)
+		if err != nil {
+			util.TEL.Error(ctx, "room of feed not found", err, "room_id", t.RoomID)
+			return nil, downstreamError(err, ErrFeedNotFound)
+		}
+		if room.HostID != t.UserID {
+			util.TEL.Error(ctx, "host of feed no longer owns the room", nil, "user_id", t.UserID, "host_id", room.HostID)
+			return nil, ErrFeedNotFound
+		}
+		feed.Name = room.Name
+		feed.Rooms[room.ID] = *room
+		filter.RoomIDs = []uint{room.ID}
+	case FeedHost:
+		rooms, err := s.roomClient.FindByHostId(ctx, t.UserID)
+		if err != nil {
+			util.TEL.Error(ctx, "could not find rooms of host", err, "host_id", t.UserID)
+			return nil, downstreamError(err, ErrFeedNotFound)
+		}
+		feed.Name = "Bookem reservations"
+		filter.RoomIDs = make([]uint, 0, len(rooms))
+		for _, room := range rooms {
+			feed.Rooms[room.ID] = room
+			filter.RoomIDs = append(filter.RoomIDs, room.ID)
+		}
+	}
+
+	feed.Reservations, err = s.repo.FindReservations(filter)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find reservations of feed", err, "token_id", t.ID)
+		return nil, err
+	}
+
+	if feed.ForGuest {
+		// Only names and addresses come from the rooms; the stays are shown
+		// without them when a room can't be found.
+		looked := map[uint]bool{}
+		for _, res := range feed.Reservations {
+			if looked[res.RoomID] {
+				continue
+			}
+			looked[res.RoomID] = true
+			room, err := s.roomClient.FindById(ctx, res.RoomID)
+			if err != nil {
+				util.TEL.Warn(ctx, "room of stay not found, leaving out its name", "room_id", res.RoomID, "error", err)
+				continue
+			}
+			feed.Rooms[room.ID] = *room
+		}
+	}
+
+	return feed, nil
+}
+
+func (s *service) JoinWaitlist(ctx context.Context, guestID uint, dto CreateWaitlistEntryDTO) (*WaitlistEntry, error) {
+	ctx, span := util.TEL.Start(ctx, "join-waitlist")
+	defer span.End()
+
+	util.TEL.Info(ctx, "guest wants to join the waitlist", "guest_id", guestID, "room_id", dto.RoomID)
+
+	user, err := s.userClient.FindById(ctx, guestID)
+	if err != nil {
+		util.TEL.Error(ctx, "user does not exist", err, "id", guestID)
+		return nil, downstreamError(err, ErrUnauthenticated)
+	}
+	if user.Role != string(util.Guest) || user.Deleted {
+		util.TEL.Error(ctx, "user cannot join the waitlist", nil, "role", user.Role, "deleted", user.Deleted)
+		return nil, ErrUnauthorized
+	}
+
+	if dto.GuestCount < 1 {
+		return nil, ErrBadRequestCustom("guest count must be at least 1")
+	}
+	if dto.DateFrom.After(dto.DateTo) {
+		return nil, ErrBadRequestCustom("dates are reversed")
+	}
+	now := time.Now().UTC()
+	if !dto.DateFrom.After(now) {
+		return nil, ErrBadRequestCustom("the stay must start in the future")
+	}
+
+	room, err := s.roomClient.FindById(ctx, dto.RoomID)
+	if err != nil {
+		util.TEL.Error(ctx, "room not found", err, "id", dto.RoomID)
+		return nil, downstreamError(err, ErrNotFound("room", dto.RoomID))
+	}
+
+	// There's only something to wait for if the dates are taken.
+	booked, err := s.repo.HasReservationsInRange(room.ID, dto.DateFrom, dto.DateTo)
+	if err != nil {
+		util.TEL.Error(ctx, "could not check for reservations for room", err, "room_id", room.ID)
+		return nil, err
+	}
+	if !booked {
+		offered, err := s.repo.HasWaitlistOffer(room.ID, dto.DateFrom, dto.DateTo, guestID, now)
+		if err != nil {
+			util.TEL.Error(ctx, "could not check for waitlist offers for room", err, "room_id", room.ID)
+			return nil, err
+		}
+		if !offered {
+			util.TEL.Error(ctx, "dates are free, nothing to wait for", nil, "room_id", room.ID, "from", dto.DateFrom, "to", dto.DateTo)
+			return nil, ErrBadRequestCustom("the room isn't booked on these dates, request them instead")
+		}
+	}
+
+	entries, err := s.repo.FindWaitlistEntriesByGuestID(guestID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find waitlist entries of guest", err, "guest_id", guestID)
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Active() && e.RoomID == room.ID && util.AreDatesIntersecting(e.DateFrom, e.DateTo, dto.DateFrom, dto.DateTo) {
+			util.TEL.Error(ctx, "guest already waits for these dates", nil, "entry_id", e.ID)
+			return nil, ErrConflict
+		}
+	}
+
+	entry := &WaitlistEntry{
+		RoomID:     room.ID,
+		GuestID:    guestID,
+		DateFrom:   dto.DateFrom,
+		DateTo:     dto.DateTo,
+		GuestCount: dto.GuestCount,
+		Status:     WaitlistWaiting,
+		CreatedAt:  now,
+	}
+	if err := s.repo.CreateWaitlistEntry(entry); err != nil {
+		util.TEL.Error(ctx, "could not create waitlist entry", err)
+		return nil, err
+	}
+
+	util.TEL.Info(ctx, "guest joined the waitlist", "entry_id", entry.ID)
+	return entry, nil
+}
+
+func (s *service) GetWaitlistEntries(ctx context.Context, guestID uint) ([]WaitlistEntry, error) {
+	ctx, span := util.TEL.Start(ctx, "get-waitlist-entries")
+	defer span.End()
+
+	entries, err := s.repo.FindWaitlistEntriesByGuestID(guestID)
+	if err != nil {
+		util.TEL.Error(ctx, "could not find waitlist entries", err, "guest_id", guestID)
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *service) LeaveWaitlist(ctx context.Context, guestID uint, entryID uint) error {
+	ctx, span := util.TEL.Start(ctx, "leave-waitlist")
+	defer span.End()
+
+	util.TEL.Info(ctx, "guest wants to leave the waitlist", "guest_id", guestID, "entry_id", entryID)
+
+	return s.repo.Transaction(func(tx Repository) error {
+		entry, err := tx.FindWaitlistEntryByIDForUpdate(entryID)
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && entry.GuestID != guestID) {
+			util.TEL.Error(ctx, "no such waitlist entry of guest", err, "entry_id", entryID)
+			return ErrNotFound("waitlist entry", entryID)
+		}
+		if err != nil {
+			util.TEL.Error(ctx, "could not find waitlist entry", err, "entry_id", entryID)
+			return err
+		}
+		if !entry.Active() {
+			util.TEL.Error(ctx, "guest isn't on the waitlist anymore", nil, "entry_id", entryID, "status", entry.Status)
+			return ErrConflict
+		}
+
+		offered := entry.Status == WaitlistOffered
+		entry.Status = WaitlistLeft
+		if err := tx.UpdateWaitlistEntry(entry); err != nil {
+			util.TEL.Error(ctx, "could not update waitlist entry", err, "entry_id", entryID)
+			return err
+		}
+		if !offered {
+			return nil
+		}
+
+		util.TEL.Debug(ctx, "passing the offer on", "entry_id", entryID)
+		return offerFreedDates(ctx, tx, entry.RoomID, entry.DateFrom, entry.DateTo, time.Now().UTC())
+	})
+}
+
+func (s *service) ClaimWaitlistOffer(ctx context.Context, authctx AuthContext, entryID uint) (*ReservationRequest, *Reservation, error) {
+	ctx, span := util.TEL.Start(ctx, "claim-waitlist-offer")
+	defer span.End()
+
+	util.TEL.Info(ctx, "guest wants to claim a waitlist offer", "guest_id", authctx.CallerID, "entry_id", entryID)
+
+	entry, err := s.repo.FindWaitlistEntryByID(entryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && entry.GuestID != authctx.CallerID) {
+		util.TEL.Error(ctx, "no such waitlist entry of guest", err, "entry_id", entryID)
+		return nil, nil, ErrNotFound("waitlist entry", entryID)
+	}
+	if err != nil {
+		util.TEL.Error(ctx, "could not find waitlist entry", err, "entry_id", entryID)
+		return nil, nil, err
+	}
+
+	if err := entry.checkClaim(time.Now().UTC()); err != nil {
+		util.TEL.Error(ctx, "waitlist offer can't be claimed", err, "entry_id", entryID, "status", entry.Status)
+		return nil, nil, err
+	}
+
+	// The entry is checked again and claimed in the transaction making the
+	// request, so a concurrent claim or the WaitlistExpirer can't get to it
+	// in between.
+	req, res, err := s.createRequest(ctx, authctx, CreateReservationRequestDTO{
+		RoomID:     entry.RoomID,
+		DateFrom:   entry.DateFrom,
+		DateTo:     entry.DateTo,
+		GuestCount: entry.GuestCount,
+	}, func(tx Repository, req *ReservationRequest) error {
+		current, err := tx.FindWaitlistEntryByIDForUpdate(entryID)
+		if err != nil {
+			util.TEL.Error(ctx, "could not find waitlist entry", err, "entry_id", entryID)
+			return err
+		}
+		if err := current.checkClaim(time.Now().UTC()); err != nil {
+			util.TEL.Error(ctx, "waitlist offer can no longer be claimed", err, "entry_id", entryID, "status", current.Status)
+			return err
+		}
+
+		current.Status = WaitlistClaimed
+		current.RequestID = req.ID
+		if err := tx.UpdateWaitlistEntry(current); err != nil {
+			util.TEL.Error(ctx, "could not mark waitlist entry as claimed", err, "entry_id", entryID, "request_id", req.ID)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	util.TEL.Info(ctx, "waitlist offer claimed", "entry_id", entryID, "request_id", req.ID)
+	return req, res, nil
+}
+
+// cancellationTerms are the terms new reservations of the room are approved
+// under.
+func cancellationTerms(repo Repository, roomID uint) (CancellationTerms, error) {
+	policy, err := repo.FindCancellationPolicy(roomID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DefaultCancellationTerms, nil
+	}
+	if err != nil {
+		return CancellationTerms{}, err
+	}
+	return policy.Terms, nil
+}
+
 func (s *service) CanUserRateHost(ctx context.Context, guestID, hostID uint) (bool, error) {
-	util.TEL.Push(ctx, "eligibility-can-user-rate-host")
-	defer util.TEL.Pop()
+	ctx, span := util.TEL.Start(ctx, "eligibility-can-user-rate-host")
+	defer span.End()
 
-	rooms, err := s.roomClient.FindByHostId(util.TEL.Ctx(), hostID)
+	rooms, err := s.roomClient.FindByHostId(ctx, hostID)
 	if err != nil {
-		util.TEL.Error("failed to fetch rooms by host", err, "host_id", hostID)
-		return false, err
+		util.TEL.Error(ctx, "failed to fetch rooms by host", err, "host_id", hostID)
+		return false, downstreamError(err, ErrNotFound("rooms of host", hostID))
 	}
 	if len(rooms) == 0 {
-		util.TEL.Info("host has no rooms; guest cannot have stayed", "host_id", hostID)
+		util.TEL.Info(ctx, "host has no rooms; guest cannot have stayed", "host_id", hostID)
 		return false, nil
 	}
 
@@ -730,65 +3070,94 @@ func (s *service) CanUserRateHost(ctx context.Context, guestID, hostID uint) (bo
 		roomIDs = append(roomIDs, r.ID)
 	}
 
-	ok, err := s.repo.HasGuestPastReservationInRooms(guestID, roomIDs, time.Now().UTC())
+	ok, err := s.repo.HasGuestCompletedStayInRooms(guestID, roomIDs)
 	if err != nil {
-		util.TEL.Error("repo eligibility check failed", err)
+		util.TEL.Error(ctx, "repo eligibility check failed", err)
 		return false, err
 	}
 	return ok, nil
 }
 
 func (s *service) CanUserRateRoom(ctx context.Context, guestID, roomID uint) (bool, error) {
-	util.TEL.Push(ctx, "eligibility-can-user-rate-room")
-	defer util.TEL.Pop()
+	ctx, span := util.TEL.Start(ctx, "eligibility-can-user-rate-room")
+	defer span.End()
 
-	ok, err := s.repo.HasGuestPastReservationInRooms(guestID, []uint{roomID}, time.Now().UTC())
+	ok, err := s.repo.HasGuestCompletedStayInRooms(guestID, []uint{roomID})
 	if err != nil {
-		util.TEL.Error("repo eligibility check failed", err, "guest_id", guestID, "room_id", roomID)
+		util.TEL.Error(ctx, "repo eligibility check failed", err, "guest_id", guestID, "room_id", roomID)
 		return false, err
 	}
 	return ok, nil
 }
 
-func (s *service) GetPastReservationsByGuest(ctx context.Context, guestID uint, before time.Time) ([]ReservationDTO, error) {
-	util.TEL.Push(ctx, "get-past-reservations-by-guest")
-	defer util.TEL.Pop()
+func (s *service) GetPastReservationsByGuest(ctx context.Context, guestID uint, before time.Time, query ListQuery) (*Page[Reservation], error) {
+	ctx, span := util.TEL.Start(ctx, "get-past-reservations-by-guest")
+	defer span.End()
 
-	util.TEL.Info("fetching past reservations for guest", "guest_id", guestID, "before", before)
+	util.TEL.Info(ctx, "fetching past reservations for guest", "guest_id", guestID, "before", before)
 
-	items, err := s.repo.GetAllPastReservationsByGuest(guestID, before)
+	page, err := query.pageRequest(Sort{Key: SortDateTo, Desc: true}, SortDateFrom, SortDateTo)
 	if err != nil {
-		util.TEL.Error("failed to fetch past reservations for guest", err, "guest_id", guestID)
+		util.TEL.Error(ctx, "bad listing query", err)
 		return nil, err
 	}
 
-	util.TEL.Push(ctx, "filter out reservations from deleted rooms")
-	var validReservations []Reservation
-	for _, reservation := range items {
-		util.TEL.Debug("find room", "id", reservation.RoomID)
-		room, err := s.roomClient.FindById(util.TEL.Ctx(), reservation.RoomID)
-		if err == nil && room.Deleted == false {
-			validReservations = append(validReservations, reservation)
-		}
+	filter := ReservationFilter{
+		GuestID:          guestID,
+		ExcludeCancelled: true,
+		EndsBy:           before,
+		From:             query.From,
+		To:               query.To,
 	}
-	items = validReservations
+	if query.RoomID != 0 {
+		filter.RoomIDs = []uint{query.RoomID}
+	}
+	reservations, err := s.repo.FindReservationsPage(filter, page)
+	if err != nil {
+		util.TEL.Error(ctx, "failed to fetch past reservations for guest", err, "guest_id", guestID)
+		return nil, err
+	}
+
+	ctx, span = util.TEL.Start(ctx, "filter out reservations from deleted rooms")
+	defer span.End()
+	if err := dropDeletedRooms(ctx, s.roomClient, reservations, func(r Reservation) uint { return r.RoomID }); err != nil {
+		return nil, err
+	}
+
+	util.TEL.Debug(ctx, "found past reservations", "count", len(reservations.Items), "guest_id", guestID)
 
-	util.TEL.Debug("found past reservations", "count", len(items), "guest_id", guestID)
+	return reservations, nil
+}
 
-	out := make([]ReservationDTO, 0, len(items))
-	for _, it := range items {
-		out = append(out, ReservationDTO{
-			ID:         it.ID,
-			RoomID:     it.RoomID,
-			DateFrom:   it.DateFrom,
-			DateTo:     it.DateTo,
-			GuestCount: it.GuestCount,
-			GuestID:    it.GuestID,
-			Cancelled:  it.Cancelled,
-			Cost:       it.Cost,
-		})
+// dropDeletedRooms removes the page's items whose room was deleted or can't
+// be found. It runs on a page that was already read, which keeps the cursor
+// valid: the page can come out shorter than the limit, and Total still counts
+// the dropped items.
+func dropDeletedRooms[T any](ctx context.Context, roomClient roomclient.RoomClient, page *Page[T], roomID func(T) uint) error {
+	kept := make([]T, 0, len(page.Items))
+	for _, item := range page.Items {
+		util.TEL.Debug(ctx, "find room", "id", roomID(item))
+		room, err := roomClient.FindById(ctx, roomID(item))
+		if isUnavailable(err) {
+			return ErrServiceUnavailable
+		}
+		if err == nil && !room.Deleted {
+			kept = append(kept, item)
+		}
 	}
+	page.Items = kept
+	return nil
+}
+
+func isUnavailable(err error) bool {
+	return errors.Is(err, transport.ErrUnavailable)
+}
 
-	util.TEL.Info("successfully fetched past reservations", "guest_id", guestID, "count", len(out))
-	return out, nil
+// downstreamError is what a failed call to another service turns into: 503 if
+// the service couldn't be reached, fallback if it answered with an error.
+func downstreamError(err error, fallback error) error {
+	if isUnavailable(err) {
+		return ErrServiceUnavailable
+	}
+	return fallback
 }
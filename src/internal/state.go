@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"bookem-reservation-service/util"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// TransitionError is returned for a status change the state machine doesn't
+// allow, e.g. approving a request that was already rejected. It maps to 409.
+type TransitionError struct {
+	Subject TransitionSubject
+	ID      uint
+	From    string
+	To      string
+}
+
+func (e *TransitionError) Error() string {
+	from := e.From
+	if from == "" {
+		from = "nothing"
+	}
+	return fmt.Sprintf("%s %d cannot go from %s to %s", e.Subject, e.ID, from, e.To)
+}
+
+// stateMachine lists the legal status changes of a subject and which roles
+// may make them. The empty status is where a subject is before it's created.
+type stateMachine[S ~string] struct {
+	subject TransitionSubject
+	edges   map[S]map[S][]util.UserRole
+}
+
+var requestStates = stateMachine[ReservationRequestStatus]{
+	subject: SubjectRequest,
+	edges: map[ReservationRequestStatus]map[ReservationRequestStatus][]util.UserRole{
+		"": {Pending: {util.Guest}},
+		Pending: {
+			Pending:   {util.Guest}, // The guest changed the request
+			Accepted:  {util.Host, ActorSystem},
+			Rejected:  {util.Host, ActorSystem},
+			Expired:   {ActorSystem},
+			Withdrawn: {util.Guest},
+		},
+	},
+}
+
+var reservationStates = stateMachine[ReservationStatus]{
+	subject: SubjectReservation,
+	edges: map[ReservationStatus]map[ReservationStatus][]util.UserRole{
+		"": {ReservationConfirmed: {util.Host, ActorSystem}},
+		ReservationConfirmed: {
+			ReservationCancelled: {util.Guest, util.Host},
+			ReservationCheckedIn: {util.Host, ActorSystem},
+			ReservationNoShow:    {util.Host, ActorSystem},
+		},
+		ReservationCheckedIn: {ReservationCheckedOut: {util.Host, ActorSystem}},
+	},
+}
+
+var modificationStates = stateMachine[ModificationStatus]{
+	subject: SubjectModification,
+	edges: map[ModificationStatus]map[ModificationStatus][]util.UserRole{
+		"": {ModificationPending: {util.Guest}},
+		ModificationPending: {
+			ModificationApproved:  {util.Host, ActorSystem},
+			ModificationRejected:  {util.Host},
+			ModificationCancelled: {util.Guest, util.Host}, // Whoever cancelled the reservation
+		},
+	},
+}
+
+// check is the guard of a transition: it has to be a legal one, made by a
+// role that's allowed to make it.
+func (m stateMachine[S]) check(id uint, from, to S, actor Actor) error {
+	roles, ok := m.edges[from][to]
+	if !ok {
+		return &TransitionError{Subject: m.subject, ID: id, From: string(from), To: string(to)}
+	}
+	if !slices.Contains(roles, actor.Role) {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// apply checks a transition and writes it to the status history. Call it in
+// the transaction that stores the new status, with from read in that
+// transaction, so a concurrent change can't slip in between.
+func (m stateMachine[S]) apply(ctx context.Context, tx Repository, id uint, from, to S, actor Actor, reason string) error {
+	if err := m.check(id, from, to, actor); err != nil {
+		util.TEL.Error(ctx, "illegal status transition", err, "subject", m.subject, "id", id, "from", from, "to", to, "role", actor.Role)
+		return err
+	}
+	return tx.RecordTransition(newTransition(ctx, m.subject, id, string(from), string(to), actor, reason))
+}
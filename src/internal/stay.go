@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/client/roomclient"
+	"bookem-reservation-service/config"
+	"bookem-reservation-service/util"
+	"context"
+	"time"
+)
+
+// withStayStatus returns a copy of r as it is once status is stored at at.
+func (r Reservation) withStayStatus(status ReservationStatus, at time.Time) *Reservation {
+	r.Status = status
+	switch status {
+	case ReservationCheckedIn:
+		r.CheckedInAt = &at
+	case ReservationCheckedOut:
+		r.CheckedOutAt = &at
+	}
+	return &r
+}
+
+// moveStay records that the guest of res arrived, left or never came. Call it
+// in a transaction, with res read for update in it. The guest is told when
+// they're marked as a no-show by the host of the room, so they can take it up
+// with them.
+func moveStay(ctx context.Context, tx Repository, res *Reservation, hostID uint, status ReservationStatus, actor Actor, reason string, at time.Time) error {
+	if err := reservationStates.apply(ctx, tx, res.ID, res.Status, status, actor, reason); err != nil {
+		return err
+	}
+
+	if err := tx.SetStayStatus(res.ID, status, at); err != nil {
+		util.TEL.Error(ctx, "could not store stay status", err, "reservation_id", res.ID, "status", status)
+		return err
+	}
+
+	if status != ReservationNoShow {
+		return nil
+	}
+	return tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+		ReceiverID: res.GuestID,
+		Type:       notificationclient.ReservationNoShow,
+		Subject:    hostID,
+		Object:     res.RoomID,
+	}))
+}
+
+// StayCloser moves on the stays their host didn't record: guests still
+// confirmed or checked in after their stay ended are checked out. With
+// AutoNoShow, reservations whose guest wasn't checked in within the no-show
+// grace period become no-shows instead.
+type StayCloser struct {
+	repo       Repository
+	roomClient roomclient.RoomClient
+	cfg        config.StayConfig
+
+	now func() time.Time
+}
+
+func NewStayCloser(repo Repository, roomClient roomclient.RoomClient, cfg config.StayConfig) *StayCloser {
+	return &StayCloser{
+		repo:       repo,
+		roomClient: roomClient,
+		cfg:        cfg,
+		now:        func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Run closes overdue stays every interval until ctx is done.
+func (c *StayCloser) Run(ctx context.Context) {
+	util.RunPeriodically(ctx, c.cfg.Interval.Std(), func(ctx context.Context) {
+		for {
+			n, err := c.CloseOnce(ctx)
+			if err != nil {
+				util.TEL.Error(ctx, "closing stays failed", err)
+			}
+			if err != nil || n < c.cfg.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+	})
+}
+
+// CloseOnce closes one batch of overdue stays and returns how many it closed.
+func (c *StayCloser) CloseOnce(ctx context.Context) (int, error) {
+	ctx, span := util.TEL.Start(ctx, "close-stays")
+	defer span.End()
+
+	now := c.now()
+	// Stays begin at midnight UTC of their first day and end at midnight
+	// after their last one.
+	var startedBefore time.Time // Zero matches nothing
+	if c.cfg.AutoNoShow {
+		startedBefore = now.Add(-c.cfg.NoShowGrace.Std())
+	}
+	endedBefore := now.Add(-c.cfg.CheckOutGrace.Std()).AddDate(0, 0, -1)
+
+	candidates, err := c.repo.FindOverdueStays(startedBefore, endedBefore, c.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+
+	// Hosts of the rooms of the no-shows, who the guests are told about. A
+	// room that has been deleted has no host, but its stays still close.
+	hosts := map[uint]uint{}
+	for _, candidate := range candidates {
+		if _, ok := hosts[candidate.RoomID]; ok || !candidate.DateFrom.Before(startedBefore) {
+			continue
+		}
+		room, err := c.roomClient.FindById(ctx, candidate.RoomID)
+		switch {
+		case isUnavailable(err):
+			util.TEL.Warn(ctx, "room service unavailable, closing the room's no-shows later", "room_id", candidate.RoomID)
+		case err != nil:
+			hosts[candidate.RoomID] = 0
+		default:
+			hosts[candidate.RoomID] = room.HostID
+		}
+	}
+
+	closed := 0
+	err = c.repo.Transaction(func(tx Repository) error {
+		for _, candidate := range candidates {
+			res, err := tx.FindReservationByIDForUpdate(candidate.ID)
+			if err != nil {
+				return err
+			}
+
+			var status ReservationStatus
+			var reason string
+			hostID, known := hosts[res.RoomID]
+			switch {
+			case res.Status == ReservationConfirmed && res.DateFrom.Before(startedBefore):
+				if !known {
+					continue
+				}
+				status, reason = ReservationNoShow, "not checked in in time"
+			case res.Status == ReservationConfirmed && res.DateTo.Before(endedBefore):
+				// Like the stays that ended before check-in was recorded, the
+				// guest counts as checked in when the stay began.
+				if err := moveStay(ctx, tx, res, hostID, ReservationCheckedIn, SystemActor, "stay ended unrecorded", res.DateFrom); err != nil {
+					return err
+				}
+				res = res.withStayStatus(ReservationCheckedIn, res.DateFrom)
+				status, reason = ReservationCheckedOut, "stay ended"
+			case res.Status == ReservationCheckedIn && res.DateTo.Before(endedBefore):
+				status, reason = ReservationCheckedOut, "stay ended"
+			default:
+				continue // The host recorded it in the meantime
+			}
+
+			if err := moveStay(ctx, tx, res, hostID, status, SystemActor, reason, now); err != nil {
+				return err
+			}
+			closed++
+			util.TEL.Info(ctx, "stay closed", "reservation_id", res.ID, "status", status)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return closed, nil
+}
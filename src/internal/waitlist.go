@@ -0,0 +1,219 @@
+package internal
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/config"
+	"bookem-reservation-service/util"
+	"context"
+	"time"
+)
+
+// WaitlistOfferTime is how long a waitlisted guest has to claim dates that
+// came free before they're offered to the next guest.
+const WaitlistOfferTime = 12 * time.Hour
+
+type WaitlistStatus string
+
+const (
+	WaitlistWaiting WaitlistStatus = "waiting"
+	WaitlistOffered WaitlistStatus = "offered" // The dates came free and the guest can claim them
+	WaitlistClaimed WaitlistStatus = "claimed" // The guest made a request out of the offer
+	WaitlistLapsed  WaitlistStatus = "lapsed"  // The guest didn't claim the offer in time
+	WaitlistLeft    WaitlistStatus = "left"    // The guest left the waitlist
+	WaitlistExpired WaitlistStatus = "expired" // The stay started before the dates came free
+)
+
+// WaitlistEntry is a guest waiting for a room's booked dates to come free.
+// When a reservation overlapping them is cancelled, the entries are offered
+// the dates first come, first served: the guest of an offered entry is the
+// only one who can request the dates until OfferExpiresAt.
+type WaitlistEntry struct {
+	ID             uint           `gorm:"primaryKey"`
+	RoomID         uint           `gorm:"not null"`
+	GuestID        uint           `gorm:"not null"`
+	DateFrom       time.Time      `gorm:"not null"`
+	DateTo         time.Time      `gorm:"not null"`
+	GuestCount     uint           `gorm:"not null"`
+	Status         WaitlistStatus `gorm:"not null"`
+	CreatedAt      time.Time      `gorm:"not null"`
+	OfferedAt      *time.Time
+	OfferExpiresAt *time.Time
+	RequestID      uint `gorm:"not null"` // The request the offer was claimed with
+}
+
+func (WaitlistEntry) TableName() string {
+	return "waitlist_entries"
+}
+
+// Active tells whether the guest is still waiting for the dates or can claim
+// them.
+func (e WaitlistEntry) Active() bool {
+	return e.Status == WaitlistWaiting || e.Status == WaitlistOffered
+}
+
+// Claimable tells whether the guest can make a request out of the entry.
+func (e WaitlistEntry) Claimable(now time.Time) bool {
+	return e.Status == WaitlistOffered && e.OfferExpiresAt != nil && now.Before(*e.OfferExpiresAt)
+}
+
+// checkClaim tells why the guest can't make a request out of the entry, if
+// they can't.
+func (e WaitlistEntry) checkClaim(now time.Time) error {
+	switch {
+	case e.Status == WaitlistLapsed, e.Status == WaitlistOffered && !e.Claimable(now):
+		return ErrWaitlistOfferLapsed
+	case e.Status != WaitlistOffered:
+		return ErrNoWaitlistOffer
+	}
+	return nil
+}
+
+// checkNotOffered fails with ErrConflict if some of the room's dates are
+// offered to a waitlisted guest other than guestID. The caller has to hold the
+// room's lock, or the dates could be offered right after the check.
+func checkNotOffered(ctx context.Context, tx Repository, roomID uint, from, to time.Time, guestID uint, now time.Time) error {
+	offered, err := tx.HasWaitlistOffer(roomID, from, to, guestID, now)
+	if err != nil {
+		util.TEL.Error(ctx, "could not check for waitlist offers for room", err, "room_id", roomID)
+		return err
+	}
+	if offered {
+		util.TEL.Error(ctx, "the dates were offered to a waitlisted guest", nil, "room_id", roomID, "from", from, "to", to)
+		return ErrConflict
+	}
+	return nil
+}
+
+// offerFreedDates offers the room's dates between from and to, which just
+// came free, to the guests waiting for them, oldest entry first. An entry is
+// only offered the dates if all of its stay is free and nobody else holds an
+// offer on any of it, so later entries can still get the days earlier ones
+// don't want.
+func offerFreedDates(ctx context.Context, tx Repository, roomID uint, from, to time.Time, now time.Time) error {
+	waiting, err := tx.FindWaitingEntries(roomID, from, to)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range waiting {
+		if !e.DateFrom.After(now) {
+			continue // The stay started; the WaitlistExpirer takes care of it
+		}
+
+		held, err := tx.HasWaitlistOffer(roomID, e.DateFrom, e.DateTo, 0, now)
+		if err != nil {
+			return err
+		}
+		booked, err := tx.HasReservationsInRange(roomID, e.DateFrom, e.DateTo)
+		if err != nil {
+			return err
+		}
+		if held || booked {
+			continue
+		}
+
+		expiresAt := now.Add(WaitlistOfferTime)
+		e.Status = WaitlistOffered
+		e.OfferedAt = &now
+		e.OfferExpiresAt = &expiresAt
+		if err := tx.UpdateWaitlistEntry(&e); err != nil {
+			return err
+		}
+
+		util.TEL.Info(ctx, "offering freed dates to waitlisted guest", "entry_id", e.ID, "guest_id", e.GuestID, "room_id", roomID)
+		err = tx.EnqueueNotification(newOutboxNotification(notificationclient.CreateNotificationDTO{
+			ReceiverID: e.GuestID,
+			Type:       notificationclient.WaitlistOffered,
+			Subject:    e.ID, // The entry to claim
+			Object:     roomID,
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitlistExpirer ends offers that weren't claimed in time, passing the dates
+// on to the next guest waiting for them, and entries whose stay started
+// before the dates came free.
+type WaitlistExpirer struct {
+	repo Repository
+	cfg  config.ExpiryConfig
+
+	now func() time.Time
+}
+
+func NewWaitlistExpirer(repo Repository, cfg config.ExpiryConfig) *WaitlistExpirer {
+	return &WaitlistExpirer{
+		repo: repo,
+		cfg:  cfg,
+		now:  func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Run expires entries every interval until ctx is done.
+func (e *WaitlistExpirer) Run(ctx context.Context) {
+	util.RunPeriodically(ctx, e.cfg.Interval.Std(), func(ctx context.Context) {
+		for {
+			n, err := e.ExpireOnce(ctx)
+			if err != nil {
+				util.TEL.Error(ctx, "waitlist expiry failed", err)
+			}
+			if err != nil || n < e.cfg.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+	})
+}
+
+// ExpireOnce expires one batch of entries and returns how many it expired.
+func (e *WaitlistExpirer) ExpireOnce(ctx context.Context) (int, error) {
+	ctx, span := util.TEL.Start(ctx, "expire-waitlist-entries")
+	defer span.End()
+
+	now := e.now()
+	candidates, err := e.repo.FindLapsedWaitlistEntries(now, e.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+
+	expired := 0
+	err = e.repo.Transaction(func(tx Repository) error {
+		for _, c := range candidates {
+			entry, err := tx.FindWaitlistEntryByIDForUpdate(c.ID)
+			if err != nil {
+				return err
+			}
+			switch {
+			case !entry.Active():
+				continue // Claimed or left in the meantime
+			case !entry.DateFrom.After(now):
+				entry.Status = WaitlistExpired
+			case entry.Status == WaitlistOffered && !entry.Claimable(now):
+				entry.Status = WaitlistLapsed
+			default:
+				continue
+			}
+			if err := tx.UpdateWaitlistEntry(entry); err != nil {
+				return err
+			}
+			expired++
+			util.TEL.Info(ctx, "waitlist entry ended", "entry_id", entry.ID, "status", entry.Status)
+
+			if entry.Status == WaitlistLapsed {
+				if err := offerFreedDates(ctx, tx, entry.RoomID, entry.DateFrom, entry.DateTo, now); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return expired, nil
+}
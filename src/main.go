@@ -3,15 +3,20 @@ package main
 import (
 	"bookem-reservation-service/client/notificationclient"
 	"bookem-reservation-service/client/roomclient"
+	"bookem-reservation-service/client/transport"
 	"bookem-reservation-service/client/userclient"
+	"bookem-reservation-service/config"
 	internal "bookem-reservation-service/internal"
+	"bookem-reservation-service/migrations"
 	"bookem-reservation-service/util"
 	"context"
 	"database/sql"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -30,9 +35,71 @@ var (
 	rawDB  *sql.DB
 )
 
-func syncDatabase() {
-	dB.AutoMigrate(&internal.Reservation{})
-	dB.AutoMigrate(&internal.ReservationRequest{})
+// checkSchema refuses to start the service while there are migrations that
+// haven't been applied. Run `reservation-service migrate up` first.
+func checkSchema() {
+	all, err := migrations.Load()
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	migrator := migrations.NewMigrator(rawDB, all, os.Stdout)
+	if err := migrator.CheckUpToDate(context.Background()); err != nil {
+		log.Fatalf("Refusing to start: %v (run `migrate up`)", err)
+	}
+}
+
+// runMigrate implements the `migrate` subcommand:
+//
+//	reservation-service migrate [-dry-run] up
+//	reservation-service migrate [-dry-run] down [steps]
+//	reservation-service migrate status
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "print the SQL instead of running it")
+	flags.Parse(args)
+
+	all, err := migrations.Load()
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	connectToDb()
+	defer rawDB.Close()
+
+	ctx := context.Background()
+	migrator := migrations.NewMigrator(rawDB, all, os.Stdout)
+	migrator.DryRun = *dryRun
+
+	switch flags.Arg(0) {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if flags.NArg() > 1 {
+			steps, err = strconv.Atoi(flags.Arg(1))
+			if err != nil || steps < 1 {
+				log.Fatalf("Bad number of steps %q", flags.Arg(1))
+			}
+		}
+		err = migrator.Down(ctx, steps)
+	case "status":
+		var statuses []migrations.MigrationStatus
+		statuses, err = migrator.Status(ctx)
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+	default:
+		log.Fatalf("Usage: migrate [-dry-run] up | down [steps] | status")
+	}
+
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
 }
 
 func connectToDb() {
@@ -59,6 +126,16 @@ func connectToDb() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
 	ctx := context.Background()
 	shutdown := util.TEL.Init(
 		ctx,
@@ -69,7 +146,7 @@ func main() {
 
 	connectToDb()
 	defer rawDB.Close()
-	syncDatabase()
+	checkSchema()
 
 	server = gin.Default()
 
@@ -95,15 +172,44 @@ func main() {
 		ctx.JSON(http.StatusOK, nil)
 	})
 
-	userClient := userclient.NewUserClient()
-	roomClient := roomclient.NewRoomClient()
-	notificationClient := notificationclient.NewNotificationClient()
+	httpTransport, err := cfg.HTTP.NewTransport()
+	if err != nil {
+		log.Fatalf("Failed to set up HTTP transport: %v", err)
+	}
+
+	// Every upstream gets its own circuit breaker but they share the pool.
+	policy := cfg.HTTP.Resilience.Policy()
+	userHTTP := transport.New("user-service", cfg.Services.User.NewHTTPClient(httpTransport), policy)
+	roomHTTP := transport.New("room-service", cfg.Services.Room.NewHTTPClient(httpTransport), policy)
+	notificationHTTP := transport.New("notification-service", cfg.Services.Notification.NewHTTPClient(httpTransport), policy)
+
+	userClient := userclient.NewUserClient(cfg.Services.User, userHTTP)
+	roomClient := roomclient.NewRoomClient(cfg.Services.Room, roomHTTP)
+	notificationClient := notificationclient.NewNotificationClient(cfg.Services.Notification, notificationHTTP)
 
 	reservationRepo := internal.NewRepository(dB)
 
-	service := internal.NewService(reservationRepo, userClient, roomClient, notificationClient)
+	service := internal.NewService(reservationRepo, userClient, roomClient)
+
+	dispatcher := internal.NewOutboxDispatcher(reservationRepo, notificationClient, cfg.Outbox, cfg.Services.Notification.Token)
+	go dispatcher.Run(ctx)
+
+	expirer := internal.NewRequestExpirer(reservationRepo, roomClient, cfg.Expiry)
+	go expirer.Run(ctx)
+
+	waitlistExpirer := internal.NewWaitlistExpirer(reservationRepo, cfg.Expiry)
+	go waitlistExpirer.Run(ctx)
+
+	holdExpirer := internal.NewHoldExpirer(reservationRepo, cfg.Expiry)
+	go holdExpirer.Run(ctx)
+
+	stayCloser := internal.NewStayCloser(reservationRepo, roomClient, cfg.Stay)
+	go stayCloser.Run(ctx)
+
+	go internal.PurgeIdempotencyKeys(ctx, reservationRepo, cfg.Idempotency)
+
 	handler := internal.NewHandler(service)
-	route := *internal.NewRoute(handler)
+	route := *internal.NewRoute(handler, internal.Idempotency(reservationRepo, cfg.Idempotency))
 
 	rg := server.Group("/api")
 	route.Route(rg)
@@ -0,0 +1,313 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Every migration is a pair of files in sql/ named
+//
+//	<version>_<name>.up.sql
+//	<version>_<name>.down.sql
+//
+// where version is a positive integer. Migrations are applied in ascending
+// version order and rolled back in descending order. Never edit a migration
+// that has been released, add a new one instead.
+//
+//go:embed sql/*.sql
+var embedded embed.FS
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// lockID is the advisory lock held while migrating, so two instances starting
+// at the same time don't apply the same migration twice.
+const lockID = 7_346_101
+
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Load returns the migrations embedded in the binary.
+func Load() ([]Migration, error) {
+	sub, err := fs.Sub(embedded, "sql")
+	if err != nil {
+		return nil, err
+	}
+	return Parse(sub)
+}
+
+// Parse reads migrations from the root of fsys and returns them sorted by
+// version. Every version needs both an up and a down file.
+func Parse(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("bad migration file name %q", entry.Name())
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("bad migration version in %q", entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+
+	return result, nil
+}
+
+// Migrator applies and rolls back migrations, keeping track of them in the
+// schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+
+	// DryRun prints the SQL that would run to Out instead of running it.
+	DryRun bool
+	Out    io.Writer
+}
+
+func NewMigrator(db *sql.DB, migrations []Migration, out io.Writer) *Migrator {
+	return &Migrator{db: db, migrations: migrations, Out: out}
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    bigint      PRIMARY KEY,
+			name       text        NOT NULL,
+			applied_at timestamptz NOT NULL DEFAULT now()
+		)`)
+	return err
+}
+
+type querier interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}
+
+// applied returns when each applied migration was applied. Nothing has been
+// if schema_migrations doesn't exist yet.
+func (m *Migrator) applied(ctx context.Context, q querier) (map[int]time.Time, error) {
+	var exists bool
+	err := q.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return map[int]time.Time{}, nil
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		result[version] = appliedAt
+	}
+	return result, rows.Err()
+}
+
+// withLock runs fn on a single connection that holds the migration lock. A
+// dry run only reads, so it neither takes the lock nor creates
+// schema_migrations.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.DryRun {
+		return fn(conn)
+	}
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("could not take migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return fmt.Errorf("could not create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// Up applies every pending migration in version order. Each migration runs in
+// its own transaction together with its schema_migrations row.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			fmt.Fprintf(m.Out, "up %d_%s\n", migration.Version, migration.Name)
+			if m.DryRun {
+				fmt.Fprintln(m.Out, migration.Up)
+				continue
+			}
+
+			err := m.run(ctx, conn, migration.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the last `steps` applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			steps--
+
+			fmt.Fprintf(m.Out, "down %d_%s\n", migration.Version, migration.Name)
+			if m.DryRun {
+				fmt.Fprintln(m.Out, migration.Down)
+				continue
+			}
+
+			err := m.run(ctx, conn, migration.Down, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+			if err != nil {
+				return fmt.Errorf("rollback of %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, script string, bookkeeping string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &at
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// CheckUpToDate returns ErrSchemaBehind if any migration hasn't been applied.
+func (m *Migrator) CheckUpToDate(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if !status.Applied {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d pending migration(s)", ErrSchemaBehind, pending)
+	}
+	return nil
+}
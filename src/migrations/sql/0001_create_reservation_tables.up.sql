@@ -0,0 +1,28 @@
+-- Matches the schema GORM's AutoMigrate used to create, so databases that were
+-- created before migrations existed adopt it without changes.
+
+CREATE TABLE IF NOT EXISTS reservation_requests (
+    id                   bigserial PRIMARY KEY,
+    room_id              bigint      NOT NULL,
+    room_availability_id bigint      NOT NULL,
+    room_price_id        bigint      NOT NULL,
+    date_from            timestamptz NOT NULL,
+    date_to              timestamptz NOT NULL,
+    guest_count          bigint      NOT NULL,
+    guest_id             bigint      NOT NULL,
+    status               text        NOT NULL,
+    cost                 bigint      NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS reservations (
+    id                   bigserial PRIMARY KEY,
+    room_id              bigint      NOT NULL,
+    room_availability_id bigint      NOT NULL,
+    room_price_id        bigint      NOT NULL,
+    guest_id             bigint      NOT NULL,
+    date_from            timestamptz NOT NULL,
+    date_to              timestamptz NOT NULL,
+    guest_count          bigint      NOT NULL,
+    cancelled            boolean     NOT NULL,
+    cost                 bigint      NOT NULL
+);
@@ -0,0 +1,15 @@
+-- Two non-cancelled reservations of the same room must never share a day.
+-- Date ranges are inclusive on both ends, same as AreThereReservationsOnDays.
+
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'reservations_no_overlap') THEN
+        ALTER TABLE reservations ADD CONSTRAINT reservations_no_overlap EXCLUDE USING gist (
+            room_id WITH =,
+            daterange((date_from AT TIME ZONE 'UTC')::date, (date_to AT TIME ZONE 'UTC')::date, '[]') WITH &&
+        ) WHERE (NOT cancelled);
+    END IF;
+END
+$$;
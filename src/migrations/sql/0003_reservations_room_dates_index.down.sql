@@ -0,0 +1 @@
+DROP INDEX IF EXISTS reservations_room_dates_idx;
@@ -0,0 +1,5 @@
+-- Backs HasReservationsInRange: any non-cancelled reservation of a room that
+-- overlaps a date range.
+CREATE INDEX IF NOT EXISTS reservations_room_dates_idx
+    ON reservations (room_id, date_from, date_to)
+    WHERE NOT cancelled;
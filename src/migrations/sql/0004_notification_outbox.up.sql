@@ -0,0 +1,20 @@
+-- Notifications waiting to be delivered to the notification service. Rows are
+-- written in the same transaction as the change they announce.
+CREATE TABLE IF NOT EXISTS notification_outbox (
+    id              bigserial PRIMARY KEY,
+    receiver_id     bigint      NOT NULL,
+    type            text        NOT NULL,
+    subject         bigint      NOT NULL,
+    object          bigint      NOT NULL,
+    status          text        NOT NULL DEFAULT 'pending',
+    attempts        bigint      NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT now(),
+    last_error      text        NOT NULL DEFAULT '',
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    sent_at         timestamptz
+);
+
+-- Backs ClaimDueNotifications.
+CREATE INDEX IF NOT EXISTS notification_outbox_due_idx
+    ON notification_outbox (next_attempt_at)
+    WHERE status = 'pending';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS reservation_requests_pending_idx;
+
+UPDATE reservation_requests SET status = 'rejected' WHERE status = 'expired';
+
+ALTER TABLE reservation_requests DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,9 @@
+-- Requests created before this column existed count as created now, so they
+-- get a full response deadline instead of expiring right away.
+ALTER TABLE reservation_requests
+    ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now();
+
+-- Backs FindExpirableRequests.
+CREATE INDEX IF NOT EXISTS reservation_requests_pending_idx
+    ON reservation_requests (created_at, date_from)
+    WHERE status = 'pending';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS reservations_guest_idx;
+DROP INDEX IF EXISTS reservation_requests_room_idx;
+DROP INDEX IF EXISTS reservation_requests_guest_idx;
@@ -0,0 +1,9 @@
+-- Back the paginated listings, which walk (date, id) within a guest or a room.
+CREATE INDEX IF NOT EXISTS reservation_requests_guest_idx
+    ON reservation_requests (guest_id, date_from, id);
+
+CREATE INDEX IF NOT EXISTS reservation_requests_room_idx
+    ON reservation_requests (room_id, date_from, id);
+
+CREATE INDEX IF NOT EXISTS reservations_guest_idx
+    ON reservations (guest_id, date_to, id);
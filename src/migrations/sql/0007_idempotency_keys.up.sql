@@ -0,0 +1,16 @@
+-- Responses to requests made with an Idempotency-Key header, replayed when a
+-- client retries the request with the same key.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id      bigint      NOT NULL,
+    key          text        NOT NULL,
+    request_hash text        NOT NULL,
+    status_code  integer     NOT NULL DEFAULT 0,
+    body         bytea,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    expires_at   timestamptz NOT NULL,
+    PRIMARY KEY (user_id, key)
+);
+
+-- Backs DeleteExpiredIdempotencyKeys.
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_idx
+    ON idempotency_keys (expires_at);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS reservations_host_cancellations_idx;
+
+-- Host cancellations become indistinguishable from guest cancellations.
+ALTER TABLE reservations
+    DROP COLUMN IF EXISTS cancelled_at,
+    DROP COLUMN IF EXISTS cancellation_reason,
+    DROP COLUMN IF EXISTS cancelled_by_id,
+    DROP COLUMN IF EXISTS cancelled_by;
@@ -0,0 +1,16 @@
+-- Who cancelled a reservation, when and why. Host cancellations don't count
+-- against the guest.
+ALTER TABLE reservations
+    ADD COLUMN IF NOT EXISTS cancelled_by        text   NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS cancelled_by_id     bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS cancellation_reason text   NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS cancelled_at        timestamptz;
+
+-- Only guests could cancel before this migration.
+UPDATE reservations SET cancelled_by = 'guest', cancelled_by_id = guest_id
+    WHERE cancelled AND cancelled_by = '';
+
+-- Backs CountHostCancellations.
+CREATE INDEX IF NOT EXISTS reservations_host_cancellations_idx
+    ON reservations (cancelled_by_id)
+    WHERE cancelled_by = 'host';
@@ -0,0 +1,6 @@
+ALTER TABLE reservations
+    DROP COLUMN IF EXISTS cancellation_penalty,
+    DROP COLUMN IF EXISTS refund_amount,
+    DROP COLUMN IF EXISTS cancellation_terms;
+
+DROP TABLE IF EXISTS cancellation_policies;
@@ -0,0 +1,22 @@
+-- The cancellation policy a host picked for a room. Rooms without one get the
+-- flexible policy.
+CREATE TABLE IF NOT EXISTS cancellation_policies (
+    room_id    bigint      PRIMARY KEY,
+    terms      jsonb       NOT NULL,
+    updated_at timestamptz NOT NULL
+);
+
+-- Every reservation keeps the policy it was approved under, and what its
+-- cancellation refunded.
+ALTER TABLE reservations
+    ADD COLUMN IF NOT EXISTS cancellation_terms   jsonb,
+    ADD COLUMN IF NOT EXISTS refund_amount        bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS cancellation_penalty bigint NOT NULL DEFAULT 0;
+
+-- Reservations approved before policies existed get the default one. Refunds
+-- of reservations cancelled before now weren't worked out, so they stay 0.
+UPDATE reservations
+    SET cancellation_terms = '{"kind":"flexible","tiers":[{"daysBefore":1,"refundPercent":100}]}'
+    WHERE cancellation_terms IS NULL;
+
+ALTER TABLE reservations ALTER COLUMN cancellation_terms SET NOT NULL;
@@ -0,0 +1,29 @@
+-- Changes guests asked for to the dates or guest count of their reservations.
+-- Rows are never deleted: they are each reservation's change history.
+CREATE TABLE IF NOT EXISTS reservation_modifications (
+    id                   bigserial   PRIMARY KEY,
+    reservation_id       bigint      NOT NULL REFERENCES reservations (id),
+    room_id              bigint      NOT NULL,
+    guest_id             bigint      NOT NULL,
+    previous_date_from   timestamptz NOT NULL,
+    previous_date_to     timestamptz NOT NULL,
+    previous_guest_count bigint      NOT NULL,
+    previous_cost        bigint      NOT NULL,
+    proposed_date_from   timestamptz NOT NULL,
+    proposed_date_to     timestamptz NOT NULL,
+    proposed_guest_count bigint      NOT NULL,
+    proposed_cost        bigint      NOT NULL,
+    room_availability_id bigint      NOT NULL,
+    room_price_id        bigint      NOT NULL,
+    status               text        NOT NULL,
+    created_at           timestamptz NOT NULL,
+    decided_at           timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS reservation_modifications_reservation_idx
+    ON reservation_modifications (reservation_id, created_at);
+
+-- A reservation has at most one modification waiting for the host.
+CREATE UNIQUE INDEX IF NOT EXISTS reservation_modifications_one_pending_idx
+    ON reservation_modifications (reservation_id)
+    WHERE status = 'pending';
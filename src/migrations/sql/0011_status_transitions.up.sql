@@ -0,0 +1,18 @@
+-- Append-only history of every status change of reservation requests and
+-- reservations: who made it, when, why, and in which trace. Changes made
+-- before this migration aren't in it.
+CREATE TABLE IF NOT EXISTS status_transitions (
+    id           bigserial   PRIMARY KEY,
+    subject_type text        NOT NULL,
+    subject_id   bigint      NOT NULL,
+    from_status  text        NOT NULL,
+    to_status    text        NOT NULL,
+    actor_id     bigint      NOT NULL,
+    actor_role   text        NOT NULL,
+    reason       text        NOT NULL,
+    trace_id     text        NOT NULL,
+    created_at   timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS status_transitions_subject_idx
+    ON status_transitions (subject_type, subject_id, created_at);
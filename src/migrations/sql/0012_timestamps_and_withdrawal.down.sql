@@ -0,0 +1,10 @@
+-- Before this migration withdrawn requests were deleted.
+DELETE FROM reservation_requests WHERE withdrawn_at IS NOT NULL;
+
+ALTER TABLE reservations
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS created_at;
+
+ALTER TABLE reservation_requests
+    DROP COLUMN IF EXISTS withdrawn_at,
+    DROP COLUMN IF EXISTS updated_at;
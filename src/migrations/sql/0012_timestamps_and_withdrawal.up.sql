@@ -0,0 +1,27 @@
+-- Requests haven't changed since they were created, as far as we can tell.
+ALTER TABLE reservation_requests
+    ADD COLUMN IF NOT EXISTS updated_at timestamptz;
+UPDATE reservation_requests SET updated_at = created_at WHERE updated_at IS NULL;
+ALTER TABLE reservation_requests
+    ALTER COLUMN updated_at SET NOT NULL;
+
+-- Withdrawn requests used to be deleted; from now on they're kept and
+-- marked here.
+ALTER TABLE reservation_requests
+    ADD COLUMN IF NOT EXISTS withdrawn_at timestamptz;
+
+-- Reservations are created when their request is accepted, which is in the
+-- status history since 0011. Older ones count as created now, like requests
+-- did in 0005.
+ALTER TABLE reservations
+    ADD COLUMN IF NOT EXISTS created_at timestamptz,
+    ADD COLUMN IF NOT EXISTS updated_at timestamptz;
+UPDATE reservations r SET created_at = COALESCE(
+    (SELECT min(t.created_at) FROM status_transitions t
+        WHERE t.subject_type = 'reservation' AND t.subject_id = r.id),
+    now())
+WHERE created_at IS NULL;
+UPDATE reservations SET updated_at = GREATEST(created_at, cancelled_at) WHERE updated_at IS NULL;
+ALTER TABLE reservations
+    ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN updated_at SET NOT NULL;
@@ -0,0 +1,7 @@
+-- Reservations get an explicit status, which the service moves through its
+-- state machine. cancelled stays, and agrees with it.
+ALTER TABLE reservations
+    ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'confirmed';
+UPDATE reservations SET status = 'cancelled' WHERE cancelled;
+ALTER TABLE reservations
+    ALTER COLUMN status DROP DEFAULT;
@@ -0,0 +1,13 @@
+-- Tokens calendar apps use to read ICS feeds. Only a SHA-256 hash of each
+-- token is stored.
+CREATE TABLE IF NOT EXISTS feed_tokens (
+    id         bigserial   PRIMARY KEY,
+    user_id    bigint      NOT NULL,
+    kind       text        NOT NULL,
+    room_id    bigint      NOT NULL,
+    token_hash text        NOT NULL UNIQUE,
+    created_at timestamptz NOT NULL,
+    revoked_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS feed_tokens_user_idx ON feed_tokens (user_id);
@@ -0,0 +1,17 @@
+-- Guests waiting for a room's booked dates to come free.
+CREATE TABLE IF NOT EXISTS waitlist_entries (
+    id               bigserial   PRIMARY KEY,
+    room_id          bigint      NOT NULL,
+    guest_id         bigint      NOT NULL,
+    date_from        timestamptz NOT NULL,
+    date_to          timestamptz NOT NULL,
+    guest_count      bigint      NOT NULL,
+    status           text        NOT NULL,
+    created_at       timestamptz NOT NULL,
+    offered_at       timestamptz,
+    offer_expires_at timestamptz,
+    request_id       bigint      NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS waitlist_entries_room_idx ON waitlist_entries (room_id, status, date_from);
+CREATE INDEX IF NOT EXISTS waitlist_entries_guest_idx ON waitlist_entries (guest_id);
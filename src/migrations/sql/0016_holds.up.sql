@@ -0,0 +1,16 @@
+-- Short holds guests put on a room's dates while making a request.
+CREATE TABLE IF NOT EXISTS holds (
+    id         bigserial   PRIMARY KEY,
+    room_id    bigint      NOT NULL,
+    guest_id   bigint      NOT NULL,
+    date_from  timestamptz NOT NULL,
+    date_to    timestamptz NOT NULL,
+    status     text        NOT NULL,
+    created_at timestamptz NOT NULL,
+    expires_at timestamptz NOT NULL,
+    ended_at   timestamptz,
+    request_id bigint      NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS holds_active_room_idx ON holds (room_id, date_from) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS holds_guest_idx ON holds (guest_id);
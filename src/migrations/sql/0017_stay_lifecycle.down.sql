@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS reservations_open_stays_idx;
+
+-- Checked in, checked out and no-show reservations are confirmed again.
+UPDATE reservations SET status = 'confirmed'
+WHERE status IN ('checked_in', 'checked_out', 'no_show');
+
+ALTER TABLE reservations
+    DROP COLUMN IF EXISTS checked_out_at,
+    DROP COLUMN IF EXISTS checked_in_at;
@@ -0,0 +1,22 @@
+-- Reservations record when the guest arrived and left. Guests can only rate
+-- stays they checked out of, so stays that ended before this count as
+-- checked out at the end of their last day, and stays going on now as
+-- checked in at the start of their first. Otherwise the service would mark
+-- them as no-shows.
+ALTER TABLE reservations
+    ADD COLUMN IF NOT EXISTS checked_in_at timestamptz,
+    ADD COLUMN IF NOT EXISTS checked_out_at timestamptz;
+
+UPDATE reservations
+SET status = 'checked_out',
+    checked_in_at = date_from,
+    checked_out_at = date_to + interval '1 day'
+WHERE status = 'confirmed' AND date_to + interval '1 day' <= now();
+
+UPDATE reservations
+SET status = 'checked_in',
+    checked_in_at = date_from
+WHERE status = 'confirmed' AND date_from <= now();
+
+CREATE INDEX IF NOT EXISTS reservations_open_stays_idx
+    ON reservations (date_from, id) WHERE status IN ('confirmed', 'checked_in');
@@ -0,0 +1,6 @@
+-- Grouped requests stay, as requests of their own.
+DROP INDEX IF EXISTS reservation_requests_group_idx;
+ALTER TABLE reservation_requests
+    DROP COLUMN IF EXISTS group_id;
+
+DROP TABLE IF EXISTS request_groups;
@@ -0,0 +1,16 @@
+-- Groups of requests a guest made for several rooms of one host at once.
+CREATE TABLE IF NOT EXISTS request_groups (
+    id         bigserial   PRIMARY KEY,
+    guest_id   bigint      NOT NULL,
+    host_id    bigint      NOT NULL,
+    created_at timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS request_groups_guest_idx ON request_groups (guest_id);
+
+-- 0 for requests that aren't part of a group, which is all of the old ones.
+ALTER TABLE reservation_requests
+    ADD COLUMN IF NOT EXISTS group_id bigint NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS reservation_requests_group_idx
+    ON reservation_requests (group_id) WHERE group_id <> 0;
@@ -2,9 +2,17 @@ package test
 
 import (
 	"bookem-reservation-service/internal"
+	"bookem-reservation-service/migrations"
 	"bookem-reservation-service/util"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCheckRoomAvailability(t *testing.T) {
@@ -42,3 +50,65 @@ func TestCheckRoomAvailability(t *testing.T) {
 	// availability2 := ResponseToReservationAvailability(resp2)
 	// assert.False(t, availability2.Available)
 }
+
+// TestHasReservationsInRange_MatchesDayByDay checks the single range query
+// AreThereReservationsOnDays uses against the day-by-day lookups it replaced,
+// on random reservations in a real database.
+func TestHasReservationsInRange_MatchesDayByDay(t *testing.T) {
+	db := OpenTestDatabase(t)
+	raw, err := db.DB()
+	require.NoError(t, err)
+	all, err := migrations.Load()
+	require.NoError(t, err)
+	require.NoError(t, migrations.NewMigrator(raw, all, io.Discard).Up(context.Background()))
+	repo := internal.NewRepository(db)
+
+	rng := rand.New(rand.NewSource(42))
+	base := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	day := func(n int) time.Time { return base.AddDate(0, 0, n) }
+
+	for i := 0; i < 40; i++ {
+		start := rng.Intn(60)
+		res := &internal.Reservation{
+			RoomID:   uint(1 + rng.Intn(2)),
+			GuestID:  1,
+			DateFrom: day(start),
+			DateTo:   day(start + rng.Intn(10)),
+			Status:   internal.ReservationConfirmed,
+		}
+		if rng.Intn(4) == 0 {
+			res.Status, res.Cancelled = internal.ReservationCancelled, true
+		}
+		// Overlapping reservations that aren't cancelled are refused by
+		// the database; they're just left out.
+		if err := repo.CreateReservation(res); !errors.Is(err, internal.ErrConflict) {
+			require.NoError(t, err)
+		}
+	}
+
+	// dayByDay is how availability used to be checked: one query per day.
+	dayByDay := func(roomID uint, from, to time.Time) bool {
+		for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+			var reservations []internal.Reservation
+			err := db.Where("room_id = ? AND date_to >= ? AND date_from <= ?", roomID, d, d).Find(&reservations).Error
+			require.NoError(t, err)
+			for _, res := range reservations {
+				if !res.Cancelled {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for i := 0; i < 300; i++ {
+		roomID := uint(1 + rng.Intn(2))
+		start := rng.Intn(70)
+		from, to := day(start), day(start+rng.Intn(20))
+
+		has, err := repo.HasReservationsInRange(roomID, from, to)
+
+		require.NoError(t, err)
+		assert.Equal(t, dayByDay(roomID, from, to), has, "room %d, %s - %s", roomID, from, to)
+	}
+}
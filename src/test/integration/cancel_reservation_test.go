@@ -3,6 +3,7 @@ package test
 import (
 	"bookem-reservation-service/internal"
 	"bookem-reservation-service/util"
+	"encoding/json"
 
 	"net/http"
 	"strconv"
@@ -56,7 +57,12 @@ func TestCancelReservation_Success(t *testing.T) {
 
 	cancelResp, err := http.DefaultClient.Do(cancelReq)
 	require.NoError(t, err)
-	require.Equal(t, http.StatusNoContent, cancelResp.StatusCode)
+	require.Equal(t, http.StatusOK, cancelResp.StatusCode)
+
+	var cancellation internal.CancellationDTO
+	require.NoError(t, json.NewDecoder(cancelResp.Body).Decode(&cancellation))
+	require.Equal(t, reservationID, cancellation.ReservationID)
+	require.Equal(t, cancellation.Cost, cancellation.Refund+cancellation.Penalty)
 }
 
 func TestCancelReservation_UnauthorizedGuest(t *testing.T) {
@@ -0,0 +1,30 @@
+package test
+
+import (
+	"bookem-reservation-service/migrations"
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations_DryRunWritesNothing(t *testing.T) {
+	db := OpenTestDatabase(t)
+	raw, err := db.DB()
+	require.NoError(t, err)
+	all, err := migrations.Load()
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	migrator := migrations.NewMigrator(raw, all, &out)
+	migrator.DryRun = true
+
+	require.NoError(t, migrator.Up(context.Background()))
+
+	assert.Contains(t, out.String(), "up 1_")
+	var exists bool
+	require.NoError(t, raw.QueryRow(`SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists))
+	assert.False(t, exists, "a dry run must not create schema_migrations")
+}
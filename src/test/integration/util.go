@@ -11,11 +11,14 @@ import (
 	"io"
 	"math/rand"
 	"net/http"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
 )
 
 const URL_user = "http://user-service:8080/api/"
@@ -189,12 +192,12 @@ func ResponseToReservations(resp *http.Response) []internal.ReservationDTO {
 		panic(fmt.Sprintf("failed to read response body: %v", err))
 	}
 
-	var obj []internal.ReservationDTO
+	var obj internal.PageDTO[internal.ReservationDTO]
 	if err := json.Unmarshal(bodyBytes, &obj); err != nil {
 		panic(fmt.Sprintf("failed to unmarshal: %v", err))
 	}
 
-	return obj
+	return obj.Items
 }
 
 func CreateReservationRequest(jwt string, dto internal.CreateReservationRequestDTO) (*http.Response, error) {
@@ -270,12 +273,12 @@ func ResponseToReservationRequests(resp *http.Response) []internal.ReservationRe
 		panic(fmt.Sprintf("failed to read response body: %v", err))
 	}
 
-	var obj []internal.ReservationRequestDTO
+	var obj internal.PageDTO[internal.ReservationRequestDTO]
 	if err := json.Unmarshal(bodyBytes, &obj); err != nil {
 		panic(fmt.Sprintf("failed to unmarshal: %v", err))
 	}
 
-	return obj
+	return obj.Items
 }
 
 func GetActiveGuestReservations(jwt string) (*http.Response, error) {
@@ -443,3 +446,37 @@ func ResponseToReservationAvailability(resp *http.Response) AvailabilityResponse
 
 	return obj
 }
+
+// OpenTestDatabase creates a new empty database next to the reservation
+// database and connects to it the way the service does. The database is
+// dropped when the test ends. Without DB_HOST the test is skipped.
+func OpenTestDatabase(t *testing.T) *gorm.DB {
+	if os.Getenv("DB_HOST") == "" {
+		t.Skip("DB_HOST isn't set")
+	}
+	dsn := func(dbname string) string {
+		return fmt.Sprintf(
+			"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+			os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), dbname,
+		)
+	}
+	name := "test_" + strings.ToLower(GenName(12))
+
+	admin, err := gorm.Open(postgres.Open(dsn(os.Getenv("DB_NAME"))), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, admin.Exec("CREATE DATABASE "+name).Error)
+
+	db, err := gorm.Open(postgres.Open(dsn(name)), &gorm.Config{})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		if raw, err := db.DB(); err == nil {
+			raw.Close()
+		}
+		admin.Exec("DROP DATABASE " + name + " WITH (FORCE)")
+		if raw, err := admin.DB(); err == nil {
+			raw.Close()
+		}
+	})
+	return db
+}
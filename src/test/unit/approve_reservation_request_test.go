@@ -9,12 +9,13 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 )
 
 func Test_ApproveReservationRequest_Success(t *testing.T) {
-	svc, repo, userClient, roomClient, notifClient := CreateTestRoomService()
+	svc, repo, userClient, roomClient := CreateTestRoomService()
 
-	req := &internal.ReservationRequest{ID: 1, RoomID: 1, GuestID: 1, GuestCount: 2}
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, GuestID: 1, GuestCount: 2, Status: internal.Pending}
 	room := *DefaultRoom
 	room.HostID = 2
 	guestVal := *DefaultUser_Guest
@@ -27,22 +28,26 @@ func Test_ApproveReservationRequest_Success(t *testing.T) {
 	roomClient.On("FindCurrentAvailabilityListOfRoom", context.Background(), uint(1)).Return(DefaultAvailabilityList, nil)
 	roomClient.On("FindCurrentPricelistOfRoom", context.Background(), uint(1)).Return(DefaultPriceList, nil)
 	roomClient.On("QueryForReservation", mock.Anything, mock.Anything, mock.Anything).Return(DefaultReservationQueryResponse, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("FindRequestByIDForUpdate", uint(1)).Return(&internal.ReservationRequest{ID: 1, Status: internal.Pending}, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("FindCancellationPolicy", uint(1)).Return(nil, gorm.ErrRecordNotFound)
 	repo.On("CreateReservation", mock.AnythingOfType("*internal.Reservation")).Return(nil)
 	repo.On("SetRequestStatus", uint(1), internal.Accepted).Return(nil)
-	repo.On("RejectPendingRequestsInRange", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	repo.On("RejectPendingRequestsInRange", mock.Anything, mock.Anything, mock.Anything).Return([]internal.ReservationRequest{}, nil)
 
 	callerID := 2
-	notifClient.On("CreateNotification", mock.Anything, mock.Anything, mock.Anything).
-		Return(&notificationclient.NotificationDTO{}, nil)
+	ExpectNotification(repo, notificationclient.ReservationAccepted, 1)
 
 	err := svc.ApproveReservationRequest(context.Background(), uint(callerID), 1, "Token")
 
 	assert.NoError(t, err)
 	repo.AssertCalled(t, "SetRequestStatus", uint(1), internal.Accepted)
+	repo.AssertNumberOfCalls(t, "EnqueueNotification", 1)
 }
 
 func Test_ApproveReservationRequest_RequestNotFound(t *testing.T) {
-	svc, repo, _, _, _ := CreateTestRoomService()
+	svc, repo, _, _ := CreateTestRoomService()
 
 	repo.On("FindRequestByID", uint(1)).Return(nil, errors.New("not found"))
 
@@ -53,9 +58,9 @@ func Test_ApproveReservationRequest_RequestNotFound(t *testing.T) {
 }
 
 func Test_ApproveReservationRequest_UnauthorizedHost(t *testing.T) {
-	svc, repo, _, roomClient, _ := CreateTestRoomService()
+	svc, repo, _, roomClient := CreateTestRoomService()
 
-	req := &internal.ReservationRequest{ID: 1, RoomID: 1}
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, Status: internal.Pending}
 	room := *DefaultRoom
 	room.HostID = 99
 
@@ -69,9 +74,9 @@ func Test_ApproveReservationRequest_UnauthorizedHost(t *testing.T) {
 }
 
 func Test_ApproveReservationRequest_GuestNotFound(t *testing.T) {
-	svc, repo, userClient, roomClient, _ := CreateTestRoomService()
+	svc, repo, userClient, roomClient := CreateTestRoomService()
 
-	req := &internal.ReservationRequest{ID: 1, RoomID: 1}
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, Status: internal.Pending}
 	req.GuestID = 11
 	roomVal := *DefaultRoom
 	room := &roomVal
@@ -91,9 +96,9 @@ func Test_ApproveReservationRequest_GuestNotFound(t *testing.T) {
 }
 
 func Test_ApproveReservationRequest_GuestDbErr(t *testing.T) {
-	svc, repo, userClient, roomClient, _ := CreateTestRoomService()
+	svc, repo, userClient, roomClient := CreateTestRoomService()
 
-	req := &internal.ReservationRequest{ID: 1, RoomID: 1}
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, Status: internal.Pending}
 	req.GuestID = 11
 	roomVal := *DefaultRoom
 	room := &roomVal
@@ -109,9 +114,9 @@ func Test_ApproveReservationRequest_GuestDbErr(t *testing.T) {
 }
 
 func Test_ApproveReservationRequest_CreateReservationFails(t *testing.T) {
-	svc, repo, userClient, roomClient, _ := CreateTestRoomService()
+	svc, repo, userClient, roomClient := CreateTestRoomService()
 
-	req := &internal.ReservationRequest{ID: 1, RoomID: 1, GuestID: 1, GuestCount: 2}
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, GuestID: 1, GuestCount: 2, Status: internal.Pending}
 	room := *DefaultRoom
 	room.HostID = 2
 	guestVal := *DefaultUser_Guest
@@ -125,6 +130,10 @@ func Test_ApproveReservationRequest_CreateReservationFails(t *testing.T) {
 	roomClient.On("FindCurrentAvailabilityListOfRoom", context.Background(), uint(1)).Return(DefaultAvailabilityList, nil)
 	roomClient.On("FindCurrentPricelistOfRoom", context.Background(), uint(1)).Return(DefaultPriceList, nil)
 
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("FindRequestByIDForUpdate", uint(1)).Return(&internal.ReservationRequest{ID: 1, Status: internal.Pending}, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("FindCancellationPolicy", uint(1)).Return(nil, gorm.ErrRecordNotFound)
 	repo.On("CreateReservation", mock.AnythingOfType("*internal.Reservation")).Return(errors.New("db create failed"))
 
 	callerID := 2
@@ -133,3 +142,85 @@ func Test_ApproveReservationRequest_CreateReservationFails(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "db create failed")
 }
+
+func Test_ApproveReservationRequest_RequestNoLongerPending(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, GuestID: 1, GuestCount: 2, Status: internal.Pending}
+	room := *DefaultRoom
+	room.HostID = 2
+	guestVal := *DefaultUser_Guest
+	guest := &guestVal
+	guest.Deleted = false
+
+	repo.On("FindRequestByID", uint(1)).Return(req, nil)
+	roomClient.On("FindById", context.Background(), uint(1)).Return(&room, nil)
+	userClient.On("FindById", context.Background(), req.GuestID).Return(guest, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", context.Background(), uint(1)).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", context.Background(), uint(1)).Return(DefaultPriceList, nil)
+
+	// Another approval rejected this request while we were waiting for the lock.
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("FindRequestByIDForUpdate", uint(1)).Return(&internal.ReservationRequest{ID: 1, Status: internal.Rejected}, nil)
+
+	err := svc.ApproveReservationRequest(context.Background(), 2, 1, "Token")
+
+	AssertTransitionError(t, err, "rejected", "accepted")
+	repo.AssertNotCalled(t, "CreateReservation", mock.Anything)
+	repo.AssertNotCalled(t, "SetRequestStatus", mock.Anything, mock.Anything)
+}
+
+func Test_ApproveReservationRequest_OverlappingReservationCreatedConcurrently(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, GuestID: 1, GuestCount: 2, Status: internal.Pending}
+	room := *DefaultRoom
+	room.HostID = 2
+	guestVal := *DefaultUser_Guest
+	guest := &guestVal
+	guest.Deleted = false
+
+	repo.On("FindRequestByID", uint(1)).Return(req, nil)
+	roomClient.On("FindById", context.Background(), uint(1)).Return(&room, nil)
+	userClient.On("FindById", context.Background(), req.GuestID).Return(guest, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", context.Background(), uint(1)).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", context.Background(), uint(1)).Return(DefaultPriceList, nil)
+
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("FindRequestByIDForUpdate", uint(1)).Return(&internal.ReservationRequest{ID: 1, Status: internal.Pending}, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(true, nil)
+
+	err := svc.ApproveReservationRequest(context.Background(), 2, 1, "Token")
+
+	assert.ErrorIs(t, err, internal.ErrConflict)
+	repo.AssertNotCalled(t, "CreateReservation", mock.Anything)
+}
+
+func Test_ApproveReservationRequest_DatabaseRejectsDoubleBooking(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, GuestID: 1, GuestCount: 2, Status: internal.Pending}
+	room := *DefaultRoom
+	room.HostID = 2
+	guestVal := *DefaultUser_Guest
+	guest := &guestVal
+	guest.Deleted = false
+
+	repo.On("FindRequestByID", uint(1)).Return(req, nil)
+	roomClient.On("FindById", context.Background(), uint(1)).Return(&room, nil)
+	userClient.On("FindById", context.Background(), req.GuestID).Return(guest, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", context.Background(), uint(1)).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", context.Background(), uint(1)).Return(DefaultPriceList, nil)
+
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("FindRequestByIDForUpdate", uint(1)).Return(&internal.ReservationRequest{ID: 1, Status: internal.Pending}, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(false, nil)
+	// The repository maps the exclusion constraint violation to ErrConflict.
+	repo.On("FindCancellationPolicy", uint(1)).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("CreateReservation", mock.AnythingOfType("*internal.Reservation")).Return(internal.ErrConflict)
+
+	err := svc.ApproveReservationRequest(context.Background(), 2, 1, "Token")
+
+	assert.ErrorIs(t, err, internal.ErrConflict)
+	repo.AssertNotCalled(t, "SetRequestStatus", mock.Anything, mock.Anything)
+}
@@ -1,44 +1,37 @@
 package test
 
 import (
-	"bookem-reservation-service/internal"
 	"context"
 	"errors"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 )
 
 func Test_AreThereReservationsOnDays_AllDaysFree(t *testing.T) {
-	svc, repo, _, _, _ := CreateTestRoomService()
-
-	// Mock: No reservations on any day
-	repo.On("FindReservationsByRoomIDForDay", uint(1), mock.Anything).Return([]internal.Reservation{}, nil)
+	svc, repo, _, _ := CreateTestRoomService()
 
 	from := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
 	to := time.Date(2025, 9, 3, 0, 0, 0, 0, time.UTC)
 
+	repo.On("HasReservationsInRange", uint(1), from, to).Return(false, nil)
+
 	result, err := svc.AreThereReservationsOnDays(context.Background(), 1, from, to)
 
 	assert.NoError(t, err)
 	assert.False(t, result)
+	repo.AssertNumberOfCalls(t, "HasReservationsInRange", 1)
 }
 
 func Test_AreThereReservationsOnDays_OneDayBooked(t *testing.T) {
-	svc, repo, _, _, _ := CreateTestRoomService()
-
-	// Mock: First two days free, third day has a reservation
-	repo.On("FindReservationsByRoomIDForDay", uint(1), time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)).Return([]internal.Reservation{}, nil)
-	repo.On("FindReservationsByRoomIDForDay", uint(1), time.Date(2025, 9, 2, 0, 0, 0, 0, time.UTC)).Return([]internal.Reservation{}, nil)
-	repo.On("FindReservationsByRoomIDForDay", uint(1), time.Date(2025, 9, 3, 0, 0, 0, 0, time.UTC)).Return([]internal.Reservation{
-		{RoomID: 1, DateFrom: time.Date(2025, 9, 3, 0, 0, 0, 0, time.UTC), DateTo: time.Date(2025, 9, 5, 0, 0, 0, 0, time.UTC)},
-	}, nil)
+	svc, repo, _, _ := CreateTestRoomService()
 
 	from := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
 	to := time.Date(2025, 9, 3, 0, 0, 0, 0, time.UTC)
 
+	repo.On("HasReservationsInRange", uint(1), from, to).Return(true, nil)
+
 	result, err := svc.AreThereReservationsOnDays(context.Background(), 1, from, to)
 
 	assert.NoError(t, err)
@@ -46,14 +39,13 @@ func Test_AreThereReservationsOnDays_OneDayBooked(t *testing.T) {
 }
 
 func Test_AreThereReservationsOnDays_RepoError(t *testing.T) {
-	svc, repo, _, _, _ := CreateTestRoomService()
-
-	// Mock: Error on first day
-	repo.On("FindReservationsByRoomIDForDay", uint(1), time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)).Return([]internal.Reservation{}, errors.New("db error"))
+	svc, repo, _, _ := CreateTestRoomService()
 
 	from := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
 	to := time.Date(2025, 9, 2, 0, 0, 0, 0, time.UTC)
 
+	repo.On("HasReservationsInRange", uint(1), from, to).Return(false, errors.New("db error"))
+
 	result, err := svc.AreThereReservationsOnDays(context.Background(), 1, from, to)
 
 	assert.Error(t, err)
@@ -0,0 +1,227 @@
+package test
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/internal"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+// pendingRequestOf is a pending request of the guest for the default room,
+// made minutes after a fixed time.
+func pendingRequestOf(id, guestID uint, minutes int) internal.ReservationRequest {
+	from := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 20)
+	return internal.ReservationRequest{
+		ID:         id,
+		RoomID:     DefaultRoom.ID,
+		GuestID:    guestID,
+		DateFrom:   from,
+		DateTo:     from.AddDate(0, 0, 3),
+		GuestCount: 2,
+		Status:     internal.Pending,
+		Cost:       400,
+		CreatedAt:  time.Date(2026, 8, 1, 12, minutes, 0, 0, time.UTC),
+	}
+}
+
+// expectDecisions sets up the host of the default room deciding requests of
+// guests that still have accounts.
+func expectDecisions(repo *MockReservationRepo, userClient *MockUserClient, roomClient *MockRoomClient, ids []uint, requests []internal.ReservationRequest, guestIDs ...uint) {
+	repo.On("FindRequests", internal.RequestFilter{IDs: ids}).Return(requests, nil)
+	for _, id := range guestIDs {
+		guest := *DefaultUser_Guest
+		guest.Id, guest.Deleted = id, false
+		userClient.On("FindById", mock.Anything, id).Return(&guest, nil)
+	}
+	roomClient.On("FindById", mock.Anything, DefaultRoom.ID).Return(DefaultRoom, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", mock.Anything, DefaultRoom.ID).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", mock.Anything, DefaultRoom.ID).Return(DefaultPriceList, nil)
+	repo.On("LockRoom", DefaultRoom.ID).Return(nil)
+}
+
+func expectDecidedNotification(repo *MockReservationRepo, guestID uint) *mock.Call {
+	return repo.On("EnqueueNotification", mock.MatchedBy(func(n *internal.NotificationOutbox) bool {
+		return n.Type == notificationclient.RequestsDecided && n.ReceiverID == guestID &&
+			n.Subject == DefaultRoom.HostID && n.Object == 0
+	})).Return(nil)
+}
+
+func Test_DecideRequests_Invalid(t *testing.T) {
+	tooMany := make([]internal.RequestDecision, internal.MaxBatchDecisions+1)
+	for i := range tooMany {
+		tooMany[i] = internal.RequestDecision{RequestID: uint(i + 1), Action: internal.ApproveDecision}
+	}
+
+	tests := map[string][]internal.RequestDecision{
+		"no decisions":   nil,
+		"too many":       tooMany,
+		"unknown action": {{RequestID: 1, Action: "maybe"}},
+		"same request twice": {
+			{RequestID: 1, Action: internal.ApproveDecision},
+			{RequestID: 1, Action: internal.RejectDecision},
+		},
+	}
+	for name, decisions := range tests {
+		t.Run(name, func(t *testing.T) {
+			svc, repo, _, _ := CreateTestRoomService()
+
+			_, err := svc.DecideRequests(context.Background(), DefaultRoom.HostID, decisions)
+
+			var apiErr *internal.APIError
+			if assert.ErrorAs(t, err, &apiErr) {
+				assert.Equal(t, 400, apiErr.Code)
+			}
+			repo.AssertNotCalled(t, "FindRequests", mock.Anything)
+		})
+	}
+}
+
+func Test_DecideRequests_EarliestCreatedWins(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	later, earlier := pendingRequestOf(5, 1, 30), pendingRequestOf(6, 1, 10)
+	expectDecisions(repo, userClient, roomClient, []uint{5, 6}, []internal.ReservationRequest{later, earlier}, 1)
+	repo.On("FindRequestByIDForUpdate", uint(6)).Return(&earlier, nil)
+	rejected := later
+	rejected.Status = internal.Rejected
+	repo.On("FindRequestByIDForUpdate", uint(5)).Return(&rejected, nil)
+	repo.On("HasReservationsInRange", DefaultRoom.ID, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("FindCancellationPolicy", DefaultRoom.ID).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("CreateReservation", mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(*internal.Reservation).ID = 20
+	}).Return(nil)
+	repo.On("SetRequestStatus", uint(6), internal.Accepted).Return(nil)
+	repo.On("RejectPendingRequestsInRange", DefaultRoom.ID, earlier.DateFrom, earlier.DateTo).Return([]internal.ReservationRequest{later}, nil)
+	expectDecidedNotification(repo, 1)
+
+	results, err := svc.DecideRequests(context.Background(), DefaultRoom.HostID, []internal.RequestDecision{
+		{RequestID: 5, Action: internal.ApproveDecision},
+		{RequestID: 6, Action: internal.ApproveDecision},
+	})
+
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, uint(5), results[0].RequestID)
+		assert.Equal(t, internal.Rejected, results[0].Status)
+		var apiErr *internal.APIError
+		if assert.ErrorAs(t, results[0].Err, &apiErr) {
+			assert.Equal(t, 409, apiErr.Code)
+		}
+
+		assert.Equal(t, uint(6), results[1].RequestID)
+		assert.Equal(t, internal.Accepted, results[1].Status)
+		assert.NoError(t, results[1].Err)
+		if assert.NotNil(t, results[1].Reservation) {
+			assert.Equal(t, uint(20), results[1].Reservation.ID)
+		}
+	}
+	repo.AssertNumberOfCalls(t, "CreateReservation", 1)
+	repo.AssertNumberOfCalls(t, "LockRoom", 1)
+	repo.AssertNumberOfCalls(t, "EnqueueNotification", 1)
+	roomClient.AssertNumberOfCalls(t, "FindById", 1)
+	userClient.AssertNumberOfCalls(t, "FindById", 1)
+}
+
+func Test_DecideRequests_ResultPerItem(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	approved, rejected := pendingRequestOf(5, 1, 0), pendingRequestOf(6, 3, 0)
+	rejected.DateFrom, rejected.DateTo = rejected.DateFrom.AddDate(0, 0, 10), rejected.DateTo.AddDate(0, 0, 10)
+	otherHost := pendingRequestOf(8, 1, 0)
+	otherHost.RoomID = 2
+	grouped := pendingRequestOf(9, 1, 0)
+	grouped.GroupID = 4
+	expectDecisions(repo, userClient, roomClient, []uint{5, 6, 7, 8, 9}, []internal.ReservationRequest{approved, rejected, otherHost, grouped}, 1, 3)
+	room := *DefaultRoom
+	room.ID, room.HostID = 2, 3
+	roomClient.On("FindById", mock.Anything, uint(2)).Return(&room, nil)
+
+	repo.On("FindRequestByIDForUpdate", uint(5)).Return(&approved, nil)
+	repo.On("FindRequestByIDForUpdate", uint(6)).Return(&rejected, nil)
+	repo.On("HasReservationsInRange", DefaultRoom.ID, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("FindCancellationPolicy", DefaultRoom.ID).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("CreateReservation", mock.Anything).Return(nil)
+	repo.On("SetRequestStatus", uint(5), internal.Accepted).Return(nil)
+	repo.On("SetRequestStatus", uint(6), internal.Rejected).Return(nil)
+	repo.On("RejectPendingRequestsInRange", DefaultRoom.ID, mock.Anything, mock.Anything).Return([]internal.ReservationRequest{}, nil)
+	expectDecidedNotification(repo, 1)
+	expectDecidedNotification(repo, 3)
+
+	results, err := svc.DecideRequests(context.Background(), DefaultRoom.HostID, []internal.RequestDecision{
+		{RequestID: 5, Action: internal.ApproveDecision},
+		{RequestID: 6, Action: internal.RejectDecision},
+		{RequestID: 7, Action: internal.ApproveDecision},
+		{RequestID: 8, Action: internal.RejectDecision},
+		{RequestID: 9, Action: internal.ApproveDecision},
+	})
+
+	assert.NoError(t, err)
+	if assert.Len(t, results, 5) {
+		assert.Equal(t, internal.Accepted, results[0].Status)
+		assert.Equal(t, internal.Rejected, results[1].Status)
+		for i, code := range map[int]int{2: 404, 3: 401, 4: 400} {
+			assert.Empty(t, results[i].Status)
+			var apiErr *internal.APIError
+			if assert.ErrorAs(t, results[i].Err, &apiErr) {
+				assert.Equal(t, code, apiErr.Code)
+			}
+		}
+	}
+	repo.AssertNumberOfCalls(t, "EnqueueNotification", 2)
+	repo.AssertNotCalled(t, "LockRoom", uint(2))
+	repo.AssertNotCalled(t, "SetRequestStatus", uint(8), mock.Anything)
+}
+
+func Test_DecideRequests_OneSummaryPerGuest(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	first, second, other := pendingRequestOf(5, 1, 0), pendingRequestOf(6, 1, 0), pendingRequestOf(7, 1, 0)
+	second.DateFrom, second.DateTo = second.DateFrom.AddDate(0, 0, 10), second.DateTo.AddDate(0, 0, 10)
+	other.RoomID = 3
+	expectDecisions(repo, userClient, roomClient, []uint{5, 6, 7}, []internal.ReservationRequest{first, second, other}, 1)
+	room := *DefaultRoom
+	room.ID = 3
+	roomClient.On("FindById", mock.Anything, uint(3)).Return(&room, nil)
+	repo.On("LockRoom", uint(3)).Return(nil)
+	for _, req := range []internal.ReservationRequest{first, second, other} {
+		repo.On("FindRequestByIDForUpdate", req.ID).Return(&req, nil)
+		repo.On("SetRequestStatus", req.ID, internal.Rejected).Return(nil)
+	}
+	expectDecidedNotification(repo, 1)
+
+	results, err := svc.DecideRequests(context.Background(), DefaultRoom.HostID, []internal.RequestDecision{
+		{RequestID: 5, Action: internal.RejectDecision},
+		{RequestID: 6, Action: internal.RejectDecision},
+		{RequestID: 7, Action: internal.RejectDecision},
+	})
+
+	assert.NoError(t, err)
+	for _, r := range results {
+		assert.Equal(t, internal.Rejected, r.Status)
+	}
+	repo.AssertNumberOfCalls(t, "EnqueueNotification", 1)
+}
+
+func Test_DecideRequests_NotPending(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	req := pendingRequestOf(5, 1, 0)
+	req.Status = internal.Expired
+	expectDecisions(repo, userClient, roomClient, []uint{5}, []internal.ReservationRequest{req}, 1)
+
+	results, err := svc.DecideRequests(context.Background(), DefaultRoom.HostID, []internal.RequestDecision{
+		{RequestID: 5, Action: internal.RejectDecision},
+	})
+
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		AssertTransitionError(t, results[0].Err, string(internal.Expired), string(internal.Rejected))
+	}
+	repo.AssertNotCalled(t, "LockRoom", mock.Anything)
+	repo.AssertNotCalled(t, "EnqueueNotification", mock.Anything)
+}
@@ -11,7 +11,7 @@ import (
 )
 
 func Test_CanUserRateHost_True(t *testing.T) {
-	svc, repo, _, roomCli, _ := CreateTestRoomService()
+	svc, repo, _, roomCli := CreateTestRoomService()
 
 	guestID := uint(10)
 	hostID := uint(20)
@@ -22,7 +22,7 @@ func Test_CanUserRateHost_True(t *testing.T) {
 	}
 	roomCli.On("FindByHostId", mock.Anything, hostID).Return(hostRooms, nil)
 
-	repo.On("HasGuestPastReservationInRooms", guestID, []uint{101, 102}, mock.AnythingOfType("time.Time")).
+	repo.On("HasGuestCompletedStayInRooms", guestID, []uint{101, 102}).
 		Return(true, nil)
 
 	ok, err := svc.CanUserRateHost(context.Background(), guestID, hostID)
@@ -30,11 +30,11 @@ func Test_CanUserRateHost_True(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, ok)
 	roomCli.AssertCalled(t, "FindByHostId", mock.Anything, hostID)
-	repo.AssertCalled(t, "HasGuestPastReservationInRooms", guestID, []uint{101, 102}, mock.AnythingOfType("time.Time"))
+	repo.AssertCalled(t, "HasGuestCompletedStayInRooms", guestID, []uint{101, 102})
 }
 
-func Test_CanUserRateHost_False_NoPastStay(t *testing.T) {
-	svc, repo, _, roomCli, _ := CreateTestRoomService()
+func Test_CanUserRateHost_False_NoCompletedStay(t *testing.T) {
+	svc, repo, _, roomCli := CreateTestRoomService()
 
 	guestID := uint(11)
 	hostID := uint(21)
@@ -42,7 +42,7 @@ func Test_CanUserRateHost_False_NoPastStay(t *testing.T) {
 	roomCli.On("FindByHostId", mock.Anything, hostID).
 		Return([]roomclient.RoomDTO{{ID: 201, HostID: hostID}}, nil)
 
-	repo.On("HasGuestPastReservationInRooms", guestID, []uint{201}, mock.AnythingOfType("time.Time")).
+	repo.On("HasGuestCompletedStayInRooms", guestID, []uint{201}).
 		Return(false, nil)
 
 	ok, err := svc.CanUserRateHost(context.Background(), guestID, hostID)
@@ -52,7 +52,7 @@ func Test_CanUserRateHost_False_NoPastStay(t *testing.T) {
 }
 
 func Test_CanUserRateHost_False_HostHasNoRooms(t *testing.T) {
-	svc, _, _, roomCli, _ := CreateTestRoomService()
+	svc, _, _, roomCli := CreateTestRoomService()
 
 	guestID := uint(12)
 	hostID := uint(22)
@@ -67,7 +67,7 @@ func Test_CanUserRateHost_False_HostHasNoRooms(t *testing.T) {
 }
 
 func Test_CanUserRateHost_RoomClientError(t *testing.T) {
-	svc, _, _, roomCli, _ := CreateTestRoomService()
+	svc, _, _, roomCli := CreateTestRoomService()
 
 	guestID := uint(13)
 	hostID := uint(23)
@@ -82,7 +82,7 @@ func Test_CanUserRateHost_RoomClientError(t *testing.T) {
 }
 
 func Test_CanUserRateHost_RepoError(t *testing.T) {
-	svc, repo, _, roomCli, _ := CreateTestRoomService()
+	svc, repo, _, roomCli := CreateTestRoomService()
 
 	guestID := uint(14)
 	hostID := uint(24)
@@ -90,7 +90,7 @@ func Test_CanUserRateHost_RepoError(t *testing.T) {
 	roomCli.On("FindByHostId", mock.Anything, hostID).
 		Return([]roomclient.RoomDTO{{ID: 301, HostID: hostID}}, nil)
 
-	repo.On("HasGuestPastReservationInRooms", guestID, []uint{301}, mock.AnythingOfType("time.Time")).
+	repo.On("HasGuestCompletedStayInRooms", guestID, []uint{301}).
 		Return(false, errors.New("db error"))
 
 	ok, err := svc.CanUserRateHost(context.Background(), guestID, hostID)
@@ -100,28 +100,28 @@ func Test_CanUserRateHost_RepoError(t *testing.T) {
 }
 
 func Test_CanUserRateRoom_True(t *testing.T) {
-	svc, repo, _, _, _ := CreateTestRoomService()
+	svc, repo, _, _ := CreateTestRoomService()
 
 	guestID := uint(15)
 	roomID := uint(401)
 
-	repo.On("HasGuestPastReservationInRooms", guestID, []uint{roomID}, mock.AnythingOfType("time.Time")).
+	repo.On("HasGuestCompletedStayInRooms", guestID, []uint{roomID}).
 		Return(true, nil)
 
 	ok, err := svc.CanUserRateRoom(context.Background(), guestID, roomID)
 
 	assert.NoError(t, err)
 	assert.True(t, ok)
-	repo.AssertCalled(t, "HasGuestPastReservationInRooms", guestID, []uint{roomID}, mock.AnythingOfType("time.Time"))
+	repo.AssertCalled(t, "HasGuestCompletedStayInRooms", guestID, []uint{roomID})
 }
 
 func Test_CanUserRateRoom_False(t *testing.T) {
-	svc, repo, _, _, _ := CreateTestRoomService()
+	svc, repo, _, _ := CreateTestRoomService()
 
 	guestID := uint(16)
 	roomID := uint(402)
 
-	repo.On("HasGuestPastReservationInRooms", guestID, []uint{roomID}, mock.AnythingOfType("time.Time")).
+	repo.On("HasGuestCompletedStayInRooms", guestID, []uint{roomID}).
 		Return(false, nil)
 
 	ok, err := svc.CanUserRateRoom(context.Background(), guestID, roomID)
@@ -131,12 +131,12 @@ func Test_CanUserRateRoom_False(t *testing.T) {
 }
 
 func Test_CanUserRateRoom_RepoError(t *testing.T) {
-	svc, repo, _, _, _ := CreateTestRoomService()
+	svc, repo, _, _ := CreateTestRoomService()
 
 	guestID := uint(17)
 	roomID := uint(403)
 
-	repo.On("HasGuestPastReservationInRooms", guestID, []uint{roomID}, mock.AnythingOfType("time.Time")).
+	repo.On("HasGuestCompletedStayInRooms", guestID, []uint{roomID}).
 		Return(false, errors.New("db error"))
 
 	ok, err := svc.CanUserRateRoom(context.Background(), guestID, roomID)
@@ -2,6 +2,7 @@ package test
 
 import (
 	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/client/transport"
 	"bookem-reservation-service/internal"
 	"context"
 	"errors"
@@ -13,90 +14,117 @@ import (
 )
 
 func TestCancelReservation_Success(t *testing.T) {
-	svc, mockRepo, mockUser, mockRoom, notifClient := CreateTestRoomService()
+	svc, mockRepo, mockUser, mockRoom := CreateTestRoomService()
 
 	res := &internal.Reservation{
-		ID:        1,
-		GuestID:   1,
-		RoomID:    1,
-		DateFrom:  time.Now().Add(48 * time.Hour),
-		Cancelled: false,
+		ID:       1,
+		GuestID:  1,
+		RoomID:   1,
+		DateFrom: time.Now().Add(48 * time.Hour),
+		Status:   internal.ReservationConfirmed,
 	}
 
 	mockUser.On("FindById", mock.Anything, uint(1)).Return(DefaultUser_Guest, nil)
 	mockRepo.On("FindReservationById", uint(1)).Return(res, nil)
-	mockRepo.On("CancelReservation", uint(1)).Return(nil)
+	mockRepo.On("FindReservationByIDForUpdate", uint(1)).Return(res, nil)
+	mockRepo.On("CancelReservation", uint(1), mock.Anything).Return(nil)
+	mockRepo.On("CancelPendingModifications", uint(1), mock.Anything).Return([]internal.ReservationModification{}, nil)
+	mockRepo.On("FindWaitingEntries", mock.Anything, mock.Anything, mock.Anything).Return([]internal.WaitlistEntry{}, nil)
 	mockRoom.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
 
-	notifClient.On("CreateNotification", mock.Anything, mock.Anything, mock.Anything).
-		Return(&notificationclient.NotificationDTO{}, nil)
+	ExpectNotification(mockRepo, notificationclient.ReservationCancelled, 2)
 
-	err := svc.CancelReservation(context.Background(), 1, 1, "Token")
+	_, err := svc.CancelReservation(context.Background(), 1, 1, "Token")
 
 	assert.NoError(t, err)
-	mockRepo.AssertCalled(t, "CancelReservation", uint(1))
+	mockRepo.AssertNumberOfCalls(t, "EnqueueNotification", 1)
+
+	cancellation := mockRepo.Calls[2].Arguments.Get(1).(internal.Cancellation)
+	assert.Equal(t, internal.CancelledByGuest, cancellation.By)
+	assert.Equal(t, uint(1), cancellation.ByID)
 }
 
 func TestCancelReservation_AlreadyCancelled(t *testing.T) {
-	svc, mockRepo, mockUser, _, _ := CreateTestRoomService()
+	svc, mockRepo, mockUser, _ := CreateTestRoomService()
 
 	res := &internal.Reservation{
-		ID:        2,
-		GuestID:   1,
-		DateFrom:  time.Now().Add(48 * time.Hour),
-		Cancelled: true,
+		ID:       2,
+		GuestID:  1,
+		DateFrom: time.Now().Add(48 * time.Hour),
+		Status:   internal.ReservationCancelled,
 	}
 
 	mockUser.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	mockRepo.On("FindReservationById", uint(2)).Return(res, nil)
 
-	err := svc.CancelReservation(context.Background(), 1, 2, "Token")
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "already cancelled")
+	_, err := svc.CancelReservation(context.Background(), 1, 2, "Token")
+	AssertTransitionError(t, err, "cancelled", "cancelled")
 }
 
 func TestCancelReservation_AlreadyStarted(t *testing.T) {
-	svc, mockRepo, mockUser, _, _ := CreateTestRoomService()
+	svc, mockRepo, mockUser, _ := CreateTestRoomService()
 
 	res := &internal.Reservation{
-		ID:        3,
-		GuestID:   1,
-		DateFrom:  time.Now().Add(-2 * time.Hour),
-		Cancelled: false,
+		ID:       3,
+		GuestID:  1,
+		DateFrom: time.Now().Add(-2 * time.Hour),
+		Status:   internal.ReservationConfirmed,
 	}
 
 	mockUser.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	mockRepo.On("FindReservationById", uint(3)).Return(res, nil)
 
-	err := svc.CancelReservation(context.Background(), 1, 3, "Token")
+	_, err := svc.CancelReservation(context.Background(), 1, 3, "Token")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "cannot cancel reservation that already started")
 }
 
 func TestCancelReservation_WrongGuest(t *testing.T) {
-	svc, mockRepo, mockUser, _, _ := CreateTestRoomService()
+	svc, mockRepo, mockUser, _ := CreateTestRoomService()
 
 	res := &internal.Reservation{
-		ID:        4,
-		GuestID:   99,
-		DateFrom:  time.Now().Add(48 * time.Hour),
-		Cancelled: false,
+		ID:       4,
+		GuestID:  99,
+		DateFrom: time.Now().Add(48 * time.Hour),
+		Status:   internal.ReservationConfirmed,
 	}
 
 	mockUser.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	mockRepo.On("FindReservationById", uint(4)).Return(res, nil)
 
-	err := svc.CancelReservation(context.Background(), 1, 4, "Token")
+	_, err := svc.CancelReservation(context.Background(), 1, 4, "Token")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "Unauthorized")
 }
 
 func TestCancelReservation_UserNotFound(t *testing.T) {
-	svc, _, mockUser, _, _ := CreateTestRoomService()
+	svc, _, mockUser, _ := CreateTestRoomService()
 
 	mockUser.On("FindById", context.Background(), uint(1)).Return(nil, errors.New("user not found"))
 
-	err := svc.CancelReservation(context.Background(), 1, 5, "Token")
+	_, err := svc.CancelReservation(context.Background(), 1, 5, "Token")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "Unauthenticated")
 }
+
+func TestCancelReservation_RoomServiceUnavailable(t *testing.T) {
+	svc, mockRepo, mockUser, mockRoom := CreateTestRoomService()
+
+	res := &internal.Reservation{
+		Status:   internal.ReservationConfirmed,
+		ID:       1,
+		GuestID:  1,
+		RoomID:   1,
+		DateFrom: time.Now().Add(48 * time.Hour),
+	}
+
+	mockUser.On("FindById", mock.Anything, uint(1)).Return(DefaultUser_Guest, nil)
+	mockRepo.On("FindReservationById", uint(1)).Return(res, nil)
+	mockRoom.On("FindById", mock.Anything, uint(1)).
+		Return(nil, &transport.Error{Upstream: "room-service", Kind: transport.ErrUnavailable})
+
+	_, err := svc.CancelReservation(context.Background(), 1, 1, "Token")
+
+	assert.ErrorIs(t, err, internal.ErrServiceUnavailable)
+	mockRepo.AssertNotCalled(t, "CancelReservation", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,212 @@
+package test
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/internal"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func Test_NewCancellationTerms_Preset(t *testing.T) {
+	terms, err := internal.NewCancellationTerms(internal.PolicyModerate, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, internal.PolicyModerate, terms.Kind)
+	assert.Equal(t, []internal.RefundTier{{DaysBefore: 5, RefundPercent: 100}, {DaysBefore: 1, RefundPercent: 50}}, terms.Tiers)
+}
+
+func Test_NewCancellationTerms_Custom(t *testing.T) {
+	terms, err := internal.NewCancellationTerms(internal.PolicyCustom, []internal.RefundTier{
+		{DaysBefore: 3, RefundPercent: 20},
+		{DaysBefore: 30, RefundPercent: 100},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []internal.RefundTier{{DaysBefore: 30, RefundPercent: 100}, {DaysBefore: 3, RefundPercent: 20}}, terms.Tiers)
+}
+
+func Test_NewCancellationTerms_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		kind  internal.CancellationPolicyKind
+		tiers []internal.RefundTier
+	}{
+		"unknown kind":          {kind: "lenient"},
+		"tiers with preset":     {kind: internal.PolicyStrict, tiers: []internal.RefundTier{{DaysBefore: 1, RefundPercent: 10}}},
+		"custom without tiers":  {kind: internal.PolicyCustom},
+		"percent over 100":      {kind: internal.PolicyCustom, tiers: []internal.RefundTier{{DaysBefore: 1, RefundPercent: 150}}},
+		"duplicate days":        {kind: internal.PolicyCustom, tiers: []internal.RefundTier{{DaysBefore: 2, RefundPercent: 50}, {DaysBefore: 2, RefundPercent: 40}}},
+		"refund grows later on": {kind: internal.PolicyCustom, tiers: []internal.RefundTier{{DaysBefore: 10, RefundPercent: 20}, {DaysBefore: 2, RefundPercent: 80}}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := internal.NewCancellationTerms(tt.kind, tt.tiers)
+
+			var apiErr *internal.APIError
+			assert.ErrorAs(t, err, &apiErr)
+		})
+	}
+}
+
+func Test_CancellationTerms_Refund(t *testing.T) {
+	terms, _ := internal.NewCancellationTerms(internal.PolicyModerate, nil)
+	start := time.Date(2026, 7, 20, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		at      time.Time
+		days    uint
+		refund  uint
+		penalty uint
+	}{
+		"well ahead":        {at: start.AddDate(0, 0, -10), days: 10, refund: 333, penalty: 0},
+		"on the tier edge":  {at: start.AddDate(0, 0, -5), days: 5, refund: 333, penalty: 0},
+		"between tiers":     {at: start.AddDate(0, 0, -3), days: 3, refund: 166, penalty: 167},
+		"less than one day": {at: start.Add(-20 * time.Hour), days: 0, refund: 0, penalty: 333},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := terms.Refund(333, start, tt.at)
+
+			assert.Equal(t, tt.days, b.DaysBefore)
+			assert.Equal(t, tt.refund, b.Refund)
+			assert.Equal(t, tt.penalty, b.Penalty)
+		})
+	}
+}
+
+func Test_CancelReservation_RefundFollowsSnapshot(t *testing.T) {
+	svc, mockRepo, mockUser, mockRoom := CreateTestRoomService()
+
+	strict, _ := internal.NewCancellationTerms(internal.PolicyStrict, nil)
+	res := &internal.Reservation{
+		Status:            internal.ReservationConfirmed,
+		ID:                1,
+		GuestID:           1,
+		RoomID:            1,
+		Cost:              1000,
+		DateFrom:          time.Now().Add(10*24*time.Hour + time.Hour),
+		CancellationTerms: strict,
+	}
+
+	mockUser.On("FindById", mock.Anything, uint(1)).Return(DefaultUser_Guest, nil)
+	mockRepo.On("FindReservationById", uint(1)).Return(res, nil)
+	mockRepo.On("FindReservationByIDForUpdate", uint(1)).Return(res, nil)
+	mockRepo.On("CancelReservation", uint(1), mock.Anything).Return(nil)
+	mockRepo.On("CancelPendingModifications", uint(1), mock.Anything).Return([]internal.ReservationModification{}, nil)
+	mockRepo.On("FindWaitingEntries", mock.Anything, mock.Anything, mock.Anything).Return([]internal.WaitlistEntry{}, nil)
+	mockRoom.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	ExpectNotification(mockRepo, notificationclient.ReservationCancelled, 2)
+
+	result, err := svc.CancelReservation(context.Background(), 1, 1, "Token")
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(10), result.Refund.DaysBefore)
+	assert.Equal(t, uint(500), result.Refund.Refund)
+	assert.Equal(t, uint(500), result.Refund.Penalty)
+	assert.True(t, result.Reservation.Cancelled)
+
+	cancellation := mockRepo.Calls[2].Arguments.Get(1).(internal.Cancellation)
+	assert.Equal(t, uint(500), cancellation.Refund)
+	assert.Equal(t, uint(500), cancellation.Penalty)
+}
+
+func Test_HostCancelReservation_FullRefund(t *testing.T) {
+	svc, mockRepo, mockUser, mockRoom := CreateTestRoomService()
+
+	strict, _ := internal.NewCancellationTerms(internal.PolicyStrict, nil)
+	res := &internal.Reservation{
+		Status:            internal.ReservationConfirmed,
+		ID:                1,
+		GuestID:           1,
+		RoomID:            1,
+		Cost:              1000,
+		DateFrom:          time.Now().Add(24 * time.Hour),
+		CancellationTerms: strict,
+	}
+
+	mockUser.On("FindById", mock.Anything, uint(2)).Return(DefaultUser_Host, nil)
+	mockRepo.On("FindReservationById", uint(1)).Return(res, nil)
+	mockRoom.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	mockRepo.On("FindReservationByIDForUpdate", uint(1)).Return(res, nil)
+	mockRepo.On("CancelReservation", uint(1), mock.Anything).Return(nil)
+	mockRepo.On("CancelPendingModifications", uint(1), mock.Anything).Return([]internal.ReservationModification{}, nil)
+	mockRepo.On("FindWaitingEntries", mock.Anything, mock.Anything, mock.Anything).Return([]internal.WaitlistEntry{}, nil)
+	ExpectNotification(mockRepo, notificationclient.ReservationCancelledByHost, 1)
+
+	result, err := svc.HostCancelReservation(context.Background(), 2, 1, "Burst pipe")
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1000), result.Refund.Refund)
+	assert.Equal(t, uint(0), result.Refund.Penalty)
+}
+
+func Test_ApproveReservationRequest_SnapshotsPolicy(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, GuestID: 1, GuestCount: 2, Status: internal.Pending}
+	strict, _ := internal.NewCancellationTerms(internal.PolicyStrict, nil)
+
+	repo.On("FindRequestByID", uint(1)).Return(req, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	userClient.On("FindById", mock.Anything, uint(1)).Return(DefaultUser_Guest, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", mock.Anything, uint(1)).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", mock.Anything, uint(1)).Return(DefaultPriceList, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("FindRequestByIDForUpdate", uint(1)).Return(req, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("FindCancellationPolicy", uint(1)).Return(&internal.CancellationPolicy{RoomID: 1, Terms: strict}, nil)
+	repo.On("CreateReservation", mock.MatchedBy(func(r *internal.Reservation) bool {
+		return r.CancellationTerms.Kind == internal.PolicyStrict
+	})).Return(nil)
+	repo.On("RejectPendingRequestsInRange", mock.Anything, mock.Anything, mock.Anything).Return([]internal.ReservationRequest{}, nil)
+	repo.On("SetRequestStatus", uint(1), internal.Accepted).Return(nil)
+	ExpectNotification(repo, notificationclient.ReservationAccepted, 1)
+
+	err := svc.ApproveReservationRequest(context.Background(), 2, 1, "Token")
+
+	assert.NoError(t, err)
+	repo.AssertCalled(t, "CreateReservation", mock.Anything)
+}
+
+func Test_GetCancellationPolicy_DefaultsToFlexible(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("FindCancellationPolicy", uint(1)).Return(nil, gorm.ErrRecordNotFound)
+
+	policy, err := svc.GetCancellationPolicy(context.Background(), 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, internal.DefaultCancellationTerms, policy.Terms)
+}
+
+func Test_SetCancellationPolicy_NotOwner(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	terms, _ := internal.NewCancellationTerms(internal.PolicyStrict, nil)
+
+	_, err := svc.SetCancellationPolicy(context.Background(), 3, 1, terms)
+
+	assert.Equal(t, internal.ErrUnauthorized, err)
+	repo.AssertNotCalled(t, "SaveCancellationPolicy", mock.Anything)
+}
+
+func Test_SetCancellationPolicy_Success(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("SaveCancellationPolicy", mock.MatchedBy(func(p *internal.CancellationPolicy) bool {
+		return p.RoomID == 1 && p.Terms.Kind == internal.PolicyStrict
+	})).Return(nil)
+	terms, _ := internal.NewCancellationTerms(internal.PolicyStrict, nil)
+
+	policy, err := svc.SetCancellationPolicy(context.Background(), 2, 1, terms)
+
+	assert.NoError(t, err)
+	assert.Equal(t, terms, policy.Terms)
+}
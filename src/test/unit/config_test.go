@@ -0,0 +1,94 @@
+package test
+
+import (
+	"bookem-reservation-service/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func envOf(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func Test_Config_Defaults(t *testing.T) {
+	cfg, err := config.LoadFrom(envOf(nil))
+
+	require.NoError(t, err)
+	assert.Equal(t, "http://room-service:8080/api", cfg.Services.Room.BaseURL)
+	assert.Equal(t, "http://user-service:8080/api", cfg.Services.User.BaseURL)
+	assert.Equal(t, "http://notification-service:8080/api", cfg.Services.Notification.BaseURL)
+	assert.Equal(t, 5*time.Second, cfg.Services.Room.Timeout.Std())
+}
+
+func Test_Config_EnvOverridesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{
+		"services": {
+			"room": {"baseUrl": "https://rooms.example.com/api", "timeout": "2s"},
+			"user": {"baseUrl": "https://users.example.com/api", "timeout": "3s"}
+		},
+		"http": {"maxIdleConnsPerHost": 32}
+	}`), 0600)
+	require.NoError(t, err)
+
+	cfg, err := config.LoadFrom(envOf(map[string]string{
+		"CONFIG_FILE":          path,
+		"USER_SERVICE_URL":     "http://localhost:9001/api",
+		"USER_SERVICE_TIMEOUT": "750ms",
+	}))
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://rooms.example.com/api", cfg.Services.Room.BaseURL)
+	assert.Equal(t, 2*time.Second, cfg.Services.Room.Timeout.Std())
+	assert.Equal(t, "http://localhost:9001/api", cfg.Services.User.BaseURL)
+	assert.Equal(t, 750*time.Millisecond, cfg.Services.User.Timeout.Std())
+	assert.Equal(t, "http://notification-service:8080/api", cfg.Services.Notification.BaseURL)
+	assert.Equal(t, 32, cfg.HTTP.MaxIdleConnsPerHost)
+}
+
+func Test_Config_InvalidValues(t *testing.T) {
+	_, err := config.LoadFrom(envOf(map[string]string{
+		"ROOM_SERVICE_URL":        "room-service:8080",
+		"HTTP_TLS_CERT_FILE":      "/tmp/cert.pem",
+		"HTTP_RETRY_BASE_BACKOFF": "0s",
+		"OUTBOX_BATCH_SIZE":       "0",
+		"IDEMPOTENCY_KEY_TTL":     "30s",
+		"STAY_NO_SHOW_GRACE":      "-1h",
+	}))
+
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "room service: base URL")
+	assert.ErrorContains(t, err, "cert file and key file must be set together")
+	assert.ErrorContains(t, err, "retry backoff must be positive")
+	assert.ErrorContains(t, err, "outbox: batch size")
+	assert.ErrorContains(t, err, "idempotency: processing timeout must be shorter")
+	assert.ErrorContains(t, err, "stay: grace periods can't be negative")
+}
+
+func Test_Config_UnparsableEnv(t *testing.T) {
+	_, err := config.LoadFrom(envOf(map[string]string{
+		"ROOM_SERVICE_TIMEOUT": "soon",
+		"HTTP_MAX_IDLE_CONNS":  "many",
+	}))
+
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "ROOM_SERVICE_TIMEOUT")
+	assert.ErrorContains(t, err, "HTTP_MAX_IDLE_CONNS")
+}
+
+func Test_Config_NewTransportAppliesPoolSettings(t *testing.T) {
+	cfg := config.Default()
+	cfg.HTTP.MaxConnsPerHost = 7
+
+	transport, err := cfg.HTTP.NewTransport()
+
+	require.NoError(t, err)
+	assert.Equal(t, 7, transport.MaxConnsPerHost)
+	assert.Equal(t, 10, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 5*time.Second, cfg.Services.Room.NewHTTPClient(transport).Timeout)
+}
@@ -3,6 +3,7 @@ package test
 import (
 	"bookem-reservation-service/client/notificationclient"
 	"bookem-reservation-service/client/roomclient"
+	"bookem-reservation-service/client/transport"
 	"bookem-reservation-service/internal"
 	"context"
 	"errors"
@@ -11,10 +12,11 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 )
 
 func Test_CreateRequest_Success(t *testing.T) {
-	svc, repo, userClient, roomClient, notifClient := CreateTestRoomService()
+	svc, repo, userClient, roomClient := CreateTestRoomService()
 
 	dto := internal.CreateReservationRequestDTO{
 		RoomID:     1,
@@ -24,55 +26,103 @@ func Test_CreateRequest_Success(t *testing.T) {
 	}
 
 	repo.On("FindPendingRequestsByGuestID", uint(1)).Return([]internal.ReservationRequest{}, nil)
-	repo.On("FindReservationsByRoomIDForDay", mock.Anything, mock.Anything).Return([]internal.Reservation{}, nil)
+	repo.On("HasReservationsInRange", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasHold", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasWaitlistOffer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
 	repo.On("CreateRequest", mock.AnythingOfType("*internal.ReservationRequest")).Return(nil)
+	repo.On("ConvertHolds", uint(1), mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(int64(0), nil)
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	roomClient.On("FindById", context.Background(), uint(1)).Return(DefaultRoom, nil)
 	roomClient.On("FindCurrentAvailabilityListOfRoom", context.Background(), uint(1)).Return(DefaultAvailabilityList, nil)
 	roomClient.On("FindCurrentPricelistOfRoom", context.Background(), uint(1)).Return(DefaultPriceList, nil)
 	roomClient.On("QueryForReservation", context.Background(), mock.Anything, mock.Anything).Return(DefaultReservationQueryResponse, nil)
-	notifClient.On("CreateNotification", mock.Anything, mock.Anything, mock.Anything).
-		Return(&notificationclient.NotificationDTO{}, nil)
+	ExpectNotification(repo, notificationclient.ReservationRequested, 2)
 
 	auth := internal.AuthContext{CallerID: 1, JWT: "token"}
-	req, err := svc.CreateRequest(context.Background(), auth, dto)
+	req, _, err := svc.CreateRequest(context.Background(), auth, dto)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, req)
 	repo.AssertNumberOfCalls(t, "CreateRequest", 1)
+	repo.AssertNumberOfCalls(t, "EnqueueNotification", 1)
+}
+
+func Test_CreateRequest_AutoApproveReturnsReservation(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	dto := internal.CreateReservationRequestDTO{
+		RoomID:     1,
+		DateFrom:   time.Now().AddDate(0, 0, 1),
+		DateTo:     time.Now().AddDate(0, 0, 3),
+		GuestCount: 2,
+	}
+	room := *DefaultRoom
+	room.AutoApprove = true
+
+	repo.On("FindPendingRequestsByGuestID", uint(1)).Return([]internal.ReservationRequest{}, nil)
+	repo.On("HasReservationsInRange", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasHold", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasWaitlistOffer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("CreateRequest", mock.AnythingOfType("*internal.ReservationRequest")).Return(nil)
+	repo.On("ConvertHolds", uint(1), mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(int64(0), nil)
+	repo.On("FindRequestByIDForUpdate", mock.Anything).Return(&internal.ReservationRequest{Status: internal.Pending}, nil)
+	repo.On("FindCancellationPolicy", uint(1)).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("CreateReservation", mock.AnythingOfType("*internal.Reservation")).Return(nil)
+	repo.On("RejectPendingRequestsInRange", mock.Anything, mock.Anything, mock.Anything).Return([]internal.ReservationRequest{}, nil)
+	repo.On("SetRequestStatus", mock.Anything, internal.Accepted).Return(nil)
+
+	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
+	roomClient.On("FindById", context.Background(), uint(1)).Return(&room, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", context.Background(), uint(1)).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", context.Background(), uint(1)).Return(DefaultPriceList, nil)
+	roomClient.On("QueryForReservation", context.Background(), mock.Anything, mock.Anything).Return(DefaultReservationQueryResponse, nil)
+	ExpectNotification(repo, notificationclient.ReservationRequested, room.HostID)
+	ExpectNotification(repo, notificationclient.ReservationAccepted, 1)
+
+	auth := internal.AuthContext{CallerID: 1, JWT: "token"}
+	req, res, err := svc.CreateRequest(context.Background(), auth, dto)
+
+	assert.NoError(t, err)
+	assert.Equal(t, internal.Accepted, req.Status)
+	if assert.NotNil(t, res) {
+		assert.Equal(t, uint(1), res.GuestID)
+		assert.Equal(t, dto.DateFrom, res.DateFrom)
+	}
 }
 
 func Test_CreateRequest_Unauthenticated(t *testing.T) {
-	svc, _, userClient, _, _ := CreateTestRoomService()
+	svc, _, userClient, _ := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(nil, errors.New("not found"))
 
 	auth := internal.AuthContext{CallerID: 1, JWT: "token"}
 	dto := internal.CreateReservationRequestDTO{RoomID: 1}
 
-	req, err := svc.CreateRequest(context.Background(), auth, dto)
+	req, _, err := svc.CreateRequest(context.Background(), auth, dto)
 
 	assert.ErrorIs(t, err, internal.ErrUnauthenticated)
 	assert.Nil(t, req)
 }
 
 func Test_CreateRequest_UnauthorizedRole(t *testing.T) {
-	svc, _, userClient, _, _ := CreateTestRoomService()
+	svc, _, userClient, _ := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Host, nil)
 
 	auth := internal.AuthContext{CallerID: 1, JWT: "token"}
 	dto := internal.CreateReservationRequestDTO{RoomID: 1}
 
-	req, err := svc.CreateRequest(context.Background(), auth, dto)
+	req, _, err := svc.CreateRequest(context.Background(), auth, dto)
 
 	assert.ErrorIs(t, err, internal.ErrUnauthorized)
 	assert.Nil(t, req)
 }
 
 func Test_CreateRequest_RoomNotFound(t *testing.T) {
-	svc, _, userClient, roomClient, _ := CreateTestRoomService()
+	svc, _, userClient, roomClient := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	roomClient.On("FindById", context.Background(), uint(1)).Return(nil, errors.New("not found"))
@@ -80,14 +130,30 @@ func Test_CreateRequest_RoomNotFound(t *testing.T) {
 	auth := internal.AuthContext{CallerID: 1, JWT: "token"}
 	dto := internal.CreateReservationRequestDTO{RoomID: 1}
 
-	req, err := svc.CreateRequest(context.Background(), auth, dto)
+	req, _, err := svc.CreateRequest(context.Background(), auth, dto)
 
 	assert.ErrorContains(t, err, "room")
 	assert.Nil(t, req)
 }
 
+func Test_CreateRequest_RoomServiceUnavailable(t *testing.T) {
+	svc, _, userClient, roomClient := CreateTestRoomService()
+
+	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
+	roomClient.On("FindById", context.Background(), uint(1)).
+		Return(nil, &transport.Error{Upstream: "room-service", Kind: transport.ErrUnavailable})
+
+	auth := internal.AuthContext{CallerID: 1, JWT: "token"}
+	dto := internal.CreateReservationRequestDTO{RoomID: 1}
+
+	req, _, err := svc.CreateRequest(context.Background(), auth, dto)
+
+	assert.ErrorIs(t, err, internal.ErrServiceUnavailable)
+	assert.Nil(t, req)
+}
+
 func Test_CreateRequest_AvailabilityListNotFound(t *testing.T) {
-	svc, _, userClient, roomClient, _ := CreateTestRoomService()
+	svc, _, userClient, roomClient := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	roomClient.On("FindById", context.Background(), uint(1)).Return(DefaultRoom, nil)
@@ -96,14 +162,14 @@ func Test_CreateRequest_AvailabilityListNotFound(t *testing.T) {
 	auth := internal.AuthContext{CallerID: 1, JWT: "token"}
 	dto := internal.CreateReservationRequestDTO{RoomID: 1}
 
-	req, err := svc.CreateRequest(context.Background(), auth, dto)
+	req, _, err := svc.CreateRequest(context.Background(), auth, dto)
 
 	assert.ErrorContains(t, err, "room availability list")
 	assert.Nil(t, req)
 }
 
 func Test_CreateRequest_PriceListNotFound(t *testing.T) {
-	svc, _, userClient, roomClient, _ := CreateTestRoomService()
+	svc, _, userClient, roomClient := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	roomClient.On("FindById", context.Background(), uint(1)).Return(DefaultRoom, nil)
@@ -113,14 +179,14 @@ func Test_CreateRequest_PriceListNotFound(t *testing.T) {
 	auth := internal.AuthContext{CallerID: 1, JWT: "token"}
 	dto := internal.CreateReservationRequestDTO{RoomID: 1}
 
-	req, err := svc.CreateRequest(context.Background(), auth, dto)
+	req, _, err := svc.CreateRequest(context.Background(), auth, dto)
 
 	assert.ErrorContains(t, err, "room price list")
 	assert.Nil(t, req)
 }
 
 func Test_CreateRequest_RoomNotAvailable(t *testing.T) {
-	svc, _, userClient, roomClient, _ := CreateTestRoomService()
+	svc, _, userClient, roomClient := CreateTestRoomService()
 
 	unavailable := &roomclient.RoomReservationQueryResponseDTO{Available: false, TotalCost: 0}
 
@@ -138,14 +204,14 @@ func Test_CreateRequest_RoomNotAvailable(t *testing.T) {
 		GuestCount: 2,
 	}
 
-	req, err := svc.CreateRequest(context.Background(), auth, dto)
+	req, _, err := svc.CreateRequest(context.Background(), auth, dto)
 
 	assert.ErrorIs(t, err, internal.ErrBadRequest)
 	assert.Nil(t, req)
 }
 
 func Test_CreateRequest_InvalidGuestCount(t *testing.T) {
-	svc, _, userClient, roomClient, _ := CreateTestRoomService()
+	svc, _, userClient, roomClient := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	roomClient.On("FindById", context.Background(), uint(1)).Return(DefaultRoom, nil)
@@ -161,14 +227,14 @@ func Test_CreateRequest_InvalidGuestCount(t *testing.T) {
 		GuestCount: 0,
 	}
 
-	req, err := svc.CreateRequest(context.Background(), auth, dto)
+	req, _, err := svc.CreateRequest(context.Background(), auth, dto)
 
 	assert.ErrorContains(t, err, "guest count")
 	assert.Nil(t, req)
 }
 
 func Test_CreateRequest_ReversedDates(t *testing.T) {
-	svc, _, userClient, roomClient, _ := CreateTestRoomService()
+	svc, _, userClient, roomClient := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	roomClient.On("FindById", context.Background(), uint(1)).Return(DefaultRoom, nil)
@@ -184,14 +250,14 @@ func Test_CreateRequest_ReversedDates(t *testing.T) {
 		GuestCount: 2,
 	}
 
-	req, err := svc.CreateRequest(context.Background(), auth, dto)
+	req, _, err := svc.CreateRequest(context.Background(), auth, dto)
 
 	assert.ErrorContains(t, err, "dates are reversed")
 	assert.Nil(t, req)
 }
 
 func Test_CreateRequest_ConflictDueToExistingRequestForUserInThatDateRange(t *testing.T) {
-	svc, repo, userClient, roomClient, notifClient := CreateTestRoomService()
+	svc, repo, userClient, roomClient := CreateTestRoomService()
 
 	existing := []internal.ReservationRequest{
 		{
@@ -201,16 +267,19 @@ func Test_CreateRequest_ConflictDueToExistingRequestForUserInThatDateRange(t *te
 		},
 	}
 	repo.On("FindPendingRequestsByGuestID", uint(1)).Return(existing, nil)
-	repo.On("FindReservationsByRoomIDForDay", mock.Anything, mock.Anything).Return([]internal.Reservation{}, nil)
+	repo.On("HasReservationsInRange", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasHold", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasWaitlistOffer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
 	repo.On("CreateRequest", mock.AnythingOfType("*internal.ReservationRequest")).Return(nil)
+	repo.On("ConvertHolds", uint(1), mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(int64(0), nil)
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	roomClient.On("FindById", context.Background(), uint(1)).Return(DefaultRoom, nil)
 	roomClient.On("FindCurrentAvailabilityListOfRoom", context.Background(), uint(1)).Return(DefaultAvailabilityList, nil)
 	roomClient.On("FindCurrentPricelistOfRoom", context.Background(), uint(1)).Return(DefaultPriceList, nil)
 	roomClient.On("QueryForReservation", context.Background(), mock.Anything, mock.Anything).Return(DefaultReservationQueryResponse, nil)
-	notifClient.On("CreateNotification", mock.Anything, mock.Anything, mock.Anything).
-		Return(&notificationclient.NotificationDTO{}, nil)
+	ExpectNotification(repo, notificationclient.ReservationRequested, 2)
 
 	auth := internal.AuthContext{CallerID: 1, JWT: "token"}
 
@@ -223,7 +292,7 @@ func Test_CreateRequest_ConflictDueToExistingRequestForUserInThatDateRange(t *te
 			GuestCount: 2,
 		}
 
-		req, err := svc.CreateRequest(context.Background(), auth, dto)
+		req, _, err := svc.CreateRequest(context.Background(), auth, dto)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, req)
@@ -238,7 +307,7 @@ func Test_CreateRequest_ConflictDueToExistingRequestForUserInThatDateRange(t *te
 			GuestCount: 2,
 		}
 
-		req, err := svc.CreateRequest(context.Background(), auth, dto)
+		req, _, err := svc.CreateRequest(context.Background(), auth, dto)
 
 		assert.ErrorIs(t, err, internal.ErrConflict)
 		assert.Nil(t, req)
@@ -246,7 +315,7 @@ func Test_CreateRequest_ConflictDueToExistingRequestForUserInThatDateRange(t *te
 }
 
 func Test_CreateRequest_ConflictDueToReservationOverlap(t *testing.T) {
-	svc, repo, userClient, roomClient, _ := CreateTestRoomService()
+	svc, repo, userClient, roomClient := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	roomClient.On("FindById", context.Background(), uint(1)).Return(DefaultRoom, nil)
@@ -255,7 +324,7 @@ func Test_CreateRequest_ConflictDueToReservationOverlap(t *testing.T) {
 	roomClient.On("QueryForReservation", context.Background(), mock.Anything, mock.Anything).Return(DefaultReservationQueryResponse, nil)
 
 	repo.On("FindPendingRequestsByGuestID", uint(1)).Return([]internal.ReservationRequest{}, nil)
-	repo.On("FindReservationsByRoomIDForDay", mock.Anything, mock.Anything).Return([]internal.Reservation{{ID: 99}}, nil)
+	repo.On("HasReservationsInRange", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
 
 	auth := internal.AuthContext{CallerID: 1, JWT: "token"}
 	dto := internal.CreateReservationRequestDTO{
@@ -265,14 +334,14 @@ func Test_CreateRequest_ConflictDueToReservationOverlap(t *testing.T) {
 		GuestCount: 2,
 	}
 
-	req, err := svc.CreateRequest(context.Background(), auth, dto)
+	req, _, err := svc.CreateRequest(context.Background(), auth, dto)
 
 	assert.ErrorIs(t, err, internal.ErrConflict)
 	assert.Nil(t, req)
 }
 
 func Test_CreateRequest_CreateFails(t *testing.T) {
-	svc, repo, userClient, roomClient, _ := CreateTestRoomService()
+	svc, repo, userClient, roomClient := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	roomClient.On("FindById", context.Background(), uint(1)).Return(DefaultRoom, nil)
@@ -281,7 +350,10 @@ func Test_CreateRequest_CreateFails(t *testing.T) {
 	roomClient.On("QueryForReservation", context.Background(), mock.Anything, mock.Anything).Return(DefaultReservationQueryResponse, nil)
 
 	repo.On("FindPendingRequestsByGuestID", uint(1)).Return([]internal.ReservationRequest{}, nil)
-	repo.On("FindReservationsByRoomIDForDay", mock.Anything, mock.Anything).Return([]internal.Reservation{}, nil)
+	repo.On("HasReservationsInRange", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasHold", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasWaitlistOffer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
 	repo.On("CreateRequest", mock.AnythingOfType("*internal.ReservationRequest")).Return(errors.New("db error"))
 
 	auth := internal.AuthContext{CallerID: 1, JWT: "token"}
@@ -292,14 +364,14 @@ func Test_CreateRequest_CreateFails(t *testing.T) {
 		GuestCount: 2,
 	}
 
-	req, err := svc.CreateRequest(context.Background(), auth, dto)
+	req, _, err := svc.CreateRequest(context.Background(), auth, dto)
 
 	assert.ErrorContains(t, err, "db error")
 	assert.Nil(t, req)
 }
 
 func Test_CreateRequest_DeletedAccount(t *testing.T) {
-	svc, _, userClient, _, _ := CreateTestRoomService()
+	svc, _, userClient, _ := CreateTestRoomService()
 
 	user := DefaultUser_Guest
 	user.Deleted = true
@@ -309,7 +381,7 @@ func Test_CreateRequest_DeletedAccount(t *testing.T) {
 	auth := internal.AuthContext{CallerID: 1, JWT: "token"}
 	dto := internal.CreateReservationRequestDTO{RoomID: 1}
 
-	req, err := svc.CreateRequest(context.Background(), auth, dto)
+	req, _, err := svc.CreateRequest(context.Background(), auth, dto)
 
 	assert.ErrorIs(t, err, internal.ErrUnauthorized)
 	assert.Nil(t, req)
@@ -7,24 +7,31 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func Test_DeleteRequest_Success(t *testing.T) {
-	svc, repo, userClient, _, _ := CreateTestRoomService()
+	svc, repo, userClient, _ := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	repo.On("FindPendingRequestsByGuestID", uint(1)).Return([]internal.ReservationRequest{
 		{ID: 10, GuestID: 1, RoomID: 1, Status: internal.Pending},
 	}, nil)
-	repo.On("DeleteRequest", uint(10)).Return(nil)
+	repo.On("FindRequestByIDForUpdate", uint(10)).Return(&internal.ReservationRequest{ID: 10, Status: internal.Pending}, nil)
+	repo.On("WithdrawRequest", uint(10), mock.Anything).Return(nil)
 
 	err := svc.DeleteRequest(context.Background(), 1, 10)
 
 	assert.NoError(t, err)
+	if assert.Len(t, repo.Transitions, 1) {
+		assert.Equal(t, "pending", repo.Transitions[0].FromStatus)
+		assert.Equal(t, "withdrawn", repo.Transitions[0].ToStatus)
+		assert.Equal(t, uint(1), repo.Transitions[0].ActorID)
+	}
 }
 
 func Test_DeleteRequest_UserNotFound(t *testing.T) {
-	svc, _, userClient, _, _ := CreateTestRoomService()
+	svc, _, userClient, _ := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(nil, errors.New("not found"))
 
@@ -34,7 +41,7 @@ func Test_DeleteRequest_UserNotFound(t *testing.T) {
 }
 
 func Test_DeleteRequest_UnauthorizedRole(t *testing.T) {
-	svc, _, userClient, _, _ := CreateTestRoomService()
+	svc, _, userClient, _ := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Host, nil)
 
@@ -44,7 +51,7 @@ func Test_DeleteRequest_UnauthorizedRole(t *testing.T) {
 }
 
 func Test_DeleteRequest_RequestNotFound(t *testing.T) {
-	svc, repo, userClient, _, _ := CreateTestRoomService()
+	svc, repo, userClient, _ := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	repo.On("FindPendingRequestsByGuestID", uint(1)).Return([]internal.ReservationRequest{
@@ -57,7 +64,7 @@ func Test_DeleteRequest_RequestNotFound(t *testing.T) {
 }
 
 func Test_DeleteRequest_RequestAccepted(t *testing.T) {
-	svc, repo, userClient, _, _ := CreateTestRoomService()
+	svc, repo, userClient, _ := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	repo.On("FindPendingRequestsByGuestID", uint(1)).Return([]internal.ReservationRequest{
@@ -66,11 +73,11 @@ func Test_DeleteRequest_RequestAccepted(t *testing.T) {
 
 	err := svc.DeleteRequest(context.Background(), 1, 1)
 
-	assert.ErrorContains(t, err, "cannot cancel a handled request")
+	AssertTransitionError(t, err, "accepted", "withdrawn")
 }
 
 func Test_DeleteRequest_RequestRejected(t *testing.T) {
-	svc, repo, userClient, _, _ := CreateTestRoomService()
+	svc, repo, userClient, _ := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 	repo.On("FindPendingRequestsByGuestID", uint(1)).Return([]internal.ReservationRequest{
@@ -79,5 +86,5 @@ func Test_DeleteRequest_RequestRejected(t *testing.T) {
 
 	err := svc.DeleteRequest(context.Background(), 1, 1)
 
-	assert.ErrorContains(t, err, "cannot cancel a handled request")
+	AssertTransitionError(t, err, "rejected", "withdrawn")
 }
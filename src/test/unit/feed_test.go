@@ -0,0 +1,168 @@
+package test
+
+import (
+	"bookem-reservation-service/client/roomclient"
+	"bookem-reservation-service/internal"
+	"bookem-reservation-service/util"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func Test_Feed_ICS(t *testing.T) {
+	updated := time.Date(2026, 6, 1, 12, 30, 0, 0, time.UTC)
+	feed := &internal.Feed{
+		Name: "Sea, sun; and sand",
+		Reservations: []internal.Reservation{
+			{ID: 40, RoomID: 1, DateFrom: july(2), DateTo: july(4), GuestCount: 2, UpdatedAt: updated},
+			{ID: 41, RoomID: 9, DateFrom: july(10), DateTo: july(10), GuestCount: 1, UpdatedAt: updated},
+		},
+		Rooms: map[uint]roomclient.RoomDTO{1: {ID: 1, Name: strings.Repeat("Cosy flat ", 10) + "by the sea"}},
+	}
+
+	ics := string(feed.ICS(july(1)))
+
+	assert.True(t, strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(ics, "END:VCALENDAR\r\n"))
+	assert.Contains(t, ics, "X-WR-CALNAME:Sea\\, sun\\; and sand\r\n")
+	assert.Equal(t, 2, strings.Count(ics, "BEGIN:VEVENT"))
+
+	assert.Contains(t, ics, "UID:reservation-40@bookem\r\n")
+	assert.Contains(t, ics, "DTSTART;VALUE=DATE:20260702\r\nDTEND;VALUE=DATE:20260705\r\n")
+	assert.Contains(t, ics, "LAST-MODIFIED:20260601T123000Z\r\n")
+	assert.Contains(t, ics, "DTSTAMP:20260701T000000Z\r\n")
+
+	assert.Contains(t, ics, "UID:reservation-41@bookem\r\n")
+	assert.Contains(t, ics, "SUMMARY:Booked: room 9\r\n")
+	assert.Contains(t, ics, "DESCRIPTION:Reservation 41\\, guests: 1\r\n")
+
+	for _, line := range strings.Split(ics, "\r\n") {
+		assert.LessOrEqual(t, len(line), 75, line)
+	}
+	unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+	assert.Contains(t, unfolded, "SUMMARY:Booked: "+strings.Repeat("Cosy flat ", 10)+"by the sea\r\n")
+}
+
+func Test_Feed_ICS_UIDStableAcrossUpdates(t *testing.T) {
+	res := internal.Reservation{ID: 40, RoomID: 1, DateFrom: july(2), DateTo: july(4)}
+	before := (&internal.Feed{Reservations: []internal.Reservation{res}}).ICS(july(1))
+
+	res.DateTo = july(6)
+	res.UpdatedAt = july(1)
+	after := (&internal.Feed{Reservations: []internal.Reservation{res}}).ICS(july(2))
+
+	assert.Contains(t, string(before), "UID:reservation-40@bookem\r\n")
+	assert.Contains(t, string(after), "UID:reservation-40@bookem\r\n")
+	assert.Contains(t, string(after), "DTEND;VALUE=DATE:20260707\r\n")
+}
+
+func Test_CreateFeedToken_StoresOnlyHash(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	var stored *internal.FeedToken
+	repo.On("CreateFeedToken", mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*internal.FeedToken)
+	}).Return(nil)
+
+	feedToken, token, err := svc.CreateFeedToken(context.Background(), internal.Actor{ID: 1, Role: util.Guest}, internal.FeedGuest, 5)
+
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, len(token), 43)
+	sum := sha256.Sum256([]byte(token))
+	assert.Equal(t, hex.EncodeToString(sum[:]), stored.TokenHash)
+	assert.NotContains(t, stored.TokenHash, token)
+	assert.Equal(t, uint(0), feedToken.RoomID, "only room feeds have a room")
+
+	_, other, _ := svc.CreateFeedToken(context.Background(), internal.Actor{ID: 1, Role: util.Guest}, internal.FeedGuest, 0)
+	assert.NotEqual(t, token, other)
+}
+
+func Test_CreateFeedToken_NotAllowed(t *testing.T) {
+	tests := map[string]struct {
+		caller internal.Actor
+		kind   internal.FeedKind
+		code   int
+	}{
+		"host wants guest feed": {caller: internal.Actor{ID: 2, Role: util.Host}, kind: internal.FeedGuest, code: 401},
+		"guest wants host feed": {caller: internal.Actor{ID: 1, Role: util.Guest}, kind: internal.FeedHost, code: 401},
+		"someone else's room":   {caller: internal.Actor{ID: 6, Role: util.Host}, kind: internal.FeedRoom, code: 401},
+		"unknown kind":          {caller: internal.Actor{ID: 2, Role: util.Host}, kind: "month", code: 400},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			svc, repo, _, roomClient := CreateTestRoomService()
+			roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+
+			_, _, err := svc.CreateFeedToken(context.Background(), tt.caller, tt.kind, 1)
+
+			var apiErr *internal.APIError
+			if assert.ErrorAs(t, err, &apiErr) {
+				assert.Equal(t, tt.code, apiErr.Code)
+			}
+			repo.AssertNotCalled(t, "CreateFeedToken", mock.Anything)
+		})
+	}
+}
+
+func Test_GetFeed_UnknownToken(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	repo.On("FindFeedTokenByHash", mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+
+	_, err := svc.GetFeed(context.Background(), "nope")
+
+	assert.Equal(t, internal.ErrFeedNotFound, err)
+}
+
+func Test_GetFeed_Guest(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	repo.On("FindFeedTokenByHash", mock.Anything).Return(&internal.FeedToken{ID: 3, UserID: 1, Kind: internal.FeedGuest}, nil)
+	repo.On("FindReservations", mock.MatchedBy(func(f internal.ReservationFilter) bool {
+		return f.GuestID == 1 && f.ExcludeCancelled && f.RoomIDs == nil && time.Since(f.EndsAfter) < time.Minute
+	})).Return([]internal.Reservation{
+		{ID: 40, RoomID: 1, DateFrom: july(2), DateTo: july(4)},
+		{ID: 41, RoomID: 1, DateFrom: july(8), DateTo: july(9)},
+	}, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil).Once()
+
+	feed, err := svc.GetFeed(context.Background(), "token")
+
+	assert.NoError(t, err)
+	assert.True(t, feed.ForGuest)
+	assert.Len(t, feed.Reservations, 2)
+	assert.Contains(t, string(feed.ICS(time.Now())), "SUMMARY:Stay at Test Room\r\n")
+	roomClient.AssertNumberOfCalls(t, "FindById", 1)
+}
+
+func Test_GetFeed_RoomNoLongerOwned(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	repo.On("FindFeedTokenByHash", mock.Anything).Return(&internal.FeedToken{ID: 3, UserID: 6, Kind: internal.FeedRoom, RoomID: 1}, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+
+	_, err := svc.GetFeed(context.Background(), "token")
+
+	assert.Equal(t, internal.ErrFeedNotFound, err)
+	repo.AssertNotCalled(t, "FindReservations", mock.Anything)
+}
+
+func Test_RevokeFeedToken_NotFound(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	repo.On("RevokeFeedToken", uint(3), uint(1), mock.Anything).Return(gorm.ErrRecordNotFound)
+
+	err := svc.RevokeFeedToken(context.Background(), 1, 3)
+
+	var apiErr *internal.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, 404, apiErr.Code)
+	}
+}
@@ -7,10 +7,11 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func Test_FindPendingRequestsByGuest_Success(t *testing.T) {
-	svc, repo, userClient, roomClient, _ := CreateTestRoomService()
+	svc, repo, userClient, roomClient := CreateTestRoomService()
 
 	room1Val := *DefaultRoom
 	room1 := &room1Val
@@ -30,33 +31,61 @@ func Test_FindPendingRequestsByGuest_Success(t *testing.T) {
 		{ID: 1, RoomID: room1.ID, GuestID: 1, Status: internal.Pending},
 		{ID: 2, RoomID: room2.ID, GuestID: 1, Status: internal.Pending},
 	}
-	repo.On("FindPendingRequestsByGuestID", uint(1)).Return(expected, nil)
+	filter := internal.RequestFilter{GuestID: 1, Statuses: []internal.ReservationRequestStatus{internal.Pending}}
+	page := internal.PageRequest{Limit: internal.DefaultPageLimit, Sort: internal.Sort{Key: internal.SortDateFrom}}
+	repo.On("FindRequestsPage", filter, page).Return(&internal.Page[internal.ReservationRequest]{Items: expected, Total: 2}, nil)
 
-	result, err := svc.FindPendingRequestsByGuest(context.Background(), 1)
+	result, err := svc.FindPendingRequestsByGuest(context.Background(), 1, internal.ListQuery{})
 
 	assert.NoError(t, err)
-	assert.Equal(t, 1, len(result))
-	assert.Equal(t, room2.ID, result[0].ID)
-	repo.AssertCalled(t, "FindPendingRequestsByGuestID", uint(1))
+	assert.Equal(t, 1, len(result.Items))
+	assert.Equal(t, room2.ID, result.Items[0].ID)
+	assert.Equal(t, int64(2), result.Total)
+	repo.AssertCalled(t, "FindRequestsPage", filter, page)
+}
+
+func Test_FindPendingRequestsByGuest_Filters(t *testing.T) {
+	svc, repo, userClient, _ := CreateTestRoomService()
+
+	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
+	repo.On("FindRequestsPage", mock.Anything, mock.Anything).
+		Return(&internal.Page[internal.ReservationRequest]{Items: []internal.ReservationRequest{}}, nil)
+
+	query := internal.ListQuery{
+		Limit:    5,
+		Sort:     "-createdAt",
+		Statuses: []internal.ReservationRequestStatus{internal.Accepted, internal.Rejected},
+		RoomID:   7,
+	}
+	_, err := svc.FindPendingRequestsByGuest(context.Background(), 1, query)
+
+	assert.NoError(t, err)
+	filter := repo.Calls[0].Arguments.Get(0).(internal.RequestFilter)
+	page := repo.Calls[0].Arguments.Get(1).(internal.PageRequest)
+	assert.Equal(t, uint(1), filter.GuestID)
+	assert.Equal(t, []uint{7}, filter.RoomIDs)
+	assert.Equal(t, query.Statuses, filter.Statuses)
+	assert.Equal(t, internal.Sort{Key: internal.SortCreatedAt, Desc: true}, page.Sort)
+	assert.Equal(t, 5, page.Limit)
 }
 
 func Test_FindPendingRequestsByGuest_UserNotFound(t *testing.T) {
-	svc, _, userClient, _, _ := CreateTestRoomService()
+	svc, _, userClient, _ := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(nil, errors.New("not found"))
 
-	result, err := svc.FindPendingRequestsByGuest(context.Background(), 1)
+	result, err := svc.FindPendingRequestsByGuest(context.Background(), 1, internal.ListQuery{})
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
 }
 
 func Test_FindPendingRequestsByGuest_UnauthorizedRole(t *testing.T) {
-	svc, _, userClient, _, _ := CreateTestRoomService()
+	svc, _, userClient, _ := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Host, nil)
 
-	result, err := svc.FindPendingRequestsByGuest(context.Background(), 1)
+	result, err := svc.FindPendingRequestsByGuest(context.Background(), 1, internal.ListQuery{})
 
 	assert.ErrorIs(t, err, internal.ErrUnauthorized)
 	assert.Nil(t, result)
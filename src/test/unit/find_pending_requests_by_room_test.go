@@ -7,10 +7,11 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func Test_FindPendingRequestsByRoom_Success(t *testing.T) {
-	svc, repo, userClient, roomClient, _ := CreateTestRoomService()
+	svc, repo, userClient, roomClient := CreateTestRoomService()
 
 	guest1Val := *DefaultUser_Guest
 	guest1 := &guest1Val
@@ -24,56 +25,62 @@ func Test_FindPendingRequestsByRoom_Success(t *testing.T) {
 
 	userClient.On("FindById", context.Background(), uint(2)).Return(DefaultUser_Host, nil).Once()
 	roomClient.On("FindById", context.Background(), uint(1)).Return(DefaultRoom, nil)
-	repo.On("FindPendingRequestsByRoomID", uint(1)).Return([]internal.ReservationRequest{
-		{ID: 1, RoomID: 1, GuestID: guest1.Id, Status: internal.Pending},
-		{ID: 2, RoomID: 1, GuestID: guest2.Id, Status: internal.Pending},
+	filter := internal.RequestFilter{RoomIDs: []uint{1}, Statuses: []internal.ReservationRequestStatus{internal.Pending}}
+	repo.On("FindRequestsPage", filter, mock.Anything).Return(&internal.Page[internal.ReservationRequest]{
+		Items: []internal.ReservationRequest{
+			{ID: 1, RoomID: 1, GuestID: guest1.Id, Status: internal.Pending},
+			{ID: 2, RoomID: 1, GuestID: guest2.Id, Status: internal.Pending},
+		},
+		NextCursor: "next",
+		Total:      3,
 	}, nil)
 	userClient.On("FindById", context.Background(), guest1.Id).Return(guest1, nil).Once()
 	userClient.On("FindById", context.Background(), guest2.Id).Return(guest2, nil).Once()
 
-	result, err := svc.FindPendingRequestsByRoom(context.Background(), 2, 1)
+	result, err := svc.FindPendingRequestsByRoom(context.Background(), 2, 1, internal.ListQuery{})
 
 	assert.NoError(t, err)
-	assert.Equal(t, 1, len(result))
-	assert.Equal(t, guest2.Id, result[0].GuestID)
+	assert.Equal(t, 1, len(result.Items))
+	assert.Equal(t, guest2.Id, result.Items[0].GuestID)
+	assert.Equal(t, "next", result.NextCursor)
 }
 
 func Test_FindPendingRequestsByRoom_UserNotFound(t *testing.T) {
-	svc, _, userClient, _, _ := CreateTestRoomService()
+	svc, _, userClient, _ := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(2)).Return(nil, errors.New("not found"))
 
-	result, err := svc.FindPendingRequestsByRoom(context.Background(), 2, 1)
+	result, err := svc.FindPendingRequestsByRoom(context.Background(), 2, 1, internal.ListQuery{})
 
 	assert.ErrorContains(t, err, "user")
 	assert.Nil(t, result)
 }
 
 func Test_FindPendingRequestsByRoom_UnauthorizedRole(t *testing.T) {
-	svc, _, userClient, _, _ := CreateTestRoomService()
+	svc, _, userClient, _ := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
 
-	result, err := svc.FindPendingRequestsByRoom(context.Background(), 1, 1)
+	result, err := svc.FindPendingRequestsByRoom(context.Background(), 1, 1, internal.ListQuery{})
 
 	assert.ErrorIs(t, err, internal.ErrUnauthorized)
 	assert.Nil(t, result)
 }
 
 func Test_FindPendingRequestsByRoom_RoomNotFound(t *testing.T) {
-	svc, _, userClient, roomClient, _ := CreateTestRoomService()
+	svc, _, userClient, roomClient := CreateTestRoomService()
 
 	userClient.On("FindById", context.Background(), uint(2)).Return(DefaultUser_Host, nil)
 	roomClient.On("FindById", context.Background(), uint(1)).Return(nil, errors.New("not found"))
 
-	result, err := svc.FindPendingRequestsByRoom(context.Background(), 2, 1)
+	result, err := svc.FindPendingRequestsByRoom(context.Background(), 2, 1, internal.ListQuery{})
 
 	assert.ErrorContains(t, err, "room")
 	assert.Nil(t, result)
 }
 
 func Test_FindPendingRequestsByRoom_UnauthorizedOwnership(t *testing.T) {
-	svc, _, userClient, roomClient, _ := CreateTestRoomService()
+	svc, _, userClient, roomClient := CreateTestRoomService()
 
 	otherRoom := *DefaultRoom
 	otherRoom.HostID = 99
@@ -81,8 +88,18 @@ func Test_FindPendingRequestsByRoom_UnauthorizedOwnership(t *testing.T) {
 	userClient.On("FindById", context.Background(), uint(2)).Return(DefaultUser_Host, nil)
 	roomClient.On("FindById", context.Background(), uint(1)).Return(&otherRoom, nil)
 
-	result, err := svc.FindPendingRequestsByRoom(context.Background(), 2, 1)
+	result, err := svc.FindPendingRequestsByRoom(context.Background(), 2, 1, internal.ListQuery{})
 
 	assert.ErrorIs(t, err, internal.ErrUnauthorized)
 	assert.Nil(t, result)
 }
+
+func Test_FindPendingRequestsByRoom_InvalidCursor(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	result, err := svc.FindPendingRequestsByRoom(context.Background(), 2, 1, internal.ListQuery{Cursor: "not a cursor"})
+
+	assert.ErrorContains(t, err, "invalid cursor")
+	assert.Nil(t, result)
+	repo.AssertNotCalled(t, "FindRequestsPage", mock.Anything, mock.Anything)
+}
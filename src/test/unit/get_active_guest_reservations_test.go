@@ -8,92 +8,75 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func Test_GetActiveGuestReservations_UserNotFound(t *testing.T) {
-	svc, mockRepo, mockUserClient, _, _ := CreateTestRoomService()
+	svc, mockRepo, mockUserClient, _ := CreateTestRoomService()
 
 	guest := DefaultUser_Guest
 
 	mockUserClient.On("FindById", context.Background(), guest.Id).Return(nil, errors.New("User is not found"))
 
-	reservations, err := svc.GetActiveGuestReservations(context.Background(), guest.Id)
+	reservations, err := svc.GetActiveGuestReservations(context.Background(), guest.Id, internal.ListQuery{})
 
 	assert.Error(t, err)
 	assert.Nil(t, reservations)
 	mockUserClient.AssertNumberOfCalls(t, "FindById", 1)
 	mockUserClient.AssertExpectations(t)
-	mockRepo.AssertNumberOfCalls(t, "FindReservationsByGuestID", 0)
+	mockRepo.AssertNumberOfCalls(t, "FindReservationsPage", 0)
 	mockRepo.AssertExpectations(t)
 }
 
 func Test_GetActiveGuestReservations_FindReservationsError(t *testing.T) {
-	svc, mockRepo, mockUserClient, _, _ := CreateTestRoomService()
+	svc, mockRepo, mockUserClient, _ := CreateTestRoomService()
 
 	guest := DefaultUser_Guest
-	var reservations []internal.Reservation
 
 	mockUserClient.On("FindById", context.Background(), guest.Id).Return(guest, nil)
-	mockRepo.On("FindReservationsByGuestID", guest.Id).Return(reservations, errors.New("Reservations are not found"))
+	mockRepo.On("FindReservationsPage", mock.Anything, mock.Anything).Return(nil, errors.New("Reservations are not found"))
 
-	reservations, err := svc.GetActiveGuestReservations(context.Background(), guest.Id)
+	reservations, err := svc.GetActiveGuestReservations(context.Background(), guest.Id, internal.ListQuery{})
 
 	assert.Error(t, err)
 	assert.Nil(t, reservations)
 	mockUserClient.AssertNumberOfCalls(t, "FindById", 1)
 	mockUserClient.AssertExpectations(t)
-	mockRepo.AssertNumberOfCalls(t, "FindReservationsByGuestID", 1)
+	mockRepo.AssertNumberOfCalls(t, "FindReservationsPage", 1)
 	mockRepo.AssertExpectations(t)
 }
 
-// This test automatically encapsulates enough of `ExtractActiveReservations`,
-// as its logic is exceptionally simple.
-func Test_GetActiveGuestReservations_SuccessNoneActive(t *testing.T) {
-	svc, mockRepo, mockUserClient, _, _ := CreateTestRoomService()
+func Test_GetActiveGuestReservations_Success(t *testing.T) {
+	svc, mockRepo, mockUserClient, _ := CreateTestRoomService()
 
 	guest := DefaultUser_Guest
-	reservartion := *DefaultReservation
-	reservartion.Cancelled = true
-	reservartion.DateTo = time.Now().Add(24 * time.Hour)
-	reservations := []internal.Reservation{reservartion}
+	reservation := *DefaultReservation
+	reservation.DateTo = time.Now().Add(24 * time.Hour)
+	page := &internal.Page[internal.Reservation]{Items: []internal.Reservation{reservation}, Total: 1}
 
 	mockUserClient.On("FindById", context.Background(), guest.Id).Return(guest, nil)
-	mockRepo.On("FindReservationsByGuestID", guest.Id).Return(reservations, nil)
+	mockRepo.On("FindReservationsPage", mock.Anything, mock.Anything).Return(page, nil)
 
-	reservartionsGot, err := svc.GetActiveGuestReservations(context.Background(), guest.Id)
+	before := time.Now()
+	reservationsGot, err := svc.GetActiveGuestReservations(context.Background(), guest.Id, internal.ListQuery{RoomID: 3})
 
 	assert.NoError(t, err)
-	assert.Nil(t, reservartionsGot)
-	mockUserClient.AssertNumberOfCalls(t, "FindById", 1)
-	mockUserClient.AssertExpectations(t)
-	mockRepo.AssertNumberOfCalls(t, "FindReservationsByGuestID", 1)
+	assert.Equal(t, page, reservationsGot)
+
+	filter := mockRepo.Calls[0].Arguments.Get(0).(internal.ReservationFilter)
+	assert.Equal(t, guest.Id, filter.GuestID)
+	assert.Equal(t, []uint{3}, filter.RoomIDs)
+	assert.True(t, filter.ExcludeCancelled)
+	assert.WithinDuration(t, before, filter.EndsAfter, time.Second)
 	mockRepo.AssertExpectations(t)
 }
 
-// This test automatically encapsulates enough of `ExtractActiveReservations`,
-// as its logic is exceptionally simple.
-func Test_GetActiveGuestReservations_Success(t *testing.T) {
-	svc, mockRepo, mockUserClient, _, _ := CreateTestRoomService()
-
-	guest := DefaultUser_Guest
-	reservartion1 := *DefaultReservation
-	reservartion1.Cancelled = false
-	reservartion1.DateTo = time.Now().Add(24 * time.Hour)
-	reservartion2 := *DefaultReservation
-	reservartion2.Cancelled = true
-	reservartion2.DateTo = time.Now().Add(24 * time.Hour)
-	reservations := []internal.Reservation{reservartion1, reservartion2}
-	expectedReservations := []internal.Reservation{reservartion1}
-
-	mockUserClient.On("FindById", context.Background(), guest.Id).Return(guest, nil)
-	mockRepo.On("FindReservationsByGuestID", guest.Id).Return(reservations, nil)
+func Test_GetActiveGuestReservations_BadSort(t *testing.T) {
+	svc, mockRepo, _, _ := CreateTestRoomService()
 
-	reservartionsGot, err := svc.GetActiveGuestReservations(context.Background(), guest.Id)
+	reservations, err := svc.GetActiveGuestReservations(context.Background(), DefaultUser_Guest.Id, internal.ListQuery{Sort: "createdAt"})
 
-	assert.NoError(t, err)
-	assert.Equal(t, expectedReservations, reservartionsGot)
-	mockUserClient.AssertNumberOfCalls(t, "FindById", 1)
-	mockUserClient.AssertExpectations(t)
-	mockRepo.AssertNumberOfCalls(t, "FindReservationsByGuestID", 1)
-	mockRepo.AssertExpectations(t)
+	assert.ErrorContains(t, err, "cannot sort by createdAt")
+	assert.Nil(t, reservations)
+	mockRepo.AssertNumberOfCalls(t, "FindReservationsPage", 0)
 }
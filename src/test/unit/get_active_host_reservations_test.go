@@ -6,103 +6,95 @@ import (
 	"context"
 	"errors"
 	"testing"
-	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func Test_GetActiveHostReservations_FindRoomsError(t *testing.T) {
-	svc, mockRepo, _, mockRoomClient, _ := CreateTestRoomService()
+	svc, mockRepo, _, mockRoomClient := CreateTestRoomService()
 
 	host := DefaultUser_Host
 
 	mockRoomClient.On("FindByHostId", context.Background(), host.Id).Return(nil, errors.New("rooms are not found"))
 
-	reservations, err := svc.GetActiveHostReservations(context.Background(), host.Id)
+	reservations, err := svc.GetActiveHostReservations(context.Background(), host.Id, internal.ListQuery{})
 
 	assert.Error(t, err)
 	assert.Nil(t, reservations)
 	mockRoomClient.AssertNumberOfCalls(t, "FindByHostId", 1)
 	mockRoomClient.AssertExpectations(t)
-	mockRepo.AssertNumberOfCalls(t, "FindReservationsByRoomID", 0)
+	mockRepo.AssertNumberOfCalls(t, "FindReservationsPage", 0)
 	mockRepo.AssertExpectations(t)
 }
 
 func Test_GetActiveHostReservations_NoRoomsSuccess(t *testing.T) {
-	svc, mockRepo, _, mockRoomClient, _ := CreateTestRoomService()
+	svc, mockRepo, _, mockRoomClient := CreateTestRoomService()
 
 	host := DefaultUser_Host
 
 	mockRoomClient.On("FindByHostId", context.Background(), host.Id).Return(nil, nil)
 
-	reservations, err := svc.GetActiveHostReservations(context.Background(), host.Id)
+	reservations, err := svc.GetActiveHostReservations(context.Background(), host.Id, internal.ListQuery{})
 
 	assert.NoError(t, err)
-	assert.Nil(t, reservations)
+	assert.Empty(t, reservations.Items)
+	assert.Zero(t, reservations.Total)
 	mockRoomClient.AssertNumberOfCalls(t, "FindByHostId", 1)
 	mockRoomClient.AssertExpectations(t)
-	mockRepo.AssertNumberOfCalls(t, "FindReservationsByRoomID", 0)
+	mockRepo.AssertNumberOfCalls(t, "FindReservationsPage", 0)
 	mockRepo.AssertExpectations(t)
 }
 
-func Test_GetActiveHostReservations_NoneRoomHasAnyReservation(t *testing.T) {
-	svc, mockRepo, _, mockRoomClient, _ := CreateTestRoomService()
+func Test_GetActiveHostReservations_ReservationsErr(t *testing.T) {
+	svc, mockRepo, _, mockRoomClient := CreateTestRoomService()
 
 	host := DefaultUser_Host
 	room1 := *DefaultRoom
 	room1.ID = 1
-	room2 := *DefaultRoom
-	room2.ID = 2
-	rooms := []roomclient.RoomDTO{room1, room2}
-	reservartion1 := *DefaultReservation
-	reservartion1.ID = room1.ID
-	reservartion1.Cancelled = true
-	reservations1 := []internal.Reservation{reservartion1}
-	reservartion2 := *DefaultReservation
-	reservartion2.ID = room2.ID
-	reservartion2.Cancelled = true
-	reservations2 := []internal.Reservation{reservartion2}
+	rooms := []roomclient.RoomDTO{room1}
 
 	mockRoomClient.On("FindByHostId", context.Background(), host.Id).Return(rooms, nil)
-	mockRepo.On("FindReservationsByRoomID", room1.ID).Return(reservations1, nil)
-	mockRepo.On("FindReservationsByRoomID", room2.ID).Return(reservations2, nil)
+	mockRepo.On("FindReservationsPage", mock.Anything, mock.Anything).Return(nil, errors.New("reservations are not found"))
 
-	reservartionsGot, err := svc.GetActiveHostReservations(context.Background(), host.Id)
+	reservartionsGot, err := svc.GetActiveHostReservations(context.Background(), host.Id, internal.ListQuery{})
 
-	assert.NoError(t, err)
+	assert.Error(t, err)
 	assert.Nil(t, reservartionsGot)
 	mockRoomClient.AssertNumberOfCalls(t, "FindByHostId", 1)
 	mockRoomClient.AssertExpectations(t)
-	mockRepo.AssertNumberOfCalls(t, "FindReservationsByRoomID", 2)
+	mockRepo.AssertNumberOfCalls(t, "FindReservationsPage", 1)
 	mockRepo.AssertExpectations(t)
 }
 
-func Test_GetActiveHostReservations_ReservationsErr(t *testing.T) {
-	svc, mockRepo, _, mockRoomClient, _ := CreateTestRoomService()
+func Test_GetActiveHostReservations_Success(t *testing.T) {
+	svc, mockRepo, _, mockRoomClient := CreateTestRoomService()
 
 	host := DefaultUser_Host
 	room1 := *DefaultRoom
 	room1.ID = 1
-	rooms := []roomclient.RoomDTO{room1}
-	var nilReservations []internal.Reservation = nil
+	room2 := *DefaultRoom
+	room2.ID = 2
+	rooms := []roomclient.RoomDTO{room1, room2}
+	page := &internal.Page[internal.Reservation]{Items: []internal.Reservation{*DefaultReservation}, Total: 1}
 
 	mockRoomClient.On("FindByHostId", context.Background(), host.Id).Return(rooms, nil)
-	mockRepo.On("FindReservationsByRoomID", room1.ID).Return(nilReservations, errors.New("reservations are not found"))
+	mockRepo.On("FindReservationsPage", mock.Anything, mock.Anything).Return(page, nil)
 
-	reservartionsGot, err := svc.GetActiveHostReservations(context.Background(), host.Id)
+	reservationsGot, err := svc.GetActiveHostReservations(context.Background(), host.Id, internal.ListQuery{})
 
-	assert.Error(t, err)
-	assert.Nil(t, reservartionsGot)
-	mockRoomClient.AssertNumberOfCalls(t, "FindByHostId", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, page, reservationsGot)
+
+	filter := mockRepo.Calls[0].Arguments.Get(0).(internal.ReservationFilter)
+	assert.Equal(t, []uint{1, 2}, filter.RoomIDs)
+	assert.True(t, filter.ExcludeCancelled)
 	mockRoomClient.AssertExpectations(t)
-	mockRepo.AssertNumberOfCalls(t, "FindReservationsByRoomID", 1)
 	mockRepo.AssertExpectations(t)
 }
 
-// This test automatically covers enough of `ExtractActiveReservations`,
-// as its logic is exceptionally simple.
-func Test_GetActiveHostReservations_Success(t *testing.T) {
-	svc, mockRepo, _, mockRoomClient, _ := CreateTestRoomService()
+func Test_GetActiveHostReservations_FilterByRoom(t *testing.T) {
+	svc, mockRepo, _, mockRoomClient := CreateTestRoomService()
 
 	host := DefaultUser_Host
 	room1 := *DefaultRoom
@@ -110,27 +102,30 @@ func Test_GetActiveHostReservations_Success(t *testing.T) {
 	room2 := *DefaultRoom
 	room2.ID = 2
 	rooms := []roomclient.RoomDTO{room1, room2}
-	reservation1 := *DefaultReservation
-	reservation1.RoomID = room1.ID
-	reservation1.Cancelled = false
-	reservation1.DateTo = time.Now().Add(24 * time.Hour)
-	reservations1 := []internal.Reservation{reservation1}
-	reservation2 := *DefaultReservation
-	reservation2.RoomID = room2.ID
-	reservation2.Cancelled = true
-	reservation2.DateTo = time.Now().Add(24 * time.Hour)
-	reservations2 := []internal.Reservation{reservation2}
 
 	mockRoomClient.On("FindByHostId", context.Background(), host.Id).Return(rooms, nil)
-	mockRepo.On("FindReservationsByRoomID", room1.ID).Return(reservations1, nil)
-	mockRepo.On("FindReservationsByRoomID", room2.ID).Return(reservations2, nil)
+	mockRepo.On("FindReservationsPage", mock.Anything, mock.Anything).
+		Return(&internal.Page[internal.Reservation]{Items: []internal.Reservation{}}, nil)
 
-	reservationsGot, err := svc.GetActiveHostReservations(context.Background(), host.Id)
+	_, err := svc.GetActiveHostReservations(context.Background(), host.Id, internal.ListQuery{RoomID: 2})
 
 	assert.NoError(t, err)
-	assert.Equal(t, reservations1, reservationsGot)
-	mockRoomClient.AssertNumberOfCalls(t, "FindByHostId", 1)
-	mockRoomClient.AssertExpectations(t)
-	mockRepo.AssertNumberOfCalls(t, "FindReservationsByRoomID", 2)
-	mockRepo.AssertExpectations(t)
+	filter := mockRepo.Calls[0].Arguments.Get(0).(internal.ReservationFilter)
+	assert.Equal(t, []uint{2}, filter.RoomIDs)
+}
+
+func Test_GetActiveHostReservations_FilterByRoomOfAnotherHost(t *testing.T) {
+	svc, mockRepo, _, mockRoomClient := CreateTestRoomService()
+
+	host := DefaultUser_Host
+	room1 := *DefaultRoom
+	room1.ID = 1
+
+	mockRoomClient.On("FindByHostId", context.Background(), host.Id).Return([]roomclient.RoomDTO{room1}, nil)
+
+	reservations, err := svc.GetActiveHostReservations(context.Background(), host.Id, internal.ListQuery{RoomID: 9})
+
+	assert.ErrorIs(t, err, internal.ErrUnauthorized)
+	assert.Nil(t, reservations)
+	mockRepo.AssertNumberOfCalls(t, "FindReservationsPage", 0)
 }
@@ -8,10 +8,11 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func Test_GetPastReservationsByGuest_Success(t *testing.T) {
-	svc, repo, _, roomClient, _ := CreateTestRoomService()
+	svc, repo, _, roomClient := CreateTestRoomService()
 
 	room1Val := *DefaultRoom
 	room1 := &room1Val
@@ -51,50 +52,50 @@ func Test_GetPastReservationsByGuest_Success(t *testing.T) {
 	roomClient.On("FindById", context.Background(), room1.ID).Return(room1, nil).Once()
 	roomClient.On("FindById", context.Background(), room2.ID).Return(room2, nil).Once()
 
-	repo.On("GetAllPastReservationsByGuest", guestID, before).
-		Return(reservations, nil)
+	filter := internal.ReservationFilter{GuestID: guestID, ExcludeCancelled: true, EndsBy: before}
+	page := internal.PageRequest{Limit: internal.DefaultPageLimit, Sort: internal.Sort{Key: internal.SortDateTo, Desc: true}}
+	repo.On("FindReservationsPage", filter, page).
+		Return(&internal.Page[internal.Reservation]{Items: reservations, Total: 2}, nil)
 
-	out, err := svc.GetPastReservationsByGuest(context.Background(), guestID, before)
+	out, err := svc.GetPastReservationsByGuest(context.Background(), guestID, before, internal.ListQuery{})
 
 	assert.NoError(t, err)
-	assert.Len(t, out, 2)
+	assert.Len(t, out.Items, 2)
 
-	assert.Equal(t, reservations[0].ID, out[0].ID)
-	assert.Equal(t, reservations[1].RoomID, out[1].RoomID)
-	assert.Equal(t, reservations[1].Cost, out[1].Cost)
+	assert.Equal(t, reservations[0].ID, out.Items[0].ID)
+	assert.Equal(t, reservations[1].RoomID, out.Items[1].RoomID)
+	assert.Equal(t, reservations[1].Cost, out.Items[1].Cost)
 
-	repo.AssertCalled(t, "GetAllPastReservationsByGuest", guestID, before)
+	repo.AssertCalled(t, "FindReservationsPage", filter, page)
 }
 
 func Test_GetPastReservationsByGuest_RepoError(t *testing.T) {
-	svc, repo, _, _, _ := CreateTestRoomService()
+	svc, repo, _, _ := CreateTestRoomService()
 
 	before := time.Now().UTC()
 	guestID := uint(5)
 
-	repo.On("GetAllPastReservationsByGuest", guestID, before).
-		Return([]internal.Reservation{}, errors.New("db failure"))
+	repo.On("FindReservationsPage", mock.Anything, mock.Anything).
+		Return(nil, errors.New("db failure"))
 
-	out, err := svc.GetPastReservationsByGuest(context.Background(), guestID, before)
+	out, err := svc.GetPastReservationsByGuest(context.Background(), guestID, before, internal.ListQuery{})
 
 	assert.Error(t, err)
 	assert.Nil(t, out)
 	assert.Contains(t, err.Error(), "db failure")
-	repo.AssertCalled(t, "GetAllPastReservationsByGuest", guestID, before)
 }
 
 func Test_GetPastReservationsByGuest_EmptyList(t *testing.T) {
-	svc, repo, _, _, _ := CreateTestRoomService()
+	svc, repo, _, _ := CreateTestRoomService()
 
 	before := time.Now().UTC()
 	guestID := uint(3)
 
-	repo.On("GetAllPastReservationsByGuest", guestID, before).
-		Return([]internal.Reservation{}, nil)
+	repo.On("FindReservationsPage", mock.Anything, mock.Anything).
+		Return(&internal.Page[internal.Reservation]{Items: []internal.Reservation{}}, nil)
 
-	out, err := svc.GetPastReservationsByGuest(context.Background(), guestID, before)
+	out, err := svc.GetPastReservationsByGuest(context.Background(), guestID, before, internal.ListQuery{})
 
 	assert.NoError(t, err)
-	assert.Len(t, out, 0)
-	repo.AssertCalled(t, "GetAllPastReservationsByGuest", guestID, before)
+	assert.Len(t, out.Items, 0)
 }
@@ -0,0 +1,264 @@
+package test
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/internal"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func groupDTO(roomIDs ...uint) internal.CreateGroupRequestDTO {
+	from := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 20)
+	var dto internal.CreateGroupRequestDTO
+	for _, id := range roomIDs {
+		dto.Requests = append(dto.Requests, internal.CreateReservationRequestDTO{RoomID: id, DateFrom: from, DateTo: from.AddDate(0, 0, 3), GuestCount: 2})
+	}
+	return dto
+}
+
+// expectGroupRooms sets up a guest requesting rooms of host 2, which approve
+// requests automatically if autoApprove is set.
+func expectGroupRooms(repo *MockReservationRepo, userClient *MockUserClient, roomClient *MockRoomClient, autoApprove bool, roomIDs ...uint) {
+	guest := *DefaultUser_Guest
+	guest.Deleted = false
+	userClient.On("FindById", mock.Anything, uint(1)).Return(&guest, nil)
+	for _, id := range roomIDs {
+		room := *DefaultRoom
+		room.ID = id
+		room.AutoApprove = autoApprove
+		roomClient.On("FindById", mock.Anything, id).Return(&room, nil)
+		roomClient.On("FindCurrentAvailabilityListOfRoom", mock.Anything, id).Return(DefaultAvailabilityList, nil)
+		roomClient.On("FindCurrentPricelistOfRoom", mock.Anything, id).Return(DefaultPriceList, nil)
+		repo.On("HasReservationsInRange", id, mock.Anything, mock.Anything).Return(false, nil)
+		repo.On("HasHold", id, mock.Anything, mock.Anything, uint(1), mock.Anything).Return(false, nil)
+		repo.On("HasWaitlistOffer", id, mock.Anything, mock.Anything, uint(1), mock.Anything).Return(false, nil)
+	}
+	roomClient.On("QueryForReservation", mock.Anything, mock.Anything, mock.Anything).Return(DefaultReservationQueryResponse, nil)
+	repo.On("FindPendingRequestsByGuestID", uint(1)).Return([]internal.ReservationRequest{}, nil)
+	repo.On("CreateRequestGroup", mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(*internal.RequestGroup).ID = 4
+	}).Return(nil)
+	nextID := uint(10)
+	repo.On("CreateRequest", mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(*internal.ReservationRequest).ID = nextID
+		nextID++
+	}).Return(nil)
+	repo.On("ConvertHolds", uint(1), mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(int64(0), nil)
+}
+
+func Test_CreateGroupRequest_Invalid(t *testing.T) {
+	differentDates := groupDTO(1, 2)
+	differentDates.Requests[1].DateTo = differentDates.Requests[1].DateTo.AddDate(0, 0, 1)
+
+	tests := map[string]internal.CreateGroupRequestDTO{
+		"one room":        groupDTO(1),
+		"too many rooms":  groupDTO(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11),
+		"same room twice": groupDTO(1, 1),
+		"different dates": differentDates,
+	}
+	for name, dto := range tests {
+		t.Run(name, func(t *testing.T) {
+			svc, repo, _, _ := CreateTestRoomService()
+
+			_, _, err := svc.CreateGroupRequest(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, dto)
+
+			var apiErr *internal.APIError
+			if assert.ErrorAs(t, err, &apiErr) {
+				assert.Equal(t, 400, apiErr.Code)
+			}
+			repo.AssertNotCalled(t, "CreateRequestGroup", mock.Anything)
+		})
+	}
+}
+
+func Test_CreateGroupRequest_DifferentHosts(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	expectGroupRooms(repo, userClient, roomClient, false, 1)
+	other := *DefaultRoom
+	other.ID, other.HostID = 2, 3
+	roomClient.On("FindById", mock.Anything, uint(2)).Return(&other, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", mock.Anything, uint(2)).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", mock.Anything, uint(2)).Return(DefaultPriceList, nil)
+	repo.On("HasReservationsInRange", uint(2), mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasHold", uint(2), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(false, nil)
+	repo.On("HasWaitlistOffer", uint(2), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(false, nil)
+
+	_, _, err := svc.CreateGroupRequest(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, groupDTO(1, 2))
+
+	var apiErr *internal.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, 400, apiErr.Code)
+	}
+	repo.AssertNotCalled(t, "CreateRequestGroup", mock.Anything)
+}
+
+func Test_CreateGroupRequest_WaitsForHost(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	expectGroupRooms(repo, userClient, roomClient, false, 1, 2)
+	ExpectNotification(repo, notificationclient.GroupRequested, DefaultRoom.HostID)
+	repo.On("LockRoom", mock.Anything).Return(nil)
+
+	group, reservations, err := svc.CreateGroupRequest(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, groupDTO(1, 2))
+
+	assert.NoError(t, err)
+	assert.Empty(t, reservations)
+	assert.Equal(t, internal.Pending, group.Status())
+	assert.Equal(t, 2*DefaultReservationQueryResponse.TotalCost, group.TotalCost())
+	for _, req := range group.Requests {
+		assert.Equal(t, uint(4), req.GroupID)
+	}
+	repo.AssertNumberOfCalls(t, "CreateRequest", 2)
+	repo.AssertNumberOfCalls(t, "EnqueueNotification", 1)
+}
+
+func Test_CreateGroupRequest_AutoApproved(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	expectGroupRooms(repo, userClient, roomClient, true, 2, 1)
+	ExpectNotification(repo, notificationclient.GroupRequested, DefaultRoom.HostID)
+	ExpectNotification(repo, notificationclient.GroupAccepted, 1)
+	var locked []uint
+	repo.On("LockRoom", mock.Anything).Run(func(args mock.Arguments) {
+		locked = append(locked, args.Get(0).(uint))
+	}).Return(nil)
+	repo.On("FindRequestByIDForUpdate", mock.Anything).Return(&internal.ReservationRequest{Status: internal.Pending}, nil)
+	repo.On("FindCancellationPolicy", mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("CreateReservation", mock.Anything).Return(nil)
+	repo.On("SetRequestStatus", mock.Anything, internal.Accepted).Return(nil)
+	repo.On("RejectPendingRequestsInRange", mock.Anything, mock.Anything, mock.Anything).Return([]internal.ReservationRequest{}, nil)
+
+	group, reservations, err := svc.CreateGroupRequest(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, groupDTO(2, 1))
+
+	assert.NoError(t, err)
+	assert.Len(t, reservations, 2)
+	assert.Equal(t, internal.Accepted, group.Status())
+	// Locked in the same order when the group is made and when it's approved.
+	assert.Equal(t, []uint{1, 2, 1, 2}, locked)
+}
+
+func pendingGroup() *internal.RequestGroup {
+	return &internal.RequestGroup{ID: 4, GuestID: 1, HostID: DefaultRoom.HostID, Requests: []internal.ReservationRequest{
+		{ID: 10, RoomID: 1, GuestID: 1, GroupID: 4, Status: internal.Pending, Cost: 400},
+		{ID: 11, RoomID: 2, GuestID: 1, GroupID: 4, Status: internal.Pending, Cost: 300},
+	}}
+}
+
+func Test_ApproveGroupRequest_ConflictApprovesNothing(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	guest := *DefaultUser_Guest
+	guest.Deleted = false
+	repo.On("FindRequestGroupByID", uint(4)).Return(pendingGroup(), nil)
+	userClient.On("FindById", mock.Anything, uint(1)).Return(&guest, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", mock.Anything, mock.Anything).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", mock.Anything, mock.Anything).Return(DefaultPriceList, nil)
+	repo.On("LockRoom", mock.Anything).Return(nil)
+	repo.On("FindRequestByIDForUpdate", mock.Anything).Return(&internal.ReservationRequest{Status: internal.Pending}, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasReservationsInRange", uint(2), mock.Anything, mock.Anything).Return(true, nil)
+	repo.On("FindCancellationPolicy", mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("CreateReservation", mock.Anything).Return(nil)
+	repo.On("SetRequestStatus", mock.Anything, internal.Accepted).Return(nil)
+	repo.On("RejectPendingRequestsInRange", mock.Anything, mock.Anything, mock.Anything).Return([]internal.ReservationRequest{}, nil)
+
+	_, _, err := svc.ApproveGroupRequest(context.Background(), DefaultRoom.HostID, 4)
+
+	assert.Equal(t, internal.ErrConflict, err)
+	repo.AssertNotCalled(t, "EnqueueNotification", mock.Anything)
+}
+
+func Test_ApproveGroupRequest_NotPending(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	group := pendingGroup()
+	group.Requests[1].Status = internal.Expired
+	repo.On("FindRequestGroupByID", uint(4)).Return(group, nil)
+
+	_, _, err := svc.ApproveGroupRequest(context.Background(), DefaultRoom.HostID, 4)
+
+	AssertTransitionError(t, err, string(internal.Expired), string(internal.Accepted))
+}
+
+func Test_RejectGroupRequest(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	repo.On("FindRequestGroupByID", uint(4)).Return(pendingGroup(), nil)
+	repo.On("FindRequestByIDForUpdate", mock.Anything).Return(&internal.ReservationRequest{Status: internal.Pending}, nil)
+	repo.On("SetRequestStatus", mock.Anything, internal.Rejected).Return(nil)
+	ExpectNotification(repo, notificationclient.GroupDeclined, 1)
+
+	err := svc.RejectGroupRequest(context.Background(), DefaultRoom.HostID, 4)
+
+	assert.NoError(t, err)
+	repo.AssertNumberOfCalls(t, "SetRequestStatus", 2)
+	repo.AssertNumberOfCalls(t, "EnqueueNotification", 1)
+}
+
+func Test_WithdrawGroupRequest_SomeoneElses(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	repo.On("FindRequestGroupByID", uint(4)).Return(pendingGroup(), nil)
+
+	err := svc.WithdrawGroupRequest(context.Background(), 5, 4)
+
+	var apiErr *internal.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, 404, apiErr.Code)
+	}
+	repo.AssertNotCalled(t, "WithdrawRequest", mock.Anything, mock.Anything)
+}
+
+func Test_ApproveReservationRequest_Grouped(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	repo.On("FindRequestByID", uint(10)).Return(&pendingGroup().Requests[0], nil)
+
+	err := svc.ApproveReservationRequest(context.Background(), DefaultRoom.HostID, 10, "Token")
+
+	var apiErr *internal.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, 400, apiErr.Code)
+	}
+}
+
+func Test_ApproveReservationRequest_RejectsRestOfOverlappingGroup(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	guest := *DefaultUser_Guest
+	guest.Deleted = false
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, GuestID: 1, Status: internal.Pending}
+	grouped := pendingGroup().Requests[0]
+	repo.On("FindRequestByID", uint(1)).Return(req, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	userClient.On("FindById", mock.Anything, uint(1)).Return(&guest, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", mock.Anything, uint(1)).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", mock.Anything, uint(1)).Return(DefaultPriceList, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("FindRequestByIDForUpdate", uint(1)).Return(req, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("FindCancellationPolicy", uint(1)).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("CreateReservation", mock.Anything).Return(nil)
+	repo.On("SetRequestStatus", uint(1), internal.Accepted).Return(nil)
+	repo.On("RejectPendingRequestsInRange", uint(1), mock.Anything, mock.Anything).Return([]internal.ReservationRequest{grouped}, nil)
+	repo.On("RejectPendingRequestsInGroup", uint(4)).Return([]internal.ReservationRequest{pendingGroup().Requests[1]}, nil)
+	ExpectNotification(repo, notificationclient.ReservationAccepted, 1)
+
+	err := svc.ApproveReservationRequest(context.Background(), DefaultRoom.HostID, 1, "Token")
+
+	assert.NoError(t, err)
+	repo.AssertCalled(t, "RejectPendingRequestsInGroup", uint(4))
+	var rejected []uint
+	for _, tr := range repo.Transitions {
+		if tr.ToStatus == string(internal.Rejected) {
+			rejected = append(rejected, tr.SubjectID)
+		}
+	}
+	assert.Equal(t, []uint{10, 11}, rejected)
+}
@@ -0,0 +1,189 @@
+package test
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/internal"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func holdDTO(minutes uint) internal.CreateHoldDTO {
+	from := time.Now().AddDate(0, 0, 10)
+	return internal.CreateHoldDTO{RoomID: 1, DateFrom: from, DateTo: from.AddDate(0, 0, 2), Minutes: minutes}
+}
+
+func Test_CreateHold_Success(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("CountActiveHolds", uint(1), mock.Anything).Return(int64(0), nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasHold", uint(1), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(false, nil)
+	repo.On("HasWaitlistOffer", uint(1), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(false, nil)
+	repo.On("CreateHold", mock.Anything).Return(nil)
+
+	hold, err := svc.CreateHold(context.Background(), 1, holdDTO(0))
+
+	assert.NoError(t, err)
+	assert.Equal(t, internal.HoldActive, hold.Status)
+	assert.Equal(t, internal.DefaultHoldTime, hold.ExpiresAt.Sub(hold.CreatedAt))
+	repo.AssertCalled(t, "LockRoom", uint(1))
+}
+
+func Test_CreateHold_DatesTaken(t *testing.T) {
+	tests := map[string]struct{ booked, held, offered bool }{
+		"booked":  {booked: true},
+		"held":    {held: true},
+		"offered": {offered: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			svc, repo, _, roomClient := CreateTestRoomService()
+
+			roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+			repo.On("CountActiveHolds", uint(1), mock.Anything).Return(int64(0), nil)
+			repo.On("LockRoom", uint(1)).Return(nil)
+			repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(tt.booked, nil)
+			repo.On("HasHold", uint(1), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(tt.held, nil)
+			repo.On("HasWaitlistOffer", uint(1), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(tt.offered, nil)
+
+			_, err := svc.CreateHold(context.Background(), 1, holdDTO(5))
+
+			assert.Equal(t, internal.ErrConflict, err)
+			repo.AssertNotCalled(t, "CreateHold", mock.Anything)
+		})
+	}
+}
+
+func Test_CreateHold_NotAllowed(t *testing.T) {
+	tests := map[string]struct {
+		dto    internal.CreateHoldDTO
+		active int64
+	}{
+		"too long":       {dto: holdDTO(uint(internal.MaxHoldTime.Minutes()) + 1)},
+		"too many holds": {dto: holdDTO(5), active: internal.MaxActiveHolds},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			svc, repo, _, roomClient := CreateTestRoomService()
+
+			roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+			repo.On("CountActiveHolds", uint(1), mock.Anything).Return(tt.active, nil)
+
+			_, err := svc.CreateHold(context.Background(), 1, tt.dto)
+
+			var apiErr *internal.APIError
+			if assert.ErrorAs(t, err, &apiErr) {
+				assert.Equal(t, 400, apiErr.Code)
+			}
+			repo.AssertNotCalled(t, "CreateHold", mock.Anything)
+		})
+	}
+}
+
+func Test_ReleaseHold_NotFound(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	repo.On("ReleaseHold", uint(4), uint(1), mock.Anything).Return(gorm.ErrRecordNotFound)
+
+	err := svc.ReleaseHold(context.Background(), 1, 4)
+
+	var apiErr *internal.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, 404, apiErr.Code)
+	}
+}
+
+func Test_IsRoomAvailable_HeldBySomeoneElse(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	from, to := july(2), july(4)
+	repo.On("HasReservationsInRange", uint(1), from, to).Return(false, nil)
+	repo.On("HasHold", uint(1), from, to, uint(1), mock.Anything).Return(false, nil)
+	repo.On("HasHold", uint(1), from, to, uint(5), mock.Anything).Return(true, nil)
+	repo.On("HasWaitlistOffer", uint(1), from, to, mock.Anything, mock.Anything).Return(false, nil)
+
+	forHolder, err := svc.IsRoomAvailable(context.Background(), 1, 1, from, to)
+	assert.NoError(t, err)
+	assert.True(t, forHolder)
+
+	forOther, err := svc.IsRoomAvailable(context.Background(), 5, 1, from, to)
+	assert.NoError(t, err)
+	assert.False(t, forOther)
+}
+
+func Test_CreateRequest_HeldBySomeoneElse(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	dto := holdDTO(0)
+	guest := *DefaultUser_Guest
+	guest.Deleted = false
+	userClient.On("FindById", mock.Anything, uint(1)).Return(&guest, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", mock.Anything, uint(1)).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", mock.Anything, uint(1)).Return(DefaultPriceList, nil)
+	roomClient.On("QueryForReservation", mock.Anything, mock.Anything, mock.Anything).Return(DefaultReservationQueryResponse, nil)
+	repo.On("FindPendingRequestsByGuestID", uint(1)).Return([]internal.ReservationRequest{}, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("HasHold", uint(1), dto.DateFrom, dto.DateTo, uint(1), mock.Anything).Return(true, nil)
+
+	_, _, err := svc.CreateRequest(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, internal.CreateReservationRequestDTO{
+		RoomID: 1, DateFrom: dto.DateFrom, DateTo: dto.DateTo, GuestCount: 1,
+	})
+
+	assert.Equal(t, internal.ErrConflict, err)
+	repo.AssertCalled(t, "LockRoom", uint(1))
+	repo.AssertNotCalled(t, "CreateRequest", mock.Anything)
+}
+
+func Test_CreateRequest_ConvertsOwnHolds(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	dto := holdDTO(0)
+	guest := *DefaultUser_Guest
+	guest.Deleted = false
+	userClient.On("FindById", mock.Anything, uint(1)).Return(&guest, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", mock.Anything, uint(1)).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", mock.Anything, uint(1)).Return(DefaultPriceList, nil)
+	roomClient.On("QueryForReservation", mock.Anything, mock.Anything, mock.Anything).Return(DefaultReservationQueryResponse, nil)
+	repo.On("FindPendingRequestsByGuestID", uint(1)).Return([]internal.ReservationRequest{}, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasHold", uint(1), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(false, nil)
+	repo.On("HasWaitlistOffer", uint(1), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(false, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("CreateRequest", mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(*internal.ReservationRequest).ID = 12
+	}).Return(nil)
+	repo.On("ConvertHolds", uint(1), uint(1), dto.DateFrom, dto.DateTo, uint(12), mock.Anything).Return(int64(1), nil)
+	ExpectNotification(repo, notificationclient.ReservationRequested, DefaultRoom.HostID)
+
+	req, _, err := svc.CreateRequest(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, internal.CreateReservationRequestDTO{
+		RoomID: 1, DateFrom: dto.DateFrom, DateTo: dto.DateTo, GuestCount: 1,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(12), req.ID)
+	repo.AssertNumberOfCalls(t, "ConvertHolds", 1)
+}
+
+func Test_ExpireHolds(t *testing.T) {
+	repo := new(MockReservationRepo)
+	expirer := internal.NewHoldExpirer(repo, testExpiryConfig)
+
+	repo.On("ExpireHolds", mock.MatchedBy(func(now time.Time) bool {
+		return time.Since(now) < time.Minute
+	})).Return(int64(3), nil)
+
+	n, err := expirer.ExpireOnce(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+}
@@ -0,0 +1,152 @@
+package test
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/internal"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHostCancelReservation_Success(t *testing.T) {
+	svc, mockRepo, mockUser, mockRoom := CreateTestRoomService()
+
+	res := &internal.Reservation{
+		Status:   internal.ReservationConfirmed,
+		ID:       1,
+		GuestID:  1,
+		RoomID:   1,
+		DateFrom: time.Now().Add(48 * time.Hour),
+	}
+
+	mockUser.On("FindById", mock.Anything, uint(2)).Return(DefaultUser_Host, nil)
+	mockRepo.On("FindReservationById", uint(1)).Return(res, nil)
+	mockRoom.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	mockRepo.On("FindReservationByIDForUpdate", uint(1)).Return(res, nil)
+	mockRepo.On("CancelReservation", uint(1), mock.Anything).Return(nil)
+	mockRepo.On("CancelPendingModifications", uint(1), mock.Anything).Return([]internal.ReservationModification{}, nil)
+	mockRepo.On("FindWaitingEntries", mock.Anything, mock.Anything, mock.Anything).Return([]internal.WaitlistEntry{}, nil)
+
+	ExpectNotification(mockRepo, notificationclient.ReservationCancelledByHost, 1)
+
+	_, err := svc.HostCancelReservation(context.Background(), 2, 1, "  Burst pipe  ")
+
+	assert.NoError(t, err)
+	mockRepo.AssertNumberOfCalls(t, "EnqueueNotification", 1)
+
+	cancellation := mockRepo.Calls[2].Arguments.Get(1).(internal.Cancellation)
+	assert.Equal(t, internal.CancelledByHost, cancellation.By)
+	assert.Equal(t, uint(2), cancellation.ByID)
+	assert.Equal(t, "Burst pipe", cancellation.Reason)
+}
+
+func TestHostCancelReservation_MissingReason(t *testing.T) {
+	svc, mockRepo, _, _ := CreateTestRoomService()
+
+	_, err := svc.HostCancelReservation(context.Background(), 2, 1, "   ")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "a reason is required")
+	mockRepo.AssertNotCalled(t, "CancelReservation", mock.Anything, mock.Anything)
+}
+
+func TestHostCancelReservation_ReasonTooLong(t *testing.T) {
+	svc, mockRepo, _, _ := CreateTestRoomService()
+
+	reason := strings.Repeat("a", internal.MaxCancellationReasonLength+1)
+	_, err := svc.HostCancelReservation(context.Background(), 2, 1, reason)
+
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "CancelReservation", mock.Anything, mock.Anything)
+}
+
+func TestHostCancelReservation_NotOwner(t *testing.T) {
+	svc, mockRepo, mockUser, mockRoom := CreateTestRoomService()
+
+	res := &internal.Reservation{
+		Status:   internal.ReservationConfirmed,
+		ID:       1,
+		GuestID:  1,
+		RoomID:   1,
+		DateFrom: time.Now().Add(48 * time.Hour),
+	}
+	otherHost := *DefaultUser_Host
+	otherHost.Id = 3
+
+	mockUser.On("FindById", mock.Anything, uint(3)).Return(&otherHost, nil)
+	mockRepo.On("FindReservationById", uint(1)).Return(res, nil)
+	mockRoom.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+
+	_, err := svc.HostCancelReservation(context.Background(), 3, 1, "Burst pipe")
+
+	assert.Equal(t, internal.ErrUnauthorized, err)
+	mockRepo.AssertNotCalled(t, "CancelReservation", mock.Anything, mock.Anything)
+}
+
+func TestHostCancelReservation_GuestCaller(t *testing.T) {
+	svc, mockRepo, mockUser, _ := CreateTestRoomService()
+
+	mockUser.On("FindById", mock.Anything, uint(1)).Return(DefaultUser_Guest, nil)
+
+	_, err := svc.HostCancelReservation(context.Background(), 1, 1, "Burst pipe")
+
+	assert.Equal(t, internal.ErrUnauthorized, err)
+	mockRepo.AssertNotCalled(t, "FindReservationById", mock.Anything)
+}
+
+func TestHostCancelReservation_AlreadyCancelled(t *testing.T) {
+	svc, mockRepo, mockUser, mockRoom := CreateTestRoomService()
+
+	res := &internal.Reservation{
+		ID:       1,
+		GuestID:  1,
+		RoomID:   1,
+		DateFrom: time.Now().Add(48 * time.Hour),
+		Status:   internal.ReservationCancelled,
+	}
+
+	mockUser.On("FindById", mock.Anything, uint(2)).Return(DefaultUser_Host, nil)
+	mockRepo.On("FindReservationById", uint(1)).Return(res, nil)
+	mockRoom.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+
+	_, err := svc.HostCancelReservation(context.Background(), 2, 1, "Burst pipe")
+
+	AssertTransitionError(t, err, "cancelled", "cancelled")
+}
+
+func TestHostCancelReservation_AlreadyStarted(t *testing.T) {
+	svc, mockRepo, mockUser, mockRoom := CreateTestRoomService()
+
+	res := &internal.Reservation{
+		Status:   internal.ReservationConfirmed,
+		ID:       1,
+		GuestID:  1,
+		RoomID:   1,
+		DateFrom: time.Now().Add(-2 * time.Hour),
+	}
+
+	mockUser.On("FindById", mock.Anything, uint(2)).Return(DefaultUser_Host, nil)
+	mockRepo.On("FindReservationById", uint(1)).Return(res, nil)
+	mockRoom.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+
+	_, err := svc.HostCancelReservation(context.Background(), 2, 1, "Burst pipe")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "already started")
+	mockRepo.AssertNotCalled(t, "CancelReservation", mock.Anything, mock.Anything)
+}
+
+func TestGetHostCancellationCount_Success(t *testing.T) {
+	svc, mockRepo, _, _ := CreateTestRoomService()
+
+	mockRepo.On("CountHostCancellations", uint(2)).Return(int64(3), nil)
+
+	count, err := svc.GetHostCancellationCount(context.Background(), 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), count)
+}
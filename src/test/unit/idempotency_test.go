@@ -0,0 +1,132 @@
+package test
+
+import (
+	"bookem-reservation-service/config"
+	"bookem-reservation-service/internal"
+	"bookem-reservation-service/util"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newIdempotentServer serves POST /req through the Idempotency middleware.
+// The handler answers with status and counts how often it ran.
+func newIdempotentServer(t *testing.T, status int) (*gin.Engine, *MockReservationRepo, *int) {
+	gin.SetMode(gin.TestMode)
+
+	parseJWT := util.ParseJWT
+	util.ParseJWT = func(string) (jwt.MapClaims, error) {
+		return jwt.MapClaims{"sub": float64(1), "username": "guest", "role": "guest"}, nil
+	}
+	t.Cleanup(func() { util.ParseJWT = parseJWT })
+
+	repo := new(MockReservationRepo)
+	calls := 0
+	server := gin.New()
+	server.POST("/req", internal.Idempotency(repo, config.Default().Idempotency), func(c *gin.Context) {
+		calls++
+		c.JSON(status, gin.H{"id": 7})
+	})
+	return server, repo, &calls
+}
+
+func postWithKey(server *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/req", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer token")
+	if key != "" {
+		req.Header.Set(internal.IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	return w
+}
+
+func Test_Idempotency_WithoutKey(t *testing.T) {
+	server, repo, calls := newIdempotentServer(t, http.StatusCreated)
+
+	w := postWithKey(server, "", `{"roomId":1}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 1, *calls)
+	repo.AssertNotCalled(t, "ClaimIdempotencyKey", mock.Anything, mock.Anything)
+}
+
+func Test_Idempotency_StoresFirstResponse(t *testing.T) {
+	server, repo, calls := newIdempotentServer(t, http.StatusCreated)
+
+	repo.On("ClaimIdempotencyKey", mock.Anything, mock.Anything).Return(nil, nil)
+	repo.On("CompleteIdempotencyKey", uint(1), "key-1", http.StatusCreated, []byte(`{"id":7}`)).Return(nil)
+
+	w := postWithKey(server, "key-1", `{"roomId":1}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 1, *calls)
+	repo.AssertExpectations(t)
+
+	claim := repo.Calls[0].Arguments.Get(0).(*internal.IdempotencyKey)
+	assert.Equal(t, uint(1), claim.UserID)
+	assert.Equal(t, "key-1", claim.Key)
+	assert.True(t, claim.ExpiresAt.After(claim.CreatedAt))
+}
+
+func Test_Idempotency_ReplaysStoredResponse(t *testing.T) {
+	server, repo, calls := newIdempotentServer(t, http.StatusCreated)
+
+	stored := &internal.IdempotencyKey{UserID: 1, Key: "key-1", StatusCode: http.StatusCreated, Body: []byte(`{"id":3}`)}
+	repo.On("ClaimIdempotencyKey", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { stored.RequestHash = args.Get(0).(*internal.IdempotencyKey).RequestHash }).
+		Return(stored, nil)
+
+	w := postWithKey(server, "key-1", `{"roomId":1}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"id":3}`, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get(internal.IdempotentReplayedHeader))
+	assert.Equal(t, 0, *calls)
+}
+
+func Test_Idempotency_KeyReusedForAnotherRequest(t *testing.T) {
+	server, repo, calls := newIdempotentServer(t, http.StatusCreated)
+
+	stored := &internal.IdempotencyKey{UserID: 1, Key: "key-1", RequestHash: "other", StatusCode: http.StatusCreated}
+	repo.On("ClaimIdempotencyKey", mock.Anything, mock.Anything).Return(stored, nil)
+
+	w := postWithKey(server, "key-1", `{"roomId":2}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, 0, *calls)
+}
+
+func Test_Idempotency_KeyStillBeingProcessed(t *testing.T) {
+	server, repo, calls := newIdempotentServer(t, http.StatusCreated)
+
+	stored := &internal.IdempotencyKey{UserID: 1, Key: "key-1"}
+	repo.On("ClaimIdempotencyKey", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { stored.RequestHash = args.Get(0).(*internal.IdempotencyKey).RequestHash }).
+		Return(stored, nil)
+
+	w := postWithKey(server, "key-1", `{"roomId":1}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, 0, *calls)
+}
+
+func Test_Idempotency_ServerErrorReleasesKey(t *testing.T) {
+	server, repo, calls := newIdempotentServer(t, http.StatusServiceUnavailable)
+
+	repo.On("ClaimIdempotencyKey", mock.Anything, mock.Anything).Return(nil, nil)
+	repo.On("ReleaseIdempotencyKey", uint(1), "key-1").Return(nil)
+
+	w := postWithKey(server, "key-1", `{"roomId":1}`)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, 1, *calls)
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "CompleteIdempotencyKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
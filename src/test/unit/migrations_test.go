@@ -0,0 +1,71 @@
+package test
+
+import (
+	"bookem-reservation-service/migrations"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Migrations_EmbeddedAreValid(t *testing.T) {
+	all, err := migrations.Load()
+
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+	for i, m := range all {
+		assert.Equal(t, i+1, m.Version, "migration versions must be contiguous")
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down)
+	}
+}
+
+func Test_Migrations_ParseSortsByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_second.up.sql":   {Data: []byte("SELECT 2;")},
+		"0002_second.down.sql": {Data: []byte("SELECT -2;")},
+		"0001_first.up.sql":    {Data: []byte("SELECT 1;")},
+		"0001_first.down.sql":  {Data: []byte("SELECT -1;")},
+	}
+
+	all, err := migrations.Parse(fsys)
+
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "first", all[0].Name)
+	assert.Equal(t, "SELECT 1;", all[0].Up)
+	assert.Equal(t, "SELECT -1;", all[0].Down)
+	assert.Equal(t, "second", all[1].Name)
+}
+
+func Test_Migrations_ParseMissingDown(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_first.up.sql": {Data: []byte("SELECT 1;")},
+	}
+
+	_, err := migrations.Parse(fsys)
+
+	assert.ErrorContains(t, err, "needs both an up and a down file")
+}
+
+func Test_Migrations_ParseBadFileName(t *testing.T) {
+	fsys := fstest.MapFS{
+		"first.sql": {Data: []byte("SELECT 1;")},
+	}
+
+	_, err := migrations.Parse(fsys)
+
+	assert.ErrorContains(t, err, "bad migration file name")
+}
+
+func Test_Migrations_ParseConflictingNames(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_first.up.sql":   {Data: []byte("SELECT 1;")},
+		"0001_other.down.sql": {Data: []byte("SELECT -1;")},
+	}
+
+	_, err := migrations.Parse(fsys)
+
+	assert.ErrorContains(t, err, "two names")
+}
@@ -0,0 +1,327 @@
+package test
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/client/roomclient"
+	"bookem-reservation-service/internal"
+	"bookem-reservation-service/util"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func upcomingReservation() *internal.Reservation {
+	from := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 10)
+	return &internal.Reservation{
+		ID:         5,
+		RoomID:     1,
+		GuestID:    1,
+		DateFrom:   from,
+		DateTo:     from.AddDate(0, 0, 2),
+		GuestCount: 2,
+		Status:     internal.ReservationConfirmed,
+		Cost:       300,
+	}
+}
+
+func expectModificationLookups(repo *MockReservationRepo, roomClient *MockRoomClient, res *internal.Reservation, room *roomclient.RoomDTO) {
+	repo.On("FindReservationById", res.ID).Return(res, nil)
+	repo.On("HasPendingModification", res.ID).Return(false, nil)
+	roomClient.On("FindById", mock.Anything, res.RoomID).Return(room, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", mock.Anything, res.RoomID).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", mock.Anything, res.RoomID).Return(DefaultPriceList, nil)
+	roomClient.On("QueryForReservation", mock.Anything, mock.Anything, mock.Anything).Return(DefaultReservationQueryResponse, nil)
+}
+
+func Test_RequestModification_WaitsForHost(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	res := upcomingReservation()
+	expectModificationLookups(repo, roomClient, res, DefaultRoom)
+	newTo := res.DateTo.AddDate(0, 0, 1)
+	repo.On("HasOtherReservationsInRange", uint(1), res.DateFrom, newTo, res.ID).Return(false, nil)
+	repo.On("CreateModification", mock.Anything).Return(nil)
+	ExpectNotification(repo, notificationclient.ModificationRequested, 2)
+
+	m, err := svc.RequestModification(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, res.ID, internal.ModifyReservationDTO{DateTo: &newTo})
+
+	assert.NoError(t, err)
+	assert.Equal(t, internal.ModificationPending, m.Status)
+	assert.Equal(t, uint(300), m.Previous.Cost)
+	assert.Equal(t, uint(400), m.Proposed.Cost)
+	assert.Equal(t, newTo, m.Proposed.DateTo)
+	assert.Equal(t, uint(2), m.Proposed.GuestCount)
+	repo.AssertNotCalled(t, "ApplyModification", mock.Anything)
+	repo.AssertNotCalled(t, "LockRoom", mock.Anything)
+	if assert.Len(t, repo.Transitions, 1) {
+		assert.Equal(t, internal.SubjectModification, repo.Transitions[0].SubjectType)
+		assert.Equal(t, string(internal.ModificationPending), repo.Transitions[0].ToStatus)
+		assert.Equal(t, util.Guest, repo.Transitions[0].ActorRole)
+	}
+}
+
+func Test_RequestModification_AutoApprove(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	res := upcomingReservation()
+	room := *DefaultRoom
+	room.AutoApprove = true
+	expectModificationLookups(repo, roomClient, res, &room)
+	guests := uint(3)
+	repo.On("HasOtherReservationsInRange", uint(1), res.DateFrom, res.DateTo, res.ID).Return(false, nil)
+	repo.On("CreateModification", mock.Anything).Return(nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("FindModificationByIDForUpdate", mock.Anything).Return(&internal.ReservationModification{Status: internal.ModificationPending}, nil)
+	repo.On("FindReservationByIDForUpdate", res.ID).Return(res, nil)
+	repo.On("HasHold", uint(1), res.DateFrom, res.DateTo, uint(1), mock.Anything).Return(false, nil)
+	repo.On("HasWaitlistOffer", uint(1), res.DateFrom, res.DateTo, uint(1), mock.Anything).Return(false, nil)
+	repo.On("ApplyModification", mock.Anything).Return(nil)
+	repo.On("SetModificationStatus", mock.Anything, internal.ModificationApproved, mock.Anything).Return(nil)
+	repo.On("RejectPendingRequestsInRange", uint(1), res.DateFrom, res.DateTo).Return([]internal.ReservationRequest{}, nil)
+	ExpectNotification(repo, notificationclient.ModificationRequested, 2)
+	ExpectNotification(repo, notificationclient.ModificationAccepted, 1)
+
+	m, err := svc.RequestModification(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, res.ID, internal.ModifyReservationDTO{GuestCount: &guests})
+
+	assert.NoError(t, err)
+	assert.Equal(t, internal.ModificationApproved, m.Status)
+	repo.AssertCalled(t, "ApplyModification", m)
+	if assert.Len(t, repo.Transitions, 2) {
+		assert.Equal(t, string(internal.ModificationApproved), repo.Transitions[1].ToStatus)
+		assert.Equal(t, internal.ActorSystem, repo.Transitions[1].ActorRole)
+		assert.Equal(t, "auto-approved", repo.Transitions[1].Reason)
+	}
+}
+
+func Test_RequestModification_AutoApproveFails(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	res := upcomingReservation()
+	room := *DefaultRoom
+	room.AutoApprove = true
+	expectModificationLookups(repo, roomClient, res, &room)
+	guests := uint(3)
+	repo.On("HasOtherReservationsInRange", uint(1), res.DateFrom, res.DateTo, res.ID).Return(false, nil).Once()
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("CreateModification", mock.Anything).Return(nil)
+	repo.On("FindModificationByIDForUpdate", mock.Anything).Return(&internal.ReservationModification{Status: internal.ModificationPending}, nil)
+	repo.On("FindReservationByIDForUpdate", res.ID).Return(res, nil)
+	// Booked in the meantime, after the check made before the transaction.
+	repo.On("HasOtherReservationsInRange", uint(1), res.DateFrom, res.DateTo, res.ID).Return(true, nil)
+	ExpectNotification(repo, notificationclient.ModificationRequested, 2)
+
+	_, err := svc.RequestModification(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, res.ID, internal.ModifyReservationDTO{GuestCount: &guests})
+
+	// The modification and the host's notification are made in the failed
+	// transaction, so neither is left behind.
+	assert.Equal(t, internal.ErrConflict, err)
+	repo.AssertCalled(t, "LockRoom", uint(1))
+	repo.AssertNotCalled(t, "ApplyModification", mock.Anything)
+}
+
+func Test_RequestModification_ConflictsWithOtherReservation(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	res := upcomingReservation()
+	expectModificationLookups(repo, roomClient, res, DefaultRoom)
+	newTo := res.DateTo.AddDate(0, 0, 3)
+	repo.On("HasOtherReservationsInRange", uint(1), res.DateFrom, newTo, res.ID).Return(true, nil)
+
+	_, err := svc.RequestModification(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, res.ID, internal.ModifyReservationDTO{DateTo: &newTo})
+
+	assert.Equal(t, internal.ErrConflict, err)
+	repo.AssertNotCalled(t, "CreateModification", mock.Anything)
+}
+
+func Test_RequestModification_AlreadyPending(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	res := upcomingReservation()
+	repo.On("FindReservationById", res.ID).Return(res, nil)
+	repo.On("HasPendingModification", res.ID).Return(true, nil)
+	guests := uint(1)
+
+	_, err := svc.RequestModification(context.Background(), internal.AuthContext{CallerID: 1}, res.ID, internal.ModifyReservationDTO{GuestCount: &guests})
+
+	assert.Equal(t, internal.ErrConflict, err)
+}
+
+func Test_RequestModification_OtherGuest(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	res := upcomingReservation()
+	repo.On("FindReservationById", res.ID).Return(res, nil)
+	guests := uint(1)
+
+	_, err := svc.RequestModification(context.Background(), internal.AuthContext{CallerID: 7}, res.ID, internal.ModifyReservationDTO{GuestCount: &guests})
+
+	assert.Equal(t, internal.ErrUnauthorized, err)
+}
+
+func Test_RequestModification_CannotMoveStartedStay(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	res := upcomingReservation()
+	res.DateFrom = time.Now().UTC().AddDate(0, 0, -1)
+	res.DateTo = time.Now().UTC().AddDate(0, 0, 2)
+	repo.On("FindReservationById", res.ID).Return(res, nil)
+	newFrom := res.DateFrom.AddDate(0, 0, 2)
+
+	_, err := svc.RequestModification(context.Background(), internal.AuthContext{CallerID: 1}, res.ID, internal.ModifyReservationDTO{DateFrom: &newFrom})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "already started")
+}
+
+func Test_RequestModification_NothingToChange(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	res := upcomingReservation()
+	repo.On("FindReservationById", res.ID).Return(res, nil)
+
+	_, err := svc.RequestModification(context.Background(), internal.AuthContext{CallerID: 1}, res.ID, internal.ModifyReservationDTO{})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "nothing to change")
+}
+
+func Test_ApproveModification_NotOwner(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	repo.On("FindModificationByID", uint(9)).Return(&internal.ReservationModification{ID: 9, RoomID: 1, Status: internal.ModificationPending}, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+
+	_, err := svc.ApproveModification(context.Background(), 3, 9)
+
+	assert.Equal(t, internal.ErrUnauthorized, err)
+	repo.AssertNotCalled(t, "ApplyModification", mock.Anything)
+}
+
+func Test_ApproveModification_AlreadyDecided(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	repo.On("FindModificationByID", uint(9)).Return(&internal.ReservationModification{ID: 9, RoomID: 1, Status: internal.ModificationRejected}, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+
+	_, err := svc.ApproveModification(context.Background(), 2, 9)
+
+	AssertTransitionError(t, err, string(internal.ModificationRejected), string(internal.ModificationApproved))
+	status, _ := internal.MapErrorToHTTP(err)
+	assert.Equal(t, 409, status)
+	repo.AssertNotCalled(t, "LockRoom", mock.Anything)
+}
+
+func Test_ApproveModification_DatesTakenInTheMeantime(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	m := &internal.ReservationModification{ID: 9, ReservationID: 5, RoomID: 1, GuestID: 1, Status: internal.ModificationPending}
+	repo.On("FindModificationByID", uint(9)).Return(m, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("FindModificationByIDForUpdate", uint(9)).Return(m, nil)
+	repo.On("FindReservationByIDForUpdate", uint(5)).Return(upcomingReservation(), nil)
+	repo.On("HasOtherReservationsInRange", uint(1), mock.Anything, mock.Anything, uint(5)).Return(true, nil)
+
+	_, err := svc.ApproveModification(context.Background(), 2, 9)
+
+	assert.Equal(t, internal.ErrConflict, err)
+	repo.AssertNotCalled(t, "ApplyModification", mock.Anything)
+}
+
+func Test_ApproveModification_DatesHeldBySomeoneElse(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	m := &internal.ReservationModification{ID: 9, ReservationID: 5, RoomID: 1, GuestID: 1, Status: internal.ModificationPending}
+	repo.On("FindModificationByID", uint(9)).Return(m, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("FindModificationByIDForUpdate", uint(9)).Return(m, nil)
+	repo.On("FindReservationByIDForUpdate", uint(5)).Return(upcomingReservation(), nil)
+	repo.On("HasOtherReservationsInRange", uint(1), mock.Anything, mock.Anything, uint(5)).Return(false, nil)
+	repo.On("HasHold", uint(1), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(true, nil)
+
+	_, err := svc.ApproveModification(context.Background(), 2, 9)
+
+	assert.Equal(t, internal.ErrConflict, err)
+	repo.AssertNotCalled(t, "ApplyModification", mock.Anything)
+}
+
+func Test_ApproveModification_ReservationCancelledInTheMeantime(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	m := &internal.ReservationModification{ID: 9, ReservationID: 5, RoomID: 1, GuestID: 1, Status: internal.ModificationPending}
+	repo.On("FindModificationByID", uint(9)).Return(m, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("FindModificationByIDForUpdate", uint(9)).Return(m, nil)
+	cancelled := upcomingReservation()
+	cancelled.Status, cancelled.Cancelled = internal.ReservationCancelled, true
+	repo.On("FindReservationByIDForUpdate", uint(5)).Return(cancelled, nil)
+
+	_, err := svc.ApproveModification(context.Background(), 2, 9)
+
+	assert.Equal(t, internal.ErrConflict, err)
+	repo.AssertNotCalled(t, "ApplyModification", mock.Anything)
+	repo.AssertNotCalled(t, "RejectPendingRequestsInRange", mock.Anything, mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "EnqueueNotification", mock.Anything)
+}
+
+func Test_RejectModification_Success(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	m := &internal.ReservationModification{ID: 9, ReservationID: 5, RoomID: 1, GuestID: 1, Status: internal.ModificationPending}
+	repo.On("FindModificationByID", uint(9)).Return(m, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("FindModificationByIDForUpdate", uint(9)).Return(m, nil)
+	repo.On("SetModificationStatus", uint(9), internal.ModificationRejected, mock.Anything).Return(nil)
+	ExpectNotification(repo, notificationclient.ModificationDeclined, 1)
+
+	err := svc.RejectModification(context.Background(), 2, 9)
+
+	assert.NoError(t, err)
+	if assert.Len(t, repo.Transitions, 1) {
+		assert.Equal(t, internal.SubjectModification, repo.Transitions[0].SubjectType)
+		assert.Equal(t, string(internal.ModificationRejected), repo.Transitions[0].ToStatus)
+		assert.Equal(t, uint(2), repo.Transitions[0].ActorID)
+	}
+}
+
+func Test_CancelReservation_CancelsPendingModification(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	res := upcomingReservation()
+	userClient.On("FindById", mock.Anything, uint(1)).Return(DefaultUser_Guest, nil)
+	repo.On("FindReservationById", res.ID).Return(res, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("FindReservationByIDForUpdate", res.ID).Return(res, nil)
+	repo.On("CancelReservation", res.ID, mock.Anything).Return(nil)
+	repo.On("CancelPendingModifications", res.ID, mock.Anything).Return([]internal.ReservationModification{{ID: 9, ReservationID: res.ID}}, nil)
+	repo.On("FindWaitingEntries", mock.Anything, mock.Anything, mock.Anything).Return([]internal.WaitlistEntry{}, nil)
+	ExpectNotification(repo, notificationclient.ReservationCancelled, 2)
+
+	_, err := svc.CancelReservation(context.Background(), 1, res.ID, "Token")
+
+	assert.NoError(t, err)
+	if assert.Len(t, repo.Transitions, 2) {
+		cancelled := repo.Transitions[1]
+		assert.Equal(t, internal.SubjectModification, cancelled.SubjectType)
+		assert.Equal(t, uint(9), cancelled.SubjectID)
+		assert.Equal(t, string(internal.ModificationCancelled), cancelled.ToStatus)
+		assert.Equal(t, util.Guest, cancelled.ActorRole)
+	}
+}
+
+func Test_GetModifications_Stranger(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	res := upcomingReservation()
+	repo.On("FindReservationById", res.ID).Return(res, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+
+	_, err := svc.GetModifications(context.Background(), 7, res.ID)
+
+	assert.Equal(t, internal.ErrUnauthorized, err)
+}
@@ -0,0 +1,117 @@
+package test
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/config"
+	"bookem-reservation-service/internal"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testOutboxConfig = config.OutboxConfig{
+	PollInterval: config.Duration(time.Second),
+	BatchSize:    10,
+	MaxAttempts:  3,
+	BaseBackoff:  config.Duration(time.Second),
+	MaxBackoff:   config.Duration(time.Minute),
+	Lease:        config.Duration(time.Minute),
+}
+
+func outboxRow(attempts uint) internal.NotificationOutbox {
+	return internal.NotificationOutbox{
+		ID:         7,
+		ReceiverID: 2,
+		Type:       notificationclient.ReservationRequested,
+		Subject:    1,
+		Object:     3,
+		Status:     internal.OutboxPending,
+		Attempts:   attempts,
+	}
+}
+
+func newTestDispatcher() (*internal.OutboxDispatcher, *MockReservationRepo, *MockNotificationClient) {
+	repo := new(MockReservationRepo)
+	client := new(MockNotificationClient)
+	return internal.NewOutboxDispatcher(repo, client, testOutboxConfig, "service-token"), repo, client
+}
+
+func Test_OutboxDispatcher_DeliversNotification(t *testing.T) {
+	dispatcher, repo, client := newTestDispatcher()
+
+	repo.On("ClaimDueNotifications", mock.Anything, time.Minute, 10).
+		Return([]internal.NotificationOutbox{outboxRow(0)}, nil)
+	client.On("CreateNotification", mock.Anything, "service-token", notificationclient.CreateNotificationDTO{
+		ReceiverID: 2,
+		Type:       notificationclient.ReservationRequested,
+		Subject:    1,
+		Object:     3,
+	}).Return(&notificationclient.NotificationDTO{}, nil)
+	repo.On("UpdateNotificationDelivery", mock.MatchedBy(func(n *internal.NotificationOutbox) bool {
+		return n.ID == 7 && n.Status == internal.OutboxSent && n.Attempts == 1 && n.SentAt != nil
+	})).Return(nil)
+
+	n, err := dispatcher.DispatchOnce(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	repo.AssertNumberOfCalls(t, "UpdateNotificationDelivery", 1)
+}
+
+func Test_OutboxDispatcher_RetriesWithBackoff(t *testing.T) {
+	dispatcher, repo, client := newTestDispatcher()
+
+	repo.On("ClaimDueNotifications", mock.Anything, mock.Anything, mock.Anything).
+		Return([]internal.NotificationOutbox{outboxRow(1)}, nil)
+	client.On("CreateNotification", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("notification-service: upstream unavailable"))
+
+	var saved *internal.NotificationOutbox
+	repo.On("UpdateNotificationDelivery", mock.Anything).
+		Run(func(args mock.Arguments) { saved = args.Get(0).(*internal.NotificationOutbox) }).
+		Return(nil)
+
+	before := time.Now()
+	_, err := dispatcher.DispatchOnce(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, internal.OutboxPending, saved.Status)
+	assert.Equal(t, uint(2), saved.Attempts)
+	assert.Contains(t, saved.LastError, "unavailable")
+	// Second attempt failed: base backoff doubled once.
+	assert.WithinDuration(t, before.Add(2*time.Second), saved.NextAttemptAt, time.Second)
+}
+
+func Test_OutboxDispatcher_DeadLettersAfterMaxAttempts(t *testing.T) {
+	dispatcher, repo, client := newTestDispatcher()
+
+	repo.On("ClaimDueNotifications", mock.Anything, mock.Anything, mock.Anything).
+		Return([]internal.NotificationOutbox{outboxRow(2)}, nil)
+	client.On("CreateNotification", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("boom"))
+	repo.On("UpdateNotificationDelivery", mock.MatchedBy(func(n *internal.NotificationOutbox) bool {
+		return n.Status == internal.OutboxDead && n.Attempts == 3 && n.LastError == "boom"
+	})).Return(nil)
+
+	_, err := dispatcher.DispatchOnce(context.Background())
+
+	assert.NoError(t, err)
+	repo.AssertNumberOfCalls(t, "UpdateNotificationDelivery", 1)
+}
+
+func Test_OutboxDispatcher_ClaimFails(t *testing.T) {
+	dispatcher, repo, client := newTestDispatcher()
+
+	repo.On("ClaimDueNotifications", mock.Anything, mock.Anything, mock.Anything).
+		Return([]internal.NotificationOutbox{}, errors.New("db error"))
+
+	n, err := dispatcher.DispatchOnce(context.Background())
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+	client.AssertNotCalled(t, "CreateNotification", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,66 @@
+package test
+
+import (
+	"bookem-reservation-service/internal"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Cursor_RoundTrip(t *testing.T) {
+	cursor := internal.Cursor{Sort: "-dateTo", Value: time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC), ID: 42}
+
+	decoded, err := internal.DecodeCursor(cursor.Encode())
+
+	require.NoError(t, err)
+	assert.Equal(t, cursor, *decoded)
+}
+
+func Test_Pagination_CursorIsPassedOn(t *testing.T) {
+	svc, repo, userClient, _ := CreateTestRoomService()
+
+	userClient.On("FindById", context.Background(), uint(1)).Return(DefaultUser_Guest, nil)
+	repo.On("FindRequestsPage", mock.Anything, mock.Anything).
+		Return(&internal.Page[internal.ReservationRequest]{Items: []internal.ReservationRequest{}}, nil)
+
+	cursor := internal.Cursor{Sort: "dateFrom", Value: time.Now().UTC(), ID: 7}
+	_, err := svc.FindPendingRequestsByGuest(context.Background(), 1, internal.ListQuery{Cursor: cursor.Encode()})
+
+	require.NoError(t, err)
+	page := repo.Calls[0].Arguments.Get(1).(internal.PageRequest)
+	require.NotNil(t, page.After)
+	assert.Equal(t, uint(7), page.After.ID)
+	assert.True(t, cursor.Value.Equal(page.After.Value))
+}
+
+func Test_Pagination_CursorForAnotherSort(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	cursor := internal.Cursor{Sort: "dateFrom", Value: time.Now(), ID: 7}
+	_, err := svc.FindPendingRequestsByGuest(context.Background(), 1, internal.ListQuery{Cursor: cursor.Encode(), Sort: "-dateFrom"})
+
+	assert.ErrorContains(t, err, "different sort order")
+	repo.AssertNotCalled(t, "FindRequestsPage", mock.Anything, mock.Anything)
+}
+
+func Test_Pagination_LimitOutOfRange(t *testing.T) {
+	svc, _, _, _ := CreateTestRoomService()
+
+	for _, limit := range []int{-1, internal.MaxPageLimit + 1} {
+		_, err := svc.GetPastReservationsByGuest(context.Background(), 1, time.Now(), internal.ListQuery{Limit: limit})
+		assert.ErrorContains(t, err, "limit must be between")
+	}
+}
+
+func Test_Pagination_ReversedDateWindow(t *testing.T) {
+	svc, _, _, _ := CreateTestRoomService()
+
+	query := internal.ListQuery{From: time.Now(), To: time.Now().AddDate(0, 0, -1)}
+	_, err := svc.GetPastReservationsByGuest(context.Background(), 1, time.Now(), query)
+
+	assert.ErrorContains(t, err, "dates are reversed")
+}
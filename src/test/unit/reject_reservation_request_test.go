@@ -8,13 +8,12 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 )
 
 func Test_RejectReservationRequest_Success(t *testing.T) {
-	svc, repo, userClient, roomClient, notifClient := CreateTestRoomService()
+	svc, repo, userClient, roomClient := CreateTestRoomService()
 
-	req := &internal.ReservationRequest{ID: 1, RoomID: 1}
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, Status: internal.Pending}
 	req.GuestID = 11
 	room := *DefaultRoom
 	room.HostID = 2
@@ -26,19 +25,20 @@ func Test_RejectReservationRequest_Success(t *testing.T) {
 	repo.On("FindRequestByID", uint(1)).Return(req, nil)
 	roomClient.On("FindById", context.Background(), uint(1)).Return(&room, nil)
 	userClient.On("FindById", context.Background(), req.GuestID).Return(guest, nil)
+	repo.On("FindRequestByIDForUpdate", uint(1)).Return(req, nil)
 	repo.On("SetRequestStatus", uint(1), internal.Rejected).Return(nil)
 
 	callerID := 2
-	notifClient.On("CreateNotification", mock.Anything, mock.Anything, mock.Anything).
-		Return(&notificationclient.NotificationDTO{}, nil)
+	ExpectNotification(repo, notificationclient.ReservationDeclined, 11)
 	err := svc.RejectReservationRequest(context.Background(), uint(callerID), 1, "Token")
 
 	assert.NoError(t, err)
 	repo.AssertCalled(t, "SetRequestStatus", uint(1), internal.Rejected)
+	repo.AssertNumberOfCalls(t, "EnqueueNotification", 1)
 }
 
 func Test_RejectReservationRequest_RequestNotFound(t *testing.T) {
-	svc, repo, _, _, _ := CreateTestRoomService()
+	svc, repo, _, _ := CreateTestRoomService()
 
 	repo.On("FindRequestByID", uint(1)).Return(nil, errors.New("not found"))
 
@@ -49,9 +49,9 @@ func Test_RejectReservationRequest_RequestNotFound(t *testing.T) {
 }
 
 func Test_RejectReservationRequest_UnauthorizedHost(t *testing.T) {
-	svc, repo, _, roomClient, _ := CreateTestRoomService()
+	svc, repo, _, roomClient := CreateTestRoomService()
 
-	req := &internal.ReservationRequest{ID: 1, RoomID: 1}
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, Status: internal.Pending}
 	room := *DefaultRoom
 	room.HostID = 99
 
@@ -65,9 +65,9 @@ func Test_RejectReservationRequest_UnauthorizedHost(t *testing.T) {
 }
 
 func Test_RejectReservationRequest_GuestNotFound(t *testing.T) {
-	svc, repo, userClient, roomClient, _ := CreateTestRoomService()
+	svc, repo, userClient, roomClient := CreateTestRoomService()
 
-	req := &internal.ReservationRequest{ID: 1, RoomID: 1}
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, Status: internal.Pending}
 	req.GuestID = 11
 	roomVal := *DefaultRoom
 	room := &roomVal
@@ -87,9 +87,9 @@ func Test_RejectReservationRequest_GuestNotFound(t *testing.T) {
 }
 
 func Test_RejectReservationRequest_GuestDbErr(t *testing.T) {
-	svc, repo, userClient, roomClient, _ := CreateTestRoomService()
+	svc, repo, userClient, roomClient := CreateTestRoomService()
 
-	req := &internal.ReservationRequest{ID: 1, RoomID: 1}
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, Status: internal.Pending}
 	req.GuestID = 11
 	roomVal := *DefaultRoom
 	room := &roomVal
@@ -105,9 +105,9 @@ func Test_RejectReservationRequest_GuestDbErr(t *testing.T) {
 }
 
 func Test_RejectReservationRequest_SetStatusFails(t *testing.T) {
-	svc, repo, userClient, roomClient, _ := CreateTestRoomService()
+	svc, repo, userClient, roomClient := CreateTestRoomService()
 
-	req := &internal.ReservationRequest{ID: 1, RoomID: 1}
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, Status: internal.Pending}
 	req.GuestID = 11
 	room := *DefaultRoom
 	room.HostID = 2
@@ -119,6 +119,7 @@ func Test_RejectReservationRequest_SetStatusFails(t *testing.T) {
 	repo.On("FindRequestByID", uint(1)).Return(req, nil)
 	roomClient.On("FindById", context.Background(), uint(1)).Return(&room, nil)
 	userClient.On("FindById", context.Background(), req.GuestID).Return(guest, nil)
+	repo.On("FindRequestByIDForUpdate", uint(1)).Return(req, nil)
 	repo.On("SetRequestStatus", uint(1), internal.Rejected).Return(errors.New("db error"))
 
 	err := svc.RejectReservationRequest(context.Background(), room.HostID, 1, "Token")
@@ -0,0 +1,126 @@
+package test
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/client/roomclient"
+	"bookem-reservation-service/client/transport"
+	"bookem-reservation-service/config"
+	"bookem-reservation-service/internal"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testExpiryConfig = config.ExpiryConfig{
+	ResponseDeadline: config.Duration(72 * time.Hour),
+	Interval:         config.Duration(time.Minute),
+	BatchSize:        100,
+}
+
+func newTestExpirer() (*internal.RequestExpirer, *MockReservationRepo, *MockRoomClient) {
+	repo := new(MockReservationRepo)
+	roomClient := new(MockRoomClient)
+	return internal.NewRequestExpirer(repo, roomClient, testExpiryConfig), repo, roomClient
+}
+
+func Test_ExpireRequests_NotifiesGuestAndHost(t *testing.T) {
+	expirer, repo, roomClient := newTestExpirer()
+
+	stale := internal.ReservationRequest{ID: 5, RoomID: 1, GuestID: 11, Status: internal.Pending, DateFrom: time.Now().AddDate(0, 0, 10)}
+	repo.On("FindExpirableRequests", mock.Anything, mock.Anything, 100).Return([]internal.ReservationRequest{stale}, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("ExpireRequests", []uint{5}).Return([]internal.ReservationRequest{stale}, nil)
+	ExpectNotification(repo, notificationclient.ReservationExpired, 11)
+	ExpectNotification(repo, notificationclient.ReservationExpired, DefaultRoom.HostID)
+
+	n, err := expirer.ExpireOnce(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	repo.AssertNumberOfCalls(t, "EnqueueNotification", 2)
+}
+
+func Test_ExpireRequests_UsesDeadlineAndStartDate(t *testing.T) {
+	expirer, repo, _ := newTestExpirer()
+
+	before := time.Now()
+	repo.On("FindExpirableRequests", mock.Anything, mock.Anything, 100).Return([]internal.ReservationRequest{}, nil)
+
+	n, err := expirer.ExpireOnce(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	call := repo.Calls[0]
+	createdBefore, startsBy := call.Arguments.Get(0).(time.Time), call.Arguments.Get(1).(time.Time)
+	assert.WithinDuration(t, before.Add(-72*time.Hour), createdBefore, time.Second)
+	assert.WithinDuration(t, before, startsBy, time.Second)
+	repo.AssertNotCalled(t, "ExpireRequests", mock.Anything)
+}
+
+func Test_ExpireRequests_SkipsRoomsWhileRoomServiceIsUnavailable(t *testing.T) {
+	expirer, repo, roomClient := newTestExpirer()
+
+	unreachable := internal.ReservationRequest{ID: 5, RoomID: 1, GuestID: 11}
+	reachable := internal.ReservationRequest{ID: 6, RoomID: 2, GuestID: 12}
+	repo.On("FindExpirableRequests", mock.Anything, mock.Anything, 100).
+		Return([]internal.ReservationRequest{unreachable, reachable}, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).
+		Return(nil, &transport.Error{Upstream: "room-service", Kind: transport.ErrUnavailable})
+	roomClient.On("FindById", mock.Anything, uint(2)).Return(&roomclient.RoomDTO{ID: 2, HostID: 3}, nil)
+	repo.On("ExpireRequests", []uint{6}).Return([]internal.ReservationRequest{reachable}, nil)
+	ExpectNotification(repo, notificationclient.ReservationExpired, 12)
+	ExpectNotification(repo, notificationclient.ReservationExpired, 3)
+
+	n, err := expirer.ExpireOnce(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func Test_ExpireRequests_DeletedRoomOnlyNotifiesGuest(t *testing.T) {
+	expirer, repo, roomClient := newTestExpirer()
+
+	req := internal.ReservationRequest{ID: 5, RoomID: 1, GuestID: 11}
+	repo.On("FindExpirableRequests", mock.Anything, mock.Anything, 100).Return([]internal.ReservationRequest{req}, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).
+		Return(nil, &transport.Error{Upstream: "room-service", Kind: transport.ErrNotFound})
+	repo.On("ExpireRequests", []uint{5}).Return([]internal.ReservationRequest{req}, nil)
+	ExpectNotification(repo, notificationclient.ReservationExpired, 11)
+
+	n, err := expirer.ExpireOnce(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	repo.AssertNumberOfCalls(t, "EnqueueNotification", 1)
+}
+
+func Test_ExpireRequests_RequestAnsweredInTheMeantime(t *testing.T) {
+	expirer, repo, roomClient := newTestExpirer()
+
+	req := internal.ReservationRequest{ID: 5, RoomID: 1, GuestID: 11}
+	repo.On("FindExpirableRequests", mock.Anything, mock.Anything, 100).Return([]internal.ReservationRequest{req}, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("ExpireRequests", []uint{5}).Return([]internal.ReservationRequest{}, nil)
+
+	n, err := expirer.ExpireOnce(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	repo.AssertNotCalled(t, "EnqueueNotification", mock.Anything)
+}
+
+func Test_ExpireRequests_RepoError(t *testing.T) {
+	expirer, repo, _ := newTestExpirer()
+
+	repo.On("FindExpirableRequests", mock.Anything, mock.Anything, 100).
+		Return([]internal.ReservationRequest{}, errors.New("db error"))
+
+	_, err := expirer.ExpireOnce(context.Background())
+
+	assert.Error(t, err)
+}
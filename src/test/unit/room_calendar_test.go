@@ -0,0 +1,125 @@
+package test
+
+import (
+	"bookem-reservation-service/client/roomclient"
+	"bookem-reservation-service/internal"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func july(day int) time.Time {
+	return time.Date(2026, 7, day, 0, 0, 0, 0, time.UTC)
+}
+
+func Test_GetRoomCalendar_Success(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	availability := &roomclient.RoomAvailabilityListDTO{ID: 1, RoomID: 1, Items: []roomclient.RoomAvailabilityItemDTO{
+		{ID: 1, DateFrom: july(1), DateTo: july(10), Available: true},
+		{ID: 2, DateFrom: july(5), DateTo: july(5), Available: false},
+	}}
+	prices := &roomclient.RoomPriceListDTO{ID: 1, RoomID: 1, BasePrice: 100, Items: []roomclient.RoomPriceItemDTO{
+		{ID: 1, DateFrom: july(3), DateTo: july(4), Price: 150},
+	}}
+
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", mock.Anything, uint(1)).Return(availability, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", mock.Anything, uint(1)).Return(prices, nil)
+	repo.On("FindReservations", internal.ReservationFilter{
+		RoomIDs: []uint{1}, ExcludeCancelled: true, From: july(1), To: july(6),
+	}).Return([]internal.Reservation{
+		{ID: 40, RoomID: 1, DateFrom: july(2), DateTo: july(3)},
+	}, nil)
+	repo.On("FindRequests", internal.RequestFilter{
+		RoomIDs: []uint{1}, Statuses: []internal.ReservationRequestStatus{internal.Pending}, From: july(1), To: july(6),
+	}).Return([]internal.ReservationRequest{
+		{ID: 7, RoomID: 1, DateFrom: july(4), DateTo: july(6)},
+		{ID: 8, RoomID: 1, DateFrom: july(1).AddDate(0, 0, -1), DateTo: july(1)},
+	}, nil)
+
+	cal, err := svc.GetRoomCalendar(context.Background(), 2, 1, july(1), july(6))
+
+	assert.NoError(t, err)
+	if !assert.Len(t, cal.Days, 6) {
+		return
+	}
+	days := cal.Days
+
+	assert.Equal(t, july(1), days[0].Date)
+	assert.Equal(t, []uint{8}, days[0].RequestIDs)
+	assert.False(t, days[0].Booked())
+
+	assert.Equal(t, uint(40), days[1].ReservationID)
+	assert.Equal(t, uint(40), days[2].ReservationID)
+	assert.False(t, days[3].Booked())
+
+	assert.Equal(t, uint(100), days[1].Price)
+	assert.Equal(t, uint(150), days[2].Price)
+	assert.Equal(t, uint(150), days[3].Price)
+
+	assert.Equal(t, []uint{7}, days[3].RequestIDs)
+	assert.Equal(t, []uint{7}, days[5].RequestIDs)
+
+	assert.True(t, days[3].Available())
+	assert.False(t, days[4].Available(), "the unavailable item wins where items overlap")
+	assert.Equal(t, uint(2), days[4].Availability.ID)
+	assert.True(t, days[5].Available())
+}
+
+func Test_GetRoomCalendar_DayWithoutAvailability(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", mock.Anything, uint(1)).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", mock.Anything, uint(1)).Return(DefaultPriceList, nil)
+	repo.On("FindReservations", mock.Anything).Return([]internal.Reservation{}, nil)
+	repo.On("FindRequests", mock.Anything).Return([]internal.ReservationRequest{}, nil)
+
+	cal, err := svc.GetRoomCalendar(context.Background(), 2, 1, july(1), july(1))
+
+	assert.NoError(t, err)
+	assert.Len(t, cal.Days, 1)
+	assert.Nil(t, cal.Days[0].Availability)
+	assert.False(t, cal.Days[0].Available())
+	assert.Equal(t, uint(100), cal.Days[0].Price)
+	assert.True(t, cal.PerGuest)
+
+	dto := internal.NewRoomCalendarDTO(*cal)
+	assert.Equal(t, []uint{}, dto.Days[0].RequestIDs)
+	assert.Nil(t, dto.Days[0].ReservationID)
+}
+
+func Test_GetRoomCalendar_NotHostOfRoom(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+
+	_, err := svc.GetRoomCalendar(context.Background(), 6, 1, july(1), july(6))
+
+	assert.Equal(t, internal.ErrUnauthorized, err)
+	repo.AssertNotCalled(t, "FindReservations", mock.Anything)
+}
+
+func Test_GetRoomCalendar_BadWindow(t *testing.T) {
+	tests := map[string]struct{ from, to time.Time }{
+		"reversed": {from: july(6), to: july(1)},
+		"too long": {from: july(1), to: july(1).AddDate(0, 0, internal.MaxCalendarDays)},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			svc, _, _, roomClient := CreateTestRoomService()
+
+			_, err := svc.GetRoomCalendar(context.Background(), 2, 1, tt.from, tt.to)
+
+			var apiErr *internal.APIError
+			if assert.ErrorAs(t, err, &apiErr) {
+				assert.Equal(t, 400, apiErr.Code)
+			}
+			roomClient.AssertNotCalled(t, "FindById", mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"bookem-reservation-service/internal"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_TransitionError_IsConflict(t *testing.T) {
+	err := &internal.TransitionError{Subject: internal.SubjectRequest, ID: 3, From: "rejected", To: "accepted"}
+
+	status, message := internal.MapErrorToHTTP(err)
+
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "request 3 cannot go from rejected to accepted", message)
+}
+
+func Test_ApproveReservationRequest_RejectedRequest(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, GuestID: 1, Status: internal.Rejected}
+	repo.On("FindRequestByID", uint(1)).Return(req, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+
+	err := svc.ApproveReservationRequest(context.Background(), 2, 1, "Token")
+
+	AssertTransitionError(t, err, "rejected", "accepted")
+	userClient.AssertNotCalled(t, "FindById", mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "CreateReservation", mock.Anything)
+	assert.Empty(t, repo.Transitions)
+}
+
+func Test_RejectReservationRequest_AlreadyAccepted(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, GuestID: 1, Status: internal.Accepted}
+	repo.On("FindRequestByID", uint(1)).Return(req, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+
+	err := svc.RejectReservationRequest(context.Background(), 2, 1, "Token")
+
+	AssertTransitionError(t, err, "accepted", "rejected")
+	repo.AssertNotCalled(t, "SetRequestStatus", mock.Anything, mock.Anything)
+}
+
+func Test_RejectReservationRequest_ExpiredInTheMeantime(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, GuestID: 1, Status: internal.Pending}
+	guest := *DefaultUser_Guest
+	guest.Deleted = false
+	repo.On("FindRequestByID", uint(1)).Return(req, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	userClient.On("FindById", mock.Anything, uint(1)).Return(&guest, nil)
+	repo.On("FindRequestByIDForUpdate", uint(1)).Return(&internal.ReservationRequest{ID: 1, Status: internal.Expired}, nil)
+
+	err := svc.RejectReservationRequest(context.Background(), 2, 1, "Token")
+
+	AssertTransitionError(t, err, "expired", "rejected")
+	repo.AssertNotCalled(t, "SetRequestStatus", mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "EnqueueNotification", mock.Anything)
+	assert.Empty(t, repo.Transitions)
+}
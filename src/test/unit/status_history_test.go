@@ -0,0 +1,130 @@
+package test
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/internal"
+	"bookem-reservation-service/util"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func Test_ApproveReservationRequest_RecordsHistory(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	req := &internal.ReservationRequest{ID: 1, RoomID: 1, GuestID: 1, GuestCount: 2, Status: internal.Pending}
+	overlapping := internal.ReservationRequest{ID: 8, RoomID: 1, GuestID: 3, Status: internal.Pending}
+	guest := *DefaultUser_Guest
+	guest.Deleted = false
+
+	repo.On("FindRequestByID", uint(1)).Return(req, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	userClient.On("FindById", mock.Anything, uint(1)).Return(&guest, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", mock.Anything, uint(1)).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", mock.Anything, uint(1)).Return(DefaultPriceList, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("FindRequestByIDForUpdate", uint(1)).Return(req, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("FindCancellationPolicy", uint(1)).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("CreateReservation", mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(*internal.Reservation).ID = 42
+	}).Return(nil)
+	repo.On("SetRequestStatus", uint(1), internal.Accepted).Return(nil)
+	repo.On("RejectPendingRequestsInRange", uint(1), mock.Anything, mock.Anything).Return([]internal.ReservationRequest{overlapping}, nil)
+	ExpectNotification(repo, notificationclient.ReservationAccepted, 1)
+
+	err := svc.ApproveReservationRequest(context.Background(), 2, 1, "Token")
+
+	assert.NoError(t, err)
+	assert.Len(t, repo.Transitions, 3)
+
+	created := repo.Transitions[0]
+	assert.Equal(t, internal.SubjectReservation, created.SubjectType)
+	assert.Equal(t, uint(42), created.SubjectID)
+	assert.Equal(t, "", created.FromStatus)
+	assert.Equal(t, string(internal.ReservationConfirmed), created.ToStatus)
+
+	accepted := repo.Transitions[1]
+	assert.Equal(t, internal.SubjectRequest, accepted.SubjectType)
+	assert.Equal(t, string(internal.Accepted), accepted.ToStatus)
+	assert.Equal(t, uint(2), accepted.ActorID)
+	assert.Equal(t, util.Host, accepted.ActorRole)
+
+	rejected := repo.Transitions[2]
+	assert.Equal(t, uint(8), rejected.SubjectID)
+	assert.Equal(t, string(internal.Rejected), rejected.ToStatus)
+	assert.Equal(t, internal.ActorSystem, rejected.ActorRole)
+	assert.Equal(t, "dates taken by reservation 42", rejected.Reason)
+}
+
+func Test_HostCancelReservation_RecordsReason(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	res := &internal.Reservation{ID: 1, GuestID: 1, RoomID: 1, Status: internal.ReservationConfirmed, DateFrom: time.Now().Add(48 * time.Hour)}
+	userClient.On("FindById", mock.Anything, uint(2)).Return(DefaultUser_Host, nil)
+	repo.On("FindReservationById", uint(1)).Return(res, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("FindReservationByIDForUpdate", uint(1)).Return(res, nil)
+	repo.On("CancelReservation", uint(1), mock.Anything).Return(nil)
+	repo.On("CancelPendingModifications", uint(1), mock.Anything).Return([]internal.ReservationModification{}, nil)
+	repo.On("FindWaitingEntries", mock.Anything, mock.Anything, mock.Anything).Return([]internal.WaitlistEntry{}, nil)
+	ExpectNotification(repo, notificationclient.ReservationCancelledByHost, 1)
+
+	_, err := svc.HostCancelReservation(context.Background(), 2, 1, "Burst pipe")
+
+	assert.NoError(t, err)
+	assert.Len(t, repo.Transitions, 1)
+	assert.Equal(t, string(internal.ReservationCancelled), repo.Transitions[0].ToStatus)
+	assert.Equal(t, util.Host, repo.Transitions[0].ActorRole)
+	assert.Equal(t, "Burst pipe", repo.Transitions[0].Reason)
+}
+
+func Test_GetRequestHistory_Access(t *testing.T) {
+	tests := map[string]struct {
+		caller  internal.Actor
+		allowed bool
+	}{
+		"owner guest":  {caller: internal.Actor{ID: 1, Role: util.Guest}, allowed: true},
+		"other guest":  {caller: internal.Actor{ID: 5, Role: util.Guest}, allowed: false},
+		"room's host":  {caller: internal.Actor{ID: 2, Role: util.Host}, allowed: true},
+		"another host": {caller: internal.Actor{ID: 6, Role: util.Host}, allowed: false},
+		"admin":        {caller: internal.Actor{ID: 9, Role: util.Admin}, allowed: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			svc, repo, _, roomClient := CreateTestRoomService()
+
+			repo.On("FindRequestByIDUnscoped", uint(3)).Return(&internal.ReservationRequest{ID: 3, RoomID: 1, GuestID: 1}, nil)
+			roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+			repo.On("FindTransitions", internal.SubjectRequest, uint(3)).Return([]internal.StatusTransition{{ToStatus: "pending"}}, nil)
+
+			history, err := svc.GetRequestHistory(context.Background(), tt.caller, 3)
+
+			if tt.allowed {
+				assert.NoError(t, err)
+				assert.Len(t, history, 1)
+			} else {
+				assert.Equal(t, internal.ErrUnauthorized, err)
+			}
+		})
+	}
+}
+
+func Test_GetModificationHistory(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	repo.On("FindModificationByID", uint(9)).Return(&internal.ReservationModification{ID: 9, ReservationID: 5, RoomID: 1, GuestID: 1}, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("FindTransitions", internal.SubjectModification, uint(9)).Return([]internal.StatusTransition{{ToStatus: "pending"}, {FromStatus: "pending", ToStatus: "approved"}}, nil)
+
+	history, err := svc.GetModificationHistory(context.Background(), internal.Actor{ID: 2, Role: util.Host}, 9)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+
+	_, err = svc.GetModificationHistory(context.Background(), internal.Actor{ID: 5, Role: util.Guest}, 9)
+	assert.Equal(t, internal.ErrUnauthorized, err)
+}
@@ -0,0 +1,186 @@
+package test
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/config"
+	"bookem-reservation-service/internal"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testStayConfig = config.StayConfig{
+	NoShowGrace:   config.Duration(36 * time.Hour),
+	CheckOutGrace: config.Duration(12 * time.Hour),
+	Interval:      config.Duration(time.Minute),
+	BatchSize:     100,
+}
+
+// expectNoShowNotification expects the guest of res to be told the host of
+// DefaultRoom marked them as a no-show.
+func expectNoShowNotification(repo *MockReservationRepo, res *internal.Reservation) *mock.Call {
+	return repo.On("EnqueueNotification", mock.MatchedBy(func(n *internal.NotificationOutbox) bool {
+		return n.Type == notificationclient.ReservationNoShow && n.ReceiverID == res.GuestID &&
+			n.Subject == DefaultRoom.HostID && n.Object == res.RoomID
+	})).Return(nil)
+}
+
+// stayOf is a reservation of DefaultRoom that begins days days from today
+// and lasts two nights.
+func stayOf(days int, status internal.ReservationStatus) *internal.Reservation {
+	from := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, days)
+	return &internal.Reservation{ID: 3, RoomID: 1, GuestID: 1, DateFrom: from, DateTo: from.AddDate(0, 0, 2), Status: status}
+}
+
+func Test_CheckIn_Success(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	res := stayOf(0, internal.ReservationConfirmed)
+	repo.On("FindReservationById", uint(3)).Return(res, nil)
+	repo.On("FindReservationByIDForUpdate", uint(3)).Return(res, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("SetStayStatus", uint(3), internal.ReservationCheckedIn, mock.Anything).Return(nil)
+
+	checkedIn, err := svc.CheckIn(context.Background(), DefaultRoom.HostID, 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, internal.ReservationCheckedIn, checkedIn.Status)
+	assert.NotNil(t, checkedIn.CheckedInAt)
+	if assert.Len(t, repo.Transitions, 1) {
+		assert.Equal(t, string(internal.ReservationCheckedIn), repo.Transitions[0].ToStatus)
+	}
+}
+
+func Test_CheckIn_BeforeStay(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	repo.On("FindReservationById", uint(3)).Return(stayOf(1, internal.ReservationConfirmed), nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+
+	_, err := svc.CheckIn(context.Background(), DefaultRoom.HostID, 3)
+
+	var apiErr *internal.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, 400, apiErr.Code)
+	}
+	repo.AssertNotCalled(t, "SetStayStatus", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func Test_CheckOut_NotCheckedIn(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	repo.On("FindReservationById", uint(3)).Return(stayOf(0, internal.ReservationConfirmed), nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+
+	_, err := svc.CheckOut(context.Background(), DefaultRoom.HostID, 3)
+
+	AssertTransitionError(t, err, string(internal.ReservationConfirmed), string(internal.ReservationCheckedOut))
+}
+
+func Test_MarkNoShow_NotOwner(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	repo.On("FindReservationById", uint(3)).Return(stayOf(-1, internal.ReservationConfirmed), nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+
+	_, err := svc.MarkNoShow(context.Background(), DefaultRoom.HostID+1, 3)
+
+	assert.Equal(t, internal.ErrUnauthorized, err)
+}
+
+func Test_MarkNoShow_NotifiesGuest(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	res := stayOf(-1, internal.ReservationConfirmed)
+	repo.On("FindReservationById", uint(3)).Return(res, nil)
+	repo.On("FindReservationByIDForUpdate", uint(3)).Return(res, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("SetStayStatus", uint(3), internal.ReservationNoShow, mock.Anything).Return(nil)
+	expectNoShowNotification(repo, res)
+
+	noShow, err := svc.MarkNoShow(context.Background(), DefaultRoom.HostID, 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, internal.ReservationNoShow, noShow.Status)
+	repo.AssertNumberOfCalls(t, "EnqueueNotification", 1)
+}
+
+func Test_RequestModification_StayOver(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	repo.On("FindReservationById", uint(3)).Return(stayOf(-1, internal.ReservationNoShow), nil)
+
+	_, err := svc.RequestModification(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, 3, internal.ModifyReservationDTO{})
+
+	var apiErr *internal.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, 400, apiErr.Code)
+	}
+}
+
+func Test_CloseStays(t *testing.T) {
+	repo := new(MockReservationRepo)
+	closer := internal.NewStayCloser(repo, new(MockRoomClient), testStayConfig)
+
+	neverRecorded := stayOf(-4, internal.ReservationConfirmed)
+	neverRecorded.ID = 3
+	stayedOn := stayOf(-4, internal.ReservationCheckedIn)
+	stayedOn.ID = 4
+	recorded := stayOf(-4, internal.ReservationCheckedOut) // Checked out by the host in the meantime
+	recorded.ID = 5
+
+	repo.On("FindOverdueStays", time.Time{}, mock.Anything, 100).
+		Return([]internal.Reservation{*neverRecorded, *stayedOn, *recorded}, nil)
+	repo.On("FindReservationByIDForUpdate", uint(3)).Return(neverRecorded, nil)
+	repo.On("FindReservationByIDForUpdate", uint(4)).Return(stayedOn, nil)
+	repo.On("FindReservationByIDForUpdate", uint(5)).Return(recorded, nil)
+	repo.On("SetStayStatus", uint(3), internal.ReservationCheckedIn, neverRecorded.DateFrom).Return(nil)
+	repo.On("SetStayStatus", uint(3), internal.ReservationCheckedOut, mock.Anything).Return(nil)
+	repo.On("SetStayStatus", uint(4), internal.ReservationCheckedOut, mock.Anything).Return(nil)
+
+	n, err := closer.CloseOnce(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	repo.AssertNumberOfCalls(t, "SetStayStatus", 3)
+	repo.AssertNotCalled(t, "EnqueueNotification", mock.Anything)
+	if assert.Len(t, repo.Transitions, 3) {
+		assert.Equal(t, string(internal.ReservationCheckedIn), repo.Transitions[0].ToStatus)
+		assert.Equal(t, string(internal.ReservationCheckedOut), repo.Transitions[1].ToStatus)
+	}
+	for _, tr := range repo.Transitions {
+		assert.Equal(t, internal.ActorSystem, tr.ActorRole)
+	}
+}
+
+func Test_CloseStays_AutoNoShow(t *testing.T) {
+	repo := new(MockReservationRepo)
+	roomClient := new(MockRoomClient)
+	cfg := testStayConfig
+	cfg.AutoNoShow = true
+	closer := internal.NewStayCloser(repo, roomClient, cfg)
+
+	neverArrived := stayOf(-2, internal.ReservationConfirmed)
+	neverArrived.ID = 3
+	stayedOn := stayOf(-4, internal.ReservationCheckedIn)
+	stayedOn.ID = 4
+
+	repo.On("FindOverdueStays", mock.Anything, mock.Anything, 100).
+		Return([]internal.Reservation{*neverArrived, *stayedOn}, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("FindReservationByIDForUpdate", uint(3)).Return(neverArrived, nil)
+	repo.On("FindReservationByIDForUpdate", uint(4)).Return(stayedOn, nil)
+	repo.On("SetStayStatus", uint(3), internal.ReservationNoShow, mock.Anything).Return(nil)
+	repo.On("SetStayStatus", uint(4), internal.ReservationCheckedOut, mock.Anything).Return(nil)
+	expectNoShowNotification(repo, neverArrived)
+
+	n, err := closer.CloseOnce(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	repo.AssertNumberOfCalls(t, "EnqueueNotification", 1)
+	roomClient.AssertNumberOfCalls(t, "FindById", 1)
+}
@@ -0,0 +1,166 @@
+package test
+
+import (
+	"bookem-reservation-service/config"
+	"bookem-reservation-service/internal"
+	"bookem-reservation-service/util"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// useRecordingTracer makes util.TEL record spans for the rest of the test.
+func useRecordingTracer(t *testing.T) (*sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	util.TEL.UseTracer(tp.Tracer("test"))
+	t.Cleanup(func() { util.TEL.UseTracer(nil) })
+
+	return tp, recorder
+}
+
+func Test_Telemetry_StartWithoutTracerKeepsContext(t *testing.T) {
+	ctx := context.Background()
+
+	newCtx, span := util.TEL.Start(ctx, "noop")
+	defer span.End()
+
+	assert.Equal(t, ctx, newCtx)
+	assert.False(t, span.SpanContext().IsValid())
+}
+
+func Test_Telemetry_ConcurrentSpansAreParentedPerContext(t *testing.T) {
+	_, recorder := useRecordingTracer(t)
+
+	const workers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, root := util.TEL.Start(context.Background(), "root", attribute.Int("worker", i))
+			defer root.End()
+
+			for j := 0; j < 3; j++ {
+				childCtx, child := util.TEL.Start(ctx, "child", attribute.Int("worker", i))
+				util.TEL.Info(childCtx, "working", "worker", i)
+				child.End()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	roots := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		if span.Name() == "root" {
+			roots[span.SpanContext().SpanID().String()] = span
+		}
+	}
+	require.Len(t, roots, workers)
+
+	children := 0
+	for _, span := range recorder.Ended() {
+		if span.Name() != "child" {
+			continue
+		}
+		children++
+
+		parent, ok := roots[span.Parent().SpanID().String()]
+		require.True(t, ok, "child span without a root parent")
+		assert.Equal(t, parent.SpanContext().TraceID(), span.SpanContext().TraceID())
+		assert.Equal(t, workerOf(parent), workerOf(span), "child span parented to another worker's root")
+	}
+	assert.Equal(t, workers*3, children)
+}
+
+func Test_Telemetry_ConcurrentRequestsHaveTheirOwnTraces(t *testing.T) {
+	tp, recorder := useRecordingTracer(t)
+	gin.SetMode(gin.TestMode)
+
+	svc, repo, _, _ := CreateTestRoomService()
+	repo.On("HasReservationsInRange", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasHold", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasWaitlistOffer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+
+	server := gin.New()
+	server.Use(util.TEL.GetLoggingMiddleware())
+	server.Use(otelgin.Middleware("test", otelgin.WithTracerProvider(tp)))
+	route := internal.NewRoute(internal.NewHandler(svc), internal.Idempotency(repo, config.Default().Idempotency))
+	route.Route(server.Group("/api"))
+
+	const requests = 40
+	var wg sync.WaitGroup
+	for i := 1; i <= requests; i++ {
+		wg.Add(1)
+		go func(roomID int) {
+			defer wg.Done()
+			url := fmt.Sprintf("/api/room/%d/availability?from=2025-09-01&to=2025-09-03", roomID)
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+		}(i)
+	}
+	wg.Wait()
+
+	byID := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		byID[span.SpanContext().SpanID().String()] = span
+	}
+
+	seenRooms := map[int64]bool{}
+	for _, span := range recorder.Ended() {
+		if span.Name() != "is-room-available" {
+			continue
+		}
+
+		handlerSpan, ok := byID[span.Parent().SpanID().String()]
+		require.True(t, ok)
+		assert.Equal(t, "check-availability-api", handlerSpan.Name())
+
+		serverSpan, ok := byID[handlerSpan.Parent().SpanID().String()]
+		require.True(t, ok)
+		assert.Equal(t, span.SpanContext().TraceID(), serverSpan.SpanContext().TraceID())
+
+		// The service span must belong to the HTTP request for the same room.
+		roomID := attrInt(span, "room.id")
+		seenRooms[roomID] = true
+		assert.True(t, hasStringAttr(serverSpan, fmt.Sprintf("/api/room/%d/availability", roomID)),
+			"service span for room %d is under another request's trace", roomID)
+	}
+	assert.Len(t, seenRooms, requests)
+}
+
+func workerOf(span sdktrace.ReadOnlySpan) int64 {
+	return attrInt(span, "worker")
+}
+
+func attrInt(span sdktrace.ReadOnlySpan, key string) int64 {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value.AsInt64()
+		}
+	}
+	return -1
+}
+
+func hasStringAttr(span sdktrace.ReadOnlySpan, value string) bool {
+	for _, kv := range span.Attributes() {
+		if kv.Value.Type() == attribute.STRING && kv.Value.AsString() == value {
+			return true
+		}
+	}
+	return false
+}
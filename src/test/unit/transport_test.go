@@ -0,0 +1,132 @@
+package test
+
+import (
+	"bookem-reservation-service/client/transport"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPolicy() transport.Policy {
+	return transport.Policy{
+		MaxRetries:       2,
+		BaseBackoff:      time.Millisecond,
+		MaxBackoff:       5 * time.Millisecond,
+		BreakerThreshold: 3,
+		BreakerCooldown:  time.Hour,
+	}
+}
+
+// flakyServer answers 503 to the first `failures` calls and 200 afterwards.
+func flakyServer(failures int32) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return srv, &calls
+}
+
+func Test_Transport_RetriesIdempotentRequests(t *testing.T) {
+	srv, calls := flakyServer(2)
+	defer srv.Close()
+
+	client := transport.New("test", srv.Client(), testPolicy())
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+
+	resp, err := client.Do(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+	resp.Body.Close()
+}
+
+func Test_Transport_DoesNotRetryPost(t *testing.T) {
+	srv, calls := flakyServer(1)
+	defer srv.Close()
+
+	client := transport.New("test", srv.Client(), testPolicy())
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, nil)
+
+	resp, err := client.Do(req)
+
+	assert.ErrorIs(t, err, transport.ErrUnavailable)
+	assert.Nil(t, resp)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+func Test_Transport_NotFoundIsNotUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	client := transport.New("test", srv.Client(), testPolicy())
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	statusErr := transport.StatusError("test", resp)
+	assert.ErrorIs(t, statusErr, transport.ErrNotFound)
+	assert.False(t, errors.Is(statusErr, transport.ErrUnavailable))
+}
+
+func Test_Transport_BreakerOpensAfterThreshold(t *testing.T) {
+	srv, calls := flakyServer(1000)
+	defer srv.Close()
+
+	policy := testPolicy()
+	policy.MaxRetries = 0
+	client := transport.New("test", srv.Client(), policy)
+
+	for i := 0; i < policy.BreakerThreshold+2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		_, err := client.Do(req)
+		assert.ErrorIs(t, err, transport.ErrUnavailable)
+	}
+
+	assert.Equal(t, int32(policy.BreakerThreshold), atomic.LoadInt32(calls))
+}
+
+func Test_Transport_ConnectionErrorIsUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	client := transport.New("test", http.DefaultClient, testPolicy())
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+
+	_, err := client.Do(req)
+
+	assert.ErrorIs(t, err, transport.ErrUnavailable)
+}
+
+func Test_Transport_StopsRetryingWhenContextIsDone(t *testing.T) {
+	srv, calls := flakyServer(1000)
+	defer srv.Close()
+
+	policy := testPolicy()
+	policy.MaxRetries = 10
+	policy.BaseBackoff = time.Hour
+	policy.MaxBackoff = time.Hour
+	client := transport.New("test", srv.Client(), policy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+
+	_, err := client.Do(req)
+
+	assert.ErrorIs(t, err, transport.ErrUnavailable)
+	assert.LessOrEqual(t, atomic.LoadInt32(calls), int32(2))
+}
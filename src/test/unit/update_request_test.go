@@ -0,0 +1,190 @@
+package test
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/internal"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func pendingRequest() internal.ReservationRequest {
+	from := time.Date(2026, 12, 10, 0, 0, 0, 0, time.UTC)
+	return internal.ReservationRequest{
+		ID:                 4,
+		RoomID:             1,
+		RoomAvailabilityID: 7,
+		RoomPriceID:        7,
+		GuestID:            1,
+		DateFrom:           from,
+		DateTo:             from.AddDate(0, 0, 2),
+		GuestCount:         2,
+		Status:             internal.Pending,
+		Cost:               300,
+	}
+}
+
+// expectGuest sets up guest 1 with an account that still exists.
+func expectGuest(userClient *MockUserClient) {
+	guest := *DefaultUser_Guest
+	guest.Deleted = false
+	userClient.On("FindById", mock.Anything, uint(1)).Return(&guest, nil)
+}
+
+// expectUpdate sets up the guest's pending requests and the room the
+// changed request is priced for.
+func expectUpdate(repo *MockReservationRepo, userClient *MockUserClient, roomClient *MockRoomClient, pending ...internal.ReservationRequest) {
+	expectGuest(userClient)
+	repo.On("FindPendingRequestsByGuestID", uint(1)).Return(pending, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", mock.Anything, uint(1)).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", mock.Anything, uint(1)).Return(DefaultPriceList, nil)
+	roomClient.On("QueryForReservation", mock.Anything, mock.Anything, mock.Anything).Return(DefaultReservationQueryResponse, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+}
+
+func Test_UpdateRequest_Success(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	req := pendingRequest()
+	newTo := req.DateTo.AddDate(0, 0, 1)
+
+	expectUpdate(repo, userClient, roomClient, req)
+	repo.On("HasReservationsInRange", uint(1), req.DateFrom, newTo).Return(false, nil)
+	repo.On("HasHold", uint(1), req.DateFrom, newTo, uint(1), mock.Anything).Return(false, nil)
+	repo.On("HasWaitlistOffer", uint(1), req.DateFrom, newTo, uint(1), mock.Anything).Return(false, nil)
+	repo.On("FindRequestByIDForUpdate", uint(4)).Return(&req, nil)
+	repo.On("UpdateRequest", mock.MatchedBy(func(r *internal.ReservationRequest) bool {
+		return r.ID == 4 && r.DateTo.Equal(newTo) && r.Cost == 400 && r.RoomAvailabilityID == DefaultAvailabilityList.ID && r.RoomPriceID == DefaultPriceList.ID
+	})).Return(nil)
+	ExpectNotification(repo, notificationclient.ReservationRequestChanged, 2)
+
+	updated, err := svc.UpdateRequest(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, 4, internal.UpdateReservationRequestDTO{DateTo: &newTo})
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(400), updated.Cost)
+	assert.Equal(t, uint(2), updated.GuestCount)
+	repo.AssertCalled(t, "LockRoom", uint(1))
+	repo.AssertNumberOfCalls(t, "EnqueueNotification", 1)
+	if assert.Len(t, repo.Transitions, 1) {
+		assert.Equal(t, string(internal.Pending), repo.Transitions[0].FromStatus)
+		assert.Equal(t, string(internal.Pending), repo.Transitions[0].ToStatus)
+		assert.Equal(t, uint(1), repo.Transitions[0].ActorID)
+	}
+}
+
+func Test_UpdateRequest_NotPendingRequestOfGuest(t *testing.T) {
+	svc, repo, userClient, _ := CreateTestRoomService()
+
+	expectGuest(userClient)
+	repo.On("FindPendingRequestsByGuestID", uint(1)).Return([]internal.ReservationRequest{}, nil)
+	guests := uint(3)
+
+	_, err := svc.UpdateRequest(context.Background(), internal.AuthContext{CallerID: 1}, 4, internal.UpdateReservationRequestDTO{GuestCount: &guests})
+
+	var apiErr *internal.APIError
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 404, apiErr.Code)
+}
+
+func Test_UpdateRequest_OverlapsOwnRequest(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	req := pendingRequest()
+	other := pendingRequest()
+	other.ID = 5
+	other.DateFrom = req.DateTo.AddDate(0, 0, 2)
+	other.DateTo = other.DateFrom.AddDate(0, 0, 2)
+	newTo := other.DateFrom
+
+	expectUpdate(repo, userClient, roomClient, req, other)
+	repo.On("FindRequestByIDForUpdate", uint(4)).Return(&req, nil)
+
+	_, err := svc.UpdateRequest(context.Background(), internal.AuthContext{CallerID: 1}, 4, internal.UpdateReservationRequestDTO{DateTo: &newTo})
+
+	assert.Equal(t, internal.ErrConflict, err)
+	repo.AssertNotCalled(t, "UpdateRequest", mock.Anything)
+}
+
+func Test_UpdateRequest_OverlapsReservation(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	req := pendingRequest()
+	guests := uint(3)
+
+	expectUpdate(repo, userClient, roomClient, req)
+	repo.On("FindRequestByIDForUpdate", uint(4)).Return(&req, nil)
+	repo.On("HasReservationsInRange", uint(1), req.DateFrom, req.DateTo).Return(true, nil)
+
+	_, err := svc.UpdateRequest(context.Background(), internal.AuthContext{CallerID: 1}, 4, internal.UpdateReservationRequestDTO{GuestCount: &guests})
+
+	assert.Equal(t, internal.ErrConflict, err)
+	repo.AssertCalled(t, "LockRoom", uint(1))
+	repo.AssertNotCalled(t, "UpdateRequest", mock.Anything)
+}
+
+func Test_UpdateRequest_HeldBySomeoneElse(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	req := pendingRequest()
+	newTo := req.DateTo.AddDate(0, 0, 1)
+
+	expectUpdate(repo, userClient, roomClient, req)
+	repo.On("FindRequestByIDForUpdate", uint(4)).Return(&req, nil)
+	repo.On("HasReservationsInRange", uint(1), req.DateFrom, newTo).Return(false, nil)
+	repo.On("HasHold", uint(1), req.DateFrom, newTo, uint(1), mock.Anything).Return(true, nil)
+
+	_, err := svc.UpdateRequest(context.Background(), internal.AuthContext{CallerID: 1}, 4, internal.UpdateReservationRequestDTO{DateTo: &newTo})
+
+	assert.Equal(t, internal.ErrConflict, err)
+	repo.AssertNotCalled(t, "UpdateRequest", mock.Anything)
+}
+
+func Test_UpdateRequest_AcceptedInTheMeantime(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	req := pendingRequest()
+	accepted := req
+	accepted.Status = internal.Accepted
+	guests := uint(3)
+
+	expectUpdate(repo, userClient, roomClient, req)
+	repo.On("FindRequestByIDForUpdate", uint(4)).Return(&accepted, nil)
+
+	_, err := svc.UpdateRequest(context.Background(), internal.AuthContext{CallerID: 1}, 4, internal.UpdateReservationRequestDTO{GuestCount: &guests})
+
+	AssertTransitionError(t, err, string(internal.Accepted), string(internal.Pending))
+	repo.AssertNotCalled(t, "UpdateRequest", mock.Anything)
+	assert.Empty(t, repo.Transitions)
+}
+
+func Test_UpdateRequest_DeletedAccount(t *testing.T) {
+	svc, repo, userClient, _ := CreateTestRoomService()
+
+	guest := *DefaultUser_Guest
+	guest.Deleted = true
+	userClient.On("FindById", mock.Anything, uint(1)).Return(&guest, nil)
+	guests := uint(3)
+
+	_, err := svc.UpdateRequest(context.Background(), internal.AuthContext{CallerID: 1}, 4, internal.UpdateReservationRequestDTO{GuestCount: &guests})
+
+	assert.Equal(t, internal.ErrUnauthorized, err)
+	repo.AssertNotCalled(t, "FindPendingRequestsByGuestID", mock.Anything)
+}
+
+func Test_UpdateRequest_ReversedDates(t *testing.T) {
+	svc, repo, userClient, _ := CreateTestRoomService()
+
+	req := pendingRequest()
+	newFrom := req.DateTo.AddDate(0, 0, 1)
+	expectGuest(userClient)
+	repo.On("FindPendingRequestsByGuestID", uint(1)).Return([]internal.ReservationRequest{req}, nil)
+
+	_, err := svc.UpdateRequest(context.Background(), internal.AuthContext{CallerID: 1}, 4, internal.UpdateReservationRequestDTO{DateFrom: &newFrom})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "dates are reversed")
+}
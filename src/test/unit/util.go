@@ -6,8 +6,10 @@ import (
 	"bookem-reservation-service/client/userclient"
 	"bookem-reservation-service/internal"
 	"context"
+	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -16,21 +18,53 @@ func CreateTestRoomService() (
 	*MockReservationRepo,
 	*MockUserClient,
 	*MockRoomClient,
-	*MockNotificationClient,
 ) {
 	mockRepo := new(MockReservationRepo)
 	mockUserClient := new(MockUserClient)
 	mockRoomClient := new(MockRoomClient)
-	mockNotificationClient := new(MockNotificationClient)
 
-	svc := internal.NewService(mockRepo, mockUserClient, mockRoomClient, mockNotificationClient)
-	return svc, mockRepo, mockUserClient, mockRoomClient, mockNotificationClient
+	svc := internal.NewService(mockRepo, mockUserClient, mockRoomClient)
+	return svc, mockRepo, mockUserClient, mockRoomClient
+}
+
+// ExpectNotification expects a notification of type typ for receiverID to be
+// put in the outbox.
+func ExpectNotification(repo *MockReservationRepo, typ notificationclient.NotificationType, receiverID uint) *mock.Call {
+	return repo.On("EnqueueNotification", mock.MatchedBy(func(n *internal.NotificationOutbox) bool {
+		return n.Type == typ && n.ReceiverID == receiverID && n.Status == internal.OutboxPending
+	})).Return(nil)
+}
+
+// AssertTransitionError asserts that err is the state machine refusing to move
+// something from from to to.
+func AssertTransitionError(t *testing.T, err error, from, to string) bool {
+	t.Helper()
+	var terr *internal.TransitionError
+	if !assert.ErrorAs(t, err, &terr) {
+		return false
+	}
+	return assert.Equal(t, from, terr.From) && assert.Equal(t, to, terr.To)
 }
 
 // ----------------------------------------------- Mock Reservation repo
 
 type MockReservationRepo struct {
 	mock.Mock
+
+	// Transitions collects the recorded status transitions, so tests that
+	// don't care about the status history don't have to expect them.
+	Transitions []internal.StatusTransition
+}
+
+// Transaction runs fn against the mock itself, so expectations set on the
+// mock apply both inside and outside of transactions.
+func (r *MockReservationRepo) Transaction(fn func(tx internal.Repository) error) error {
+	return fn(r)
+}
+
+func (r *MockReservationRepo) LockRoom(roomID uint) error {
+	args := r.Called(roomID)
+	return args.Error(0)
 }
 
 func (r *MockReservationRepo) CreateRequest(req *internal.ReservationRequest) error {
@@ -38,8 +72,8 @@ func (r *MockReservationRepo) CreateRequest(req *internal.ReservationRequest) er
 	return args.Error(0)
 }
 
-func (r *MockReservationRepo) DeleteRequest(id uint) error {
-	args := r.Called(id)
+func (r *MockReservationRepo) WithdrawRequest(id uint, at time.Time) error {
+	args := r.Called(id, at)
 	return args.Error(0)
 }
 
@@ -48,18 +82,18 @@ func (r *MockReservationRepo) FindRequestsByRoomIDUpcoming(roomID uint, now time
 	return args.Get(0).([]internal.ReservationRequest), args.Error(1)
 }
 
-func (r *MockReservationRepo) SetRequestStatus(id uint, status internal.ReservationRequestStatus) error {
-	args := r.Called(id, status)
+func (r *MockReservationRepo) UpdateRequest(req *internal.ReservationRequest) error {
+	args := r.Called(req)
 	return args.Error(0)
 }
 
-func (r *MockReservationRepo) RejectPendingRequestsInRange(roomID uint, from, to time.Time) error {
-	args := r.Called(roomID, from, to)
+func (r *MockReservationRepo) SetRequestStatus(id uint, status internal.ReservationRequestStatus) error {
+	args := r.Called(id, status)
 	return args.Error(0)
 }
 
-func (r *MockReservationRepo) FindPendingRequestsByRoomID(roomID uint) ([]internal.ReservationRequest, error) {
-	args := r.Called(roomID)
+func (r *MockReservationRepo) RejectPendingRequestsInRange(roomID uint, from, to time.Time) ([]internal.ReservationRequest, error) {
+	args := r.Called(roomID, from, to)
 	return args.Get(0).([]internal.ReservationRequest), args.Error(1)
 }
 
@@ -68,13 +102,21 @@ func (r *MockReservationRepo) FindPendingRequestsByGuestID(guestID uint) ([]inte
 	return args.Get(0).([]internal.ReservationRequest), args.Error(1)
 }
 
+func (r *MockReservationRepo) FindRequestsPage(filter internal.RequestFilter, page internal.PageRequest) (*internal.Page[internal.ReservationRequest], error) {
+	args := r.Called(filter, page)
+	if p, ok := args.Get(0).(*internal.Page[internal.ReservationRequest]); ok {
+		return p, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (r *MockReservationRepo) CreateReservation(res *internal.Reservation) error {
 	args := r.Called(res)
 	return args.Error(0)
 }
 
-func (r *MockReservationRepo) CancelReservation(id uint) error {
-	args := r.Called(id)
+func (r *MockReservationRepo) CancelReservation(id uint, c internal.Cancellation) error {
+	args := r.Called(id, c)
 	return args.Error(0)
 }
 
@@ -83,14 +125,9 @@ func (r *MockReservationRepo) FindCancelledReservationsByGuestID(guestID uint) (
 	return args.Get(0).([]internal.Reservation), args.Error(1)
 }
 
-func (r *MockReservationRepo) FindReservationsByRoomIDForDay(roomID uint, day time.Time) ([]internal.Reservation, error) {
-	args := r.Called(roomID, day)
-	return args.Get(0).([]internal.Reservation), args.Error(1)
-}
-
-func (r *MockReservationRepo) FindReservationsByGuestID(guestID uint) ([]internal.Reservation, error) {
-	args := r.Called(guestID)
-	return args.Get(0).([]internal.Reservation), args.Error(1)
+func (r *MockReservationRepo) HasReservationsInRange(roomID uint, from, to time.Time) (bool, error) {
+	args := r.Called(roomID, from, to)
+	return args.Bool(0), args.Error(1)
 }
 
 func (r *MockReservationRepo) CountGuestCancellations(guestID uint) (int64, error) {
@@ -98,9 +135,9 @@ func (r *MockReservationRepo) CountGuestCancellations(guestID uint) (int64, erro
 	return args.Get(0).(int64), args.Error(1)
 }
 
-func (r *MockReservationRepo) FindReservationsByRoomID(roomID uint) ([]internal.Reservation, error) {
-	args := r.Called(roomID)
-	return args.Get(0).([]internal.Reservation), args.Error(1)
+func (r *MockReservationRepo) CountHostCancellations(hostID uint) (int64, error) {
+	args := r.Called(hostID)
+	return args.Get(0).(int64), args.Error(1)
 }
 
 func (m *MockReservationRepo) FindRequestByID(id uint) (*internal.ReservationRequest, error) {
@@ -111,16 +148,228 @@ func (m *MockReservationRepo) FindRequestByID(id uint) (*internal.ReservationReq
 	return nil, args.Error(1)
 }
 
-func (r *MockReservationRepo) HasGuestPastReservationInRooms(guestID uint, roomIDs []uint, now time.Time) (bool, error) {
-    args := r.Called(guestID, roomIDs, now)
+func (m *MockReservationRepo) FindRequestByIDUnscoped(id uint) (*internal.ReservationRequest, error) {
+	args := m.Called(id)
+	if req, ok := args.Get(0).(*internal.ReservationRequest); ok {
+		return req, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockReservationRepo) FindRequestByIDForUpdate(id uint) (*internal.ReservationRequest, error) {
+	args := m.Called(id)
+	if req, ok := args.Get(0).(*internal.ReservationRequest); ok {
+		return req, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MockReservationRepo) FindExpirableRequests(createdBefore, startsBy time.Time, limit int) ([]internal.ReservationRequest, error) {
+	args := r.Called(createdBefore, startsBy, limit)
+	return args.Get(0).([]internal.ReservationRequest), args.Error(1)
+}
+
+func (r *MockReservationRepo) ExpireRequests(ids []uint) ([]internal.ReservationRequest, error) {
+	args := r.Called(ids)
+	return args.Get(0).([]internal.ReservationRequest), args.Error(1)
+}
+
+func (r *MockReservationRepo) HasGuestCompletedStayInRooms(guestID uint, roomIDs []uint) (bool, error) {
+    args := r.Called(guestID, roomIDs)
     return args.Bool(0), args.Error(1)
 }
 
-func (r *MockReservationRepo) GetAllPastReservationsByGuest(guestID uint, before time.Time) ([]internal.Reservation, error) {
-	args := r.Called(guestID, before)
+func (r *MockReservationRepo) SetStayStatus(id uint, status internal.ReservationStatus, at time.Time) error {
+	args := r.Called(id, status, at)
+	return args.Error(0)
+}
+
+func (r *MockReservationRepo) FindOverdueStays(startedBefore, endedBefore time.Time, limit int) ([]internal.Reservation, error) {
+	args := r.Called(startedBefore, endedBefore, limit)
+	if res := args.Get(0); res != nil {
+		return res.([]internal.Reservation), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MockReservationRepo) CreateFeedToken(t *internal.FeedToken) error {
+	args := r.Called(t)
+	return args.Error(0)
+}
+
+func (r *MockReservationRepo) FindFeedTokenByHash(hash string) (*internal.FeedToken, error) {
+	args := r.Called(hash)
+	if t, ok := args.Get(0).(*internal.FeedToken); ok {
+		return t, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MockReservationRepo) FindFeedTokensByUserID(userID uint) ([]internal.FeedToken, error) {
+	args := r.Called(userID)
+	return args.Get(0).([]internal.FeedToken), args.Error(1)
+}
+
+func (r *MockReservationRepo) RevokeFeedToken(id, userID uint, at time.Time) error {
+	args := r.Called(id, userID, at)
+	return args.Error(0)
+}
+
+func (r *MockReservationRepo) CreateWaitlistEntry(e *internal.WaitlistEntry) error {
+	args := r.Called(e)
+	return args.Error(0)
+}
+
+func (r *MockReservationRepo) FindWaitlistEntryByID(id uint) (*internal.WaitlistEntry, error) {
+	args := r.Called(id)
+	if e, ok := args.Get(0).(*internal.WaitlistEntry); ok {
+		return e, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MockReservationRepo) FindWaitlistEntryByIDForUpdate(id uint) (*internal.WaitlistEntry, error) {
+	args := r.Called(id)
+	if e, ok := args.Get(0).(*internal.WaitlistEntry); ok {
+		return e, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MockReservationRepo) FindWaitlistEntriesByGuestID(guestID uint) ([]internal.WaitlistEntry, error) {
+	args := r.Called(guestID)
+	return args.Get(0).([]internal.WaitlistEntry), args.Error(1)
+}
+
+func (r *MockReservationRepo) FindWaitingEntries(roomID uint, from, to time.Time) ([]internal.WaitlistEntry, error) {
+	args := r.Called(roomID, from, to)
+	return args.Get(0).([]internal.WaitlistEntry), args.Error(1)
+}
+
+func (r *MockReservationRepo) HasWaitlistOffer(roomID uint, from, to time.Time, exceptGuestID uint, now time.Time) (bool, error) {
+	args := r.Called(roomID, from, to, exceptGuestID, now)
+	return args.Bool(0), args.Error(1)
+}
+
+func (r *MockReservationRepo) FindLapsedWaitlistEntries(now time.Time, limit int) ([]internal.WaitlistEntry, error) {
+	args := r.Called(now, limit)
+	return args.Get(0).([]internal.WaitlistEntry), args.Error(1)
+}
+
+func (r *MockReservationRepo) UpdateWaitlistEntry(e *internal.WaitlistEntry) error {
+	args := r.Called(e)
+	return args.Error(0)
+}
+
+func (r *MockReservationRepo) CreateHold(h *internal.Hold) error {
+	args := r.Called(h)
+	return args.Error(0)
+}
+
+func (r *MockReservationRepo) HasHold(roomID uint, from, to time.Time, exceptGuestID uint, now time.Time) (bool, error) {
+	args := r.Called(roomID, from, to, exceptGuestID, now)
+	return args.Bool(0), args.Error(1)
+}
+
+func (r *MockReservationRepo) CountActiveHolds(guestID uint, now time.Time) (int64, error) {
+	args := r.Called(guestID, now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (r *MockReservationRepo) ReleaseHold(id, guestID uint, at time.Time) error {
+	args := r.Called(id, guestID, at)
+	return args.Error(0)
+}
+
+func (r *MockReservationRepo) ConvertHolds(guestID, roomID uint, from, to time.Time, requestID uint, now time.Time) (int64, error) {
+	args := r.Called(guestID, roomID, from, to, requestID, now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (r *MockReservationRepo) ExpireHolds(now time.Time) (int64, error) {
+	args := r.Called(now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (r *MockReservationRepo) CreateRequestGroup(g *internal.RequestGroup) error {
+	args := r.Called(g)
+	return args.Error(0)
+}
+
+func (r *MockReservationRepo) FindRequestGroupByID(id uint) (*internal.RequestGroup, error) {
+	args := r.Called(id)
+	if g := args.Get(0); g != nil {
+		return g.(*internal.RequestGroup), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MockReservationRepo) RejectPendingRequestsInGroup(groupID uint) ([]internal.ReservationRequest, error) {
+	args := r.Called(groupID)
+	return args.Get(0).([]internal.ReservationRequest), args.Error(1)
+}
+
+func (r *MockReservationRepo) FindRequests(filter internal.RequestFilter) ([]internal.ReservationRequest, error) {
+	args := r.Called(filter)
+	return args.Get(0).([]internal.ReservationRequest), args.Error(1)
+}
+
+func (r *MockReservationRepo) FindReservations(filter internal.ReservationFilter) ([]internal.Reservation, error) {
+	args := r.Called(filter)
 	return args.Get(0).([]internal.Reservation), args.Error(1)
 }
 
+func (r *MockReservationRepo) FindReservationsPage(filter internal.ReservationFilter, page internal.PageRequest) (*internal.Page[internal.Reservation], error) {
+	args := r.Called(filter, page)
+	if p, ok := args.Get(0).(*internal.Page[internal.Reservation]); ok {
+		return p, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MockReservationRepo) EnqueueNotification(n *internal.NotificationOutbox) error {
+	args := r.Called(n)
+	return args.Error(0)
+}
+
+func (r *MockReservationRepo) ClaimDueNotifications(now time.Time, lease time.Duration, limit int) ([]internal.NotificationOutbox, error) {
+	args := r.Called(now, lease, limit)
+	return args.Get(0).([]internal.NotificationOutbox), args.Error(1)
+}
+
+func (r *MockReservationRepo) UpdateNotificationDelivery(n *internal.NotificationOutbox) error {
+	args := r.Called(n)
+	return args.Error(0)
+}
+
+func (r *MockReservationRepo) CountNotificationsByStatus(status internal.OutboxStatus) (int64, error) {
+	args := r.Called(status)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (r *MockReservationRepo) ClaimIdempotencyKey(k *internal.IdempotencyKey, staleBefore time.Time) (*internal.IdempotencyKey, error) {
+	args := r.Called(k, staleBefore)
+	if existing, ok := args.Get(0).(*internal.IdempotencyKey); ok {
+		return existing, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MockReservationRepo) CompleteIdempotencyKey(userID uint, key string, statusCode int, body []byte) error {
+	args := r.Called(userID, key, statusCode, body)
+	return args.Error(0)
+}
+
+func (r *MockReservationRepo) ReleaseIdempotencyKey(userID uint, key string) error {
+	args := r.Called(userID, key)
+	return args.Error(0)
+}
+
+func (r *MockReservationRepo) DeleteExpiredIdempotencyKeys(now time.Time) (int64, error) {
+	args := r.Called(now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 
 // ----------------------------------------------- Mock user client
 
@@ -178,6 +427,88 @@ func (r *MockReservationRepo) FindReservationById(id uint) (*internal.Reservatio
 	return nil, args.Error(1)
 }
 
+func (r *MockReservationRepo) FindReservationByIDForUpdate(id uint) (*internal.Reservation, error) {
+	args := r.Called(id)
+	if res, ok := args.Get(0).(*internal.Reservation); ok {
+		return res, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MockReservationRepo) HasOtherReservationsInRange(roomID uint, from, to time.Time, exceptID uint) (bool, error) {
+	args := r.Called(roomID, from, to, exceptID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (r *MockReservationRepo) CreateModification(m *internal.ReservationModification) error {
+	args := r.Called(m)
+	return args.Error(0)
+}
+
+func (r *MockReservationRepo) FindModificationByID(id uint) (*internal.ReservationModification, error) {
+	args := r.Called(id)
+	if m, ok := args.Get(0).(*internal.ReservationModification); ok {
+		return m, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MockReservationRepo) FindModificationByIDForUpdate(id uint) (*internal.ReservationModification, error) {
+	args := r.Called(id)
+	if m, ok := args.Get(0).(*internal.ReservationModification); ok {
+		return m, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MockReservationRepo) FindModificationsByReservationID(reservationID uint) ([]internal.ReservationModification, error) {
+	args := r.Called(reservationID)
+	return args.Get(0).([]internal.ReservationModification), args.Error(1)
+}
+
+func (r *MockReservationRepo) HasPendingModification(reservationID uint) (bool, error) {
+	args := r.Called(reservationID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (r *MockReservationRepo) SetModificationStatus(id uint, status internal.ModificationStatus, decidedAt time.Time) error {
+	args := r.Called(id, status, decidedAt)
+	return args.Error(0)
+}
+
+func (r *MockReservationRepo) CancelPendingModifications(reservationID uint, at time.Time) ([]internal.ReservationModification, error) {
+	args := r.Called(reservationID, at)
+	return args.Get(0).([]internal.ReservationModification), args.Error(1)
+}
+
+func (r *MockReservationRepo) ApplyModification(m *internal.ReservationModification) error {
+	args := r.Called(m)
+	return args.Error(0)
+}
+
+func (r *MockReservationRepo) RecordTransition(t *internal.StatusTransition) error {
+	r.Transitions = append(r.Transitions, *t)
+	return nil
+}
+
+func (r *MockReservationRepo) FindTransitions(subject internal.TransitionSubject, id uint) ([]internal.StatusTransition, error) {
+	args := r.Called(subject, id)
+	return args.Get(0).([]internal.StatusTransition), args.Error(1)
+}
+
+func (r *MockReservationRepo) FindCancellationPolicy(roomID uint) (*internal.CancellationPolicy, error) {
+	args := r.Called(roomID)
+	if p, ok := args.Get(0).(*internal.CancellationPolicy); ok {
+		return p, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MockReservationRepo) SaveCancellationPolicy(p *internal.CancellationPolicy) error {
+	args := r.Called(p)
+	return args.Error(0)
+}
+
 // ----------------------------------------------- Mock notification client
 
 type MockNotificationClient struct {
@@ -0,0 +1,318 @@
+package test
+
+import (
+	"bookem-reservation-service/client/notificationclient"
+	"bookem-reservation-service/internal"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_CancelReservation_OffersDatesToFirstWaitingGuest(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	from := time.Now().AddDate(0, 0, 10)
+	res := &internal.Reservation{ID: 1, GuestID: 1, RoomID: 1, DateFrom: from, DateTo: from.AddDate(0, 0, 3), Status: internal.ReservationConfirmed}
+	first := internal.WaitlistEntry{ID: 7, RoomID: 1, GuestID: 5, DateFrom: from, DateTo: from.AddDate(0, 0, 2), Status: internal.WaitlistWaiting}
+	second := internal.WaitlistEntry{ID: 8, RoomID: 1, GuestID: 6, DateFrom: from.AddDate(0, 0, 1), DateTo: from.AddDate(0, 0, 3), Status: internal.WaitlistWaiting}
+
+	userClient.On("FindById", mock.Anything, uint(1)).Return(DefaultUser_Guest, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("FindReservationById", uint(1)).Return(res, nil)
+	repo.On("FindReservationByIDForUpdate", uint(1)).Return(res, nil)
+	repo.On("CancelReservation", uint(1), mock.Anything).Return(nil)
+	repo.On("CancelPendingModifications", uint(1), mock.Anything).Return([]internal.ReservationModification{}, nil)
+	repo.On("FindWaitingEntries", uint(1), res.DateFrom, res.DateTo).Return([]internal.WaitlistEntry{first, second}, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(false, nil)
+	// The first guest's offer covers part of the second guest's stay.
+	repo.On("HasWaitlistOffer", uint(1), first.DateFrom, first.DateTo, uint(0), mock.Anything).Return(false, nil)
+	repo.On("HasWaitlistOffer", uint(1), second.DateFrom, second.DateTo, uint(0), mock.Anything).Return(true, nil)
+	var offered []internal.WaitlistEntry
+	repo.On("UpdateWaitlistEntry", mock.Anything).Run(func(args mock.Arguments) {
+		offered = append(offered, *args.Get(0).(*internal.WaitlistEntry))
+	}).Return(nil)
+	ExpectNotification(repo, notificationclient.ReservationCancelled, DefaultRoom.HostID)
+	ExpectNotification(repo, notificationclient.WaitlistOffered, 5)
+
+	_, err := svc.CancelReservation(context.Background(), 1, 1, "Token")
+
+	assert.NoError(t, err)
+	if assert.Len(t, offered, 1) {
+		assert.Equal(t, uint(7), offered[0].ID)
+		assert.Equal(t, internal.WaitlistOffered, offered[0].Status)
+		assert.WithinDuration(t, time.Now().Add(internal.WaitlistOfferTime), *offered[0].OfferExpiresAt, time.Minute)
+	}
+	repo.AssertNumberOfCalls(t, "EnqueueNotification", 2)
+}
+
+func Test_CreateRequest_DatesOfferedToWaitlistedGuest(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	from := time.Now().AddDate(0, 0, 10)
+	guest := *DefaultUser_Guest
+	guest.Deleted = false
+	userClient.On("FindById", mock.Anything, uint(1)).Return(&guest, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", mock.Anything, uint(1)).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", mock.Anything, uint(1)).Return(DefaultPriceList, nil)
+	roomClient.On("QueryForReservation", mock.Anything, mock.Anything, mock.Anything).Return(DefaultReservationQueryResponse, nil)
+	repo.On("FindPendingRequestsByGuestID", uint(1)).Return([]internal.ReservationRequest{}, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasHold", uint(1), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(false, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("HasWaitlistOffer", uint(1), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(true, nil)
+
+	_, _, err := svc.CreateRequest(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, internal.CreateReservationRequestDTO{
+		RoomID: 1, DateFrom: from, DateTo: from.AddDate(0, 0, 2), GuestCount: 1,
+	})
+
+	assert.Equal(t, internal.ErrConflict, err)
+	repo.AssertCalled(t, "LockRoom", uint(1))
+	repo.AssertNotCalled(t, "CreateRequest", mock.Anything)
+}
+
+func Test_UpdateRequest_DatesOfferedToWaitlistedGuest(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	req := pendingRequest()
+	newTo := req.DateTo.AddDate(0, 0, 1)
+	expectUpdate(repo, userClient, roomClient, req)
+	repo.On("FindRequestByIDForUpdate", uint(4)).Return(&req, nil)
+	repo.On("HasReservationsInRange", uint(1), req.DateFrom, newTo).Return(false, nil)
+	repo.On("HasHold", uint(1), req.DateFrom, newTo, uint(1), mock.Anything).Return(false, nil)
+	repo.On("HasWaitlistOffer", uint(1), req.DateFrom, newTo, uint(1), mock.Anything).Return(true, nil)
+
+	_, err := svc.UpdateRequest(context.Background(), internal.AuthContext{CallerID: 1}, 4, internal.UpdateReservationRequestDTO{DateTo: &newTo})
+
+	assert.Equal(t, internal.ErrConflict, err)
+	repo.AssertNotCalled(t, "UpdateRequest", mock.Anything)
+}
+
+func Test_ApproveModification_DatesOfferedToWaitlistedGuest(t *testing.T) {
+	svc, repo, _, roomClient := CreateTestRoomService()
+
+	m := &internal.ReservationModification{ID: 9, ReservationID: 5, RoomID: 1, GuestID: 1, Status: internal.ModificationPending}
+	repo.On("FindModificationByID", uint(9)).Return(m, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("FindModificationByIDForUpdate", uint(9)).Return(m, nil)
+	repo.On("FindReservationByIDForUpdate", uint(5)).Return(upcomingReservation(), nil)
+	repo.On("HasOtherReservationsInRange", uint(1), mock.Anything, mock.Anything, uint(5)).Return(false, nil)
+	repo.On("HasHold", uint(1), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(false, nil)
+	repo.On("HasWaitlistOffer", uint(1), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(true, nil)
+
+	_, err := svc.ApproveModification(context.Background(), 2, 9)
+
+	assert.Equal(t, internal.ErrConflict, err)
+	repo.AssertNotCalled(t, "ApplyModification", mock.Anything)
+}
+
+func Test_JoinWaitlist_DatesAreFree(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	from := time.Now().AddDate(0, 0, 10)
+	guest := *DefaultUser_Guest
+	guest.Deleted = false
+	userClient.On("FindById", mock.Anything, uint(1)).Return(&guest, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasWaitlistOffer", uint(1), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(false, nil)
+
+	_, err := svc.JoinWaitlist(context.Background(), 1, internal.CreateWaitlistEntryDTO{
+		RoomID: 1, DateFrom: from, DateTo: from.AddDate(0, 0, 2), GuestCount: 1,
+	})
+
+	var apiErr *internal.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, 400, apiErr.Code)
+	}
+	repo.AssertNotCalled(t, "CreateWaitlistEntry", mock.Anything)
+}
+
+func Test_JoinWaitlist_Success(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	from := time.Now().AddDate(0, 0, 10)
+	guest := *DefaultUser_Guest
+	guest.Deleted = false
+	userClient.On("FindById", mock.Anything, uint(1)).Return(&guest, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(true, nil)
+	repo.On("FindWaitlistEntriesByGuestID", uint(1)).Return([]internal.WaitlistEntry{
+		{ID: 3, RoomID: 1, DateFrom: from, DateTo: from, Status: internal.WaitlistLeft},
+	}, nil)
+	repo.On("CreateWaitlistEntry", mock.Anything).Return(nil)
+
+	entry, err := svc.JoinWaitlist(context.Background(), 1, internal.CreateWaitlistEntryDTO{
+		RoomID: 1, DateFrom: from, DateTo: from.AddDate(0, 0, 2), GuestCount: 2,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, internal.WaitlistWaiting, entry.Status)
+	assert.Equal(t, uint(1), entry.GuestID)
+}
+
+func Test_ClaimWaitlistOffer_NothingToClaim(t *testing.T) {
+	lapsedAt := time.Now().Add(-time.Minute)
+	tests := map[string]struct {
+		entry internal.WaitlistEntry
+		err   error
+	}{
+		"still waiting":   {entry: internal.WaitlistEntry{Status: internal.WaitlistWaiting}, err: internal.ErrNoWaitlistOffer},
+		"offer over":      {entry: internal.WaitlistEntry{Status: internal.WaitlistOffered, OfferExpiresAt: &lapsedAt}, err: internal.ErrWaitlistOfferLapsed},
+		"offer lapsed":    {entry: internal.WaitlistEntry{Status: internal.WaitlistLapsed}, err: internal.ErrWaitlistOfferLapsed},
+		"already claimed": {entry: internal.WaitlistEntry{Status: internal.WaitlistClaimed}, err: internal.ErrNoWaitlistOffer},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			svc, repo, userClient, _ := CreateTestRoomService()
+
+			entry := tt.entry
+			entry.ID, entry.GuestID, entry.RoomID = 7, 1, 1
+			repo.On("FindWaitlistEntryByID", uint(7)).Return(&entry, nil)
+
+			_, _, err := svc.ClaimWaitlistOffer(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, 7)
+
+			assert.Equal(t, tt.err, err)
+			userClient.AssertNotCalled(t, "FindById", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func Test_ClaimWaitlistOffer_SomeoneElsesEntry(t *testing.T) {
+	svc, repo, _, _ := CreateTestRoomService()
+
+	repo.On("FindWaitlistEntryByID", uint(7)).Return(&internal.WaitlistEntry{ID: 7, GuestID: 5, Status: internal.WaitlistOffered}, nil)
+
+	_, _, err := svc.ClaimWaitlistOffer(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, 7)
+
+	var apiErr *internal.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, 404, apiErr.Code)
+	}
+}
+
+// expectClaim sets up guest 1 claiming the offer of entry 7 for room 1, as
+// the request is made.
+func expectClaim(repo *MockReservationRepo, userClient *MockUserClient, roomClient *MockRoomClient) internal.WaitlistEntry {
+	from := time.Now().AddDate(0, 0, 10)
+	expiresAt := time.Now().Add(time.Hour)
+	entry := internal.WaitlistEntry{ID: 7, RoomID: 1, GuestID: 1, DateFrom: from, DateTo: from.AddDate(0, 0, 2), GuestCount: 1, Status: internal.WaitlistOffered, OfferExpiresAt: &expiresAt}
+
+	guest := *DefaultUser_Guest
+	guest.Deleted = false
+	userClient.On("FindById", mock.Anything, uint(1)).Return(&guest, nil)
+	roomClient.On("FindById", mock.Anything, uint(1)).Return(DefaultRoom, nil)
+	roomClient.On("FindCurrentAvailabilityListOfRoom", mock.Anything, uint(1)).Return(DefaultAvailabilityList, nil)
+	roomClient.On("FindCurrentPricelistOfRoom", mock.Anything, uint(1)).Return(DefaultPriceList, nil)
+	roomClient.On("QueryForReservation", mock.Anything, mock.Anything, mock.Anything).Return(DefaultReservationQueryResponse, nil)
+	repo.On("FindWaitlistEntryByID", uint(7)).Return(&entry, nil)
+	repo.On("FindPendingRequestsByGuestID", uint(1)).Return([]internal.ReservationRequest{}, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("HasHold", uint(1), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(false, nil)
+	repo.On("HasWaitlistOffer", uint(1), mock.Anything, mock.Anything, uint(1), mock.Anything).Return(false, nil)
+	repo.On("LockRoom", uint(1)).Return(nil)
+	repo.On("CreateRequest", mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(*internal.ReservationRequest).ID = 12
+	}).Return(nil)
+	repo.On("ConvertHolds", uint(1), mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(int64(0), nil)
+	return entry
+}
+
+func Test_ClaimWaitlistOffer_Success(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	entry := expectClaim(repo, userClient, roomClient)
+	repo.On("FindWaitlistEntryByIDForUpdate", uint(7)).Return(&entry, nil)
+	repo.On("UpdateWaitlistEntry", mock.MatchedBy(func(e *internal.WaitlistEntry) bool {
+		return e.ID == 7 && e.Status == internal.WaitlistClaimed && e.RequestID == 12
+	})).Return(nil)
+	ExpectNotification(repo, notificationclient.ReservationRequested, DefaultRoom.HostID)
+
+	req, _, err := svc.ClaimWaitlistOffer(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, 7)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(12), req.ID)
+	repo.AssertNumberOfCalls(t, "UpdateWaitlistEntry", 1)
+}
+
+func Test_ClaimWaitlistOffer_ClaimedInTheMeantime(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	entry := expectClaim(repo, userClient, roomClient)
+	claimed := entry
+	claimed.Status = internal.WaitlistClaimed
+	repo.On("FindWaitlistEntryByIDForUpdate", uint(7)).Return(&claimed, nil)
+
+	_, _, err := svc.ClaimWaitlistOffer(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, 7)
+
+	assert.Equal(t, internal.ErrNoWaitlistOffer, err)
+	repo.AssertNotCalled(t, "UpdateWaitlistEntry", mock.Anything)
+	repo.AssertNotCalled(t, "EnqueueNotification", mock.Anything)
+}
+
+func Test_ClaimWaitlistOffer_EntryNotUpdated(t *testing.T) {
+	svc, repo, userClient, roomClient := CreateTestRoomService()
+
+	entry := expectClaim(repo, userClient, roomClient)
+	repo.On("FindWaitlistEntryByIDForUpdate", uint(7)).Return(&entry, nil)
+	repo.On("UpdateWaitlistEntry", mock.Anything).Return(errors.New("db error"))
+
+	_, _, err := svc.ClaimWaitlistOffer(context.Background(), internal.AuthContext{CallerID: 1, JWT: "Token"}, 7)
+
+	assert.EqualError(t, err, "db error")
+	repo.AssertNotCalled(t, "EnqueueNotification", mock.Anything)
+}
+
+func Test_ExpireWaitlist_PassesLapsedOfferOn(t *testing.T) {
+	repo := new(MockReservationRepo)
+	expirer := internal.NewWaitlistExpirer(repo, testExpiryConfig)
+
+	from := time.Now().AddDate(0, 0, 10)
+	lapsedAt := time.Now().Add(-time.Minute)
+	lapsed := internal.WaitlistEntry{ID: 7, RoomID: 1, GuestID: 5, DateFrom: from, DateTo: from.AddDate(0, 0, 2), Status: internal.WaitlistOffered, OfferExpiresAt: &lapsedAt}
+	next := internal.WaitlistEntry{ID: 8, RoomID: 1, GuestID: 6, DateFrom: from, DateTo: from.AddDate(0, 0, 1), Status: internal.WaitlistWaiting}
+
+	repo.On("FindLapsedWaitlistEntries", mock.Anything, 100).Return([]internal.WaitlistEntry{lapsed}, nil)
+	repo.On("FindWaitlistEntryByIDForUpdate", uint(7)).Return(&lapsed, nil)
+	repo.On("FindWaitingEntries", uint(1), lapsed.DateFrom, lapsed.DateTo).Return([]internal.WaitlistEntry{next}, nil)
+	repo.On("HasWaitlistOffer", uint(1), mock.Anything, mock.Anything, uint(0), mock.Anything).Return(false, nil)
+	repo.On("HasReservationsInRange", uint(1), mock.Anything, mock.Anything).Return(false, nil)
+	var updated []internal.WaitlistEntry
+	repo.On("UpdateWaitlistEntry", mock.Anything).Run(func(args mock.Arguments) {
+		updated = append(updated, *args.Get(0).(*internal.WaitlistEntry))
+	}).Return(nil)
+	ExpectNotification(repo, notificationclient.WaitlistOffered, 6)
+
+	n, err := expirer.ExpireOnce(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	if assert.Len(t, updated, 2) {
+		assert.Equal(t, internal.WaitlistLapsed, updated[0].Status)
+		assert.Equal(t, uint(8), updated[1].ID)
+		assert.Equal(t, internal.WaitlistOffered, updated[1].Status)
+	}
+}
+
+func Test_ExpireWaitlist_StayStarted(t *testing.T) {
+	repo := new(MockReservationRepo)
+	expirer := internal.NewWaitlistExpirer(repo, testExpiryConfig)
+
+	started := internal.WaitlistEntry{ID: 7, RoomID: 1, GuestID: 5, DateFrom: time.Now().Add(-time.Hour), Status: internal.WaitlistWaiting}
+	repo.On("FindLapsedWaitlistEntries", mock.Anything, 100).Return([]internal.WaitlistEntry{started}, nil)
+	repo.On("FindWaitlistEntryByIDForUpdate", uint(7)).Return(&started, nil)
+	repo.On("UpdateWaitlistEntry", mock.MatchedBy(func(e *internal.WaitlistEntry) bool {
+		return e.Status == internal.WaitlistExpired
+	})).Return(nil)
+
+	n, err := expirer.ExpireOnce(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	repo.AssertNotCalled(t, "FindWaitingEntries", mock.Anything, mock.Anything, mock.Anything)
+}
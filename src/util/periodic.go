@@ -0,0 +1,24 @@
+package util
+
+import (
+	"context"
+	"time"
+)
+
+// RunPeriodically calls fn right away and then every interval, until ctx is
+// done. A call that takes longer than interval delays the next one instead of
+// overlapping with it.
+func RunPeriodically(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		fn(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
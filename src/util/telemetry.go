@@ -22,26 +22,23 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
-type SpanPair struct {
-	Ctx  context.Context
-	Span trace.Span
-}
-
+// Telemetry logs and traces. It keeps no per-request state: the current span
+// and the request's logger travel in the context.Context handed to every call,
+// so concurrent requests can't see each other's spans.
 type Telemetry struct {
 	// During tests, the tracer is not set up, so we silently ignore tracing.
-	// This has to be done manually (i.e. don't call tracer methods, don't touch
-	// spans etc.)
+	// Start then hands back the context it was given, unchanged.
 	tracerReady bool
 	Tracer      trace.Tracer
 
-	SpanStack []SpanPair
-
 	loggerReady bool
 	logger      *slog.Logger
 }
 
 var TEL Telemetry
 
+type loggerKey struct{}
+
 func (t *Telemetry) Init(ctx context.Context, serviceName, deploymentEnvironment string) func(context.Context) error {
 	// [0] Init logger
 	{
@@ -70,8 +67,7 @@ func (t *Telemetry) Init(ctx context.Context, serviceName, deploymentEnvironment
 		otel.SetTracerProvider(tp)
 		otel.SetTextMapPropagator(propagation.TraceContext{})
 
-		t.Tracer = otel.Tracer(serviceName)
-		t.tracerReady = true
+		t.UseTracer(otel.Tracer(serviceName))
 		return tp.Shutdown
 	}
 }
@@ -95,13 +91,34 @@ func (t *Telemetry) initLogger() error {
 	)
 
 	t.loggerReady = true
-	t.Debug("Logger initialized")
+	t.Debug(context.Background(), "Logger initialized")
 	return nil
 }
 
+// UseTracer makes Start create spans with tracer; nil turns tracing off. Init
+// calls it, tests can call it with a tracer that records spans.
+func (t *Telemetry) UseTracer(tracer trace.Tracer) {
+	t.Tracer = tracer
+	t.tracerReady = tracer != nil
+}
+
+// UseLogger replaces the logger set up by Init; nil turns logging off.
+func (t *Telemetry) UseLogger(logger *slog.Logger) {
+	t.logger = logger
+	t.loggerReady = logger != nil
+}
+
+// GetLoggingMiddleware gives every request its own logger, carried in the
+// request's context, and logs the request once it's done.
 func (t *Telemetry) GetLoggingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := t.WithLogAttrs(c.Request.Context(), "method", c.Request.Method, "path", c.Request.URL.Path)
+		c.Request = c.Request.WithContext(ctx)
+
 		c.Next()
+		if !t.loggerReady {
+			return
+		}
 		t.logger.Info("request",
 			slog.String("method", c.Request.Method),
 			slog.String("path", c.Request.URL.Path),
@@ -113,101 +130,94 @@ func (t *Telemetry) GetLoggingMiddleware() gin.HandlerFunc {
 	}
 }
 
-func (t *Telemetry) Push(ctx context.Context, name string, attrs ...attribute.KeyValue) {
-	if t.tracerReady {
-		newCtx, span := t.Tracer.Start(ctx, name, trace.WithAttributes(attrs...))
-		t.SpanStack = append(t.SpanStack, SpanPair{Ctx: newCtx, Span: span})
-	} else {
-		newCtx := ctx
-		var span trace.Span
-		t.SpanStack = append(t.SpanStack, SpanPair{Ctx: newCtx, Span: span})
+// Start begins a span as a child of the span in ctx, and returns a context
+// carrying the new span. Pass that context on and end the span when done:
+//
+//	ctx, span := util.TEL.Start(ctx, "do-something")
+//	defer span.End()
+func (t *Telemetry) Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !t.tracerReady {
+		return ctx, trace.SpanFromContext(ctx)
 	}
+	return t.Tracer.Start(ctx, name, trace.WithAttributes(attrs...))
 }
 
-func (t *Telemetry) Pop() {
-	top := t.SpanStack[len(t.SpanStack)-1]
-	if t.tracerReady {
-		top.Span.End()
+// WithLogAttrs returns a context whose log lines all carry attrs.
+func (t *Telemetry) WithLogAttrs(ctx context.Context, attrs ...any) context.Context {
+	if !t.loggerReady {
+		return ctx
 	}
-	t.SpanStack = t.SpanStack[:len(t.SpanStack)-1]
-}
-
-func (t *Telemetry) Top() SpanPair {
-	return t.SpanStack[len(t.SpanStack)-1]
+	return context.WithValue(ctx, loggerKey{}, t.loggerFrom(ctx).With(attrs...))
 }
 
-func (t *Telemetry) Ctx() context.Context {
-	if len(t.SpanStack) > 0 {
-		return t.Top().Ctx
-	} else {
-		return context.Background() // Ehh...
-	}
+func (t *Telemetry) SetAttrib(ctx context.Context, kv ...attribute.KeyValue) {
+	trace.SpanFromContext(ctx).SetAttributes(kv...)
 }
 
-func (t *Telemetry) SetAttrib(kv ...attribute.KeyValue) {
-	if t.tracerReady {
-		t.Top().Span.SetAttributes(kv...)
-	}
+func (t *Telemetry) SetUser(ctx context.Context, id uint) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("user.id", fmt.Sprintf("%d", id)))
 }
 
-func (t *Telemetry) SetUser(id uint) {
-	if t.tracerReady {
-		t.Top().Span.SetAttributes(attribute.String("user.id", fmt.Sprintf("%d", id)))
+// TraceID returns the ID of the trace ctx belongs to, or "" outside of one.
+func (t *Telemetry) TraceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		return sc.TraceID().String()
 	}
+	return ""
 }
 
-func (t *Telemetry) Inject(outgoingRequest *http.Request) {
-	otel.GetTextMapPropagator().Inject(t.Ctx(), propagation.HeaderCarrier(outgoingRequest.Header))
+// Inject adds the trace context of ctx to an outgoing request's headers.
+func (t *Telemetry) Inject(ctx context.Context, outgoingRequest *http.Request) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(outgoingRequest.Header))
 }
 
-func (t *Telemetry) Info(msg string, attrs ...any) {
+func (t *Telemetry) Info(ctx context.Context, msg string, attrs ...any) {
 	if t.loggerReady {
-		t.logger.Info(msg, attrs...)
-	}
-	if span := t.currentSpan(); span != nil {
-		span.AddEvent(msg)
+		t.loggerFrom(ctx).InfoContext(ctx, msg, attrs...)
 	}
+	trace.SpanFromContext(ctx).AddEvent(msg)
 }
 
-func (t *Telemetry) Warn(msg string, attrs ...any) {
+func (t *Telemetry) Warn(ctx context.Context, msg string, attrs ...any) {
 	if t.loggerReady {
-		t.logger.Warn(msg, attrs...)
-	}
-	if span := t.currentSpan(); span != nil {
-		span.AddEvent(msg)
+		t.loggerFrom(ctx).WarnContext(ctx, msg, attrs...)
 	}
+	trace.SpanFromContext(ctx).AddEvent(msg)
 }
 
-func (t *Telemetry) Debug(msg string, attrs ...any) {
+func (t *Telemetry) Debug(ctx context.Context, msg string, attrs ...any) {
 	if t.loggerReady {
-		t.logger.Debug(msg, attrs...)
-	}
-	if span := t.currentSpan(); span != nil {
-		span.AddEvent(msg)
+		t.loggerFrom(ctx).DebugContext(ctx, msg, attrs...)
 	}
+	trace.SpanFromContext(ctx).AddEvent(msg)
 }
 
-func (t *Telemetry) Error(msg string, err error, attrs ...any) {
+func (t *Telemetry) Error(ctx context.Context, msg string, err error, attrs ...any) {
 	if t.loggerReady {
 		if err != nil {
 			attrs = append(attrs, slog.Any("error", err))
 		}
-		t.logger.Error(msg, attrs...)
+		t.loggerFrom(ctx).ErrorContext(ctx, msg, attrs...)
 	}
 
-	if span := t.currentSpan(); span != nil {
-		span.AddEvent(msg, trace.WithAttributes(attribute.Bool("error", true)))
-		span.SetStatus(codes.Error, "error")
-		if err != nil {
-			span.AddEvent(msg, trace.WithAttributes(attribute.String("error.message", err.Error())))
-			span.SetStatus(codes.Error, err.Error())
-		}
+	span := trace.SpanFromContext(ctx)
+	span.AddEvent(msg, trace.WithAttributes(attribute.Bool("error", true)))
+	span.SetStatus(codes.Error, "error")
+	if err != nil {
+		span.AddEvent(msg, trace.WithAttributes(attribute.String("error.message", err.Error())))
+		span.SetStatus(codes.Error, err.Error())
 	}
 }
 
-func (t *Telemetry) currentSpan() trace.Span {
-	if t.tracerReady && len(t.SpanStack) > 0 {
-		return t.SpanStack[len(t.SpanStack)-1].Span
+// loggerFrom returns the request's logger, with the trace and span IDs of ctx
+// so log lines can be matched with traces.
+func (t *Telemetry) loggerFrom(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(loggerKey{}).(*slog.Logger)
+	if !ok {
+		logger = t.logger
 	}
-	return nil
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		logger = logger.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+	}
+	return logger
 }